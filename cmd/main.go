@@ -1,24 +1,69 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
 
 	lucene_to_dsl "github.com/zhuliquan/lucene-to-dsl"
+	"github.com/zhuliquan/lucene-to-dsl/convert"
 )
 
 func main() {
 	var mappingPath string
 	var luceneQuery string
+	var componentPath string
+	var documentsPath string
+	var inferMapping bool
+	var rulesPath string
 
 	flag.StringVar(&mappingPath, "m", "", "mapping file path")
 	flag.StringVar(&mappingPath, "mapping", "", "mapping file path")
+	flag.StringVar(&componentPath, "c", "", "component templates file path, mapping file is index template composed of them")
+	flag.StringVar(&componentPath, "component-templates", "", "component templates file path, mapping file is index template composed of them")
+	flag.StringVar(&documentsPath, "d", "", "sample documents file path (json array or ndjson), mapping is inferred from them if mapping file isn't provided")
+	flag.StringVar(&documentsPath, "documents", "", "sample documents file path (json array or ndjson), mapping is inferred from them if mapping file isn't provided")
+	flag.StringVar(&rulesPath, "r", "", "inference rules file path, json array of rules inferring field type by field name, i.e. [{\"pattern\": \"*_at\", \"type\": \"date\"}]")
+	flag.StringVar(&rulesPath, "inference-rules", "", "inference rules file path, json array of rules inferring field type by field name, i.e. [{\"pattern\": \"*_at\", \"type\": \"date\"}]")
+	flag.BoolVar(&inferMapping, "infer-mapping", false, "print mapping inferred from sample documents and exit")
 	flag.StringVar(&luceneQuery, "q", "", "lucene query")
 	flag.StringVar(&luceneQuery, "query", "", "lucene query")
 	flag.Parse()
 
+	var docs [][]byte
+	if documentsPath != "" {
+		documentsData, err := os.ReadFile(documentsPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading documents file: %v\n", err)
+			os.Exit(1)
+		}
+		if docs, err = convert.ParseDocuments(documentsData); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if inferMapping {
+		if documentsPath == "" {
+			fmt.Fprintln(os.Stderr, "Error: sample documents file is required to infer mapping")
+			os.Exit(1)
+		}
+		mappingData, err := convert.InferMappingDataFromDocuments(docs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		var out bytes.Buffer
+		if err := json.Indent(&out, mappingData, "", "  "); err != nil {
+			fmt.Fprintf(os.Stderr, "Error marshaling to JSON: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(out.String())
+		return
+	}
+
 	if luceneQuery == "" {
 		fmt.Fprintln(os.Stderr, "Error: lucene query is required")
 		os.Exit(1)
@@ -32,7 +77,35 @@ func main() {
 			fmt.Fprintf(os.Stderr, "Error reading mapping file: %v\n", err)
 			os.Exit(1)
 		}
-		opts = append(opts, lucene_to_dsl.WithMappingData(mappingData))
+		if componentPath != "" {
+			componentData, err := os.ReadFile(componentPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading component templates file: %v\n", err)
+				os.Exit(1)
+			}
+			opts = append(opts, lucene_to_dsl.WithIndexTemplate(mappingData, componentData))
+		} else {
+			opts = append(opts, lucene_to_dsl.WithMappingData(mappingData))
+		}
+	} else if componentPath != "" {
+		fmt.Fprintln(os.Stderr, "Error: mapping file of index template is required with component templates")
+		os.Exit(1)
+	} else if documentsPath != "" {
+		opts = append(opts, lucene_to_dsl.WithSampleDocuments(docs))
+	}
+
+	if rulesPath != "" {
+		rulesData, err := os.ReadFile(rulesPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading inference rules file: %v\n", err)
+			os.Exit(1)
+		}
+		rules, err := convert.LoadInferenceRules(rulesData)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, lucene_to_dsl.WithInferenceRules(rules))
 	}
 
 	dsl, err := lucene_to_dsl.LuceneToDSL(luceneQuery, opts...)
@@ -3,4 +3,19 @@ package convert
 const (
 	EXIST_FIELD = "_exists_"
 	ID_FIELD    = "_id"
+	INDEX_FIELD = "_index"
+
+	// FLATTENED_FIELD_TYPE is type of field mapping whole object as keywords, which is queried by keyed sub path (i.e. `labels.app`)
+	FLATTENED_FIELD_TYPE = "flattened"
+	// NESTED_FIELD_TYPE is type of object field whose objects are indexed as separate documents, which is queried by nested query
+	NESTED_FIELD_TYPE = "nested"
+	// GEO_POINT_FIELD_TYPE and GEO_SHAPE_FIELD_TYPE are types of geo fields, which are queried by bounding box and distance
+	GEO_POINT_FIELD_TYPE = "geo_point"
+	GEO_SHAPE_FIELD_TYPE = "geo_shape"
+
+	// DEFAULT_DATE_FORMAT is the first default format of date fields in es
+	DEFAULT_DATE_FORMAT = "strict_date_optional_time"
+	// DEFAULT_MAX_FIELD_EXPANSION is default maximum number of fields which object field or wildcard field expands to,
+	// which is same as default `indices.query.bool.max_clause_count` of es
+	DEFAULT_MAX_FIELD_EXPANSION = 1024
 )
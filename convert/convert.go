@@ -3,8 +3,10 @@ package convert
 import (
 	"fmt"
 	"net"
-	"regexp"
+	"path"
+	"sort"
 	"strings"
+	"time"
 
 	mapping "github.com/zhuliquan/es-mapping"
 	"github.com/zhuliquan/go_tools/ip_tools"
@@ -25,19 +27,157 @@ type Converter interface {
 	LuceneToAstNode(q *lucene.Lucene) (dsl.AstNode, error)
 }
 
-func NewConverter(mp *mapping.PropertyMapping, mf map[string]ConvertFunc) Converter {
+// ConverterOption customizes the behaviour of converter
+type ConverterOption func(*converter)
+
+// WithRegexpFlags specifies enabled optional operators of regexp query, e.g. "COMPLEMENT|INTERVAL",
+// the flags are used for both validating lucene regexp and `flags` parameter of regexp dsl.
+func WithRegexpFlags(flags dsl.RegexpFlagType) ConverterOption {
+	return func(c *converter) {
+		c.regexpFlags = flags
+	}
+}
+
+// WithRangeRelation specifies default relation of range query on range type fields, e.g. integer_range, date_range,
+// it's INTERSECTS if not specified.
+func WithRangeRelation(relation dsl.RelationType) ConverterOption {
+	return func(c *converter) {
+		c.rangeRelation = dsl.RelationType(strings.ToUpper(string(relation)))
+	}
+}
+
+// WithFieldRangeRelations specifies relation of range query for specific range type fields, which overrides default relation
+func WithFieldRangeRelations(relations map[string]dsl.RelationType) ConverterOption {
+	return func(c *converter) {
+		c.fieldRangeRelations = make(map[string]dsl.RelationType, len(relations))
+		for field, relation := range relations {
+			c.fieldRangeRelations[field] = dsl.RelationType(strings.ToUpper(string(relation)))
+		}
+	}
+}
+
+// WithPreserveDateMath specifies whether keeping original date math expr (e.g. "now-1h/h") in range query on date fields,
+// date values are resolved to epoch_millis if not specified, which is frozen timestamp and breaks request cache of es.
+// resolved values are still used for merging range queries and detecting conflict.
+func WithPreserveDateMath(preserve bool) ConverterOption {
+	return func(c *converter) {
+		c.preserveDateMath = preserve
+	}
+}
+
+// WithTimeZone specifies time zone used for parsing date value without time zone and rounding date math, e.g. "Asia/Shanghai", "+08:00",
+// it's also provided as `time_zone` of range query if date math expr is preserved.
+func WithTimeZone(timeZone string) ConverterOption {
+	return func(c *converter) {
+		c.timeZone = timeZone
+	}
+}
+
+// WithFieldTimeZones specifies time zone for specific date fields, which overrides default time zone
+func WithFieldTimeZones(timeZones map[string]string) ConverterOption {
+	return func(c *converter) {
+		c.fieldTimeZones = timeZones
+	}
+}
+
+// WithDateString specifies whether printing date values of range query as strings in the first format of mapping printing them
+// exactly (strict_date_optional_time(_nanos) if none of formats does), date values are printed as epoch_millis if not specified,
+// and date_nanos values are always printed with nanosecond precision.
+func WithDateString(dateString bool) ConverterOption {
+	return func(c *converter) {
+		c.dateString = dateString
+	}
+}
+
+// WithIndexMappings provides mappings of multiple indices, which are used instead of single mapping,
+// field is converted into a clause per property of field in indices
+func WithIndexMappings(im *IndexMappings) ConverterOption {
+	return func(c *converter) {
+		c.im = im
+	}
+}
+
+// WithIndexScope provides scoping clause of field which isn't mapped by all indices with `_index` term in filter context,
+// so that es doesn't error on indices whose field type mismatches the clause
+func WithIndexScope(indexScope bool) ConverterOption {
+	return func(c *converter) {
+		c.indexScope = indexScope
+	}
+}
+
+// WithDynamicMapping provides dynamic settings of mapping, field which isn't mapped explicitly is resolved by
+// `dynamic_templates` with match mapping type inferred from query value (or es default dynamic mapping if no template matches),
+// and is rejected if dynamic mapping is disabled
+func WithDynamicMapping(dm *DynamicMapping) ConverterOption {
+	return func(c *converter) {
+		c.dm = dm
+	}
+}
+
+// WithRuntimeFields provides runtime fields of mapping and search request, which shadow properties with same names
+func WithRuntimeFields(rf *RuntimeFields) ConverterOption {
+	return func(c *converter) {
+		c.rf = rf
+	}
+}
+
+// WithMaxFieldExpansion specifies maximum number of fields which object field of `_exists_` or wildcard field pattern
+// expands to, it's DEFAULT_MAX_FIELD_EXPANSION if not specified
+func WithMaxFieldExpansion(maxExpansion int) ConverterOption {
+	return func(c *converter) {
+		c.maxExpansion = maxExpansion
+	}
+}
+
+// WithNumericInference infers integers as long and decimals as double instead of keyword when mapping isn't provided,
+// so that range bounds are parsed, compared and merged numerically
+func WithNumericInference(numericInference bool) ConverterOption {
+	return func(c *converter) {
+		c.numericInference = numericInference
+	}
+}
+
+// WithKeywordFields specifies fields (wildcard is supported) whose numeric values are still inferred as keyword
+// with numeric inference, i.e. ID-like fields `*_id` whose values may have leading zeros
+func WithKeywordFields(patterns []string) ConverterOption {
+	return func(c *converter) {
+		c.keywordFields = patterns
+	}
+}
+
+// WithInferenceRules specifies rules inferring type of field by its name when mapping isn't provided, the first rule
+// matching field is used instead of inferring type from query value, i.e. `*_at` is date and `*_id` is keyword
+func WithInferenceRules(rules []InferenceRule) ConverterOption {
+	return func(c *converter) {
+		c.inferenceRules = compileInferenceRules(rules)
+	}
+}
+
+func NewConverter(mp *mapping.PropertyMapping, mf map[string]ConvertFunc, opts ...ConverterOption) Converter {
 	c := &converter{
-		mp: mp,
-		mf: mf,
+		mp:            mp,
+		mf:            mf,
+		regexpFlags:   dsl.ALL_FLAG,
+		rangeRelation: dsl.INTERSECTS,
+		maxExpansion:  DEFAULT_MAX_FIELD_EXPANSION,
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
 	return c
 }
 
-func NewConverterWithFilter(mp *mapping.PropertyMapping, mf map[string]ConvertFunc, filterPatterns []string) Converter {
+func NewConverterWithFilter(mp *mapping.PropertyMapping, mf map[string]ConvertFunc, filterPatterns []string, opts ...ConverterOption) Converter {
 	c := &converter{
 		mp:             mp,
 		mf:             mf,
 		filterPatterns: filterPatterns,
+		regexpFlags:    dsl.ALL_FLAG,
+		rangeRelation:  dsl.INTERSECTS,
+		maxExpansion:   DEFAULT_MAX_FIELD_EXPANSION,
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
 	return c
 }
@@ -51,9 +191,54 @@ type converter struct {
 	mf map[string]ConvertFunc
 	// filterPatterns fields matching these patterns use filter context
 	filterPatterns []string
+	// regexpFlags enabled optional operators of lucene regexp
+	regexpFlags dsl.RegexpFlagType
+	// rangeRelation is default relation of range query on range type fields
+	rangeRelation dsl.RelationType
+	// fieldRangeRelations specific relation of range query for specific range type fields
+	fieldRangeRelations map[string]dsl.RelationType
+	// preserveDateMath keeps original date math expr in range query on date fields
+	preserveDateMath bool
+	// timeZone is default time zone of date fields
+	timeZone string
+	// fieldTimeZones specific time zone for specific date fields
+	fieldTimeZones map[string]string
+	// dateString prints date values as strings in the first format of mapping printing them exactly
+	dateString bool
+	// im is mappings of multiple indices, which is used instead of mp if it's provided
+	im *IndexMappings
+	// indexScope scopes clause of field with `_index` term if field isn't mapped by all indices
+	indexScope bool
+	// dm is dynamic settings of mapping, which resolve field isn't mapped explicitly
+	dm *DynamicMapping
+	// rf is runtime fields, which shadow properties of mapping with same names
+	rf *RuntimeFields
+	// maxExpansion is maximum number of fields which object field or wildcard field pattern expands to
+	maxExpansion int
+	// numericInference infers integers as long and decimals as double when mapping isn't provided
+	numericInference bool
+	// keywordFields are patterns of fields whose numeric values are inferred as keyword with numeric inference
+	keywordFields []string
+	// inferenceRules infer type of field by its name when mapping isn't provided
+	inferenceRules []InferenceRule
+	// fieldTypes are types of fields inferred from the whole query when mapping isn't provided
+	fieldTypes map[string]mapping.FieldType
 }
 
 func (c *converter) LuceneToAstNode(q *lucene.Lucene) (dsl.AstNode, error) {
+	if err := c.checkRangeRelations(); err != nil {
+		return nil, err
+	}
+	if c.mp == nil && c.im == nil {
+		// 没有提供mapping时，先从整个查询推断各字段统一的类型，再转换各个子句
+		fieldTypes, err := c.inferQueryFieldTypes(q)
+		if err != nil {
+			return nil, err
+		}
+		var qc = *c
+		qc.fieldTypes = fieldTypes
+		return qc.luceneToAstNode(q)
+	}
 	return c.luceneToAstNode(q)
 }
 
@@ -66,6 +251,78 @@ func (c *converter) shouldUseFilter(field string) bool {
 	return false
 }
 
+// checkRangeRelations checks that relations of range query are one of INTERSECTS, CONTAINS and WITHIN
+func (c *converter) checkRangeRelations() error {
+	if _, err := dsl.ParseRelation(string(c.rangeRelation)); err != nil {
+		return fmt.Errorf("range relation is invalid, err: %v", err)
+	}
+	for field, relation := range c.fieldRangeRelations {
+		if _, err := dsl.ParseRelation(string(relation)); err != nil {
+			return fmt.Errorf("range relation of field: %s is invalid, err: %v", field, err)
+		}
+	}
+	return nil
+}
+
+// getRangeRelation returns relation of range query on the field, relation is meaningful for range type fields only
+func (c *converter) getRangeRelation(field string, property *mapping.Property) dsl.RelationType {
+	if !dsl.CheckRangeFieldType(property.Type) {
+		return dsl.INTERSECTS
+	}
+	if relation, ok := c.fieldRangeRelations[field]; ok {
+		return relation
+	}
+	return c.rangeRelation
+}
+
+// getTimeZone returns time zone of date field
+func (c *converter) getTimeZone(field string) string {
+	if timeZone, ok := c.fieldTimeZones[field]; ok {
+		return timeZone
+	}
+	return c.timeZone
+}
+
+// getLocation returns location of time zone of date field, nil is returned if time zone isn't specified
+func (c *converter) getLocation(field string) (*time.Location, error) {
+	var timeZone = c.getTimeZone(field)
+	if loc, err := utils.LoadTimeZone(timeZone); err != nil {
+		return nil, fmt.Errorf("field: %s time zone: %s is invalid, err: %s", field, timeZone, err)
+	} else {
+		return loc, nil
+	}
+}
+
+// getDateFormat returns candidate formats of printing resolved date values of the field,
+// the first of them printing the value exactly is used by range node
+func (c *converter) getDateFormat(property *mapping.Property) string {
+	if c.dateString && property.Format != "" {
+		return property.Format
+	} else if property.Type == mapping.DATE_NANOS_FIELD_TYPE {
+		return utils.DATE_NANOS_FORMAT
+	} else if c.dateString {
+		return DEFAULT_DATE_FORMAT
+	} else {
+		return utils.EPOCH_MILLIS_FORMAT
+	}
+}
+
+// getDateOptions returns options of printing date values in range query on date fields,
+// time zone is provided for parsing date strings, i.e. date math expr and date formatted by mapping format
+func (c *converter) getDateOptions(field string, property *mapping.Property) []func(dsl.AstNode) {
+	var dateFormat = c.getDateFormat(property)
+	var opts = []func(dsl.AstNode){dsl.WithDateFormat(dateFormat)}
+	if c.preserveDateMath {
+		opts = append(opts, dsl.WithFormat(dateMathFormat(property, dateFormat)))
+	} else if c.dateString {
+		opts = append(opts, dsl.WithFormat(property.Format))
+	}
+	if c.preserveDateMath || c.dateString {
+		opts = append(opts, dsl.WithTimeZone(c.getTimeZone(field)))
+	}
+	return opts
+}
+
 func (c *converter) applyFilterCtx(node dsl.AstNode, field string) {
 	if c.shouldUseFilter(field) {
 		if fc, ok := node.(dsl.FilterCtxNode); ok {
@@ -80,19 +337,65 @@ func (c *converter) inferTypeFromQuery(q *lucene.FieldQuery) mapping.FieldType {
 		return mapping.KEYWORD_FIELD_TYPE
 	}
 
+	if typ := matchInferenceRules(c.inferenceRules, q.Field.String()); typ != "" {
+		return typ
+	}
+	if typ, ok := c.fieldTypes[q.Field.String()]; ok {
+		return typ
+	}
+
+	var infer = InferFieldType
+	if c.numericInference && !c.isKeywordField(q.Field.String()) {
+		infer = InferNumericFieldType
+	}
+
 	termType := q.Term.GetTermType()
 
-	// For range queries, try to extract a value from the bound
+	// For range queries, infer type from the bounds
 	if termType&term.RANGE_TERM_TYPE == term.RANGE_TERM_TYPE {
 		bound := q.Term.GetBound()
-		if bound != nil && bound.LeftValue != nil {
-			return InferFieldType(bound.LeftValue.String())
+		if bound != nil {
+			return inferRangeFieldType(bound, infer)
 		}
 		return mapping.KEYWORD_FIELD_TYPE
 	}
 
 	// For other term types, use the string value
-	return InferFieldType(q.Term.String())
+	return infer(q.Term.String())
+}
+
+// isKeywordField checks whether numeric values of field are inferred as keyword
+func (c *converter) isKeywordField(field string) bool {
+	for _, pattern := range c.keywordFields {
+		if ok, _ := path.Match(pattern, field); ok || pattern == field {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveDynamicProperty resolves property of field which isn't mapped explicitly by dynamic settings of mapping,
+// nil is returned if field can't be resolved
+func (c *converter) resolveDynamicProperty(q *lucene.FieldQuery) (*mapping.Property, error) {
+	var field = q.Field.String()
+	if c.dm == nil || strings.ContainsAny(field, "*?") {
+		return nil, nil
+	}
+
+	var termType = q.Term.GetTermType()
+	var mappingTypes = []string{STRING_MAPPING_TYPE}
+	if termType&term.RANGE_TERM_TYPE == term.RANGE_TERM_TYPE {
+		if bound := q.Term.GetBound(); bound != nil {
+			if bound.LeftValue != nil && !bound.LeftValue.IsInf(-1) {
+				mappingTypes = mappingTypesOfValue(bound.LeftValue.String())
+			} else if bound.RightValue != nil && !bound.RightValue.IsInf(1) {
+				mappingTypes = mappingTypesOfValue(bound.RightValue.String())
+			}
+		}
+	} else if termType&(term.SINGLE_TERM_TYPE|term.PHRASE_TERM_TYPE) != 0 {
+		mappingTypes = mappingTypesOfValue(q.Term.String())
+	}
+	return c.dm.ResolveProperty(field, mappingTypes)
 }
 
 func (c *converter) luceneToAstNode(q *lucene.Lucene, pp ...*mapping.Property) (dsl.AstNode, error) {
@@ -193,16 +496,28 @@ func (c *converter) fieldQueryToAstNode(q *lucene.FieldQuery, pp ...*mapping.Pro
 
 	var field = q.Field.String()
 	if q.Field.String() == EXIST_FIELD {
-		return dsl.NewExistsNode(
-			dsl.NewFieldNode(dsl.NewLfNode(), q.Term.String()),
-		), nil
+		return c.existsToAstNode(q.Term.String())
 	}
 	if field == "*" && q.Term.String() == "*" {
 		return &dsl.MatchAllNode{}, nil
 	}
 
+	// 运行时字段会覆盖mapping中同名的字段
+	var runtimeProps = c.rf.GetProperty(field)
+	if len(pp) == 0 && c.im != nil && len(runtimeProps) == 0 {
+		return c.fieldQueryToAstNodeByIndices(q)
+	}
+
+	if len(pp) == 0 && c.mp != nil && c.im == nil && strings.ContainsAny(field, "*?") {
+		return c.wildcardFieldQueryToAstNode(q, runtimeProps)
+	}
+
 	var props []*mapping.Property
-	if len(pp) == 0 && c.mp == nil {
+	if len(pp) == 0 && c.mp == nil && len(runtimeProps) != 0 {
+		for _, prop := range runtimeProps {
+			props = append(props, prop)
+		}
+	} else if len(pp) == 0 && c.mp == nil {
 		// 如果没有提供mapping，则尝试从查询中推断字段类型
 		inferredType := c.inferTypeFromQuery(q)
 		prop := CreateDefaultProperty(inferredType)
@@ -214,7 +529,14 @@ func (c *converter) fieldQueryToAstNode(q *lucene.FieldQuery, pp ...*mapping.Pro
 			return nil, err
 		} else {
 			var notSupportErr error
+			var allProps = make(map[string]*mapping.Property, len(_props)+len(runtimeProps))
 			for key, prop := range _props {
+				allProps[key] = prop
+			}
+			for key, prop := range runtimeProps {
+				allProps[key] = prop
+			}
+			for key, prop := range allProps {
 				if !mapping.CheckTypeSupportLucene(prop.Type) {
 					notSupportErr = fmt.Errorf("field: %s, type: %s is not support lucene query", key, prop.Type)
 				} else {
@@ -226,6 +548,16 @@ func (c *converter) fieldQueryToAstNode(q *lucene.FieldQuery, pp ...*mapping.Pro
 				if notSupportErr != nil {
 					// 如果是当前支持的类型但不支持lucene查询的类型，则返回不支持lucene查询的错误；
 					return nil, notSupportErr
+				} else if prop, err := flattenedProperty(c.mp, field); err != nil {
+					return nil, err
+				} else if prop != nil {
+					// 如果字段是flattened字段的子路径，则使用flattened字段的映射
+					props = append(props, prop)
+				} else if prop, err := c.resolveDynamicProperty(q); err != nil {
+					return nil, err
+				} else if prop != nil {
+					// 如果字段没有显式映射，但会被dynamic_templates动态映射，则使用模板生成的映射
+					props = append(props, prop)
 				} else {
 					// 如果是没有这个字段的映射，则返回找不到字段的错误
 					return nil, fmt.Errorf("field: %s don't match any es mapping", field)
@@ -249,8 +581,363 @@ func (c *converter) fieldQueryToAstNode(q *lucene.FieldQuery, pp ...*mapping.Pro
 	return res, nil
 }
 
+// existsToAstNode converts `_exists_` query, object field is expanded to should of exists queries over its leaf fields,
+// with index mappings leaf fields of every index are expanded, and field itself is kept if it isn't object in some index
+func (c *converter) existsToAstNode(field string) (dsl.AstNode, error) {
+	var pms = c.propertyMappings()
+	var leavesOfMappings = make([][]string, len(pms))
+	var leafSet = map[string]bool{}
+	var notObject bool
+	for i, pm := range pms {
+		leaves, err := c.objectLeafFields(pm, field)
+		if err != nil {
+			return nil, err
+		}
+		if len(leaves) == 0 && len(pms) > 1 {
+			// field may be mapped as object in some indices and as leaf field in others
+			props, err := pm.GetProperty(field)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := props[field]; ok {
+				notObject = true
+			}
+		}
+		leavesOfMappings[i] = leaves
+		for _, leaf := range leaves {
+			leafSet[leaf] = true
+		}
+	}
+	if len(leafSet) == 0 {
+		return dsl.NewExistsNode(dsl.NewFieldNode(dsl.NewLfNode(), field)), nil
+	}
+	if notObject {
+		leafSet[field] = true
+	}
+	var leaves = make([]string, 0, len(leafSet))
+	for leaf := range leafSet {
+		leaves = append(leaves, leaf)
+	}
+	sort.Strings(leaves)
+	if len(leaves) > c.maxExpansion {
+		return nil, fmt.Errorf("object field: %s expands to %d fields, which exceeds max field expansion: %d", field, len(leaves), c.maxExpansion)
+	}
+	for i, pm := range pms {
+		if err := checkNotNested(pm, field, leavesOfMappings[i]); err != nil {
+			return nil, err
+		}
+	}
+	var res dsl.AstNode = &dsl.EmptyNode{}
+	var err error
+	for _, leaf := range leaves {
+		if res, err = res.UnionJoin(dsl.NewExistsNode(dsl.NewFieldNode(dsl.NewLfNode(), leaf))); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// propertyMappings returns mapping of converter, or mappings of indices ordered by index name if index mappings are given
+func (c *converter) propertyMappings() []*mapping.PropertyMapping {
+	if c.im != nil {
+		var pms = make([]*mapping.PropertyMapping, 0, len(c.im.Indices()))
+		for _, index := range c.im.Indices() {
+			pms = append(pms, c.im.Mappings()[index])
+		}
+		return pms
+	} else if c.mp != nil {
+		return []*mapping.PropertyMapping{c.mp}
+	}
+	return nil
+}
+
+// objectLeafFields returns sorted leaf fields of object field in mapping pm, multi fields of leaf fields are excluded,
+// nil is returned if field isn't object field in mapping
+func (c *converter) objectLeafFields(pm *mapping.PropertyMapping, field string) ([]string, error) {
+	if pm == nil || strings.ContainsAny(field, "*?") || len(c.rf.GetProperty(field)) != 0 {
+		return nil, nil
+	}
+	props, err := pm.GetProperty(field)
+	if err != nil {
+		return nil, err
+	}
+	if prop, ok := props[field]; ok && !isObjectFieldType(prop.Type) {
+		return nil, nil
+	}
+	subProps, err := pm.GetProperty(field + ".*")
+	if err != nil {
+		return nil, err
+	}
+	var leafProps = map[string]*mapping.Property{}
+	for key, prop := range subProps {
+		leafProps[key] = prop
+	}
+	for key, prop := range c.rf.GetProperty(field + ".*") {
+		leafProps[key] = prop
+	}
+	var leaves []string
+	for key, prop := range leafProps {
+		if isObjectFieldType(prop.Type) {
+			continue
+		}
+		if idx := strings.LastIndexByte(key, '.'); idx > 0 {
+			if parent, ok := leafProps[key[:idx]]; ok && !isObjectFieldType(parent.Type) {
+				// multi field (i.e. `title.raw`) exists whenever its parent exists
+				continue
+			}
+		}
+		leaves = append(leaves, key)
+	}
+	sort.Strings(leaves)
+	return leaves, nil
+}
+
+// wildcardFieldQueryToAstNode expands wildcard field pattern (i.e. `user.*`) to leaf fields of mapping and unions
+// clauses of them, field whose type can't convert value of query (i.e. `alice` for integer field) is skipped
+func (c *converter) wildcardFieldQueryToAstNode(q *lucene.FieldQuery, runtimeProps map[string]*mapping.Property) (dsl.AstNode, error) {
+	var field = q.Field.String()
+	props, err := c.mp.GetProperty(field)
+	if err != nil {
+		return nil, err
+	}
+	var allProps = make(map[string]*mapping.Property, len(props)+len(runtimeProps))
+	for key, prop := range props {
+		allProps[key] = prop
+	}
+	for key, prop := range runtimeProps {
+		allProps[key] = prop
+	}
+	var keys []string
+	for key, prop := range allProps {
+		if !isObjectFieldType(prop.Type) && mapping.CheckTypeSupportLucene(prop.Type) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("field: %s don't match any es mapping", field)
+	}
+	if len(keys) > c.maxExpansion {
+		return nil, fmt.Errorf("field: %s expands to %d fields, which exceeds max field expansion: %d", field, len(keys), c.maxExpansion)
+	}
+	sort.Strings(keys)
+	var mappedKeys []string
+	for _, key := range keys {
+		if _, ok := runtimeProps[key]; !ok {
+			mappedKeys = append(mappedKeys, key)
+		}
+	}
+	if err := checkNotNested(c.mp, field, mappedKeys); err != nil {
+		return nil, err
+	}
+
+	var res dsl.AstNode = &dsl.EmptyNode{}
+	var convertErr error
+	for _, key := range keys {
+		var subQuery = &lucene.FieldQuery{Field: &term.Field{Value: []string{key}}, Term: q.Term}
+		node, err := c.fieldQueryToAstNodeByProp(subQuery, allProps[key])
+		if err != nil {
+			if convertErr == nil {
+				convertErr = err
+			}
+			continue
+		}
+		if res, err = res.UnionJoin(node); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := res.(*dsl.EmptyNode); ok {
+		return nil, fmt.Errorf("field: %s don't match any field compatible with value: %s, err: %v", field, q.Term.String(), convertErr)
+	}
+	return res, nil
+}
+
+// checkNotNested returns error if any of fields expanded from field is in nested field, because clause on field
+// of nested objects at root level matches nothing and must be wrapped in nested query instead
+func checkNotNested(pm *mapping.PropertyMapping, field string, expanded []string) error {
+	for _, key := range expanded {
+		path, err := nestedPath(pm, key)
+		if err != nil {
+			return err
+		}
+		if path != "" {
+			return fmt.Errorf("field: %s expands to field: %s of nested field: %s, which can only be queried by nested query", field, key, path)
+		}
+	}
+	return nil
+}
+
+// nestedPath finds path of the closest nested field in mapping pm which field is or is in, empty path is returned
+// if field isn't in nested field
+func nestedPath(pm *mapping.PropertyMapping, field string) (string, error) {
+	for path := field; ; {
+		props, err := pm.GetProperty(path)
+		if err != nil {
+			return "", err
+		}
+		if prop, ok := props[path]; ok && prop.Type == NESTED_FIELD_TYPE {
+			return path, nil
+		}
+		idx := strings.LastIndexByte(path, '.')
+		if idx < 0 {
+			return "", nil
+		}
+		path = path[:idx]
+	}
+}
+
+// flattenedProperty finds property of flattened field in mapping pm which field is or is keyed sub path of, nil is
+// returned if field or its closest mapped ancestor isn't flattened field
+func flattenedProperty(pm *mapping.PropertyMapping, field string) (*mapping.Property, error) {
+	if strings.ContainsAny(field, "*?") {
+		return nil, nil
+	}
+	for path := field; ; {
+		props, err := pm.GetProperty(path)
+		if err != nil {
+			return nil, err
+		}
+		if prop, ok := props[path]; ok {
+			if prop.Type == FLATTENED_FIELD_TYPE {
+				return prop, nil
+			}
+			return nil, nil
+		}
+		idx := strings.LastIndexByte(path, '.')
+		if idx < 0 {
+			return nil, nil
+		}
+		path = path[:idx]
+	}
+}
+
+// flattenedQueryToAstNode converts query on flattened field or its keyed sub path, leaf values of flattened field
+// are indexed as keywords, so query is converted as query on keyword field and range query compares values
+// lexicographically, regexp and fuzzy queries aren't supported by flattened field
+func (c *converter) flattenedQueryToAstNode(q *lucene.FieldQuery, property *mapping.Property) (dsl.AstNode, error) {
+	var termType = q.Term.GetTermType()
+	if termType&term.REGEXP_TERM_TYPE == term.REGEXP_TERM_TYPE {
+		return nil, fmt.Errorf("field: %s is flattened field, which doesn't support regexp query", q.Field.String())
+	} else if termType&term.FUZZY_TERM_TYPE == term.FUZZY_TERM_TYPE {
+		return nil, fmt.Errorf("field: %s is flattened field, which doesn't support fuzzy / proximity query", q.Field.String())
+	}
+	var keyword = &mapping.Property{
+		Type:          mapping.KEYWORD_FIELD_TYPE,
+		NullValue:     property.NullValue,
+		ExtProperties: property.ExtProperties,
+	}
+	return c.fieldQueryToAstNodeByProp(q, keyword)
+}
+
+// fieldQueryToAstNodeByIndices converts field query into a clause per property of field in indices and unions them,
+// property which can't convert value of query (i.e. `x:abc` on long field) is skipped, because no document
+// in its indices can match the value
+func (c *converter) fieldQueryToAstNodeByIndices(q *lucene.FieldQuery) (dsl.AstNode, error) {
+	var field = q.Field.String()
+	groups, err := c.im.GetFieldIndices(field)
+	if err != nil {
+		return nil, err
+	}
+	if strings.ContainsAny(field, "*?") {
+		if groups, err = c.expandedIndexGroups(field, groups); err != nil {
+			return nil, err
+		}
+	}
+
+	var res dsl.AstNode = &dsl.EmptyNode{}
+	var converted bool
+	var convertErr error
+	for _, group := range groups {
+		if !mapping.CheckTypeSupportLucene(group.Property.Type) {
+			convertErr = fmt.Errorf("field: %s, type: %s is not support lucene query", group.Field, group.Property.Type)
+			continue
+		}
+		var subQuery = q
+		if group.Field != field {
+			// wildcard field pattern is resolved to concrete fields of indices
+			subQuery = &lucene.FieldQuery{Field: &term.Field{Value: []string{group.Field}}, Term: q.Term}
+		}
+		node, err := c.fieldQueryToAstNodeByProp(subQuery, group.Property)
+		if err != nil {
+			if convertErr == nil {
+				convertErr = err
+			}
+			continue
+		}
+		if len(groups) > 1 {
+			// nodes of different properties are typed differently and can't be compared or merged,
+			// so node keys of all leaves are scoped by indices, nodes of same indices are still merged with each other
+			node = dsl.ScopeAstNode(node, strings.Join(group.Indices, ","))
+		}
+		if c.indexScope && len(group.Indices) < len(c.im.Indices()) {
+			if node, err = scopeAstNodeByIndices(node, group.Indices); err != nil {
+				return nil, err
+			}
+		}
+		if res, err = res.UnionJoin(node); err != nil {
+			return nil, err
+		}
+		converted = true
+	}
+
+	if !converted {
+		if convertErr != nil {
+			return nil, convertErr
+		}
+		return nil, fmt.Errorf("field: %s don't match any es mapping", field)
+	}
+	return res, nil
+}
+
+// expandedIndexGroups drops groups of object fields and fields not supporting lucene query from groups of wildcard
+// field pattern, and checks expanded fields against max field expansion and nested fields of every index
+// as wildcardFieldQueryToAstNode does
+func (c *converter) expandedIndexGroups(field string, groups []*FieldIndices) ([]*FieldIndices, error) {
+	var res []*FieldIndices
+	var fields = map[string]bool{}
+	for _, group := range groups {
+		if !isObjectFieldType(group.Property.Type) && mapping.CheckTypeSupportLucene(group.Property.Type) {
+			res = append(res, group)
+			fields[group.Field] = true
+		}
+	}
+	if len(fields) > c.maxExpansion {
+		return nil, fmt.Errorf("field: %s expands to %d fields, which exceeds max field expansion: %d", field, len(fields), c.maxExpansion)
+	}
+	for _, group := range res {
+		for _, index := range group.Indices {
+			if err := checkNotNested(c.im.Mappings()[index], field, []string{group.Field}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return res, nil
+}
+
+// scopeAstNodeByIndices intersects node with `_index` terms of indices in filter context
+func scopeAstNodeByIndices(node dsl.AstNode, indices []string) (dsl.AstNode, error) {
+	var indexNode dsl.AstNode = &dsl.EmptyNode{}
+	for _, index := range indices {
+		var err error
+		if indexNode, err = indexNode.UnionJoin(dsl.NewTermNode(dsl.NewKVNode(
+			dsl.NewFieldNode(dsl.NewLfNode(), INDEX_FIELD),
+			dsl.NewValueNode(index, dsl.NewValueType(mapping.KEYWORD_FIELD_TYPE, false)),
+		))); err != nil {
+			return nil, err
+		}
+	}
+	if fc, ok := indexNode.(dsl.FilterCtxNode); ok {
+		fc.SetFilterCtx(true)
+	}
+	return dsl.NewBoolNode(indexNode, dsl.AND).InterSect(node)
+}
+
 func (c *converter) fieldQueryToAstNodeByProp(q *lucene.FieldQuery, property *mapping.Property) (dsl.AstNode, error) {
 	var termType = q.Term.GetTermType()
+	if property.Type == FLATTENED_FIELD_TYPE {
+		return c.flattenedQueryToAstNode(q, property)
+	} else if property.Type == GEO_POINT_FIELD_TYPE || property.Type == GEO_SHAPE_FIELD_TYPE {
+		return c.geoQueryToAstNode(q, property)
+	}
 	if termType&term.RANGE_TERM_TYPE == term.RANGE_TERM_TYPE {
 		return c.convertToRange(q.Field, q.Term, property)
 	} else if termType&term.SINGLE_TERM_TYPE == term.SINGLE_TERM_TYPE {
@@ -289,26 +976,63 @@ func (c *converter) convertToRange(field *term.Field, termV *term.Term, property
 		rightCmp = dsl.LT
 	}
 
-	if lv, err := termValueToLeafValue(bound.LeftValue, property); err != nil {
+	var loc *time.Location
+	if mapping.CheckDateType(property.Type) {
+		if l, err := c.getLocation(field.String()); err != nil {
+			return nil, err
+		} else {
+			loc = l
+		}
+	}
+
+	if lv, err := termValueToLeafValue(bound.LeftValue, property, loc); err != nil {
 		return nil, fmt.Errorf("field: %s value: %s is invalid, type: %s, err: %s",
 			field, bound.LeftValue.String(), property.Type, err)
 	} else {
 		leftValue = lv
 	}
 
-	if rv, err := termValueToLeafValue(bound.RightValue, property); err != nil {
+	if rv, err := termValueToLeafValue(bound.RightValue, property, loc); err != nil {
 		return nil, fmt.Errorf("field: %s value: %s is invalid, type: %s, err: %s",
 			field, bound.RightValue.String(), property.Type, err)
 	} else {
 		rightValue = rv
 	}
 
+	var opts = []func(dsl.AstNode){
+		dsl.WithBoost(termV.Boost().Float()),
+		dsl.WithRelation(c.getRangeRelation(field.String(), property)),
+	}
+	if mapping.CheckDateType(property.Type) {
+		opts = append(opts, c.getDateOptions(field.String(), property)...)
+	}
+	if c.preserveDateMath && mapping.CheckDateType(property.Type) {
+		var lDateMath, rDateMath string
+		if !bound.LeftValue.IsInf(-1) {
+			if dm, err := bound.LeftValue.Value(convertToDateMath(property, loc, leftCmp == dsl.GT)); err != nil {
+				return nil, fmt.Errorf("field: %s value: %s is invalid, type: %s, err: %s",
+					field, bound.LeftValue.String(), property.Type, err)
+			} else {
+				lDateMath, leftValue = dm.(*dateMath).expr, dm.(*dateMath).value
+			}
+		}
+		if !bound.RightValue.IsInf(1) {
+			if dm, err := bound.RightValue.Value(convertToDateMath(property, loc, rightCmp == dsl.LTE)); err != nil {
+				return nil, fmt.Errorf("field: %s value: %s is invalid, type: %s, err: %s",
+					field, bound.RightValue.String(), property.Type, err)
+			} else {
+				rDateMath, rightValue = dm.(*dateMath).expr, dm.(*dateMath).value
+			}
+		}
+		opts = append(opts, dsl.WithDateMath(lDateMath, rDateMath))
+	}
+
 	var node = dsl.NewRangeNode(
 		dsl.NewRgNode(
 			dsl.NewFieldNode(dsl.NewLfNode(), field.String()),
 			dsl.NewValueType(property.Type, true),
 			leftValue, rightValue, leftCmp, rightCmp,
-		), dsl.WithBoost(termV.Boost().Float()),
+		), opts...,
 	)
 	if err := dsl.CheckValidRangeNode(node); err != nil {
 		return nil, fmt.Errorf("field: %s value: %s is invalid, err: %s", field, termV.String(), err)
@@ -382,15 +1106,23 @@ func (c *converter) convertToNormal(field *term.Field, termV *term.Term, propert
 
 	var node dsl.AstNode
 	switch property.Type {
+	case mapping.INTEGER_RANGE_FIELD_TYPE, mapping.LONG_RANGE_FIELD_TYPE,
+		mapping.FLOAT_RANGE_FIELD_TYPE, mapping.DOUBLE_RANGE_FIELD_TYPE:
+		if val, err := termValueToLeafValue(termV, property, nil); err != nil {
+			return nil, fmt.Errorf("field: %s value: %s is invalid, type: %s, err: %s",
+				field, termV.String(), property.Type, err)
+		} else {
+			node = newPointRangeNode(field.String(), property, val, termV.Boost().Float())
+		}
 	case mapping.BOOLEAN_FIELD_TYPE,
 		mapping.BYTE_FIELD_TYPE, mapping.SHORT_FIELD_TYPE,
-		mapping.INTEGER_FIELD_TYPE, mapping.INTEGER_RANGE_FIELD_TYPE,
-		mapping.LONG_FIELD_TYPE, mapping.LONG_RANGE_FIELD_TYPE, mapping.UNSIGNED_LONG_FIELD_TYPE,
+		mapping.INTEGER_FIELD_TYPE,
+		mapping.LONG_FIELD_TYPE, mapping.UNSIGNED_LONG_FIELD_TYPE,
 		mapping.HALF_FLOAT_FIELD_TYPE, mapping.SCALED_FLOAT_FIELD_TYPE,
-		mapping.FLOAT_FIELD_TYPE, mapping.FLOAT_RANGE_FIELD_TYPE,
+		mapping.FLOAT_FIELD_TYPE, mapping.DOUBLE_FIELD_TYPE,
 		mapping.VERSION_FIELD_TYPE,
 		mapping.KEYWORD_FIELD_TYPE, mapping.CONSTANT_KEYWORD_FIELD_TYPE, mapping.WILDCARD_FIELD_TYPE:
-		if val, err := termValueToLeafValue(termV, property); err != nil {
+		if val, err := termValueToLeafValue(termV, property, nil); err != nil {
 			return nil, fmt.Errorf("field: %s value: %s is invalid, type: %s, err: %s",
 				field, termV.String(), property.Type, err)
 		} else {
@@ -404,7 +1136,16 @@ func (c *converter) convertToNormal(field *term.Field, termV *term.Term, propert
 		}
 
 	case mapping.DATE_FIELD_TYPE, mapping.DATE_RANGE_FIELD_TYPE, mapping.DATE_NANOS_FIELD_TYPE:
-		if dr, err := termV.Value(convertToDateRange(property)); err != nil {
+		if c.preserveDateMath {
+			if node, err := c.convertToDateMathRange(field, termV, property); err != nil {
+				return nil, err
+			} else {
+				c.applyFilterCtx(node, field.String())
+				return node, nil
+			}
+		} else if loc, err := c.getLocation(field.String()); err != nil {
+			return nil, err
+		} else if dr, err := termV.Value(convertToDateRange(property, loc)); err != nil {
 			return nil, fmt.Errorf("field: %s value: %s is invalid, expect to date math expr", field, termV.String())
 		} else {
 			var dateRange = dr.(*dateRange)
@@ -414,11 +1155,16 @@ func (c *converter) convertToNormal(field *term.Field, termV *term.Term, propert
 					dsl.NewValueType(property.Type, true),
 					dateRange.from, dateRange.to, dsl.GTE, dsl.LTE,
 				),
-				dsl.WithBoost(termV.Boost().Float()),
+				append([]func(dsl.AstNode){
+					dsl.WithBoost(termV.Boost().Float()),
+					dsl.WithRelation(c.getRangeRelation(field.String(), property)),
+				}, c.getDateOptions(field.String(), property)...)...,
 			)
 		}
 	case mapping.IP_FIELD_TYPE, mapping.IP_RANGE_FIELD_TYPE:
-		if ip, err := termV.Value(convertToIp); err == nil {
+		if ip, err := termV.Value(convertToIp); err == nil && property.Type == mapping.IP_RANGE_FIELD_TYPE {
+			node = newPointRangeNode(field.String(), property, ip, termV.Boost().Float())
+		} else if err == nil {
 			node = dsl.NewTermNode(
 				dsl.NewKVNode(
 					dsl.NewFieldNode(dsl.NewLfNode(), field.String()),
@@ -431,7 +1177,9 @@ func (c *converter) convertToNormal(field *term.Field, termV *term.Term, propert
 				dsl.NewFieldNode(dsl.NewLfNode(), field.String()),
 				dsl.NewValueType(property.Type, true),
 				net.IP(ip1), net.IP(ip2), dsl.GTE, dsl.LTE,
-			), dsl.WithBoost(termV.Boost().Float()))
+			), dsl.WithBoost(termV.Boost().Float()),
+				dsl.WithRelation(c.getRangeRelation(field.String(), property)),
+			)
 		} else {
 			return nil, fmt.Errorf("field: %s value: %s is invalid, type: %s",
 				field, termV.String(), property.Type)
@@ -462,12 +1210,57 @@ func (c *converter) convertToNormal(field *term.Field, termV *term.Term, propert
 	return node, nil
 }
 
+// convertToDateMathRange converts single date math expr to range query which keeps the expr on both bounds,
+// es rounds `gte` down and `lte` up, so the query matches whole rounding unit or partial date, e.g. "now/d", "2019-02"
+func (c *converter) convertToDateMathRange(field *term.Field, termV *term.Term, property *mapping.Property) (dsl.AstNode, error) {
+	loc, err := c.getLocation(field.String())
+	if err != nil {
+		return nil, err
+	}
+	from, err := termV.Value(convertToDateMath(property, loc, false))
+	if err != nil {
+		return nil, fmt.Errorf("field: %s value: %s is invalid, expect to date math expr", field, termV.String())
+	}
+	to, _ := termV.Value(convertToDateMath(property, loc, true))
+	var expr = from.(*dateMath).expr
+	return dsl.NewRangeNode(
+		dsl.NewRgNode(
+			dsl.NewFieldNode(dsl.NewLfNode(), field.String()),
+			dsl.NewValueType(property.Type, true),
+			from.(*dateMath).value, to.(*dateMath).value, dsl.GTE, dsl.LTE,
+		),
+		append([]func(dsl.AstNode){
+			dsl.WithBoost(termV.Boost().Float()),
+			dsl.WithRelation(c.getRangeRelation(field.String(), property)),
+			dsl.WithDateMath(expr, expr),
+		}, c.getDateOptions(field.String(), property)...)...,
+	), nil
+}
+
+// newPointRangeNode converts single value on range type field to range query,
+// which matches documents whose range contains the value, es don't support term query on range type field
+func newPointRangeNode(field string, property *mapping.Property, val dsl.LeafValue, boost float64) dsl.AstNode {
+	return dsl.NewRangeNode(
+		dsl.NewRgNode(
+			dsl.NewFieldNode(dsl.NewLfNode(), field),
+			dsl.NewValueType(property.Type, true),
+			val, val, dsl.GTE, dsl.LTE,
+		),
+		dsl.WithBoost(boost),
+		dsl.WithRelation(dsl.CONTAINS),
+	)
+}
+
 func (c *converter) convertToRegexp(field *term.Field, termV *term.Term, property *mapping.Property) (dsl.AstNode, error) {
 	if !mapping.CheckStringType(property.Type) {
 		return nil, fmt.Errorf("type: %s, don't support regex query, expect text", property.Type)
 	}
 	var valStr, _ = termV.Value(convertToString)
-	if pattern, err := regexp.Compile(valStr.(string)); err != nil {
+	flags, err := utils.ParseRegexpFlags(string(c.regexpFlags))
+	if err != nil {
+		return nil, err
+	}
+	if pattern, err := utils.NewRegexpPattern(valStr.(string), flags); err != nil {
 		return nil, fmt.Errorf("regexp str: %+v is invalid, err: %+v", valStr, err)
 	} else {
 		return dsl.NewRegexpNode(
@@ -476,6 +1269,7 @@ func (c *converter) convertToRegexp(field *term.Field, termV *term.Term, propert
 				dsl.NewValueNode(valStr, dsl.NewValueType(property.Type, true)),
 			),
 			pattern,
+			dsl.WithFlags(c.regexpFlags),
 		), nil
 	}
 
@@ -485,7 +1279,8 @@ func (c *converter) convertToGroup(field *term.Field, termV *term.Term, property
 	return c.luceneToAstNode(lucene.TermGroupToLucene(field, termV.TermGroup), property)
 }
 
-func termValueToLeafValue(termV termValue, property *mapping.Property) (dsl.LeafValue, error) {
+// termValueToLeafValue converts term value to leaf value according to field type, loc is used for date fields only
+func termValueToLeafValue(termV termValue, property *mapping.Property, loc *time.Location) (dsl.LeafValue, error) {
 	switch typ := property.Type; typ {
 	case mapping.BOOLEAN_FIELD_TYPE:
 		if termR, ok := termV.(rangeValue); ok {
@@ -561,10 +1356,10 @@ func termValueToLeafValue(termV termValue, property *mapping.Property) (dsl.Leaf
 			} else if termR.IsInf(1) {
 				return dsl.MaxTime, nil
 			} else {
-				return termR.Value(convertToDate(property))
+				return termR.Value(convertToDate(property, loc))
 			}
 		} else {
-			return termV.Value(convertToDate(property))
+			return termV.Value(convertToDate(property, loc))
 		}
 	case mapping.VERSION_FIELD_TYPE:
 		if termR, ok := termV.(rangeValue); ok {
@@ -6,6 +6,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	mapping "github.com/zhuliquan/es-mapping"
 	"github.com/zhuliquan/lucene-to-dsl/dsl"
+	lucene "github.com/zhuliquan/lucene_parser"
 	op "github.com/zhuliquan/lucene_parser/operator"
 	term "github.com/zhuliquan/lucene_parser/term"
 )
@@ -591,3 +592,157 @@ func TestConvertToGroup(t *testing.T) {
 		})
 	}
 }
+
+var nestedMappingData = []byte(`{
+  "properties": {
+    "status": {"type": "keyword"},
+    "user": {"properties": {"name": {"type": "keyword"}, "age": {"type": "integer"}}},
+    "comments": {"type": "nested", "properties": {"author": {"type": "keyword"}, "likes": {"type": "integer"}}},
+    "post": {"properties": {"title": {"type": "keyword"}, "tags": {"type": "nested", "properties": {"name": {"type": "keyword"}}}}}
+  }
+}`)
+
+func TestExistsToAstNode(t *testing.T) {
+	mp, err := LoadMappingPayload(nestedMappingData)
+	assert.Nil(t, err)
+	cc := NewConverter(mp, nil).(*converter)
+	for _, tt := range []struct {
+		name    string
+		field   string
+		want    string
+		wantErr string
+	}{
+		{name: "leaf", field: "status", want: `{"exists":{"field":"status"}}`},
+		{name: "object", field: "user", want: `{"bool":{"should":[{"exists":{"field":"user.age"}},{"exists":{"field":"user.name"}}]}}`},
+		{
+			name:    "nested",
+			field:   "comments",
+			wantErr: "field: comments expands to field: comments.author of nested field: comments, which can only be queried by nested query",
+		},
+		{
+			name:    "object_containing_nested",
+			field:   "post",
+			wantErr: "field: post expands to field: post.tags.name of nested field: post.tags, which can only be queried by nested query",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := cc.existsToAstNode(tt.field)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, dsl.Compact(node.ToDSL()).String())
+		})
+	}
+}
+
+func TestWildcardFieldQueryToAstNode(t *testing.T) {
+	mp, err := LoadMappingPayload(nestedMappingData)
+	assert.Nil(t, err)
+	cc := NewConverter(mp, nil).(*converter)
+	for _, tt := range []struct {
+		name    string
+		field   string
+		value   string
+		want    string
+		wantErr string
+	}{
+		{name: "object", field: "user.*", value: "30", want: `{"bool":{"should":[{"term":{"user.age":30}},{"term":{"user.name":"30"}}]}}`},
+		{name: "skip_incompatible", field: "user.*", value: "alice", want: `{"term":{"user.name":"alice"}}`},
+		{
+			name:    "nested",
+			field:   "comments.*",
+			value:   "alice",
+			wantErr: "field: comments.* expands to field: comments.author of nested field: comments, which can only be queried by nested query",
+		},
+		{
+			name:    "object_containing_nested",
+			field:   "post.*",
+			value:   "alice",
+			wantErr: "field: post.* expands to field: post.tags.name of nested field: post.tags, which can only be queried by nested query",
+		},
+		{name: "no_field", field: "missing.*", value: "alice", wantErr: "field: missing.* don't match any es mapping"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := lucene.ParseLucene(tt.field + ":" + tt.value)
+			assert.Nil(t, err)
+			node, err := cc.wildcardFieldQueryToAstNode(q.OrQuery.AndQuery.FieldQuery, nil)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, dsl.Compact(node.ToDSL()).String())
+		})
+	}
+}
+
+func TestExpansionByIndices(t *testing.T) {
+	a, err := LoadMappingPayload(nestedMappingData)
+	assert.Nil(t, err)
+	b, err := LoadMappingPayload([]byte(`{
+  "properties": {
+    "user": {"type": "keyword"},
+    "post": {"properties": {"title": {"type": "keyword"}, "views": {"type": "long"}}}
+  }
+}`))
+	assert.Nil(t, err)
+	im := NewIndexMappings(map[string]*mapping.PropertyMapping{"a": a, "b": b})
+	for _, tt := range []struct {
+		name         string
+		query        string
+		maxExpansion int
+		want         string
+		wantErr      string
+	}{
+		{name: "exists_leaf", query: "_exists_:status", want: `{"exists":{"field":"status"}}`},
+		{
+			name:  "exists_object_and_leaf",
+			query: "_exists_:user",
+			want:  `{"bool":{"should":[{"exists":{"field":"user"}},{"exists":{"field":"user.age"}},{"exists":{"field":"user.name"}}]}}`,
+		},
+		{
+			name:    "exists_nested",
+			query:   "_exists_:comments",
+			wantErr: "field: comments expands to field: comments.author of nested field: comments, which can only be queried by nested query",
+		},
+		{
+			name:         "exists_exceeds_max_expansion",
+			query:        "_exists_:user",
+			maxExpansion: 2,
+			wantErr:      "object field: user expands to 3 fields, which exceeds max field expansion: 2",
+		},
+		{name: "wildcard", query: "user.*:30", want: `{"bool":{"should":[{"term":{"user.age":30}},{"term":{"user.name":"30"}}]}}`},
+		{name: "wildcard_skip_incompatible", query: "user.*:alice", want: `{"term":{"user.name":"alice"}}`},
+		{
+			name:    "wildcard_nested",
+			query:   "post.*:alice",
+			wantErr: "field: post.* expands to field: post.tags.name of nested field: post.tags, which can only be queried by nested query",
+		},
+		{
+			name:         "wildcard_exceeds_max_expansion",
+			query:        "user.*:30",
+			maxExpansion: 1,
+			wantErr:      "field: user.* expands to 2 fields, which exceeds max field expansion: 1",
+		},
+		{name: "wildcard_no_field", query: "missing.*:alice", wantErr: "field: missing.* don't match any es mapping"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts = []ConverterOption{WithIndexMappings(im)}
+			if tt.maxExpansion != 0 {
+				opts = append(opts, WithMaxFieldExpansion(tt.maxExpansion))
+			}
+			cc := NewConverter(nil, nil, opts...)
+			q, err := lucene.ParseLucene(tt.query)
+			assert.Nil(t, err)
+			node, err := cc.LuceneToAstNode(q)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, dsl.Compact(node.ToDSL()).String())
+		})
+	}
+}
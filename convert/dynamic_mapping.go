@@ -0,0 +1,388 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	mapping "github.com/zhuliquan/es-mapping"
+)
+
+// dynamic setting of mapping
+const (
+	DYNAMIC_TRUE    = "true"
+	DYNAMIC_FALSE   = "false"
+	DYNAMIC_STRICT  = "strict"
+	DYNAMIC_RUNTIME = "runtime"
+)
+
+// match mapping type of dynamic template, which is json type of value detected by es
+const (
+	STRING_MAPPING_TYPE  = "string"
+	LONG_MAPPING_TYPE    = "long"
+	DOUBLE_MAPPING_TYPE  = "double"
+	BOOLEAN_MAPPING_TYPE = "boolean"
+	DATE_MAPPING_TYPE    = "date"
+)
+
+// defaultDynamicTypes are field types mapped by es for match mapping types, which replace `{dynamic_type}` placeholder
+var defaultDynamicTypes = map[string]mapping.FieldType{
+	STRING_MAPPING_TYPE:  mapping.TEXT_FIELD_TYPE,
+	LONG_MAPPING_TYPE:    mapping.LONG_FIELD_TYPE,
+	DOUBLE_MAPPING_TYPE:  mapping.FLOAT_FIELD_TYPE,
+	BOOLEAN_MAPPING_TYPE: mapping.BOOLEAN_FIELD_TYPE,
+	DATE_MAPPING_TYPE:    mapping.DATE_FIELD_TYPE,
+}
+
+// defaultRuntimeTypes are runtime field types mapped by es for match mapping types when dynamic is runtime
+var defaultRuntimeTypes = map[string]mapping.FieldType{
+	STRING_MAPPING_TYPE:  mapping.KEYWORD_FIELD_TYPE,
+	LONG_MAPPING_TYPE:    mapping.LONG_FIELD_TYPE,
+	DOUBLE_MAPPING_TYPE:  mapping.DOUBLE_FIELD_TYPE,
+	BOOLEAN_MAPPING_TYPE: mapping.BOOLEAN_FIELD_TYPE,
+	DATE_MAPPING_TYPE:    mapping.DATE_FIELD_TYPE,
+}
+
+// DynamicTemplate is a template of `dynamic_templates` in es mapping
+type DynamicTemplate struct {
+	Name               string
+	Match              []string
+	Unmatch            []string
+	PathMatch          []string
+	PathUnmatch        []string
+	MatchMappingType   []string
+	UnmatchMappingType []string
+	// MatchPattern is "simple" (only `*` wildcard is supported) or "regex"
+	MatchPattern string
+	// Mapping is raw mapping of field, placeholders `{name}` and `{dynamic_type}` are replaced when field is resolved
+	Mapping map[string]interface{}
+
+	// compiled patterns of Match, Unmatch, PathMatch and PathUnmatch
+	match, unmatch, pathMatch, pathUnmatch []*regexp.Regexp
+}
+
+// DynamicMapping is dynamic settings of es mapping, i.e. `dynamic` of root and object fields and `dynamic_templates`,
+// which resolve field that isn't mapped explicitly but is mapped by es when document having it is indexed
+type DynamicMapping struct {
+	// dynamic is setting of root object, which is true if it's not specified
+	dynamic string
+	// objects are dynamic settings of object fields keyed by path, empty setting is inherited from parent
+	objects      map[string]string
+	dateDetected bool
+	templates    []*DynamicTemplate
+}
+
+// LoadDynamicMapping loads dynamic settings from mapping payload of any shape accepted by LoadMappingPayload
+func LoadDynamicMapping(data []byte) (*DynamicMapping, error) {
+	obj, err := decodeJSONObject(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse mapping payload, err: %v", err)
+	}
+	body, err := payloadToMappingBody(obj)
+	if err != nil {
+		return nil, err
+	}
+	return newDynamicMapping(body)
+}
+
+// LoadIndexTemplateDynamicMapping loads dynamic settings of index template composed of component templates as LoadIndexTemplate does
+func LoadIndexTemplateDynamicMapping(indexTemplate, componentTemplates []byte) (*DynamicMapping, error) {
+	body, err := composeIndexTemplate(indexTemplate, componentTemplates)
+	if err != nil {
+		return nil, err
+	}
+	return newDynamicMapping(body)
+}
+
+func newDynamicMapping(body jsonObject) (*DynamicMapping, error) {
+	var dm = &DynamicMapping{
+		dynamic:      toDynamicSetting(body["dynamic"]),
+		objects:      map[string]string{},
+		dateDetected: body["date_detection"] != false,
+	}
+	if dm.dynamic == "" {
+		dm.dynamic = DYNAMIC_TRUE
+	}
+	collectObjectDynamic("", body, dm.objects)
+
+	var templates, _ = body[DYNAMIC_TEMPLATES_KEY].([]interface{})
+	for _, x := range templates {
+		item, ok := x.(jsonObject)
+		if !ok || len(item) != 1 {
+			return nil, fmt.Errorf("dynamic template must be object with single named template")
+		}
+		for name, value := range item {
+			template, err := newDynamicTemplate(name, value)
+			if err != nil {
+				return nil, err
+			}
+			dm.templates = append(dm.templates, template)
+		}
+	}
+	return dm, nil
+}
+
+func newDynamicTemplate(name string, value interface{}) (*DynamicTemplate, error) {
+	obj, ok := value.(jsonObject)
+	if !ok {
+		return nil, fmt.Errorf("dynamic template: %s is not an object", name)
+	}
+	var template = &DynamicTemplate{
+		Name:               name,
+		Match:              toStringOrList(obj["match"]),
+		Unmatch:            toStringOrList(obj["unmatch"]),
+		PathMatch:          toStringOrList(obj["path_match"]),
+		PathUnmatch:        toStringOrList(obj["path_unmatch"]),
+		MatchMappingType:   toStringOrList(obj["match_mapping_type"]),
+		UnmatchMappingType: toStringOrList(obj["unmatch_mapping_type"]),
+	}
+	template.MatchPattern, _ = obj["match_pattern"].(string)
+	if template.MatchPattern != "" && template.MatchPattern != "simple" && template.MatchPattern != "regex" {
+		return nil, fmt.Errorf("dynamic template: %s has invalid match_pattern: %s", name, template.MatchPattern)
+	}
+	if template.Mapping, ok = obj["mapping"].(jsonObject); !ok {
+		// template of runtime field is mapped as same type
+		if template.Mapping, ok = obj[RUNTIME_KEY].(jsonObject); !ok {
+			return nil, fmt.Errorf("dynamic template: %s has neither mapping nor runtime", name)
+		}
+	}
+	for _, x := range []struct {
+		patterns []string
+		compiled *[]*regexp.Regexp
+	}{
+		{template.Match, &template.match},
+		{template.Unmatch, &template.unmatch},
+		{template.PathMatch, &template.pathMatch},
+		{template.PathUnmatch, &template.pathUnmatch},
+	} {
+		for _, pattern := range x.patterns {
+			re, err := template.compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("dynamic template: %s has invalid pattern: %s, err: %v", name, pattern, err)
+			}
+			*x.compiled = append(*x.compiled, re)
+		}
+	}
+	return template, nil
+}
+
+// Matches checks whether template is applied to field of path with match mapping type as es does
+func (t *DynamicTemplate) Matches(path string, mappingType string) bool {
+	var name = path[strings.LastIndexByte(path, '.')+1:]
+	if len(t.match) != 0 && !matchAny(t.match, name) {
+		return false
+	}
+	if matchAny(t.unmatch, name) {
+		return false
+	}
+	if len(t.pathMatch) != 0 && !matchAny(t.pathMatch, path) {
+		return false
+	}
+	if matchAny(t.pathUnmatch, path) {
+		return false
+	}
+	if len(t.MatchMappingType) != 0 && !matchMappingType(t.MatchMappingType, mappingType) {
+		return false
+	}
+	return !matchMappingType(t.UnmatchMappingType, mappingType)
+}
+
+// Property creates property of field by mapping of template
+func (t *DynamicTemplate) Property(name string, mappingType string) (*mapping.Property, error) {
+	var dynamicType = string(defaultDynamicTypes[mappingType])
+	var raw = replacePlaceholders(t.Mapping, name, dynamicType).(jsonObject)
+	if _, ok := raw["type"]; !ok {
+		raw["type"] = dynamicType
+	}
+	prop, err := newProperty(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load mapping of dynamic template: %s, err: %v", t.Name, err)
+	}
+	return prop, nil
+}
+
+// defaultDynamicProperty creates property mapped by es for match mapping type when no dynamic template matches,
+// string is mapped as text with keyword sub-field, or as keyword runtime field if dynamic is runtime
+func defaultDynamicProperty(dynamic string, mappingType string) (*mapping.Property, error) {
+	if dynamic == DYNAMIC_RUNTIME {
+		return newProperty(jsonObject{"type": string(defaultRuntimeTypes[mappingType])})
+	}
+	var raw = jsonObject{"type": string(defaultDynamicTypes[mappingType])}
+	if mappingType == STRING_MAPPING_TYPE {
+		raw["fields"] = jsonObject{"keyword": jsonObject{"type": string(mapping.KEYWORD_FIELD_TYPE), "ignore_above": 256}}
+	}
+	return newProperty(raw)
+}
+
+// newProperty loads property from raw mapping of field
+func newProperty(raw jsonObject) (*mapping.Property, error) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var prop = &mapping.Property{}
+	if err := json.Unmarshal(data, prop); err != nil {
+		return nil, err
+	}
+	return prop, nil
+}
+
+func matchAny(patterns []*regexp.Regexp, s string) bool {
+	for _, re := range patterns {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *DynamicTemplate) compile(pattern string) (*regexp.Regexp, error) {
+	if t.MatchPattern == "regex" {
+		return regexp.Compile("^(?:" + pattern + ")$")
+	}
+	return compileSimplePattern(pattern)
+}
+
+// compileSimplePattern compiles simple pattern, whose only wildcard `*` matches any characters (including `.`)
+func compileSimplePattern(pattern string) (*regexp.Regexp, error) {
+	var parts = strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.Compile("^" + strings.Join(parts, ".*") + "$")
+}
+
+// Dynamic returns effective dynamic setting of object containing field, which is inherited from the closest mapped object
+func (m *DynamicMapping) Dynamic(field string) string {
+	for path := field; ; {
+		idx := strings.LastIndexByte(path, '.')
+		if idx < 0 {
+			return m.dynamic
+		}
+		path = path[:idx]
+		if dynamic, ok := m.objects[path]; ok && dynamic != "" {
+			return dynamic
+		}
+	}
+}
+
+// ResolveProperty synthesises property of unmapped field by the first dynamic template matching field path and
+// one of mapping types, error is returned if field can't be mapped dynamically because of dynamic setting.
+// If no template matches, field is mapped by es default dynamic mapping of the most specific mapping type.
+// nil property is returned for object field
+func (m *DynamicMapping) ResolveProperty(field string, mappingTypes []string) (*mapping.Property, error) {
+	if _, ok := m.objects[field]; ok {
+		return nil, nil
+	}
+	var dynamic = m.Dynamic(field)
+	switch dynamic {
+	case DYNAMIC_STRICT:
+		return nil, fmt.Errorf("field: %s isn't mapped and dynamic mapping is strict", field)
+	case DYNAMIC_FALSE:
+		return nil, fmt.Errorf("field: %s isn't mapped and dynamic mapping is false, it isn't indexed", field)
+	}
+	for _, mappingType := range mappingTypes {
+		if mappingType == DATE_MAPPING_TYPE && !m.dateDetected {
+			continue
+		}
+		for _, template := range m.templates {
+			if template.Matches(field, mappingType) {
+				return template.Property(field[strings.LastIndexByte(field, '.')+1:], mappingType)
+			}
+		}
+	}
+	for _, mappingType := range mappingTypes {
+		if mappingType == DATE_MAPPING_TYPE && !m.dateDetected {
+			continue
+		}
+		return defaultDynamicProperty(dynamic, mappingType)
+	}
+	return nil, nil
+}
+
+// mappingTypesOfValue returns match mapping types of value in order of specificity, value can be string in json
+// document whatever it looks like, so string is always the last one
+func mappingTypesOfValue(value string) []string {
+	value = strings.Trim(value, "\"'")
+	switch {
+	case isBoolean(value):
+		return []string{BOOLEAN_MAPPING_TYPE, STRING_MAPPING_TYPE}
+	case isInteger(value):
+		return []string{LONG_MAPPING_TYPE, STRING_MAPPING_TYPE}
+	case isFloat(value):
+		return []string{DOUBLE_MAPPING_TYPE, STRING_MAPPING_TYPE}
+	case isDate(value):
+		return []string{DATE_MAPPING_TYPE, STRING_MAPPING_TYPE}
+	default:
+		return []string{STRING_MAPPING_TYPE}
+	}
+}
+
+func matchMappingType(types []string, mappingType string) bool {
+	for _, t := range types {
+		if t == "*" || t == mappingType {
+			return true
+		}
+	}
+	return false
+}
+
+// collectObjectDynamic records dynamic settings of object fields by path
+func collectObjectDynamic(prefix string, obj jsonObject, objects map[string]string) {
+	var props, _ = obj[PROPERTIES_KEY].(jsonObject)
+	for name, x := range props {
+		prop, ok := x.(jsonObject)
+		if !ok || !isObjectProperty(prop) {
+			continue
+		}
+		var path = name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		objects[path] = toDynamicSetting(prop["dynamic"])
+		collectObjectDynamic(path, prop, objects)
+	}
+}
+
+func toDynamicSetting(x interface{}) string {
+	switch v := x.(type) {
+	case bool:
+		if v {
+			return DYNAMIC_TRUE
+		}
+		return DYNAMIC_FALSE
+	case string:
+		return strings.ToLower(v)
+	default:
+		return ""
+	}
+}
+
+func toStringOrList(x interface{}) []string {
+	if s, ok := x.(string); ok {
+		return []string{s}
+	}
+	return toStringList(x)
+}
+
+func replacePlaceholders(x interface{}, name, dynamicType string) interface{} {
+	switch v := x.(type) {
+	case string:
+		return strings.NewReplacer("{name}", name, "{dynamic_type}", dynamicType).Replace(v)
+	case jsonObject:
+		var res = make(jsonObject, len(v))
+		for key, value := range v {
+			res[key] = replacePlaceholders(value, name, dynamicType)
+		}
+		return res
+	case []interface{}:
+		var res = make([]interface{}, len(v))
+		for i, value := range v {
+			res[i] = replacePlaceholders(value, name, dynamicType)
+		}
+		return res
+	default:
+		return x
+	}
+}
@@ -0,0 +1,261 @@
+package convert
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	mapping "github.com/zhuliquan/es-mapping"
+)
+
+var dynamicMappingData = []byte(`{
+  "dynamic_templates": [
+    {"ids": {"match": "*_id", "unmatch": "user_*", "mapping": {"type": "keyword"}}},
+    {"labels": {"path_match": "labels.*", "match_mapping_type": "string", "mapping": {"type": "keyword", "ignore_above": 256}}},
+    {"metrics": {"path_match": "metrics.*", "match_mapping_type": ["long", "double"], "mapping": {"type": "double"}}},
+    {"regex": {"match_pattern": "regex", "match": "^ts_\\d+$", "mapping": {"type": "date", "format": "epoch_millis"}}},
+    {"strings": {"match_mapping_type": "string", "path_unmatch": "strict.*", "mapping": {"type": "text", "fields": {"raw": {"type": "{dynamic_type}"}}}}},
+    {"others": {"match_mapping_type": "*", "unmatch_mapping_type": "string", "path_unmatch": "labels.*", "mapping": {"type": "{dynamic_type}"}}}
+  ],
+  "properties": {
+    "status": {"type": "keyword"},
+    "labels": {"type": "object"},
+    "strict": {"type": "object", "dynamic": "strict", "properties": {"inner": {"properties": {"x": {"type": "keyword"}}}}},
+    "disabled": {"dynamic": false, "properties": {"enabled": {"type": "object", "dynamic": true}}}
+  }
+}`)
+
+func TestDynamicTemplateMatches(t *testing.T) {
+	dm, err := LoadDynamicMapping(dynamicMappingData)
+	assert.Nil(t, err)
+	var templates = map[string]*DynamicTemplate{}
+	for _, template := range dm.templates {
+		templates[template.Name] = template
+	}
+
+	for _, tt := range []struct {
+		name        string
+		template    string
+		path        string
+		mappingType string
+		want        bool
+	}{
+		{"match", "ids", "order_id", STRING_MAPPING_TYPE, true},
+		{"match_nested_name", "ids", "order.order_id", LONG_MAPPING_TYPE, true},
+		{"unmatch", "ids", "user_id", STRING_MAPPING_TYPE, false},
+		{"path_match", "labels", "labels.team", STRING_MAPPING_TYPE, true},
+		{"path_match_mapping_type_mismatch", "labels", "labels.team", LONG_MAPPING_TYPE, false},
+		{"path_mismatch", "labels", "team", STRING_MAPPING_TYPE, false},
+		{"mapping_type_list", "metrics", "metrics.cpu", DOUBLE_MAPPING_TYPE, true},
+		{"regex", "regex", "ts_1", LONG_MAPPING_TYPE, true},
+		{"regex_mismatch", "regex", "ts_a", LONG_MAPPING_TYPE, false},
+		{"path_unmatch", "strings", "strict.a", STRING_MAPPING_TYPE, false},
+		{"any_mapping_type", "others", "x", BOOLEAN_MAPPING_TYPE, true},
+		{"unmatch_mapping_type", "others", "x", STRING_MAPPING_TYPE, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, templates[tt.template].Matches(tt.path, tt.mappingType))
+		})
+	}
+}
+
+func TestDynamicMappingResolveProperty(t *testing.T) {
+	dm, err := LoadDynamicMapping(dynamicMappingData)
+	assert.Nil(t, err)
+
+	for _, tt := range []struct {
+		name         string
+		field        string
+		mappingTypes []string
+		want         *mapping.Property
+		wantErr      bool
+	}{
+		{
+			name:         "template",
+			field:        "labels.team",
+			mappingTypes: []string{STRING_MAPPING_TYPE},
+			want:         &mapping.Property{Type: mapping.KEYWORD_FIELD_TYPE},
+		},
+		{
+			name:         "fallback_to_string",
+			field:        "labels.count",
+			mappingTypes: mappingTypesOfValue("5"),
+			want:         &mapping.Property{Type: mapping.KEYWORD_FIELD_TYPE},
+		},
+		{
+			name:         "more_specific_type_first",
+			field:        "metrics.cpu",
+			mappingTypes: mappingTypesOfValue("0.5"),
+			want:         &mapping.Property{Type: mapping.DOUBLE_FIELD_TYPE},
+		},
+		{
+			name:         "dynamic_type_placeholder",
+			field:        "message",
+			mappingTypes: []string{STRING_MAPPING_TYPE},
+			want: &mapping.Property{Type: mapping.TEXT_FIELD_TYPE, Fields: map[string]*mapping.Property{
+				"raw": {Type: mapping.TEXT_FIELD_TYPE},
+			}},
+		},
+		{
+			name:         "dynamic_type_of_long",
+			field:        "count",
+			mappingTypes: mappingTypesOfValue("5"),
+			want:         &mapping.Property{Type: mapping.LONG_FIELD_TYPE},
+		},
+		{
+			name:         "inherit_dynamic_of_enabled_object",
+			field:        "disabled.enabled.x",
+			mappingTypes: []string{BOOLEAN_MAPPING_TYPE},
+			want:         &mapping.Property{Type: mapping.BOOLEAN_FIELD_TYPE},
+		},
+		{
+			name:         "object_field",
+			field:        "labels",
+			mappingTypes: []string{STRING_MAPPING_TYPE},
+		},
+		{
+			name:         "strict",
+			field:        "strict.inner.y",
+			mappingTypes: []string{STRING_MAPPING_TYPE},
+			wantErr:      true,
+		},
+		{
+			name:         "disabled",
+			field:        "disabled.x",
+			mappingTypes: []string{STRING_MAPPING_TYPE},
+			wantErr:      true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			prop, err := dm.ResolveProperty(tt.field, tt.mappingTypes)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+			if tt.want == nil {
+				assert.Nil(t, prop)
+			} else if assert.NotNil(t, prop) {
+				assert.Equal(t, tt.want.Type, prop.Type)
+				assert.Equal(t, len(tt.want.Fields), len(prop.Fields))
+				for name, sub := range tt.want.Fields {
+					if assert.Contains(t, prop.Fields, name) {
+						assert.Equal(t, sub.Type, prop.Fields[name].Type)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDynamicMappingDefaultProperty(t *testing.T) {
+	dm, err := LoadDynamicMapping([]byte(`{"date_detection": false, "properties": {"rt": {"type": "object", "dynamic": "runtime"}}}`))
+	assert.Nil(t, err)
+
+	for _, tt := range []struct {
+		name         string
+		field        string
+		mappingTypes []string
+		want         *mapping.Property
+	}{
+		{
+			name:         "string",
+			field:        "message",
+			mappingTypes: []string{STRING_MAPPING_TYPE},
+			want: &mapping.Property{Type: mapping.TEXT_FIELD_TYPE, Fields: map[string]*mapping.Property{
+				"keyword": {Type: mapping.KEYWORD_FIELD_TYPE},
+			}},
+		},
+		{"long", "count", mappingTypesOfValue("5"), &mapping.Property{Type: mapping.LONG_FIELD_TYPE}},
+		{"double", "ratio", mappingTypesOfValue("0.5"), &mapping.Property{Type: mapping.FLOAT_FIELD_TYPE}},
+		{"boolean", "enabled", mappingTypesOfValue("true"), &mapping.Property{Type: mapping.BOOLEAN_FIELD_TYPE}},
+		{"date_not_detected", "day", mappingTypesOfValue("2021-01-01"), &mapping.Property{
+			Type: mapping.TEXT_FIELD_TYPE, Fields: map[string]*mapping.Property{"keyword": {Type: mapping.KEYWORD_FIELD_TYPE}},
+		}},
+		{"runtime_string", "rt.message", []string{STRING_MAPPING_TYPE}, &mapping.Property{Type: mapping.KEYWORD_FIELD_TYPE}},
+		{"runtime_double", "rt.ratio", mappingTypesOfValue("0.5"), &mapping.Property{Type: mapping.DOUBLE_FIELD_TYPE}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			prop, err := dm.ResolveProperty(tt.field, tt.mappingTypes)
+			assert.Nil(t, err)
+			if assert.NotNil(t, prop) {
+				assert.Equal(t, tt.want.Type, prop.Type)
+				assert.Equal(t, len(tt.want.Fields), len(prop.Fields))
+				for name, sub := range tt.want.Fields {
+					if assert.Contains(t, prop.Fields, name) {
+						assert.Equal(t, sub.Type, prop.Fields[name].Type)
+					}
+				}
+			}
+		})
+	}
+
+	prop, err := dm.ResolveProperty("day", []string{DATE_MAPPING_TYPE})
+	assert.Nil(t, err)
+	assert.Nil(t, prop)
+}
+
+func TestDynamicMappingDynamic(t *testing.T) {
+	dm, err := LoadDynamicMapping([]byte(`{"dynamic": "strict", "date_detection": false, "properties": {"a": {"dynamic": "true", "properties": {}}}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, DYNAMIC_STRICT, dm.Dynamic("x"))
+	assert.Equal(t, DYNAMIC_TRUE, dm.Dynamic("a.x"))
+	assert.Equal(t, DYNAMIC_TRUE, dm.Dynamic("a.b.x"))
+	assert.False(t, dm.dateDetected)
+
+	dm, err = LoadDynamicMapping([]byte(`{"properties": {}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, DYNAMIC_TRUE, dm.Dynamic("x"))
+	assert.True(t, dm.dateDetected)
+}
+
+func TestLoadDynamicMapping(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "mapping_response", data: `{"logs-1": {"mappings": {"dynamic_templates": [{"a": {"match": "*", "mapping": {"type": "keyword"}}}]}}}`},
+		{name: "runtime_template", data: `{"dynamic_templates": [{"a": {"match": "*", "runtime": {"type": "keyword"}}}]}`},
+		{name: "without_mapping", data: `{"dynamic_templates": [{"a": {"match": "*"}}]}`, wantErr: true},
+		{name: "multiple_names", data: `{"dynamic_templates": [{"a": {"mapping": {}}, "b": {"mapping": {}}}]}`, wantErr: true},
+		{name: "invalid_match_pattern", data: `{"dynamic_templates": [{"a": {"match_pattern": "glob", "mapping": {}}}]}`, wantErr: true},
+		{name: "invalid_regex", data: `{"dynamic_templates": [{"a": {"match_pattern": "regex", "match": "(", "mapping": {}}}]}`, wantErr: true},
+		{name: "unrecognised_payload", data: `{"a": 1, "b": 2}`, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dm, err := LoadDynamicMapping([]byte(tt.data))
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				assert.Nil(t, dm)
+			} else {
+				assert.Nil(t, err)
+				assert.NotNil(t, dm)
+			}
+		})
+	}
+}
+
+func TestLoadIndexTemplateDynamicMapping(t *testing.T) {
+	var components = []byte(`{
+	  "base": {"template": {"mappings": {"dynamic_templates": [
+	    {"strings": {"match_mapping_type": "string", "mapping": {"type": "text"}}},
+	    {"longs": {"match_mapping_type": "long", "mapping": {"type": "long"}}}
+	  ]}}}
+	}`)
+	dm, err := LoadIndexTemplateDynamicMapping([]byte(`{"composed_of": ["base"], "template": {"mappings": {
+	  "dynamic_templates": [
+	    {"labels": {"path_match": "labels.*", "mapping": {"type": "keyword"}}},
+	    {"strings": {"match_mapping_type": "string", "mapping": {"type": "keyword"}}}
+	  ]
+	}}}`), components)
+	assert.Nil(t, err)
+	var names = []string{}
+	for _, template := range dm.templates {
+		names = append(names, template.Name)
+	}
+	assert.Equal(t, []string{"strings", "longs", "labels"}, names)
+
+	prop, err := dm.ResolveProperty("message", []string{STRING_MAPPING_TYPE})
+	assert.Nil(t, err)
+	assert.Equal(t, mapping.KEYWORD_FIELD_TYPE, prop.Type)
+}
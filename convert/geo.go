@@ -0,0 +1,154 @@
+package convert
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	mapping "github.com/zhuliquan/es-mapping"
+	"github.com/zhuliquan/lucene-to-dsl/dsl"
+	lucene "github.com/zhuliquan/lucene_parser"
+	term "github.com/zhuliquan/lucene_parser/term"
+)
+
+// geoDistanceUnits are distance units supported by es
+var geoDistanceUnits = map[string]bool{
+	"mi": true, "miles": true,
+	"yd": true, "yards": true,
+	"ft": true, "feet": true,
+	"in": true, "inch": true,
+	"km": true, "kilometers": true,
+	"m": true, "meters": true,
+	"cm": true, "centimeters": true,
+	"mm": true, "millimeters": true,
+	"NM": true, "nmi": true, "nauticalmiles": true,
+}
+
+var (
+	// geoDistanceTermRegexp matches phrase of distance query, i.e. "40.7,-74.0 within 5km"
+	geoDistanceTermRegexp = regexp.MustCompile(`^\s*(.+?)\s+(?i:within)\s+(.+?)\s*$`)
+	// geoDistanceRegexp matches positive distance with unit, i.e. "5km", "1.5 mi"
+	geoDistanceRegexp = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([a-zA-Z]+)$`)
+)
+
+// geoQueryToAstNode converts query on geo_point / geo_shape field, range `[lat,lon TO lat,lon]` of south west and
+// north east corners is converted to geo_bounding_box query (geo_shape query of envelope for geo_shape field),
+// phrase `"lat,lon within <distance>"` is converted to geo_distance query
+func (c *converter) geoQueryToAstNode(q *lucene.FieldQuery, property *mapping.Property) (dsl.AstNode, error) {
+	var (
+		field    = q.Field.String()
+		termType = q.Term.GetTermType()
+		boost    = dsl.WithBoost(q.Term.Boost().Float())
+		node     dsl.AstNode
+	)
+	if termType&term.GROUP_TERM_TYPE == term.GROUP_TERM_TYPE {
+		return c.convertToGroup(q.Field, q.Term, property)
+	} else if termType&term.RANGE_TERM_TYPE == term.RANGE_TERM_TYPE {
+		southWest, northEast, err := parseGeoBoundingBox(q.Term.GetBound())
+		if err != nil {
+			return nil, fmt.Errorf("field: %s is %s field, err: %v", field, property.Type, err)
+		}
+		var topLeft = dsl.GeoPoint{Lat: northEast.Lat, Lon: southWest.Lon}
+		var bottomRight = dsl.GeoPoint{Lat: southWest.Lat, Lon: northEast.Lon}
+		if property.Type == GEO_SHAPE_FIELD_TYPE {
+			node = dsl.NewGeoShapeNode(dsl.NewFieldNode(dsl.NewLfNode(), field), topLeft, bottomRight, boost)
+		} else {
+			node = dsl.NewGeoBoundingBoxNode(dsl.NewFieldNode(dsl.NewLfNode(), field), topLeft, bottomRight, boost)
+		}
+	} else if termType&term.PHRASE_TERM_TYPE == term.PHRASE_TERM_TYPE {
+		strVal, _ := q.Term.Value(convertToString)
+		point, distance, err := parseGeoDistanceTerm(strVal.(string))
+		if err != nil {
+			return nil, fmt.Errorf("field: %s is %s field, err: %v", field, property.Type, err)
+		}
+		node = dsl.NewGeoDistanceNode(dsl.NewFieldNode(dsl.NewLfNode(), field), point, distance, boost)
+	} else if strVal, _ := q.Term.Value(convertToString); termType&term.SINGLE_TERM_TYPE == term.SINGLE_TERM_TYPE && strVal == "*" {
+		return c.convertToSingle(q.Field, q.Term, property)
+	} else {
+		return nil, fmt.Errorf("field: %s is %s field, which supports bounding box `[lat,lon TO lat,lon]` "+
+			"and distance `\"lat,lon within <distance>\"` queries only, but got: %s", field, property.Type, q.Term.String())
+	}
+	c.applyFilterCtx(node, field)
+	return node, nil
+}
+
+// parseGeoBoundingBox parses south west and north east corners of bounding box from inclusive range,
+// box crosses the dateline if lon of south west corner is greater than lon of north east corner
+func parseGeoBoundingBox(bound *term.Bound) (dsl.GeoPoint, dsl.GeoPoint, error) {
+	var southWest, northEast dsl.GeoPoint
+	if bound.LeftValue.IsInf(-1) || bound.RightValue.IsInf(1) {
+		return southWest, northEast, fmt.Errorf("bounding box expects both of south west and north east corners")
+	}
+	if !bound.LeftInclude || !bound.RightInclude {
+		return southWest, northEast, fmt.Errorf("bounding box expects inclusive range `[lat,lon TO lat,lon]`")
+	}
+	var err error
+	if southWest, err = parseGeoPoint(bound.LeftValue.String()); err != nil {
+		return southWest, northEast, err
+	}
+	if northEast, err = parseGeoPoint(bound.RightValue.String()); err != nil {
+		return southWest, northEast, err
+	}
+	if southWest.Lat > northEast.Lat {
+		return southWest, northEast, fmt.Errorf("lat: %v of south west corner is greater than lat: %v of north east corner",
+			southWest.Lat, northEast.Lat)
+	}
+	return southWest, northEast, nil
+}
+
+// parseGeoDistanceTerm parses point and distance from phrase `lat,lon within <distance>`
+func parseGeoDistanceTerm(s string) (dsl.GeoPoint, string, error) {
+	var match = geoDistanceTermRegexp.FindStringSubmatch(s)
+	if match == nil {
+		return dsl.GeoPoint{}, "", fmt.Errorf("distance query: %q is invalid, expect `lat,lon within <distance>`", s)
+	}
+	point, err := parseGeoPoint(match[1])
+	if err != nil {
+		return dsl.GeoPoint{}, "", err
+	}
+	distance, err := parseGeoDistance(match[2])
+	if err != nil {
+		return dsl.GeoPoint{}, "", err
+	}
+	return point, distance, nil
+}
+
+// parseGeoPoint parses point of form `lat,lon` and validates its coordinates
+func parseGeoPoint(s string) (dsl.GeoPoint, error) {
+	var parts = strings.Split(strings.Trim(s, `"`), ",")
+	if len(parts) != 2 {
+		return dsl.GeoPoint{}, fmt.Errorf("geo point: %s is invalid, expect `lat,lon`", s)
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return dsl.GeoPoint{}, fmt.Errorf("geo point: %s has invalid lat, err: %v", s, err)
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return dsl.GeoPoint{}, fmt.Errorf("geo point: %s has invalid lon, err: %v", s, err)
+	}
+	// negated comparisons reject NaN as well
+	if !(lat >= -90 && lat <= 90) {
+		return dsl.GeoPoint{}, fmt.Errorf("geo point: %s has lat out of range [-90, 90]", s)
+	}
+	if !(lon >= -180 && lon <= 180) {
+		return dsl.GeoPoint{}, fmt.Errorf("geo point: %s has lon out of range [-180, 180]", s)
+	}
+	return dsl.GeoPoint{Lat: lat, Lon: lon}, nil
+}
+
+// parseGeoDistance validates distance with unit supported by es, and returns it without spaces, i.e. "5km"
+func parseGeoDistance(s string) (string, error) {
+	var match = geoDistanceRegexp.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return "", fmt.Errorf("distance: %s is invalid, expect positive number with unit, i.e. 5km", s)
+	}
+	if !geoDistanceUnits[match[2]] {
+		return "", fmt.Errorf("distance: %s has unsupported unit: %s", s, match[2])
+	}
+	if value, _ := strconv.ParseFloat(match[1], 64); value <= 0 {
+		return "", fmt.Errorf("distance: %s must be positive", s)
+	}
+	return match[1] + match[2], nil
+}
@@ -0,0 +1,78 @@
+package convert
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zhuliquan/lucene-to-dsl/dsl"
+)
+
+func TestParseGeoPoint(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		value   string
+		want    dsl.GeoPoint
+		wantErr bool
+	}{
+		{name: "point", value: "40.7,-74.0", want: dsl.GeoPoint{Lat: 40.7, Lon: -74}},
+		{name: "spaces", value: " 40.7 , -74.0 ", want: dsl.GeoPoint{Lat: 40.7, Lon: -74}},
+		{name: "quoted", value: `"-90,180"`, want: dsl.GeoPoint{Lat: -90, Lon: 180}},
+		{name: "missing_lon", value: "40.7", wantErr: true},
+		{name: "extra_coordinate", value: "1,2,3", wantErr: true},
+		{name: "invalid_lat", value: "a,1", wantErr: true},
+		{name: "invalid_lon", value: "1,b", wantErr: true},
+		{name: "lat_out_of_range", value: "-90.1,0", wantErr: true},
+		{name: "lon_out_of_range", value: "0,180.5", wantErr: true},
+		{name: "nan", value: "NaN,0", wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseGeoPoint(tt.value)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseGeoDistance(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "km", value: "5km", want: "5km"},
+		{name: "space_before_unit", value: "1.5 mi", want: "1.5mi"},
+		{name: "nautical_miles", value: "2NM", want: "2NM"},
+		{name: "missing_unit", value: "5", wantErr: true},
+		{name: "unknown_unit", value: "5 parsecs", wantErr: true},
+		{name: "case_sensitive_unit", value: "5KM", wantErr: true},
+		{name: "negative", value: "-5km", wantErr: true},
+		{name: "zero", value: "0.0m", wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseGeoDistance(tt.value)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseGeoDistanceTerm(t *testing.T) {
+	point, distance, err := parseGeoDistanceTerm("40.7, -74.0 Within 10 yd")
+	assert.Nil(t, err)
+	assert.Equal(t, dsl.GeoPoint{Lat: 40.7, Lon: -74}, point)
+	assert.Equal(t, "10yd", distance)
+
+	for _, value := range []string{"40.7,-74.0", "within 5km", "40.7,-74.0 near 5km", "40.7,-74.0 within 5km 1km"} {
+		_, _, err := parseGeoDistanceTerm(value)
+		assert.NotNil(t, err, value)
+	}
+}
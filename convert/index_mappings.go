@@ -0,0 +1,116 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	mapping "github.com/zhuliquan/es-mapping"
+)
+
+// IndexMappings combines es mappings of multiple indices (i.e. indices matched by `logs-*`),
+// field is resolved in every index and grouped by its property, so that a field mapped as
+// different types in different indices is converted into a clause per type
+type IndexMappings struct {
+	indices  []string
+	mappings map[string]*mapping.PropertyMapping
+}
+
+// FieldIndices is property of field shared by indices
+type FieldIndices struct {
+	Field    string
+	Property *mapping.Property
+	Indices  []string
+}
+
+// NewIndexMappings combines mappings keyed by index name
+func NewIndexMappings(mappings map[string]*mapping.PropertyMapping) *IndexMappings {
+	var m = &IndexMappings{mappings: make(map[string]*mapping.PropertyMapping, len(mappings))}
+	for index, pm := range mappings {
+		if pm != nil {
+			m.indices = append(m.indices, index)
+			m.mappings[index] = pm
+		}
+	}
+	sort.Strings(m.indices)
+	return m
+}
+
+// LoadIndexMappingsData loads response of es `GET <index-pattern>/_mapping` api,
+// i.e. `{"logs-1": {"mappings": {"properties": {...}}}, "logs-2": {...}}`
+func LoadIndexMappingsData(data []byte) (*IndexMappings, error) {
+	var resp map[string]struct {
+		Mappings json.RawMessage `json:"mappings"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse mapping response, err: %v", err)
+	}
+	var mappings = make(map[string]*mapping.PropertyMapping, len(resp))
+	for index, body := range resp {
+		if len(body.Mappings) == 0 {
+			return nil, fmt.Errorf("index: %s has no mappings", index)
+		}
+		pm, err := LoadMappingPayload(body.Mappings)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load mapping of index: %s, err: %v", index, err)
+		}
+		mappings[index] = pm
+	}
+	return NewIndexMappings(mappings), nil
+}
+
+// Indices returns sorted names of indices
+func (m *IndexMappings) Indices() []string {
+	return m.indices
+}
+
+// Mappings returns mappings keyed by index name
+func (m *IndexMappings) Mappings() map[string]*mapping.PropertyMapping {
+	return m.mappings
+}
+
+// GetFieldIndices resolves field (wildcard is supported) in every index, keyed sub path of flattened field is resolved
+// to property of flattened field, indices having same property of field are grouped together, groups are ordered
+// by field and first index of group
+func (m *IndexMappings) GetFieldIndices(field string) ([]*FieldIndices, error) {
+	var groups = map[string]*FieldIndices{}
+	for _, index := range m.indices {
+		props, err := m.mappings[index].GetProperty(field)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get property of field: %s in index: %s, err: %v", field, index, err)
+		}
+		if len(props) == 0 {
+			// field may be keyed sub path of flattened field (i.e. `labels.app` of flattened `labels`)
+			prop, err := flattenedProperty(m.mappings[index], field)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get property of field: %s in index: %s, err: %v", field, index, err)
+			} else if prop != nil {
+				props = map[string]*mapping.Property{field: prop}
+			}
+		}
+		for key, prop := range props {
+			data, err := json.Marshal(prop)
+			if err != nil {
+				return nil, err
+			}
+			var groupKey = key + "\x00" + string(data)
+			if group, ok := groups[groupKey]; ok {
+				group.Indices = append(group.Indices, index)
+			} else {
+				groups[groupKey] = &FieldIndices{Field: key, Property: prop, Indices: []string{index}}
+			}
+		}
+	}
+
+	var res = make([]*FieldIndices, 0, len(groups))
+	for _, group := range groups {
+		res = append(res, group)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Field != res[j].Field {
+			return res[i].Field < res[j].Field
+		}
+		return res[i].Indices[0] < res[j].Indices[0]
+	})
+	return res, nil
+}
@@ -0,0 +1,99 @@
+package convert
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	mapping "github.com/zhuliquan/es-mapping"
+)
+
+var indexMappingsResponse = []byte(`{
+  "logs-1": {"mappings": {"properties": {"x": {"type": "keyword"}, "y": {"type": "text"}, "z": {"type": "keyword"}, "labels": {"type": "flattened"}}}},
+  "logs-2": {"mappings": {"properties": {"x": {"type": "long"}, "y": {"type": "text"}, "labels": {"properties": {"app": {"type": "keyword"}}}}}},
+  "logs-3": {"mappings": {"properties": {"x": {"type": "long"}, "y": {"type": "text"}}}}
+}`)
+
+func TestLoadIndexMappingsData(t *testing.T) {
+	im, err := LoadIndexMappingsData(indexMappingsResponse)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"logs-1", "logs-2", "logs-3"}, im.Indices())
+
+	_, err = LoadIndexMappingsData([]byte(`{"logs-1":`))
+	assert.NotNil(t, err)
+	_, err = LoadIndexMappingsData([]byte(`{"logs-1": {"settings": {}}}`))
+	assert.NotNil(t, err)
+}
+
+func TestNewIndexMappings(t *testing.T) {
+	pm, err := mapping.LoadMappingData([]byte(`{"properties": {"x": {"type": "keyword"}}}`))
+	assert.Nil(t, err)
+	var im = NewIndexMappings(map[string]*mapping.PropertyMapping{"b": pm, "a": pm, "c": nil})
+	assert.Equal(t, []string{"a", "b"}, im.Indices())
+}
+
+func TestGetFieldIndices(t *testing.T) {
+	im, err := LoadIndexMappingsData(indexMappingsResponse)
+	assert.Nil(t, err)
+
+	type want struct {
+		field   string
+		mType   mapping.FieldType
+		indices []string
+	}
+	for _, tt := range []struct {
+		name  string
+		field string
+		want  []want
+	}{
+		{
+			name:  "same_type",
+			field: "y",
+			want:  []want{{"y", mapping.TEXT_FIELD_TYPE, []string{"logs-1", "logs-2", "logs-3"}}},
+		},
+		{
+			name:  "different_types",
+			field: "x",
+			want: []want{
+				{"x", mapping.KEYWORD_FIELD_TYPE, []string{"logs-1"}},
+				{"x", mapping.LONG_FIELD_TYPE, []string{"logs-2", "logs-3"}},
+			},
+		},
+		{
+			name:  "partial_indices",
+			field: "z",
+			want:  []want{{"z", mapping.KEYWORD_FIELD_TYPE, []string{"logs-1"}}},
+		},
+		{
+			name:  "wildcard",
+			field: "[xz]",
+			want: []want{
+				{"x", mapping.KEYWORD_FIELD_TYPE, []string{"logs-1"}},
+				{"x", mapping.LONG_FIELD_TYPE, []string{"logs-2", "logs-3"}},
+				{"z", mapping.KEYWORD_FIELD_TYPE, []string{"logs-1"}},
+			},
+		},
+		{
+			name:  "flattened_sub_path",
+			field: "labels.app",
+			want: []want{
+				{"labels.app", FLATTENED_FIELD_TYPE, []string{"logs-1"}},
+				{"labels.app", mapping.KEYWORD_FIELD_TYPE, []string{"logs-2"}},
+			},
+		},
+		{
+			name:  "missing",
+			field: "w",
+			want:  []want{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, err := im.GetFieldIndices(tt.field)
+			assert.Nil(t, err)
+			var got = []want{}
+			for _, group := range groups {
+				got = append(got, want{group.Field, group.Property.Type, group.Indices})
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
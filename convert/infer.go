@@ -2,11 +2,13 @@ package convert
 
 import (
 	"net"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	mapping "github.com/zhuliquan/es-mapping"
+	term "github.com/zhuliquan/lucene_parser/term"
 )
 
 var dateFormats = []string{
@@ -18,6 +20,16 @@ var dateFormats = []string{
 	"02/01/2006",
 }
 
+// esDateFormats are es formats of date layouts in dateFormats
+var esDateFormats = map[string]string{
+	"2006-01-02":                DEFAULT_DATE_FORMAT,
+	"2006-01-02T15:04:05":       DEFAULT_DATE_FORMAT,
+	"2006-01-02T15:04:05Z07:00": DEFAULT_DATE_FORMAT,
+	"2006/01/02":                "yyyy/MM/dd",
+	"01/02/2006":                "MM/dd/yyyy",
+	"02/01/2006":                "dd/MM/yyyy",
+}
+
 // InferFieldType infers field type based on the value content.
 // Returns keyword as default for most cases.
 func InferFieldType(value string) mapping.FieldType {
@@ -42,6 +54,69 @@ func InferFieldType(value string) mapping.FieldType {
 	return mapping.KEYWORD_FIELD_TYPE
 }
 
+// InferNumericFieldType infers field type as InferFieldType does, except that integers are inferred as long
+// and decimals are inferred as double, so that values are compared numerically
+func InferNumericFieldType(value string) mapping.FieldType {
+	var trimmed = strings.Trim(value, "\"'")
+	if isBoolean(trimmed) {
+		return mapping.BOOLEAN_FIELD_TYPE
+	} else if isInteger(trimmed) {
+		return mapping.LONG_FIELD_TYPE
+	} else if isFloat(trimmed) {
+		return mapping.DOUBLE_FIELD_TYPE
+	}
+	return InferFieldType(value)
+}
+
+// inferRangeFieldType infers field type from bounds of range, infinite bound is skipped, date math bound is date,
+// bounds of integer and decimal are inferred as double, and bounds of other different types are inferred as keyword
+func inferRangeFieldType(bound *term.Bound, infer func(string) mapping.FieldType) mapping.FieldType {
+	var types []mapping.FieldType
+	if bound.LeftValue != nil && !bound.LeftValue.IsInf(-1) {
+		types = append(types, inferBoundFieldType(bound.LeftValue.String(), infer))
+	}
+	if bound.RightValue != nil && !bound.RightValue.IsInf(1) {
+		types = append(types, inferBoundFieldType(bound.RightValue.String(), infer))
+	}
+	switch {
+	case len(types) == 0:
+		return mapping.KEYWORD_FIELD_TYPE
+	case len(types) == 1 || types[0] == types[1]:
+		return types[0]
+	case isNumericFieldType(types[0]) && isNumericFieldType(types[1]):
+		return mapping.DOUBLE_FIELD_TYPE
+	default:
+		return mapping.KEYWORD_FIELD_TYPE
+	}
+}
+
+// inferBoundFieldType infers field type of range bound as infer does, except that date math
+// (i.e. `now-1d/d` and `2024-01-01||+1M`) is inferred as date
+func inferBoundFieldType(value string, infer func(string) mapping.FieldType) mapping.FieldType {
+	if isDateMath(value) {
+		return mapping.DATE_FIELD_TYPE
+	}
+	return infer(value)
+}
+
+// dateMathPattern matches math part of date math, i.e. `+1M-1d/d` of `now+1M-1d/d`
+var dateMathPattern = regexp.MustCompile(`^([+-]\d+[yMwdhHms]|/[yMwdhHms])*$`)
+
+// isDateMath checks whether value is date math anchored at `now` or at date followed by `||`
+func isDateMath(value string) bool {
+	value = strings.Trim(value, "\"'")
+	if strings.HasPrefix(value, "now") {
+		return dateMathPattern.MatchString(value[len("now"):])
+	} else if idx := strings.Index(value, "||"); idx > 0 {
+		return isDate(value[:idx]) && dateMathPattern.MatchString(value[idx+len("||"):])
+	}
+	return false
+}
+
+func isNumericFieldType(t mapping.FieldType) bool {
+	return t == mapping.LONG_FIELD_TYPE || t == mapping.DOUBLE_FIELD_TYPE
+}
+
 func isBoolean(value string) bool {
 	lower := strings.ToLower(value)
 	return lower == "true" || lower == "false"
@@ -52,7 +127,14 @@ func isInteger(value string) bool {
 	return err == nil
 }
 
+// decimalPattern matches plain decimal float syntax, which excludes `nan`, `inf`, `Infinity` and hex floats
+// accepted by strconv.ParseFloat
+var decimalPattern = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
 func isFloat(value string) bool {
+	if !decimalPattern.MatchString(value) {
+		return false
+	}
 	_, err := strconv.ParseFloat(value, 64)
 	return err == nil
 }
@@ -66,6 +148,16 @@ func isDate(value string) bool {
 	return false
 }
 
+// inferDateFormat returns es format of the first layout of dateFormats parsing value, empty string is returned if none
+func inferDateFormat(value string) string {
+	for _, format := range dateFormats {
+		if _, err := time.Parse(format, value); err == nil {
+			return esDateFormats[format]
+		}
+	}
+	return ""
+}
+
 func isIP(value string) bool {
 	if net.ParseIP(value) != nil {
 		return true
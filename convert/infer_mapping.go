@@ -0,0 +1,223 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	mapping "github.com/zhuliquan/es-mapping"
+)
+
+// IGNORE_ABOVE_KEY is key of max length of keyword values which are indexed
+const IGNORE_ABOVE_KEY = "ignore_above"
+
+// inferredField is field inferred from values of sample documents, it's object field if it has properties
+type inferredField struct {
+	typ     mapping.FieldType
+	formats []string
+	object  bool
+	props   map[string]*inferredField
+}
+
+// InferMappingFromDocuments infers es mapping from sample json documents, see InferMappingDataFromDocuments
+func InferMappingFromDocuments(docs [][]byte) (*mapping.PropertyMapping, error) {
+	data, err := InferMappingDataFromDocuments(docs)
+	if err != nil {
+		return nil, err
+	}
+	return mapping.LoadMappingData(data)
+}
+
+// InferMappingDataFromDocuments infers mapping body (i.e. `{"properties": {...}}`) from sample json documents,
+// leaf values are detected by InferNumericFieldType (strings of numbers are keywords), strings with spaces are texts
+// with keyword sub field as es does, objects are walked recursively, dotted keys are expanded to objects and elements
+// of arrays are values of the same field. types of a field conflicting across samples are resolved to the common type,
+// i.e. long and double are double, texts and other values are text, other values of different types are keywords
+func InferMappingDataFromDocuments(docs [][]byte) ([]byte, error) {
+	var root = &inferredField{object: true, props: map[string]*inferredField{}}
+	for i, doc := range docs {
+		obj, err := decodeJSONObject(doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse document: %d, err: %v", i, err)
+		}
+		if err := root.observe("", obj); err != nil {
+			return nil, fmt.Errorf("failed to infer mapping from document: %d, err: %v", i, err)
+		}
+	}
+	return json.Marshal(jsonObject{PROPERTIES_KEY: root.properties()})
+}
+
+// ParseDocuments parses sample documents from json array of documents or newline-delimited json documents
+func ParseDocuments(data []byte) ([][]byte, error) {
+	var docs [][]byte
+	var decoder = json.NewDecoder(bytes.NewReader(data))
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err == io.EOF {
+			return docs, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse documents, err: %v", err)
+		}
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err == nil {
+			for _, item := range items {
+				docs = append(docs, item)
+			}
+		} else {
+			docs = append(docs, raw)
+		}
+	}
+}
+
+// observe merges value of field at path into inferred field
+func (f *inferredField) observe(path string, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		for _, x := range v {
+			if err := f.observe(path, x); err != nil {
+				return err
+			}
+		}
+		return nil
+	case jsonObject:
+		if f.typ != "" {
+			return fmt.Errorf("field: %s is %s in some documents but object in others", path, f.typ)
+		}
+		f.object = true
+		for key, x := range v {
+			child, err := f.child(path, key)
+			if err != nil {
+				return err
+			}
+			if err := child.observe(joinPath(path, key), x); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		if f.object {
+			return fmt.Errorf("field: %s is object in some documents but value in others", path)
+		}
+		typ, format := inferValueType(v)
+		f.typ = mergeInferredTypes(f.typ, typ)
+		if format != "" && !containsString(f.formats, format) {
+			f.formats = append(f.formats, format)
+		}
+		return nil
+	}
+}
+
+// child returns inferred field of key, dotted key (i.e. `a.b`) is expanded to object fields as es does
+func (f *inferredField) child(path, key string) (*inferredField, error) {
+	var cur = f
+	var names = strings.Split(key, ".")
+	for i, name := range names {
+		if cur.typ != "" {
+			return nil, fmt.Errorf("field: %s is %s in some documents but object in others", path, cur.typ)
+		}
+		cur.object = true
+		if cur.props == nil {
+			cur.props = map[string]*inferredField{}
+		}
+		if _, ok := cur.props[name]; !ok {
+			cur.props[name] = &inferredField{}
+		}
+		cur, path = cur.props[name], joinPath(path, strings.Join(names[:i+1], "."))
+	}
+	return cur, nil
+}
+
+// properties returns mapping of properties, fields having only null values or empty arrays are omitted
+func (f *inferredField) properties() jsonObject {
+	var props = jsonObject{}
+	for name, field := range f.props {
+		if prop := field.property(); prop != nil {
+			props[name] = prop
+		}
+	}
+	return props
+}
+
+func (f *inferredField) property() jsonObject {
+	switch {
+	case f.object && len(f.props) == 0:
+		return jsonObject{"type": "object"}
+	case f.object:
+		return jsonObject{PROPERTIES_KEY: f.properties()}
+	case f.typ == "":
+		return nil
+	case f.typ == mapping.TEXT_FIELD_TYPE:
+		return jsonObject{
+			"type": f.typ,
+			FIELDS_KEY: jsonObject{
+				string(mapping.KEYWORD_FIELD_TYPE): jsonObject{"type": mapping.KEYWORD_FIELD_TYPE, IGNORE_ABOVE_KEY: 256},
+			},
+		}
+	case f.typ == mapping.DATE_FIELD_TYPE:
+		var prop = jsonObject{"type": f.typ}
+		// format is omitted if all values are in default format
+		if len(f.formats) != 1 || f.formats[0] != DEFAULT_DATE_FORMAT {
+			var formats = append([]string{}, f.formats...)
+			sort.Strings(formats)
+			prop["format"] = strings.Join(formats, "||")
+		}
+		return prop
+	default:
+		return jsonObject{"type": f.typ}
+	}
+}
+
+// inferValueType infers field type of json value and es format of date value
+func inferValueType(value interface{}) (mapping.FieldType, string) {
+	switch v := value.(type) {
+	case bool:
+		return mapping.BOOLEAN_FIELD_TYPE, ""
+	case json.Number:
+		return InferNumericFieldType(v.String()), ""
+	case string:
+		var typ = InferFieldType(v)
+		if typ == mapping.DATE_FIELD_TYPE {
+			return typ, inferDateFormat(v)
+		} else if typ == mapping.KEYWORD_FIELD_TYPE && strings.ContainsAny(strings.TrimSpace(v), " \t\n") {
+			return mapping.TEXT_FIELD_TYPE, ""
+		}
+		return typ, ""
+	default:
+		return mapping.KEYWORD_FIELD_TYPE, ""
+	}
+}
+
+// mergeInferredTypes resolves type of field whose values are inferred as different types in samples
+func mergeInferredTypes(a, b mapping.FieldType) mapping.FieldType {
+	switch {
+	case a == "" || a == b:
+		return b
+	case isNumericFieldType(a) && isNumericFieldType(b):
+		return mapping.DOUBLE_FIELD_TYPE
+	case a == mapping.TEXT_FIELD_TYPE || b == mapping.TEXT_FIELD_TYPE:
+		return mapping.TEXT_FIELD_TYPE
+	default:
+		return mapping.KEYWORD_FIELD_TYPE
+	}
+}
+
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
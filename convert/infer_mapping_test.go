@@ -0,0 +1,104 @@
+package convert
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	mapping "github.com/zhuliquan/es-mapping"
+)
+
+func TestInferMappingDataFromDocuments(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		docs    []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "leaf_types",
+			docs: []string{`{"a": 1, "b": 1.5, "c": true, "d": "x", "e": "2021-01-01T00:00:00Z", "f": "127.0.0.1", "g": "10"}`},
+			want: `{"properties":{"a":{"type":"long"},"b":{"type":"double"},"c":{"type":"boolean"},"d":{"type":"keyword"},` +
+				`"e":{"type":"date"},"f":{"type":"ip"},"g":{"type":"keyword"}}}`,
+		},
+		{
+			name: "text_with_keyword_sub_field",
+			docs: []string{`{"msg": "hello world"}`},
+			want: `{"properties":{"msg":{"fields":{"keyword":{"ignore_above":256,"type":"keyword"}},"type":"text"}}}`,
+		},
+		{
+			name: "date_formats",
+			docs: []string{`{"a": "2021/01/02", "b": "2021-01-02"}`, `{"a": "2021-01-02"}`},
+			want: `{"properties":{"a":{"format":"strict_date_optional_time||yyyy/MM/dd","type":"date"},"b":{"type":"date"}}}`,
+		},
+		{
+			name: "nested_objects_and_dotted_keys",
+			docs: []string{`{"user": {"name": "x", "geo": {"city": "y"}}}`, `{"user.age": 3, "meta": {}}`},
+			want: `{"properties":{"meta":{"type":"object"},"user":{"properties":{"age":{"type":"long"},` +
+				`"geo":{"properties":{"city":{"type":"keyword"}}},"name":{"type":"keyword"}}}}}`,
+		},
+		{
+			name: "arrays",
+			docs: []string{`{"tags": ["a", "b"], "items": [{"id": 1}, {"id": 2.5}], "empty": []}`},
+			want: `{"properties":{"items":{"properties":{"id":{"type":"double"}}},"tags":{"type":"keyword"}}}`,
+		},
+		{
+			name: "conflicting_types",
+			docs: []string{`{"a": 1, "b": 1, "c": "x", "d": null}`, `{"a": 2.5, "b": "x", "c": "x y"}`},
+			want: `{"properties":{"a":{"type":"double"},"b":{"type":"keyword"},"c":{"fields":{"keyword":{"ignore_above":256,"type":"keyword"}},"type":"text"}}}`,
+		},
+		{name: "value_then_object", docs: []string{`{"a": 1}`, `{"a": {"b": 1}}`}, wantErr: true},
+		{name: "object_then_value", docs: []string{`{"a": {"b": 1}}`, `{"a": 1}`}, wantErr: true},
+		{name: "value_then_dotted_key", docs: []string{`{"a": 1, "a.b": 1}`}, wantErr: true},
+		{name: "not_object", docs: []string{`[1]`}, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var docs [][]byte
+			for _, doc := range tt.docs {
+				docs = append(docs, []byte(doc))
+			}
+			got, err := InferMappingDataFromDocuments(docs)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestInferMappingFromDocuments(t *testing.T) {
+	pm, err := InferMappingFromDocuments([][]byte{[]byte(`{"user": {"age": 3}}`)})
+	assert.Nil(t, err)
+	prop, err := pm.GetProperty("user.age")
+	assert.Nil(t, err)
+	assert.Equal(t, mapping.LONG_FIELD_TYPE, prop["user.age"].Type)
+}
+
+func TestParseDocuments(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		data    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "json_array", data: `[{"a": 1}, {"b": 2}]`, want: []string{`{"a": 1}`, `{"b": 2}`}},
+		{name: "ndjson", data: "{\"a\": 1}\n{\"b\": 2}\n", want: []string{`{"a": 1}`, `{"b": 2}`}},
+		{name: "empty", data: ""},
+		{name: "invalid_json", data: `{"a": `, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			docs, err := ParseDocuments([]byte(tt.data))
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			var got []string
+			for _, doc := range docs {
+				got = append(got, string(doc))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package convert
+
+import (
+	"fmt"
+	"strings"
+
+	mapping "github.com/zhuliquan/es-mapping"
+	lucene "github.com/zhuliquan/lucene_parser"
+	term "github.com/zhuliquan/lucene_parser/term"
+)
+
+// inferredValue is value of field in query and its inferred type
+type inferredValue struct {
+	value string
+	typ   mapping.FieldType
+}
+
+// inferQueryFieldTypes infers type of every field from all of its values in the whole query when mapping isn't provided,
+// so that clauses of the same field are converted consistently, i.e. `x:5 OR x:2.5` are double, `x:5 OR x:abc`
+// are keyword, field having values of incompatible types (i.e. date and long) is reported as conflict
+func (c *converter) inferQueryFieldTypes(q *lucene.Lucene) (map[string]mapping.FieldType, error) {
+	var values = map[string][]inferredValue{}
+	c.collectInferredValues(q, values)
+	var types = make(map[string]mapping.FieldType, len(values))
+	for field, vals := range values {
+		typ, err := unifyInferredValues(field, vals)
+		if err != nil {
+			return nil, err
+		}
+		types[field] = typ
+	}
+	return types, nil
+}
+
+// collectInferredValues collects values of fields whose types are inferred from query, infinite bounds of range
+// and `*` of exists query are skipped, fields matching inference rules or runtime fields are skipped as well
+func (c *converter) collectInferredValues(q *lucene.Lucene, values map[string][]inferredValue) {
+	if q == nil {
+		return
+	}
+	var orQueries = []*lucene.OrQuery{q.OrQuery}
+	for _, osQuery := range q.OSQuery {
+		orQueries = append(orQueries, osQuery.OrQuery)
+	}
+	for _, orQuery := range orQueries {
+		if orQuery == nil {
+			continue
+		}
+		var andQueries = []*lucene.AndQuery{orQuery.AndQuery}
+		for _, ansQuery := range orQuery.AnSQuery {
+			andQueries = append(andQueries, ansQuery.AndQuery)
+		}
+		for _, andQuery := range andQueries {
+			if andQuery == nil {
+				continue
+			} else if andQuery.ParenQuery != nil {
+				c.collectInferredValues(andQuery.ParenQuery.SubQuery, values)
+			} else if andQuery.FieldQuery != nil {
+				c.collectFieldQueryValues(andQuery.FieldQuery, values)
+			}
+		}
+	}
+}
+
+func (c *converter) collectFieldQueryValues(q *lucene.FieldQuery, values map[string][]inferredValue) {
+	if q.Field == nil || q.Term == nil {
+		return
+	}
+	var field = q.Field.String()
+	if field == EXIST_FIELD || field == "*" || len(c.rf.GetProperty(field)) != 0 ||
+		matchInferenceRules(c.inferenceRules, field) != "" {
+		return
+	}
+
+	var infer = InferFieldType
+	if c.numericInference && !c.isKeywordField(field) {
+		infer = InferNumericFieldType
+	}
+	var add = func(value string) {
+		values[field] = append(values[field], inferredValue{value: value, typ: infer(value)})
+	}
+	var addBound = func(value string) {
+		values[field] = append(values[field], inferredValue{value: value, typ: inferBoundFieldType(value, infer)})
+	}
+
+	var termType = q.Term.GetTermType()
+	if termType&term.GROUP_TERM_TYPE == term.GROUP_TERM_TYPE {
+		c.collectInferredValues(lucene.TermGroupToLucene(q.Field, q.Term.TermGroup), values)
+	} else if termType&term.RANGE_TERM_TYPE == term.RANGE_TERM_TYPE {
+		if bound := q.Term.GetBound(); bound != nil {
+			if bound.LeftValue != nil && !bound.LeftValue.IsInf(-1) {
+				addBound(bound.LeftValue.String())
+			}
+			if bound.RightValue != nil && !bound.RightValue.IsInf(1) {
+				addBound(bound.RightValue.String())
+			}
+		}
+	} else if value := q.Term.String(); value != "*" {
+		add(value)
+	}
+}
+
+// unifyInferredValues resolves one type of all values of field, i.e. long and double are double, keyword and values
+// of any other types are keyword, values of other different types (i.e. date and long) conflict
+func unifyInferredValues(field string, values []inferredValue) (mapping.FieldType, error) {
+	var typ mapping.FieldType
+	var keyword, conflict bool
+	for _, v := range values {
+		keyword = keyword || v.typ == mapping.KEYWORD_FIELD_TYPE
+		if typ == "" || typ == v.typ {
+			typ = v.typ
+		} else if isNumericFieldType(typ) && isNumericFieldType(v.typ) {
+			typ = mapping.DOUBLE_FIELD_TYPE
+		} else {
+			conflict = true
+		}
+	}
+	switch {
+	case !conflict:
+		return typ, nil
+	case keyword:
+		return mapping.KEYWORD_FIELD_TYPE, nil
+	default:
+		return "", fmt.Errorf("field: %s has values of conflicting types in query: %s, "+
+			"provide mapping or inference rule of the field", field, formatInferredValues(values))
+	}
+}
+
+// formatInferredValues formats the first value of each type, i.e. `5 (long), 2024-01-01 (date)`
+func formatInferredValues(values []inferredValue) string {
+	var parts []string
+	var seen = map[mapping.FieldType]bool{}
+	for _, v := range values {
+		if !seen[v.typ] {
+			seen[v.typ] = true
+			parts = append(parts, fmt.Sprintf("%s (%s)", v.value, v.typ))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
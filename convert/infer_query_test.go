@@ -0,0 +1,46 @@
+package convert
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	mapping "github.com/zhuliquan/es-mapping"
+)
+
+func TestUnifyInferredValues(t *testing.T) {
+	var (
+		long    = inferredValue{value: "5", typ: mapping.LONG_FIELD_TYPE}
+		double  = inferredValue{value: "2.5", typ: mapping.DOUBLE_FIELD_TYPE}
+		keyword = inferredValue{value: "abc", typ: mapping.KEYWORD_FIELD_TYPE}
+		date    = inferredValue{value: "2024-01-01", typ: mapping.DATE_FIELD_TYPE}
+		ip      = inferredValue{value: "127.0.0.1", typ: mapping.IP_FIELD_TYPE}
+	)
+	for _, tt := range []struct {
+		name    string
+		values  []inferredValue
+		want    mapping.FieldType
+		wantErr bool
+	}{
+		{name: "single", values: []inferredValue{date}, want: mapping.DATE_FIELD_TYPE},
+		{name: "same", values: []inferredValue{long, long}, want: mapping.LONG_FIELD_TYPE},
+		{name: "long_and_double", values: []inferredValue{long, double, long}, want: mapping.DOUBLE_FIELD_TYPE},
+		{name: "long_and_keyword", values: []inferredValue{long, keyword}, want: mapping.KEYWORD_FIELD_TYPE},
+		{name: "keyword_after_conflict", values: []inferredValue{date, long, keyword}, want: mapping.KEYWORD_FIELD_TYPE},
+		{name: "date_and_long", values: []inferredValue{date, long}, wantErr: true},
+		{name: "ip_and_double", values: []inferredValue{double, ip}, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := unifyInferredValues("x", tt.values)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := unifyInferredValues("x", []inferredValue{date, long, date})
+	assert.EqualError(t, err, "field: x has values of conflicting types in query: 2024-01-01 (date), 5 (long), "+
+		"provide mapping or inference rule of the field")
+}
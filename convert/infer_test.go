@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	mapping "github.com/zhuliquan/es-mapping"
+	term "github.com/zhuliquan/lucene_parser/term"
 )
 
 func TestInferFieldType(t *testing.T) {
@@ -104,6 +105,76 @@ func TestInferFieldType(t *testing.T) {
 	}
 }
 
+func TestInferNumericFieldType(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected mapping.FieldType
+	}{
+		{name: "integer", value: "123", expected: mapping.LONG_FIELD_TYPE},
+		{name: "negative integer", value: "-456", expected: mapping.LONG_FIELD_TYPE},
+		{name: "quoted integer", value: "\"100\"", expected: mapping.LONG_FIELD_TYPE},
+		{name: "float", value: "3.14", expected: mapping.DOUBLE_FIELD_TYPE},
+		{name: "integer overflowing long", value: "92233720368547758070", expected: mapping.DOUBLE_FIELD_TYPE},
+		{name: "exponent", value: "1.5e3", expected: mapping.DOUBLE_FIELD_TYPE},
+		{name: "leading dot", value: ".5", expected: mapping.DOUBLE_FIELD_TYPE},
+		{name: "trailing dot", value: "5.", expected: mapping.DOUBLE_FIELD_TYPE},
+		{name: "nan", value: "nan", expected: mapping.KEYWORD_FIELD_TYPE},
+		{name: "NaN", value: "NaN", expected: mapping.KEYWORD_FIELD_TYPE},
+		{name: "inf", value: "inf", expected: mapping.KEYWORD_FIELD_TYPE},
+		{name: "negative inf", value: "-Inf", expected: mapping.KEYWORD_FIELD_TYPE},
+		{name: "Infinity", value: "Infinity", expected: mapping.KEYWORD_FIELD_TYPE},
+		{name: "hex float", value: "0x1p-2", expected: mapping.KEYWORD_FIELD_TYPE},
+		{name: "underscore", value: "1_000.5", expected: mapping.KEYWORD_FIELD_TYPE},
+		{name: "boolean", value: "true", expected: mapping.BOOLEAN_FIELD_TYPE},
+		{name: "date", value: "2021-01-01", expected: mapping.DATE_FIELD_TYPE},
+		{name: "ip", value: "192.168.1.1", expected: mapping.IP_FIELD_TYPE},
+		{name: "plain string", value: "hello", expected: mapping.KEYWORD_FIELD_TYPE},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := InferNumericFieldType(tt.value)
+			if result != tt.expected {
+				t.Errorf("InferNumericFieldType(%q) = %v, want %v", tt.value, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestInferRangeFieldType(t *testing.T) {
+	var value = func(s string) *term.RangeValue {
+		return &term.RangeValue{SingleValue: []string{s}}
+	}
+	var inf = &term.RangeValue{InfinityVal: "*"}
+	tests := []struct {
+		name     string
+		bound    *term.Bound
+		infer    func(string) mapping.FieldType
+		expected mapping.FieldType
+	}{
+		{name: "integers", bound: &term.Bound{LeftValue: value("9"), RightValue: value("100")}, infer: InferNumericFieldType, expected: mapping.LONG_FIELD_TYPE},
+		{name: "integer and float", bound: &term.Bound{LeftValue: value("1"), RightValue: value("2.5")}, infer: InferNumericFieldType, expected: mapping.DOUBLE_FIELD_TYPE},
+		{name: "infinite left", bound: &term.Bound{LeftValue: inf, RightValue: value("100")}, infer: InferNumericFieldType, expected: mapping.LONG_FIELD_TYPE},
+		{name: "infinite right", bound: &term.Bound{LeftValue: value("2021-01-01"), RightValue: inf}, infer: InferFieldType, expected: mapping.DATE_FIELD_TYPE},
+		{name: "both infinite", bound: &term.Bound{LeftValue: inf, RightValue: inf}, infer: InferNumericFieldType, expected: mapping.KEYWORD_FIELD_TYPE},
+		{name: "mixed types", bound: &term.Bound{LeftValue: value("1"), RightValue: value("abc")}, infer: InferNumericFieldType, expected: mapping.KEYWORD_FIELD_TYPE},
+		{name: "date and now", bound: &term.Bound{LeftValue: value("2024-01-01"), RightValue: value("now")}, infer: InferNumericFieldType, expected: mapping.DATE_FIELD_TYPE},
+		{name: "date and date math", bound: &term.Bound{LeftValue: value("now-1d/d"), RightValue: value("2024-01-01||+1M")}, infer: InferFieldType, expected: mapping.DATE_FIELD_TYPE},
+		{name: "now and integer", bound: &term.Bound{LeftValue: value("5"), RightValue: value("now")}, infer: InferNumericFieldType, expected: mapping.KEYWORD_FIELD_TYPE},
+		{name: "keyword integers", bound: &term.Bound{LeftValue: value("9"), RightValue: value("100")}, infer: InferFieldType, expected: mapping.KEYWORD_FIELD_TYPE},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := inferRangeFieldType(tt.bound, tt.infer)
+			if result != tt.expected {
+				t.Errorf("inferRangeFieldType() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
 func TestCreateDefaultProperty(t *testing.T) {
 	prop := CreateDefaultProperty(mapping.KEYWORD_FIELD_TYPE)
 	if prop.Type != mapping.KEYWORD_FIELD_TYPE {
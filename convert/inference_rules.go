@@ -0,0 +1,91 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	mapping "github.com/zhuliquan/es-mapping"
+)
+
+// InferenceRule infers type of field by its name when mapping isn't provided, which is checked before inferring
+// type from query value, i.e. `{"pattern": "*_at", "type": "date"}`
+type InferenceRule struct {
+	// Pattern is simple pattern of field name, whose wildcard `*` matches any characters (i.e. `*_at`, `@timestamp`),
+	// or regex of field name enclosed in slashes (i.e. `/.*_(ip|addr)/`), both of them match the whole field name
+	Pattern string `json:"pattern"`
+	// Type is field type of fields matching the pattern
+	Type mapping.FieldType `json:"type"`
+
+	// re is compiled pattern cached by LoadInferenceRules and WithInferenceRules, so that pattern isn't compiled
+	// every time field is matched
+	re *regexp.Regexp
+}
+
+// LoadInferenceRules loads inference rules from json array of rules, i.e. `[{"pattern": "*_id", "type": "keyword"}]`
+func LoadInferenceRules(data []byte) ([]InferenceRule, error) {
+	var rules []InferenceRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse inference rules, err: %v", err)
+	}
+	rules = compileInferenceRules(rules)
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// Validate checks pattern and type of the rule
+func (r InferenceRule) Validate() error {
+	if r.re == nil {
+		if _, err := r.compile(); err != nil {
+			return fmt.Errorf("inference rule: %s has invalid pattern, err: %v", r.Pattern, err)
+		}
+	}
+	if r.Type == "" || !mapping.CheckTypeSupportLucene(r.Type) {
+		return fmt.Errorf("inference rule: %s has invalid type: %q", r.Pattern, r.Type)
+	}
+	return nil
+}
+
+// Match returns true if the whole field name matches pattern of the rule
+func (r InferenceRule) Match(field string) bool {
+	if r.re != nil {
+		return r.re.MatchString(field)
+	}
+	re, err := r.compile()
+	return err == nil && re.MatchString(field)
+}
+
+func (r InferenceRule) compile() (*regexp.Regexp, error) {
+	if len(r.Pattern) >= 2 && strings.HasPrefix(r.Pattern, "/") && strings.HasSuffix(r.Pattern, "/") {
+		return regexp.Compile("^(?:" + r.Pattern[1:len(r.Pattern)-1] + ")$")
+	}
+	return compileSimplePattern(r.Pattern)
+}
+
+// compileInferenceRules returns copy of rules whose patterns are compiled and cached, rule with invalid pattern
+// is left uncompiled and never matches
+func compileInferenceRules(rules []InferenceRule) []InferenceRule {
+	var res = make([]InferenceRule, len(rules))
+	for i, rule := range rules {
+		res[i] = rule
+		if re, err := rule.compile(); err == nil {
+			res[i].re = re
+		}
+	}
+	return res
+}
+
+// matchInferenceRules returns type of the first rule matching field, empty type is returned if no rule matches
+func matchInferenceRules(rules []InferenceRule, field string) mapping.FieldType {
+	for _, rule := range rules {
+		if rule.Match(field) {
+			return rule.Type
+		}
+	}
+	return ""
+}
@@ -0,0 +1,82 @@
+package convert
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	mapping "github.com/zhuliquan/es-mapping"
+)
+
+func TestLoadInferenceRules(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		data    string
+		want    []InferenceRule
+		wantErr bool
+	}{
+		{
+			name: "rules",
+			data: `[{"pattern": "*_at", "type": "date"}, {"pattern": "/.*_(ip|addr)/", "type": "ip"}]`,
+			want: []InferenceRule{{Pattern: "*_at", Type: mapping.DATE_FIELD_TYPE}, {Pattern: "/.*_(ip|addr)/", Type: mapping.IP_FIELD_TYPE}},
+		},
+		{name: "invalid_regex", data: `[{"pattern": "/(/", "type": "ip"}]`, wantErr: true},
+		{name: "missing_type", data: `[{"pattern": "*_at"}]`, wantErr: true},
+		{name: "object_type", data: `[{"pattern": "user", "type": "object"}]`, wantErr: true},
+		{name: "not_array", data: `{"*_at": "date"}`, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := LoadInferenceRules([]byte(tt.data))
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			if assert.Equal(t, len(tt.want), len(rules)) {
+				for i, rule := range rules {
+					assert.Equal(t, tt.want[i].Pattern, rule.Pattern)
+					assert.Equal(t, tt.want[i].Type, rule.Type)
+					assert.NotNil(t, rule.re)
+				}
+			}
+		})
+	}
+}
+
+func TestMatchInferenceRules(t *testing.T) {
+	var rules = []InferenceRule{
+		{Pattern: "@timestamp", Type: mapping.DATE_FIELD_TYPE},
+		{Pattern: "*_at", Type: mapping.DATE_FIELD_TYPE},
+		{Pattern: "/.*_(ip|addr)/", Type: mapping.IP_FIELD_TYPE},
+		{Pattern: "*_id", Type: mapping.KEYWORD_FIELD_TYPE},
+		{Pattern: "*", Type: mapping.TEXT_FIELD_TYPE},
+	}
+	for _, tt := range []struct {
+		field string
+		want  mapping.FieldType
+	}{
+		{"@timestamp", mapping.DATE_FIELD_TYPE},
+		{"created_at", mapping.DATE_FIELD_TYPE},
+		{"event.created_at", mapping.DATE_FIELD_TYPE},
+		{"created_at_ms", mapping.TEXT_FIELD_TYPE},
+		{"client_addr", mapping.IP_FIELD_TYPE},
+		{"ip_client", mapping.TEXT_FIELD_TYPE},
+		{"user_id", mapping.KEYWORD_FIELD_TYPE},
+		{"msg", mapping.TEXT_FIELD_TYPE},
+	} {
+		t.Run(tt.field, func(t *testing.T) {
+			assert.Equal(t, tt.want, matchInferenceRules(rules, tt.field))
+			assert.Equal(t, tt.want, matchInferenceRules(compileInferenceRules(rules), tt.field))
+		})
+	}
+	assert.Equal(t, mapping.FieldType(""), matchInferenceRules(rules[:1], "msg"))
+}
+
+func TestWithInferenceRules(t *testing.T) {
+	var rules = []InferenceRule{{Pattern: "*_at", Type: mapping.DATE_FIELD_TYPE}, {Pattern: "/(/", Type: mapping.IP_FIELD_TYPE}}
+	cc := NewConverter(nil, nil, WithInferenceRules(rules)).(*converter)
+	assert.NotNil(t, cc.inferenceRules[0].re)
+	assert.Nil(t, cc.inferenceRules[1].re)
+	assert.False(t, cc.inferenceRules[1].Match("("))
+	// rules of caller aren't modified
+	assert.Nil(t, rules[0].re)
+}
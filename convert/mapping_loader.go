@@ -0,0 +1,446 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	mapping "github.com/zhuliquan/es-mapping"
+)
+
+// mapping payload key
+const (
+	PROPERTIES_KEY          = "properties"
+	MAPPINGS_KEY            = "mappings"
+	TEMPLATE_KEY            = "template"
+	PRIORITY_KEY            = "priority"
+	COMPOSED_OF_KEY         = "composed_of"
+	INDEX_TEMPLATE_KEY      = "index_template"
+	INDEX_TEMPLATES_KEY     = "index_templates"
+	COMPONENT_TEMPLATE_KEY  = "component_template"
+	COMPONENT_TEMPLATES_KEY = "component_templates"
+	DYNAMIC_TEMPLATES_KEY   = "dynamic_templates"
+
+	IGNORE_MISSING_COMPONENT_TEMPLATES_KEY = "ignore_missing_component_templates"
+)
+
+// mappingBodyKeys are keys of typeless mapping body besides properties
+var mappingBodyKeys = []string{PROPERTIES_KEY, "dynamic", DYNAMIC_TEMPLATES_KEY, RUNTIME_KEY, "_source", "_routing", "_meta"}
+
+type jsonObject = map[string]interface{}
+
+// LoadMappingPayload loads es mapping from payload of any recognised shape:
+//  1. mapping body, i.e. `{"properties": {...}}` or legacy typed `{"_doc": {"properties": {...}}}`
+//  2. body of create index api or mapping response of single index,
+//     i.e. `{"mappings": {...}}` or `{"my-index": {"mappings": {...}}}`
+//  3. component template or index template without `composed_of`, i.e. `{"template": {"mappings": {...}}}`
+//  4. response of `GET _index_template` api, index template with highest priority is used
+//
+// index template composed of component templates is loaded by LoadIndexTemplate
+func LoadMappingPayload(data []byte) (*mapping.PropertyMapping, error) {
+	obj, err := decodeJSONObject(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse mapping payload, err: %v", err)
+	}
+	body, err := payloadToMappingBody(obj)
+	if err != nil {
+		return nil, err
+	}
+	return loadMappingBody(body)
+}
+
+// LoadIndexTemplate composes index template with its component templates into es mapping as es does, mappings of
+// component templates are merged in order of `composed_of`, and mapping of index template itself is merged at last.
+// Index template is body of index template or response of `GET _index_template` api (highest priority is used),
+// component templates are response of `GET _component_template` api or object of component template bodies keyed by name
+func LoadIndexTemplate(indexTemplate, componentTemplates []byte) (*mapping.PropertyMapping, error) {
+	body, err := composeIndexTemplate(indexTemplate, componentTemplates)
+	if err != nil {
+		return nil, err
+	}
+	return loadMappingBody(body)
+}
+
+// Mappings are es mapping, dynamic settings and runtime fields loaded from the same mapping body, so that
+// payload is decoded (or index template is composed) only once
+type Mappings struct {
+	Property *mapping.PropertyMapping
+	Dynamic  *DynamicMapping
+	Runtime  *RuntimeFields
+}
+
+// LoadMappings loads mappings from payload of any shape accepted by LoadMappingPayload
+func LoadMappings(data []byte) (*Mappings, error) {
+	obj, err := decodeJSONObject(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse mapping payload, err: %v", err)
+	}
+	body, err := payloadToMappingBody(obj)
+	if err != nil {
+		return nil, err
+	}
+	return newMappings(body)
+}
+
+// LoadIndexTemplateMappings loads mappings of index template composed of component templates as LoadIndexTemplate does
+func LoadIndexTemplateMappings(indexTemplate, componentTemplates []byte) (*Mappings, error) {
+	body, err := composeIndexTemplate(indexTemplate, componentTemplates)
+	if err != nil {
+		return nil, err
+	}
+	return newMappings(body)
+}
+
+func newMappings(body jsonObject) (*Mappings, error) {
+	pm, err := loadMappingBody(body)
+	if err != nil {
+		return nil, err
+	}
+	dm, err := newDynamicMapping(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dynamic mapping, err: %v", err)
+	}
+	runtime, _ := body[RUNTIME_KEY].(jsonObject)
+	rf, err := NewRuntimeFields(runtime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load runtime fields, err: %v", err)
+	}
+	return &Mappings{Property: pm, Dynamic: dm, Runtime: rf}, nil
+}
+
+// composeIndexTemplate merges mappings of component templates and index template into mapping body
+func composeIndexTemplate(indexTemplate, componentTemplates []byte) (jsonObject, error) {
+	obj, err := decodeJSONObject(indexTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse index template, err: %v", err)
+	}
+	if _, ok := obj[INDEX_TEMPLATES_KEY]; ok {
+		if obj, err = highestPriorityIndexTemplate(obj); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := obj[TEMPLATE_KEY]; !ok {
+		if _, ok := obj[COMPOSED_OF_KEY]; !ok {
+			return nil, fmt.Errorf("unrecognised index template, expect object with template or composed_of")
+		}
+	}
+
+	var components = map[string]jsonObject{}
+	if len(bytes.TrimSpace(componentTemplates)) != 0 {
+		if components, err = parseComponentTemplates(componentTemplates); err != nil {
+			return nil, err
+		}
+	}
+	var ignoreMissing = map[string]bool{}
+	for _, name := range toStringList(obj[IGNORE_MISSING_COMPONENT_TEMPLATES_KEY]) {
+		ignoreMissing[name] = true
+	}
+
+	var body = jsonObject{}
+	for _, name := range toStringList(obj[COMPOSED_OF_KEY]) {
+		component, ok := components[name]
+		if !ok {
+			if ignoreMissing[name] {
+				continue
+			}
+			return nil, fmt.Errorf("component template: %s composed by index template is missing", name)
+		}
+		componentBody, err := templateToMappingBody(component)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load component template: %s, err: %v", name, err)
+		}
+		mergeMappingBody(body, componentBody)
+	}
+	if _, ok := obj[TEMPLATE_KEY]; ok {
+		templateBody, err := templateToMappingBody(obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load index template, err: %v", err)
+		}
+		mergeMappingBody(body, templateBody)
+	}
+	return body, nil
+}
+
+// payloadToMappingBody finds typeless mapping body in payload
+func payloadToMappingBody(obj jsonObject) (jsonObject, error) {
+	if isMappingBody(obj) {
+		return obj, nil
+	}
+	if mappings, ok := obj[MAPPINGS_KEY]; ok {
+		return mappingsToMappingBody(mappings)
+	}
+	if _, ok := obj[TEMPLATE_KEY]; ok {
+		if _, ok := obj[COMPOSED_OF_KEY]; ok {
+			return nil, fmt.Errorf("index template is composed of component templates, load it by LoadIndexTemplate")
+		}
+		return templateToMappingBody(obj)
+	}
+	if _, ok := obj[INDEX_TEMPLATES_KEY]; ok {
+		template, err := highestPriorityIndexTemplate(obj)
+		if err != nil {
+			return nil, err
+		}
+		return payloadToMappingBody(template)
+	}
+	if _, ok := obj[COMPONENT_TEMPLATES_KEY]; ok {
+		return nil, fmt.Errorf("component templates can't be loaded alone, load them with index template by LoadIndexTemplate")
+	}
+	if len(obj) == 1 {
+		for name, value := range obj {
+			if inner, ok := value.(jsonObject); ok {
+				if mappings, ok := inner[MAPPINGS_KEY]; ok {
+					// response of `GET /<index>/_mapping` api
+					return mappingsToMappingBody(mappings)
+				} else if isMappingBody(inner) {
+					// legacy typed mapping, i.e. `{"_doc": {"properties": {...}}}`
+					return inner, nil
+				}
+				return nil, fmt.Errorf("unrecognised mapping payload of: %s, expect mappings or properties", name)
+			}
+		}
+	}
+	if len(obj) > 1 {
+		var indices []string
+		for name, value := range obj {
+			if inner, ok := value.(jsonObject); ok && inner[MAPPINGS_KEY] != nil {
+				indices = append(indices, name)
+			}
+		}
+		if len(indices) == len(obj) {
+			sort.Strings(indices)
+			return nil, fmt.Errorf("mapping response has multiple indices: %v, load it by LoadIndexMappingsData", indices)
+		}
+	}
+	return nil, fmt.Errorf("unrecognised mapping payload, expect mapping body, mapping response, index template or component template")
+}
+
+// mappingsToMappingBody unwraps value of mappings, which is typeless mapping body or legacy typed mapping
+func mappingsToMappingBody(mappings interface{}) (jsonObject, error) {
+	obj, ok := mappings.(jsonObject)
+	if !ok {
+		return nil, fmt.Errorf("mappings is not an object")
+	}
+	if len(obj) == 0 || isMappingBody(obj) {
+		return obj, nil
+	}
+	if len(obj) == 1 {
+		for _, value := range obj {
+			if inner, ok := value.(jsonObject); ok && isMappingBody(inner) {
+				return inner, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unrecognised mappings, expect typeless mapping or mapping of single type")
+}
+
+// templateToMappingBody gets mapping body of component template or index template, i.e. `{"template": {"mappings": {...}}}`
+func templateToMappingBody(obj jsonObject) (jsonObject, error) {
+	if inner, ok := obj[COMPONENT_TEMPLATE_KEY].(jsonObject); ok {
+		obj = inner
+	}
+	template, ok := obj[TEMPLATE_KEY].(jsonObject)
+	if !ok {
+		return nil, fmt.Errorf("template is not an object")
+	}
+	mappings, ok := template[MAPPINGS_KEY]
+	if !ok {
+		return jsonObject{}, nil
+	}
+	return mappingsToMappingBody(mappings)
+}
+
+// highestPriorityIndexTemplate picks index template with highest priority from response of `GET _index_template` api
+func highestPriorityIndexTemplate(obj jsonObject) (jsonObject, error) {
+	templates, ok := obj[INDEX_TEMPLATES_KEY].([]interface{})
+	if !ok || len(templates) == 0 {
+		return nil, fmt.Errorf("index_templates is empty")
+	}
+	var res jsonObject
+	var resPriority int64 = -1
+	var tied bool
+	for _, x := range templates {
+		item, _ := x.(jsonObject)
+		template, ok := item[INDEX_TEMPLATE_KEY].(jsonObject)
+		if !ok {
+			return nil, fmt.Errorf("index_templates has item without index_template")
+		}
+		var priority int64
+		if p, ok := template[PRIORITY_KEY].(json.Number); ok {
+			if priority, ok = toInt64(p); !ok {
+				return nil, fmt.Errorf("priority: %s of index template is invalid", p)
+			}
+		}
+		if priority > resPriority {
+			res, resPriority, tied = template, priority, false
+		} else if priority == resPriority {
+			tied = true
+		}
+	}
+	if tied {
+		return nil, fmt.Errorf("multiple index templates have highest priority: %d", resPriority)
+	}
+	return res, nil
+}
+
+// parseComponentTemplates parses response of `GET _component_template` api or object of component template bodies
+func parseComponentTemplates(data []byte) (map[string]jsonObject, error) {
+	obj, err := decodeJSONObject(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse component templates, err: %v", err)
+	}
+	var res = map[string]jsonObject{}
+	if items, ok := obj[COMPONENT_TEMPLATES_KEY]; ok {
+		list, ok := items.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("component_templates is not an array")
+		}
+		for _, x := range list {
+			item, _ := x.(jsonObject)
+			name, _ := item["name"].(string)
+			template, ok := item[COMPONENT_TEMPLATE_KEY].(jsonObject)
+			if name == "" || !ok {
+				return nil, fmt.Errorf("component_templates has item without name or component_template")
+			}
+			res[name] = template
+		}
+		return res, nil
+	}
+	for name, value := range obj {
+		template, ok := value.(jsonObject)
+		if !ok {
+			return nil, fmt.Errorf("component template: %s is not an object", name)
+		}
+		res[name] = template
+	}
+	return res, nil
+}
+
+// mergeMappingBody merges src mapping body into dst as es composes templates, properties of object fields are
+// merged recursively and other settings of src (i.e. field type, dynamic) override dst
+func mergeMappingBody(dst, src jsonObject) {
+	for key, value := range src {
+		if key == PROPERTIES_KEY {
+			var dstProps, _ = dst[key].(jsonObject)
+			var srcProps, _ = value.(jsonObject)
+			if dstProps == nil {
+				dstProps = jsonObject{}
+				dst[key] = dstProps
+			}
+			for field, srcProp := range srcProps {
+				dstProp, ok1 := dstProps[field].(jsonObject)
+				srcPropObj, ok2 := srcProp.(jsonObject)
+				if ok1 && ok2 && isObjectProperty(dstProp) && isObjectProperty(srcPropObj) {
+					mergeMappingBody(dstProp, srcPropObj)
+				} else {
+					dstProps[field] = srcProp
+				}
+			}
+		} else if key == RUNTIME_KEY {
+			// runtime fields are merged by name
+			var runtime, _ = dst[key].(jsonObject)
+			if runtime == nil {
+				runtime = jsonObject{}
+				dst[key] = runtime
+			}
+			var srcRuntime, _ = value.(jsonObject)
+			for name, definition := range srcRuntime {
+				runtime[name] = definition
+			}
+		} else if key == DYNAMIC_TEMPLATES_KEY {
+			dst[key] = mergeDynamicTemplates(dst[key], value)
+		} else {
+			dst[key] = value
+		}
+	}
+}
+
+// mergeDynamicTemplates merges dynamic templates by name, template of src replaces template of dst with same name
+// in place and other templates of src are appended
+func mergeDynamicTemplates(dst, src interface{}) interface{} {
+	var dstList, _ = dst.([]interface{})
+	var srcList, ok = src.([]interface{})
+	if !ok {
+		return src
+	}
+	var res = append([]interface{}{}, dstList...)
+	var index = map[string]int{}
+	for i, x := range res {
+		if name, ok := dynamicTemplateName(x); ok {
+			index[name] = i
+		}
+	}
+	for _, x := range srcList {
+		if name, ok := dynamicTemplateName(x); ok {
+			if i, ok := index[name]; ok {
+				res[i] = x
+				continue
+			}
+			index[name] = len(res)
+		}
+		res = append(res, x)
+	}
+	return res
+}
+
+func dynamicTemplateName(x interface{}) (string, bool) {
+	if obj, ok := x.(jsonObject); ok && len(obj) == 1 {
+		for name := range obj {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// isObjectProperty checks whether property is object or nested field whose properties can be merged
+func isObjectProperty(prop jsonObject) bool {
+	var typ, _ = prop["type"].(string)
+	_, hasProps := prop[PROPERTIES_KEY]
+	return typ == "object" || typ == "nested" || (typ == "" && hasProps)
+}
+
+func isMappingBody(obj jsonObject) bool {
+	for _, key := range mappingBodyKeys {
+		if _, ok := obj[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func loadMappingBody(body jsonObject) (*mapping.PropertyMapping, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return mapping.LoadMappingData(data)
+}
+
+func decodeJSONObject(data []byte) (jsonObject, error) {
+	var obj jsonObject
+	var decoder = json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&obj); err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, fmt.Errorf("payload is not an object")
+	}
+	return obj, nil
+}
+
+func toStringList(x interface{}) []string {
+	var list, _ = x.([]interface{})
+	var res = make([]string, 0, len(list))
+	for _, v := range list {
+		if s, ok := v.(string); ok {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+func toInt64(n json.Number) (int64, bool) {
+	v, err := n.Int64()
+	return v, err == nil
+}
@@ -0,0 +1,200 @@
+package convert
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	mapping "github.com/zhuliquan/es-mapping"
+)
+
+func TestLoadMappingPayload(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "mapping_body", data: `{"properties": {"x": {"type": "keyword"}}}`},
+		{name: "legacy_typed_mapping", data: `{"_doc": {"properties": {"x": {"type": "keyword"}}}}`},
+		{name: "create_index_body", data: `{"settings": {}, "mappings": {"properties": {"x": {"type": "keyword"}}}}`},
+		{name: "typed_create_index_body", data: `{"mappings": {"_doc": {"properties": {"x": {"type": "keyword"}}}}}`},
+		{name: "mapping_response", data: `{"logs-1": {"mappings": {"properties": {"x": {"type": "keyword"}}}}}`},
+		{name: "typed_mapping_response", data: `{"logs-1": {"mappings": {"_doc": {"properties": {"x": {"type": "keyword"}}}}}}`},
+		{name: "component_template", data: `{"template": {"mappings": {"properties": {"x": {"type": "keyword"}}}}}`},
+		{
+			name: "index_templates_response",
+			data: `{"index_templates": [
+			  {"name": "low", "index_template": {"priority": 1, "template": {"mappings": {"properties": {"x": {"type": "long"}}}}}},
+			  {"name": "high", "index_template": {"priority": 10, "template": {"mappings": {"properties": {"x": {"type": "keyword"}}}}}}
+			]}`,
+		},
+		{
+			name: "index_templates_same_priority",
+			data: `{"index_templates": [
+			  {"name": "a", "index_template": {"template": {"mappings": {"properties": {"x": {"type": "long"}}}}}},
+			  {"name": "b", "index_template": {"template": {"mappings": {"properties": {"x": {"type": "keyword"}}}}}}
+			]}`,
+			wantErr: true,
+		},
+		{name: "composed_index_template", data: `{"composed_of": ["base"], "template": {}}`, wantErr: true},
+		{name: "component_templates_response", data: `{"component_templates": []}`, wantErr: true},
+		{
+			name:    "multiple_indices_response",
+			data:    `{"logs-1": {"mappings": {}}, "logs-2": {"mappings": {}}}`,
+			wantErr: true,
+		},
+		{name: "multiple_types", data: `{"mappings": {"a": {"properties": {}}, "b": {"properties": {}}}}`, wantErr: true},
+		{name: "unrecognised_object", data: `{"logs-1": {"settings": {}}}`, wantErr: true},
+		{name: "unrecognised", data: `{"foo": 1, "bar": 2}`, wantErr: true},
+		{name: "not_object", data: `[]`, wantErr: true},
+		{name: "invalid_json", data: `{"properties":`, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pm, err := LoadMappingPayload([]byte(tt.data))
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				assert.Nil(t, pm)
+				return
+			}
+			assert.Nil(t, err)
+			props, err := pm.GetProperty("x")
+			assert.Nil(t, err)
+			assert.Equal(t, mapping.KEYWORD_FIELD_TYPE, props["x"].Type)
+		})
+	}
+}
+
+func TestLoadIndexTemplate(t *testing.T) {
+	var componentTemplates = []byte(`{"component_templates": [
+	  {"name": "base", "component_template": {"template": {"mappings": {"properties": {
+	    "x": {"type": "long"},
+	    "user": {"properties": {"name": {"type": "text"}}}
+	  }}}}},
+	  {"name": "logs", "component_template": {"template": {"mappings": {"properties": {
+	    "y": {"type": "text"},
+	    "user": {"properties": {"name": {"type": "keyword"}, "age": {"type": "integer"}}}
+	  }}}}}
+	]}`)
+
+	type want struct {
+		field string
+		mType mapping.FieldType
+	}
+	for _, tt := range []struct {
+		name       string
+		template   string
+		components []byte
+		want       []want
+		wantErr    bool
+	}{
+		{
+			name:       "compose_in_order",
+			template:   `{"index_patterns": ["logs-*"], "composed_of": ["base", "logs"], "template": {"mappings": {"properties": {"x": {"type": "keyword"}}}}}`,
+			components: componentTemplates,
+			want: []want{
+				{"x", mapping.KEYWORD_FIELD_TYPE},
+				{"y", mapping.TEXT_FIELD_TYPE},
+				{"user.name", mapping.KEYWORD_FIELD_TYPE},
+				{"user.age", mapping.INTEGER_FIELD_TYPE},
+			},
+		},
+		{
+			name:       "without_own_template",
+			template:   `{"composed_of": ["logs", "base"]}`,
+			components: componentTemplates,
+			want: []want{
+				{"x", mapping.LONG_FIELD_TYPE},
+				{"user.name", mapping.TEXT_FIELD_TYPE},
+				{"user.age", mapping.INTEGER_FIELD_TYPE},
+			},
+		},
+		{
+			name:       "components_keyed_by_name",
+			template:   `{"composed_of": ["base"]}`,
+			components: []byte(`{"base": {"template": {"mappings": {"properties": {"x": {"type": "keyword"}}}}}}`),
+			want:       []want{{"x", mapping.KEYWORD_FIELD_TYPE}},
+		},
+		{
+			name: "index_templates_response",
+			template: `{"index_templates": [{"name": "logs", "index_template": {
+			  "composed_of": ["base"], "template": {"mappings": {"properties": {"y": {"type": "keyword"}}}}
+			}}]}`,
+			components: componentTemplates,
+			want:       []want{{"x", mapping.LONG_FIELD_TYPE}, {"y", mapping.KEYWORD_FIELD_TYPE}},
+		},
+		{
+			name:       "ignore_missing_component",
+			template:   `{"composed_of": ["base", "custom"], "ignore_missing_component_templates": ["custom"]}`,
+			components: componentTemplates,
+			want:       []want{{"x", mapping.LONG_FIELD_TYPE}},
+		},
+		{
+			name:       "missing_component",
+			template:   `{"composed_of": ["base", "custom"]}`,
+			components: componentTemplates,
+			wantErr:    true,
+		},
+		{
+			name:     "missing_components",
+			template: `{"composed_of": ["base"]}`,
+			wantErr:  true,
+		},
+		{
+			name:       "invalid_components",
+			template:   `{"composed_of": ["base"]}`,
+			components: []byte(`{"component_templates": {}}`),
+			wantErr:    true,
+		},
+		{
+			name:     "unrecognised_template",
+			template: `{"properties": {"x": {"type": "keyword"}}}`,
+			wantErr:  true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pm, err := LoadIndexTemplate([]byte(tt.template), tt.components)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				assert.Nil(t, pm)
+				return
+			}
+			assert.Nil(t, err)
+			for _, w := range tt.want {
+				props, err := pm.GetProperty(w.field)
+				assert.Nil(t, err)
+				if assert.Contains(t, props, w.field) {
+					assert.Equal(t, w.mType, props[w.field].Type, w.field)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadMappings(t *testing.T) {
+	var data = []byte(`{"my-index": {"mappings": {
+	  "dynamic": "strict",
+	  "dynamic_templates": [{"labels": {"path_match": "labels.*", "mapping": {"type": "keyword"}}}],
+	  "runtime": {"day": {"type": "keyword", "script": {"source": "emit('x')"}}},
+	  "properties": {"x": {"type": "long"}, "labels": {"type": "object", "dynamic": true}}
+	}}}`)
+	ms, err := LoadMappings(data)
+	assert.Nil(t, err)
+	props, err := ms.Property.GetProperty("x")
+	assert.Nil(t, err)
+	assert.Equal(t, mapping.LONG_FIELD_TYPE, props["x"].Type)
+	assert.Equal(t, DYNAMIC_STRICT, ms.Dynamic.Dynamic("y"))
+	prop, err := ms.Dynamic.ResolveProperty("labels.team", []string{STRING_MAPPING_TYPE})
+	assert.Nil(t, err)
+	assert.Equal(t, mapping.KEYWORD_FIELD_TYPE, prop.Type)
+	assert.Equal(t, mapping.KEYWORD_FIELD_TYPE, ms.Runtime.GetProperty("day")["day"].Type)
+
+	ms, err = LoadIndexTemplateMappings([]byte(`{"composed_of": ["base"], "template": {"mappings": {"properties": {"y": {"type": "keyword"}}}}}`),
+		[]byte(`{"base": {"template": {"mappings": {"runtime": {"day": {"type": "long"}}, "properties": {"x": {"type": "long"}}}}}}`))
+	assert.Nil(t, err)
+	props, err = ms.Property.GetProperty("*")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(props))
+	assert.Equal(t, mapping.LONG_FIELD_TYPE, ms.Runtime.GetProperty("day")["day"].Type)
+
+	_, err = LoadMappings([]byte(`{"runtime": {"day": {"script": {"source": "emit('x')"}}}}`))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,191 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	mapping "github.com/zhuliquan/es-mapping"
+)
+
+const (
+	FIELDS_KEY           = "fields"
+	RUNTIME_KEY          = "runtime"
+	RUNTIME_MAPPINGS_KEY = "runtime_mappings"
+)
+
+// runtime field type
+const (
+	COMPOSITE_RUNTIME_TYPE = "composite"
+	LOOKUP_RUNTIME_TYPE    = "lookup"
+)
+
+// RuntimeFields are runtime fields defined in `runtime` section of mapping or `runtime_mappings` of search request,
+// which are queryable as fields of their types and shadow properties with same names as es does
+type RuntimeFields struct {
+	// definitions are raw definitions of runtime fields keyed by name
+	definitions map[string]interface{}
+	// props are properties of queryable fields, sub fields of composite runtime field are keyed by `<name>.<sub field>`
+	props map[string]*mapping.Property
+	// owners are names of runtime fields defining queryable fields
+	owners map[string]string
+}
+
+// NewRuntimeFields parses definitions of runtime fields keyed by name, i.e. `{"day_of_week": {"type": "keyword", "script": ...}}`
+func NewRuntimeFields(definitions map[string]interface{}) (*RuntimeFields, error) {
+	var r = &RuntimeFields{
+		definitions: make(map[string]interface{}, len(definitions)),
+		props:       map[string]*mapping.Property{},
+		owners:      map[string]string{},
+	}
+	for name, x := range definitions {
+		definition, ok := x.(jsonObject)
+		if !ok {
+			return nil, fmt.Errorf("runtime field: %s is not an object", name)
+		}
+		typ, _ := definition["type"].(string)
+		switch typ {
+		case "":
+			return nil, fmt.Errorf("runtime field: %s has no type", name)
+		case LOOKUP_RUNTIME_TYPE:
+			// lookup field only retrieves fields from another index, which isn't queryable
+		case COMPOSITE_RUNTIME_TYPE:
+			fields, ok := definition[FIELDS_KEY].(jsonObject)
+			if !ok || len(fields) == 0 {
+				return nil, fmt.Errorf("composite runtime field: %s has no fields", name)
+			}
+			for sub, y := range fields {
+				subDefinition, _ := y.(jsonObject)
+				prop, err := runtimeFieldProperty(name+"."+sub, subDefinition)
+				if err != nil {
+					return nil, err
+				}
+				r.props[name+"."+sub] = prop
+				r.owners[name+"."+sub] = name
+			}
+		default:
+			prop, err := runtimeFieldProperty(name, definition)
+			if err != nil {
+				return nil, err
+			}
+			r.props[name] = prop
+			r.owners[name] = name
+		}
+		r.definitions[name] = definition
+	}
+	return r, nil
+}
+
+// LoadRuntimeFields loads runtime fields from `runtime` section of mapping payload of any shape accepted by LoadMappingPayload
+func LoadRuntimeFields(data []byte) (*RuntimeFields, error) {
+	obj, err := decodeJSONObject(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse mapping payload, err: %v", err)
+	}
+	body, err := payloadToMappingBody(obj)
+	if err != nil {
+		return nil, err
+	}
+	runtime, _ := body[RUNTIME_KEY].(jsonObject)
+	return NewRuntimeFields(runtime)
+}
+
+// LoadIndexTemplateRuntimeFields loads runtime fields of index template composed of component templates as LoadIndexTemplate does
+func LoadIndexTemplateRuntimeFields(indexTemplate, componentTemplates []byte) (*RuntimeFields, error) {
+	body, err := composeIndexTemplate(indexTemplate, componentTemplates)
+	if err != nil {
+		return nil, err
+	}
+	runtime, _ := body[RUNTIME_KEY].(jsonObject)
+	return NewRuntimeFields(runtime)
+}
+
+// LoadRuntimeMappings loads `runtime_mappings` of search request, which is definitions keyed by name
+// or search request body having `runtime_mappings`
+func LoadRuntimeMappings(data []byte) (*RuntimeFields, error) {
+	obj, err := decodeJSONObject(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse runtime mappings, err: %v", err)
+	}
+	if inner, ok := obj[RUNTIME_MAPPINGS_KEY]; ok {
+		if obj, ok = inner.(jsonObject); !ok {
+			return nil, fmt.Errorf("runtime_mappings is not an object")
+		}
+	}
+	return NewRuntimeFields(obj)
+}
+
+// Shadow returns runtime fields of both, runtime field of other shadows runtime field of r with same name
+func (r *RuntimeFields) Shadow(other *RuntimeFields) *RuntimeFields {
+	if r == nil {
+		return other
+	} else if other == nil {
+		return r
+	}
+	var res = &RuntimeFields{
+		definitions: map[string]interface{}{},
+		props:       map[string]*mapping.Property{},
+		owners:      map[string]string{},
+	}
+	for _, rf := range []*RuntimeFields{r, other} {
+		for field, owner := range rf.owners {
+			if other != rf && other.definitions[owner] != nil {
+				continue
+			}
+			res.props[field] = rf.props[field]
+			res.owners[field] = owner
+		}
+		for name, definition := range rf.definitions {
+			res.definitions[name] = definition
+		}
+	}
+	return res
+}
+
+// GetProperty gets properties of runtime fields matching field (wildcard is supported)
+func (r *RuntimeFields) GetProperty(field string) map[string]*mapping.Property {
+	if r == nil {
+		return nil
+	}
+	var res = map[string]*mapping.Property{}
+	for key, prop := range r.props {
+		if ok, _ := path.Match(field, key); ok || key == field {
+			res[key] = prop
+		}
+	}
+	return res
+}
+
+// Definitions returns raw definitions of runtime fields keyed by name, which are `runtime_mappings` of search request
+func (r *RuntimeFields) Definitions() map[string]interface{} {
+	if r == nil {
+		return nil
+	}
+	var res = make(map[string]interface{}, len(r.definitions))
+	for name, definition := range r.definitions {
+		res[name] = definition
+	}
+	return res
+}
+
+// runtimeFieldProperty creates property of runtime field, type of runtime field is also type of field mapping
+func runtimeFieldProperty(field string, definition jsonObject) (*mapping.Property, error) {
+	var typ, _ = definition["type"].(string)
+	if typ == "" || strings.ContainsAny(field, "*?") {
+		return nil, fmt.Errorf("runtime field: %s is invalid, expect name without wildcard and type", field)
+	}
+	var raw = jsonObject{"type": typ}
+	if format, ok := definition["format"]; ok {
+		raw["format"] = format
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var prop = &mapping.Property{}
+	if err := json.Unmarshal(data, prop); err != nil {
+		return nil, fmt.Errorf("failed to load runtime field: %s, err: %v", field, err)
+	}
+	return prop, nil
+}
@@ -0,0 +1,102 @@
+package convert
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	mapping "github.com/zhuliquan/es-mapping"
+)
+
+func TestLoadRuntimeFields(t *testing.T) {
+	rf, err := LoadRuntimeFields([]byte(`{"mappings": {
+	  "runtime": {
+	    "day_of_week": {"type": "keyword", "script": {"source": "emit('Monday')"}},
+	    "duration": {"type": "long"},
+	    "ts": {"type": "date", "format": "yyyy-MM-dd"},
+	    "client": {"type": "composite", "script": "emit(['ip': '127.0.0.1'])", "fields": {"ip": {"type": "ip"}}},
+	    "owner": {"type": "lookup", "target_index": "users"}
+	  },
+	  "properties": {"duration": {"type": "keyword"}}
+	}}`))
+	assert.Nil(t, err)
+
+	for _, tt := range []struct {
+		name  string
+		field string
+		want  map[string]mapping.FieldType
+	}{
+		{"keyword", "day_of_week", map[string]mapping.FieldType{"day_of_week": mapping.KEYWORD_FIELD_TYPE}},
+		{"composite_sub_field", "client.ip", map[string]mapping.FieldType{"client.ip": mapping.IP_FIELD_TYPE}},
+		{"composite", "client", map[string]mapping.FieldType{}},
+		{"lookup", "owner", map[string]mapping.FieldType{}},
+		{"wildcard", "d*", map[string]mapping.FieldType{"day_of_week": mapping.KEYWORD_FIELD_TYPE, "duration": mapping.LONG_FIELD_TYPE}},
+		{"missing", "missing", map[string]mapping.FieldType{}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var got = map[string]mapping.FieldType{}
+			for key, prop := range rf.GetProperty(tt.field) {
+				got[key] = prop.Type
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	assert.Equal(t, "yyyy-MM-dd", rf.GetProperty("ts")["ts"].Format)
+	assert.Len(t, rf.Definitions(), 5)
+
+	for _, data := range []string{
+		`{"runtime": {"a": 1}}`,
+		`{"runtime": {"a": {"script": "emit(1)"}}}`,
+		`{"runtime": {"a": {"type": "composite"}}}`,
+		`{"runtime": {"a": {"type": "composite", "fields": {"b": {}}}}}`,
+		`{"a": 1, "b": 2}`,
+	} {
+		_, err := LoadRuntimeFields([]byte(data))
+		assert.NotNil(t, err, data)
+	}
+}
+
+func TestLoadRuntimeMappings(t *testing.T) {
+	rf, err := LoadRuntimeMappings([]byte(`{"size": 0, "runtime_mappings": {"a": {"type": "long"}}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, mapping.LONG_FIELD_TYPE, rf.GetProperty("a")["a"].Type)
+
+	rf, err = LoadRuntimeMappings([]byte(`{"a": {"type": "double"}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, mapping.DOUBLE_FIELD_TYPE, rf.GetProperty("a")["a"].Type)
+
+	_, err = LoadRuntimeMappings([]byte(`{"runtime_mappings": []}`))
+	assert.NotNil(t, err)
+}
+
+func TestRuntimeFieldsShadow(t *testing.T) {
+	base, err := NewRuntimeFields(map[string]interface{}{
+		"a": map[string]interface{}{"type": "keyword"},
+		"c": map[string]interface{}{"type": "composite", "fields": map[string]interface{}{"x": map[string]interface{}{"type": "keyword"}}},
+	})
+	assert.Nil(t, err)
+	search, err := NewRuntimeFields(map[string]interface{}{
+		"a": map[string]interface{}{"type": "long"},
+		"c": map[string]interface{}{"type": "composite", "fields": map[string]interface{}{"y": map[string]interface{}{"type": "long"}}},
+	})
+	assert.Nil(t, err)
+
+	var rf = base.Shadow(search)
+	assert.Equal(t, mapping.LONG_FIELD_TYPE, rf.GetProperty("a")["a"].Type)
+	assert.Empty(t, rf.GetProperty("c.x"))
+	assert.Equal(t, mapping.LONG_FIELD_TYPE, rf.GetProperty("c.y")["c.y"].Type)
+
+	var empty *RuntimeFields
+	assert.Equal(t, search, empty.Shadow(search))
+	assert.Equal(t, base, base.Shadow(nil))
+	assert.Nil(t, empty.GetProperty("a"))
+}
+
+func TestLoadIndexTemplateRuntimeFields(t *testing.T) {
+	rf, err := LoadIndexTemplateRuntimeFields(
+		[]byte(`{"composed_of": ["base"], "template": {"mappings": {"runtime": {"b": {"type": "keyword"}}}}}`),
+		[]byte(`{"base": {"template": {"mappings": {"runtime": {"a": {"type": "long"}, "b": {"type": "long"}}}}}}`),
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, mapping.LONG_FIELD_TYPE, rf.GetProperty("a")["a"].Type)
+	assert.Equal(t, mapping.KEYWORD_FIELD_TYPE, rf.GetProperty("b")["b"].Type)
+}
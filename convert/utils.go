@@ -3,6 +3,7 @@ package convert
 import (
 	"fmt"
 	"net"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -12,6 +13,7 @@ import (
 	"github.com/zhuliquan/datemath_parser"
 	mapping "github.com/zhuliquan/es-mapping"
 	"github.com/zhuliquan/lucene-to-dsl/dsl"
+	"github.com/zhuliquan/lucene-to-dsl/utils"
 	"github.com/zhuliquan/scaled_float"
 )
 
@@ -88,10 +90,10 @@ func convertToFloat128(floatValue string, scalingFactor float64) (interface{}, e
 	}
 }
 
-// convertToDate parse date math expr.
-func convertToDate(property *mapping.Property) convertFunc {
+// convertToDate parse date math expr, date without time zone is parsed in loc.
+func convertToDate(property *mapping.Property, loc *time.Location) convertFunc {
 	return func(s string) (interface{}, error) {
-		var parser = getDateParserFromMapping(property)
+		var parser = getDateParserFromMapping(property, loc)
 		return parser.Parse(s)
 	}
 }
@@ -105,9 +107,9 @@ type dateRange struct {
 // TODO: 需要考虑如何解决如何处理 日缺失想查年月中所有天，月缺失想查整年的情况,
 // 例如：field:2019-02 对应查询时间区间从2019-02-01 到 2019-02-28
 // 例如：field:2019 对应查询时间区间从2019-01-01 到 2019-12-31
-func convertToDateRange(property *mapping.Property) convertFunc {
+func convertToDateRange(property *mapping.Property, loc *time.Location) convertFunc {
 	return func(s string) (interface{}, error) {
-		var parser = getDateParserFromMapping(property)
+		var parser = getDateParserFromMapping(property, loc)
 		d, err := parser.Parse(s)
 		if err != nil {
 			return nil, err
@@ -119,6 +121,83 @@ func convertToDateRange(property *mapping.Property) convertFunc {
 	}
 }
 
+type dateMath struct {
+	expr  string
+	value time.Time
+}
+
+// convertToDateMath parse date math expr to `dateMath` object, which keeps original expr and its resolved value.
+// same as es resolves expr of range query, value is rounded up if roundUp is true (i.e. `gt` and `lte`), otherwise rounded down.
+// example: "now/d" is rounded up to 23:59:59.999999999 of today, "2019-02" is rounded up to the end of 2019-02-28
+func convertToDateMath(property *mapping.Property, loc *time.Location, roundUp bool) convertFunc {
+	return func(s string) (interface{}, error) {
+		var parser = getDateParserFromMapping(property, loc)
+		d, err := parser.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		if roundUp {
+			d = roundUpDateMath(s, d)
+		}
+		return &dateMath{expr: s, value: d}, nil
+	}
+}
+
+var dateMathRoundingRegexp = regexp.MustCompile(`/([yMwdhHms])$`)
+
+// roundUpDateMath rounds resolved value of date math expr up to the last nanosecond of rounding unit,
+// date without date math is rounded up by filling missing fields with max value
+func roundUpDateMath(expr string, d time.Time) time.Time {
+	if m := dateMathRoundingRegexp.FindStringSubmatch(expr); m != nil {
+		return addDateUnit(d, m[1][0]).Add(-time.Nanosecond)
+	} else if !strings.HasPrefix(expr, "now") && !strings.Contains(expr, "||") {
+		var _, to = getDateRange(d)
+		return to
+	} else {
+		return d
+	}
+}
+
+func addDateUnit(d time.Time, unit byte) time.Time {
+	var (
+		year, month, day     = d.Date()
+		hour, minute, second = d.Clock()
+	)
+	switch unit {
+	case 'y':
+		return dateInLocation(year+1, month, day, hour, minute, second, d.Nanosecond(), d.Location())
+	case 'M':
+		return dateInLocation(year, month+1, day, hour, minute, second, d.Nanosecond(), d.Location())
+	case 'w':
+		return dateInLocation(year, month, day+7, hour, minute, second, d.Nanosecond(), d.Location())
+	case 'd':
+		return dateInLocation(year, month, day+1, hour, minute, second, d.Nanosecond(), d.Location())
+	case 'h', 'H':
+		return d.Add(time.Hour)
+	case 'm':
+		return d.Add(time.Minute)
+	default:
+		return d.Add(time.Second)
+	}
+}
+
+// dateMathFormat returns format for parsing kept date math expr of range query,
+// dateFormat is candidate formats of printing bounds which are resolved value
+func dateMathFormat(property *mapping.Property, dateFormat string) string {
+	var formats = property.Format
+	if formats == "" {
+		formats = defaultDateFormats[property.Type]
+	}
+	var res = property.Format
+	for _, format := range strings.Split(dateFormat, "||") {
+		if !strings.Contains("||"+formats+"||", "||"+format+"||") {
+			formats += "||" + format
+			res = formats
+		}
+	}
+	return res
+}
+
 // convertToVersion parse string version value to `Version` object.
 func convertToVersion(versionValue string) (interface{}, error) {
 	if v, err := version.NewVersion(versionValue); err != nil {
@@ -153,89 +232,67 @@ var convertToString = func(s string) (interface{}, error) {
 	return s, nil
 }
 
-var monthDay = map[time.Month]int{
-	time.January:  31,
-	time.March:    31,
-	time.May:      31,
-	time.July:     31,
-	time.August:   31,
-	time.October:  31,
-	time.December: 31,
-
-	time.April:     30,
-	time.June:      30,
-	time.September: 30,
-	time.November:  30,
-}
-
-// getMonthDay get number of day in this month
-// example: January has 31 days
-// this func consider leap year
-// example: 2000 year is leap year and February has 29 days
-func getMonthDay(year int, month time.Month) int {
-	if month == time.February {
-		// check year is leap year
-		if (year%4 == 0 && year%100 != 0) || year%400 == 0 {
-			return 29
-		} else {
-			return 28
-		}
-	} else {
-		return monthDay[month]
+// dateInLocation is same as time.Date, except that time in DST gap is moved forward by length of the gap as es does,
+// e.g. 2018-11-04T00:00 is skipped in America/Sao_Paulo, 2018-11-04T01:00-02:00 is returned instead of 2018-11-03T23:00-03:00
+func dateInLocation(year int, month time.Month, day, hour, minute, second, nsec int, loc *time.Location) time.Time {
+	var t = time.Date(year, month, day, hour, minute, second, nsec, loc)
+	var want = time.Date(year, month, day, hour, minute, second, nsec, time.UTC)
+	var wall = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	if wall.Before(want) {
+		return t.Add(want.Sub(wall))
 	}
-
+	return t
 }
 
-const maxHour = 23
-const maxMinute = 59
-const maxSecond = 59
-const maxNano = 999999999
-
-// getDateRange get date range for prefix date.
-// example: given 2021-01-01, we can get [2021-01-01 00:00:00 2021-01-01 23:59:59]
+// getDateRange get date range for prefix date, missing fields of date are filled with max value.
+// example: given 2021-01-01, we can get [2021-01-01 00:00:00 2021-01-01 23:59:59.999999999]
+// end of range is start of next unit minus 1ns in location of the date, so a day crossing DST transition lasts 23 or 25 hours.
 func getDateRange(t time.Time) (time.Time, time.Time) {
-	var month = t.Month()
-	var dateArr = []int{t.Year(), int(month), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond()}
-	var location = t.Location()
-	if dateArr[6] != 0 {
+	var (
+		year, month, day = t.Date()
+		hour, minute, _  = t.Clock()
+		location         = t.Location()
+	)
+	switch {
+	case t.Nanosecond() != 0:
 		return t, t
+	case !t.Equal(dateInLocation(year, month, day, hour, minute, 0, 0, location)):
+		return t, t.Add(time.Second - time.Nanosecond)
+	case !t.Equal(dateInLocation(year, month, day, hour, 0, 0, 0, location)):
+		return t, t.Add(time.Minute - time.Nanosecond)
+	case !t.Equal(dateInLocation(year, month, day, 0, 0, 0, 0, location)):
+		return t, t.Add(time.Hour - time.Nanosecond)
+	case day != 1:
+		return t, dateInLocation(year, month, day+1, 0, 0, 0, 0, location).Add(-time.Nanosecond)
+	case month != time.January:
+		return t, dateInLocation(year, month+1, 1, 0, 0, 0, 0, location).Add(-time.Nanosecond)
+	default:
+		return t, dateInLocation(year+1, time.January, 1, 0, 0, 0, 0, location).Add(-time.Nanosecond)
 	}
-	if dateArr[5] != 0 {
-		return t, time.Date(dateArr[0], month, dateArr[2], dateArr[3], dateArr[4], dateArr[5], maxNano, location)
-	}
-	if dateArr[4] != 0 {
-		return t, time.Date(dateArr[0], month, dateArr[2], dateArr[3], dateArr[4], maxSecond, maxNano, location)
-	}
-	if dateArr[3] != 0 {
-		return t, time.Date(dateArr[0], month, dateArr[2], dateArr[3], maxMinute, maxSecond, maxNano, location)
-	}
-	if dateArr[2] != 1 {
-		return t, time.Date(dateArr[0], month, dateArr[2], maxHour, maxMinute, maxSecond, maxNano, location)
-	}
-	if dateArr[1] != 1 {
-		return t, time.Date(dateArr[0], month, getMonthDay(dateArr[0], month), maxHour, maxMinute, maxSecond, maxNano, location)
-	}
-	return t, time.Date(dateArr[0], time.December, getMonthDay(dateArr[0], time.December), maxHour, maxMinute, maxSecond, maxNano, location)
 }
 
-func getDateParserFromMapping(property *mapping.Property) *datemath_parser.DateMathParser {
+// defaultDateFormats is format of date fields whose format isn't specified in mapping
+var defaultDateFormats = map[mapping.FieldType]string{
+	mapping.DATE_FIELD_TYPE:       DEFAULT_DATE_FORMAT + "||" + datemath_parser.EPOCH_MILLIS,
+	mapping.DATE_RANGE_FIELD_TYPE: DEFAULT_DATE_FORMAT + "||" + datemath_parser.EPOCH_MILLIS,
+	mapping.DATE_NANOS_FIELD_TYPE: utils.DATE_NANOS_FORMAT + "||" + datemath_parser.EPOCH_MILLIS,
+}
+
+// getDateParserFromMapping creates date math parser with format of the field,
+// `now` and date without time zone are resolved in loc if it's not nil.
+func getDateParserFromMapping(property *mapping.Property, loc *time.Location) *datemath_parser.DateMathParser {
 	var opts = []datemath_parser.DateMathParserOption{}
 	if property.Format != "" {
 		opts = append(opts, datemath_parser.WithFormat(strings.Split(property.Format, "||")))
 	} else {
-		if property.Type == mapping.DATE_NANOS_FIELD_TYPE {
-			opts = append(opts, datemath_parser.WithFormat(
-				[]string{"strict_date_optional_time_nanos", "epoch_millis"},
-			))
-		} else {
-			opts = append(opts, datemath_parser.WithFormat(
-				[]string{"strict_date_optional_time", "epoch_millis"},
-			))
-		}
+		opts = append(opts, datemath_parser.WithFormat(strings.Split(defaultDateFormats[property.Type], "||")))
 	}
 	if dp, err := datemath_parser.NewDateMathParser(opts...); err != nil {
 		panic(err)
 	} else {
+		if loc != nil {
+			dp.TimeZone = loc
+		}
 		return dp
 	}
 }
@@ -276,3 +333,8 @@ func toLower(x string) (string, error) {
 func toStrLst(x string) (interface{}, error) {
 	return strings.Split(x, ","), nil
 }
+
+// isObjectFieldType checks whether field type is object type, whose properties are fields
+func isObjectFieldType(t mapping.FieldType) bool {
+	return t == "" || t == "object" || t == NESTED_FIELD_TYPE
+}
@@ -237,7 +237,7 @@ func TestConvertToDate(t *testing.T) {
 			property := &mapping.Property{
 				Format: "yyyy-MM-dd'T'HH:mm:ss'Z'",
 			}
-			var f = convertToDate(property)
+			var f = convertToDate(property, nil)
 			got, err := f(tt.args.timeValue)
 			assert.Equal(t, tt.wantErr, (err != nil))
 			assert.Equal(t, tt.want, got)
@@ -245,6 +245,47 @@ func TestConvertToDate(t *testing.T) {
 	}
 }
 
+func TestConvertToDateMath(t *testing.T) {
+	tests := []struct {
+		name      string
+		timeValue string
+		// roundUpDiff is difference between value rounded up and rounded down
+		roundUpDiff time.Duration
+	}{
+		{"round_day", "2001-01-02T09:09:09Z||/d", 24*time.Hour - time.Nanosecond},
+		{"round_hour", "2001-01-02T09:09:09Z||-1h/h", time.Hour - time.Nanosecond},
+		{"no_rounding", "2001-01-02T09:09:09Z||+7d", 0},
+		{"missing_fields", "2001-01-02T09:09:00Z", time.Minute - time.Nanosecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			property := &mapping.Property{
+				Format: "yyyy-MM-dd'T'HH:mm:ss'Z'",
+			}
+			down, err := convertToDateMath(property, nil, false)(tt.timeValue)
+			assert.Nil(t, err)
+			up, err := convertToDateMath(property, nil, true)(tt.timeValue)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.timeValue, down.(*dateMath).expr)
+			assert.Equal(t, tt.timeValue, up.(*dateMath).expr)
+			assert.Equal(t, tt.roundUpDiff, up.(*dateMath).value.Sub(down.(*dateMath).value))
+		})
+	}
+
+	_, err := convertToDateMath(&mapping.Property{}, nil, true)("1.3x")
+	assert.NotNil(t, err)
+}
+
+func TestDateMathFormat(t *testing.T) {
+	assert.Equal(t, "", dateMathFormat(&mapping.Property{Type: mapping.DATE_FIELD_TYPE}, "epoch_millis"))
+	assert.Equal(t, "", dateMathFormat(&mapping.Property{Type: mapping.DATE_NANOS_FIELD_TYPE}, "strict_date_optional_time_nanos"))
+	assert.Equal(t, "yyyy-MM-dd||epoch_millis", dateMathFormat(&mapping.Property{Type: mapping.DATE_FIELD_TYPE, Format: "yyyy-MM-dd"}, "epoch_millis"))
+	assert.Equal(t, "epoch_millis||yyyy-MM-dd", dateMathFormat(&mapping.Property{Type: mapping.DATE_FIELD_TYPE, Format: "epoch_millis||yyyy-MM-dd"}, "epoch_millis"))
+	assert.Equal(t, "yyyy-MM-dd||strict_date_optional_time_nanos", dateMathFormat(&mapping.Property{Type: mapping.DATE_NANOS_FIELD_TYPE, Format: "yyyy-MM-dd"}, "strict_date_optional_time_nanos"))
+	assert.Equal(t, "yyyy-MM-dd||epoch_second", dateMathFormat(&mapping.Property{Type: mapping.DATE_FIELD_TYPE, Format: "yyyy-MM-dd||epoch_second"}, "yyyy-MM-dd||epoch_second"))
+	assert.Equal(t, "strict_date_optional_time||epoch_millis||yyyy-MM-dd||epoch_second", dateMathFormat(&mapping.Property{Type: mapping.DATE_FIELD_TYPE}, "yyyy-MM-dd||epoch_second"))
+}
+
 func TestConvertToIp(t *testing.T) {
 	type args struct {
 		ipValue string
@@ -483,153 +524,6 @@ func TestConvertToInt(t *testing.T) {
 	}
 }
 
-func TestGetMonthDay(t *testing.T) {
-	type args struct {
-		year  int
-		month time.Month
-	}
-	tests := []struct {
-		name string
-		args args
-		want int
-	}{
-		{
-			name: "test_leap_Feb_01",
-			args: args{
-				year:  2000,
-				month: time.February,
-			},
-			want: 29,
-		},
-		{
-			name: "test_leap_Feb_02",
-			args: args{
-				year:  1600,
-				month: time.February,
-			},
-			want: 29,
-		},
-		{
-			name: "test_leap_Feb_03",
-			args: args{
-				year:  2008,
-				month: time.February,
-			},
-			want: 29,
-		},
-		{
-			name: "test_leap_Feb_04",
-			args: args{
-				year:  1900,
-				month: time.February,
-			},
-			want: 28,
-		},
-		{
-			name: "test_no_leap_Jan",
-			args: args{
-				year:  2007,
-				month: time.January,
-			},
-			want: 31,
-		},
-		{
-			name: "test_no_leap_Feb",
-			args: args{
-				year:  2007,
-				month: time.February,
-			},
-			want: 28,
-		},
-		{
-			name: "test_no_leap_Mar",
-			args: args{
-				year:  2007,
-				month: time.March,
-			},
-			want: 31,
-		},
-		{
-			name: "test_no_leap_Apr",
-			args: args{
-				year:  2007,
-				month: time.April,
-			},
-			want: 30,
-		},
-		{
-			name: "test_no_leap_May",
-			args: args{
-				year:  2007,
-				month: time.May,
-			},
-			want: 31,
-		},
-		{
-			name: "test_no_leap_Jun",
-			args: args{
-				year:  2007,
-				month: time.June,
-			},
-			want: 30,
-		},
-		{
-			name: "test_no_leap_Jul",
-			args: args{
-				year:  2007,
-				month: time.July,
-			},
-			want: 31,
-		},
-		{
-			name: "test_no_leap_Aug",
-			args: args{
-				year:  2007,
-				month: time.July,
-			},
-			want: 31,
-		},
-		{
-			name: "test_no_leap_Sep",
-			args: args{
-				year:  2007,
-				month: time.September,
-			},
-			want: 30,
-		},
-		{
-			name: "test_no_leap_Oct",
-			args: args{
-				year:  2007,
-				month: time.October,
-			},
-			want: 31,
-		},
-		{
-			name: "test_no_leap_Nov",
-			args: args{
-				year:  2007,
-				month: time.November,
-			},
-			want: 30,
-		},
-		{
-			name: "test_no_leap_Dec",
-			args: args{
-				year:  2007,
-				month: time.December,
-			},
-			want: 31,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := getMonthDay(tt.args.year, tt.args.month)
-			assert.Equal(t, tt.want, got)
-		})
-	}
-}
-
 func TestGetDateRange(t *testing.T) {
 	type args struct {
 		t time.Time
@@ -717,6 +611,28 @@ func TestGetDateRange(t *testing.T) {
 	t.Logf("parse time: %+v", k)
 }
 
+func TestGetDateRangeWithDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.Nil(t, err)
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	assert.Nil(t, err)
+
+	// 2021-03-14 has 23 hours in New York
+	from, to := getDateRange(time.Date(2021, time.March, 14, 0, 0, 0, 0, newYork))
+	assert.Equal(t, 23*time.Hour-time.Nanosecond, to.Sub(from))
+	// 2021-11-07 has 25 hours in New York
+	from, to = getDateRange(time.Date(2021, time.November, 7, 0, 0, 0, 0, newYork))
+	assert.Equal(t, 25*time.Hour-time.Nanosecond, to.Sub(from))
+	// midnight of 2018-11-04 is skipped in Sao Paulo, 2018-11-03 ends at 23:59:59.999999999-03:00
+	from, to = getDateRange(time.Date(2018, time.November, 3, 0, 0, 0, 0, saoPaulo))
+	assert.Equal(t, 24*time.Hour-time.Nanosecond, to.Sub(from))
+	assert.Equal(t, time.Date(2018, time.November, 4, 1, 0, 0, 0, saoPaulo), dateInLocation(2018, time.November, 4, 0, 0, 0, 0, saoPaulo))
+	assert.Equal(t, time.Date(2018, time.November, 4, 1, 0, 0, 0, saoPaulo), addDateUnit(from, 'd'))
+	// hour crossing DST transition
+	from, to = getDateRange(time.Date(2021, time.November, 7, 1, 0, 0, 0, newYork))
+	assert.Equal(t, time.Hour-time.Nanosecond, to.Sub(from))
+}
+
 func TestGetDateParserFromMapping(t *testing.T) {
 	type args struct {
 		property *mapping.Property
@@ -772,7 +688,7 @@ func TestGetDateParserFromMapping(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := getDateParserFromMapping(tt.args.property)
+			got := getDateParserFromMapping(tt.args.property, nil)
 			assert.Equal(t, tt.want, got)
 		})
 	}
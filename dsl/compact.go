@@ -0,0 +1,185 @@
+package dsl
+
+import "encoding/json"
+
+// defaultParams are default values of query params in es, which are omitted in compact DSL
+var defaultParams = map[string]DSL{
+	TERM_KEY:     {BOOST_KEY: 1.0},
+	RANGE_KEY:    {BOOST_KEY: 1.0, RELATION_KEY: INTERSECTS},
+	PREFIX_KEY:   {BOOST_KEY: 1.0, REWRITE_KEY: CONSTANT_SCORE},
+	WILDCARD_KEY: {BOOST_KEY: 1.0, REWRITE_KEY: CONSTANT_SCORE},
+	REGEXP_KEY: {
+		BOOST_KEY:   1.0,
+		REWRITE_KEY: CONSTANT_SCORE,
+		FLAGS_KEY:   ALL_FLAG,
+
+		MAX_DETERMINIZED_STATES_KEY: 10000,
+	},
+	// default rewrite of fuzzy query is top_terms_blended_freqs_${max_expansions}, so rewrite is kept
+	FUZZY_KEY: {
+		BOOST_KEY:          1.0,
+		FUZZINESS_KEY:      "AUTO",
+		PREFIX_LENGTH_KEY:  0,
+		MAX_EXPANSIONS_KEY: 50,
+		TRANSPOSITIONS_KEY: true,
+	},
+	MATCH_KEY:               {BOOST_KEY: 1.0, MAX_EXPANSIONS_KEY: 50},
+	MATCH_PHRASE_KEY:        {BOOST_KEY: 1.0, SLOP_KEY: 0},
+	MATCH_PHRASE_PREFIX_KEY: {BOOST_KEY: 1.0, SLOP_KEY: 0, MAX_EXPANSIONS_KEY: 50},
+	QUERY_STRING_KEY:        {BOOST_KEY: 1.0, REWRITE_KEY: CONSTANT_SCORE},
+	GEO_SHAPE_KEY:           {BOOST_KEY: 1.0},
+	GEO_DISTANCE_KEY:        {BOOST_KEY: 1.0},
+	GEO_BOUNDING_BOX_KEY:    {BOOST_KEY: 1.0},
+}
+
+// fieldQueryKeys are kinds of query with form {"<kind>": {"<field>": <params>}}
+var fieldQueryKeys = map[string]bool{
+	TERM_KEY:                true,
+	RANGE_KEY:               true,
+	PREFIX_KEY:              true,
+	WILDCARD_KEY:            true,
+	REGEXP_KEY:              true,
+	FUZZY_KEY:               true,
+	MATCH_KEY:               true,
+	MATCH_PHRASE_KEY:        true,
+	MATCH_PHRASE_PREFIX_KEY: true,
+}
+
+// shortFormKeys are kinds of query which support short form {"<kind>": {"<field>": <value>}}
+// and key of value in the params
+var shortFormKeys = map[string]string{
+	TERM_KEY:                VALUE_KEY,
+	PREFIX_KEY:              VALUE_KEY,
+	WILDCARD_KEY:            VALUE_KEY,
+	REGEXP_KEY:              VALUE_KEY,
+	FUZZY_KEY:               VALUE_KEY,
+	MATCH_KEY:               QUERY_KEY,
+	MATCH_PHRASE_KEY:        QUERY_KEY,
+	MATCH_PHRASE_PREFIX_KEY: QUERY_KEY,
+}
+
+// Compact returns semantically identical DSL in compact form, params equal to es defaults (i.e. `boost: 1`,
+// `relation: INTERSECTS`) are omitted, leaf query with only value uses short form (i.e. `{"term":{"f":"v"}}`)
+// and bool query with single must / should clause is unwrapped
+func Compact(d DSL) DSL {
+	if len(d) == 0 {
+		return d
+	}
+	return compactQuery(d)
+}
+
+func compactQuery(d map[string]interface{}) DSL {
+	if len(d) != 1 {
+		return DSL(d)
+	}
+	var res = DSL{}
+	for kind, body := range d {
+		var m, ok = toMap(body)
+		switch {
+		case !ok:
+			res[kind] = body
+		case kind == BOOL_KEY:
+			return compactBool(m)
+		case fieldQueryKeys[kind]:
+			var fieldRes = DSL{}
+			for field, params := range m {
+				if p, ok := toMap(params); ok {
+					fieldRes[field] = compactParams(kind, p)
+				} else {
+					fieldRes[field] = params
+				}
+			}
+			res[kind] = fieldRes
+		default:
+			res[kind] = compactParams(kind, m)
+		}
+	}
+	return res
+}
+
+// compactParams omits default params and returns value only if query supports short form
+func compactParams(kind string, params map[string]interface{}) interface{} {
+	var res = DSL{}
+	for key, value := range params {
+		if defaultValue, ok := defaultParams[kind][key]; !ok || !jsonEqual(value, defaultValue) {
+			res[key] = value
+		}
+	}
+	if valueKey, ok := shortFormKeys[kind]; ok && len(res) == 1 && res[valueKey] != nil {
+		return res[valueKey]
+	}
+	return res
+}
+
+// compactBool compacts clauses of bool query, omits default minimum_should_match and unwraps single must / should clause
+func compactBool(body map[string]interface{}) DSL {
+	var res = DSL{}
+	for key, value := range body {
+		switch key {
+		case MUST_KEY, FILTER_KEY, SHOULD_KEY, MUST_NOT_KEY:
+			res[key] = compactClauses(value)
+		default:
+			res[key] = value
+		}
+	}
+	// minimum_should_match is 1 by default if bool query has should clauses but no must or filter clauses, otherwise 0
+	var defaultMinimumShouldMatch = 0
+	if res[SHOULD_KEY] != nil && res[MUST_KEY] == nil && res[FILTER_KEY] == nil {
+		defaultMinimumShouldMatch = 1
+	}
+	if value, ok := res[MINIMUM_SHOULD_MATCH_KEY]; ok && jsonEqual(value, defaultMinimumShouldMatch) {
+		delete(res, MINIMUM_SHOULD_MATCH_KEY)
+	}
+	if len(res) == 1 {
+		for key, value := range res {
+			// filter clause doesn't score and must_not clause is inverse, so they can't be unwrapped
+			if d, ok := value.(DSL); ok && (key == MUST_KEY || key == SHOULD_KEY) {
+				return d
+			}
+		}
+	}
+	return DSL{BOOL_KEY: res}
+}
+
+func compactClauses(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []DSL:
+		var res = make([]DSL, 0, len(v))
+		for _, d := range v {
+			res = append(res, compactQuery(d))
+		}
+		return res
+	case []interface{}:
+		var res = make([]DSL, 0, len(v))
+		for _, x := range v {
+			if d, ok := toMap(x); ok {
+				res = append(res, compactQuery(d))
+			}
+		}
+		return res
+	default:
+		if d, ok := toMap(value); ok {
+			return compactQuery(d)
+		}
+		return value
+	}
+}
+
+// toMap converts DSL or map parsed from json to map
+func toMap(x interface{}) (map[string]interface{}, bool) {
+	switch v := x.(type) {
+	case DSL:
+		return v, true
+	case map[string]interface{}:
+		return v, true
+	default:
+		return nil, false
+	}
+}
+
+// jsonEqual checks whether two values are same in json, i.e. int 1, float 1.0 and json.Number "1"
+func jsonEqual(a, b interface{}) bool {
+	x, err1 := json.Marshal(a)
+	y, err2 := json.Marshal(b)
+	return err1 == nil && err2 == nil && string(x) == string(y)
+}
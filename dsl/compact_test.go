@@ -0,0 +1,156 @@
+package dsl
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompact(t *testing.T) {
+	type testCase struct {
+		name string
+		d    DSL
+		want string
+	}
+	for _, tt := range []testCase{
+		{
+			name: "term_short_form",
+			d:    DSL{"term": DSL{"foo": DSL{"value": "bar", "boost": 1.0}}},
+			want: `{"term":{"foo":"bar"}}`,
+		},
+		{
+			name: "term_with_boost",
+			d:    DSL{"term": DSL{"foo": DSL{"value": "bar", "boost": 1.2}}},
+			want: `{"term":{"foo":{"boost":1.2,"value":"bar"}}}`,
+		},
+		{
+			name: "range",
+			d:    DSL{"range": DSL{"foo": DSL{"gte": 1, "lt": 10, "boost": 1.0, "relation": INTERSECTS}}},
+			want: `{"range":{"foo":{"gte":1,"lt":10}}}`,
+		},
+		{
+			name: "range_with_relation",
+			d:    DSL{"range": DSL{"foo": DSL{"gte": 1, "lt": 10, "boost": 1.0, "relation": WITHIN, "format": "epoch_millis"}}},
+			want: `{"range":{"foo":{"format":"epoch_millis","gte":1,"lt":10,"relation":"WITHIN"}}}`,
+		},
+		{
+			name: "regexp",
+			d:    DSL{"regexp": DSL{"foo": DSL{"value": "a.*", "rewrite": CONSTANT_SCORE, "flags": ALL_FLAG, "max_determinized_states": 10000}}},
+			want: `{"regexp":{"foo":"a.*"}}`,
+		},
+		{
+			name: "regexp_with_flags",
+			d:    DSL{"regexp": DSL{"foo": DSL{"value": "a.*", "rewrite": CONSTANT_SCORE, "flags": INTERVAL_FLAG, "max_determinized_states": 10000}}},
+			want: `{"regexp":{"foo":{"flags":"INTERVAL","value":"a.*"}}}`,
+		},
+		{
+			name: "fuzzy_keeps_rewrite",
+			d:    DSL{"fuzzy": DSL{"foo": DSL{"value": "bar", "rewrite": CONSTANT_SCORE, "fuzziness": "AUTO", "prefix_length": 0, "max_expansions": 50, "transpositions": true}}},
+			want: `{"fuzzy":{"foo":{"rewrite":"constant_score","value":"bar"}}}`,
+		},
+		{
+			name: "match",
+			d:    DSL{"match": DSL{"foo": DSL{"query": "bar", "boost": 1.0, "max_expansions": 50}}},
+			want: `{"match":{"foo":"bar"}}`,
+		},
+		{
+			name: "match_with_analyzer",
+			d:    DSL{"match": DSL{"foo": DSL{"query": "bar", "boost": 1.0, "max_expansions": 50, "analyzer": "standard"}}},
+			want: `{"match":{"foo":{"analyzer":"standard","query":"bar"}}}`,
+		},
+		{
+			name: "query_string",
+			d:    DSL{"query_string": DSL{"query": "bar*", "boost": 1.0, "rewrite": CONSTANT_SCORE, "default_field": "foo"}},
+			want: `{"query_string":{"default_field":"foo","query":"bar*"}}`,
+		},
+		{
+			name: "exists",
+			d:    DSL{"exists": DSL{"field": "foo"}},
+			want: `{"exists":{"field":"foo"}}`,
+		},
+		{
+			name: "bool_must",
+			d: DSL{"bool": DSL{"must": []DSL{
+				{"term": DSL{"foo": DSL{"value": "bar", "boost": 1.0}}},
+				{"term": DSL{"bar": DSL{"value": "foo", "boost": 1.0}}},
+			}, "minimum_should_match": 0}},
+			want: `{"bool":{"must":[{"term":{"foo":"bar"}},{"term":{"bar":"foo"}}]}}`,
+		},
+		{
+			name: "bool_should",
+			d: DSL{"bool": DSL{"should": []DSL{
+				{"term": DSL{"foo": DSL{"value": "bar", "boost": 1.0}}},
+				{"term": DSL{"bar": DSL{"value": "foo", "boost": 1.0}}},
+			}, "minimum_should_match": 1}},
+			want: `{"bool":{"should":[{"term":{"foo":"bar"}},{"term":{"bar":"foo"}}]}}`,
+		},
+		{
+			name: "bool_must_and_should",
+			d: DSL{"bool": DSL{
+				"must":                 DSL{"term": DSL{"foo": DSL{"value": "bar", "boost": 1.0}}},
+				"should":               DSL{"term": DSL{"bar": DSL{"value": "foo", "boost": 1.0}}},
+				"minimum_should_match": 1,
+			}},
+			want: `{"bool":{"minimum_should_match":1,"must":{"term":{"foo":"bar"}},"should":{"term":{"bar":"foo"}}}}`,
+		},
+		{
+			name: "bool_unwrap_single_must",
+			d: DSL{"bool": DSL{
+				"must":                 DSL{"term": DSL{"foo": DSL{"value": "bar", "boost": 1.0}}},
+				"minimum_should_match": 0,
+			}},
+			want: `{"term":{"foo":"bar"}}`,
+		},
+		{
+			name: "bool_unwrap_nested_should",
+			d: DSL{"bool": DSL{
+				"should": DSL{"bool": DSL{
+					"must":                 DSL{"exists": DSL{"field": "foo"}},
+					"minimum_should_match": 0,
+				}},
+				"minimum_should_match": 1,
+			}},
+			want: `{"exists":{"field":"foo"}}`,
+		},
+		{
+			name: "bool_keep_single_must_not",
+			d: DSL{"bool": DSL{
+				"must_not":             DSL{"term": DSL{"foo": DSL{"value": "bar", "boost": 1.0}}},
+				"minimum_should_match": 0,
+			}},
+			want: `{"bool":{"must_not":{"term":{"foo":"bar"}}}}`,
+		},
+		{
+			name: "bool_keep_single_filter",
+			d: DSL{"bool": DSL{
+				"filter":               DSL{"term": DSL{"foo": DSL{"value": "bar", "boost": 1.0}}},
+				"minimum_should_match": 0,
+			}},
+			want: `{"bool":{"filter":{"term":{"foo":"bar"}}}}`,
+		},
+		{
+			name: "empty",
+			d:    EmptyDSL,
+			want: `{}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Compact(tt.d).String())
+		})
+	}
+}
+
+func TestCompactParsedDSL(t *testing.T) {
+	var d DSL
+	assert.Nil(t, json.Unmarshal([]byte(`{"bool":{"minimum_should_match":1,"should":[{"term":{"foo":{"boost":1,"value":"bar"}}},{"range":{"x":{"boost":1,"gt":1,"relation":"INTERSECTS"}}}]}}`), &d))
+	assert.Equal(t, `{"bool":{"should":[{"term":{"foo":"bar"}},{"range":{"x":{"gt":1}}}]}}`, Compact(d).String())
+}
+
+func TestJsonEqual(t *testing.T) {
+	assert.True(t, jsonEqual(1, 1.0))
+	assert.True(t, jsonEqual(json.Number("1"), 1))
+	assert.True(t, jsonEqual(INTERSECTS, "INTERSECTS"))
+	assert.False(t, jsonEqual(1, "1"))
+	assert.False(t, jsonEqual(make(chan int), 1))
+}
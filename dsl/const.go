@@ -49,6 +49,10 @@ const (
 	MATCH_PHRASE_DSL_TYPE
 	QUERY_STRING_DSL_TYPE
 	MATCH_PHRASE_PREFIX_DSL_TYPE
+
+	GEO_BOUNDING_BOX_DSL_TYPE
+	GEO_DISTANCE_DSL_TYPE
+	GEO_SHAPE_DSL_TYPE
 )
 
 var (
@@ -68,8 +72,9 @@ var (
 	})
 	MinIP = net.IP([]byte{0, 0, 0, 0})
 
-	MinTime       = time.Unix(math.MinInt64, 0)
-	MaxTime       = time.Unix(math.MaxInt64, 999999999)
+	// bounds of time which can be represented by unix nano, time.Unix(math.MaxInt64, 0) overflows time.Time
+	MinTime       = time.Unix(0, math.MinInt64).UTC()
+	MaxTime       = time.Unix(0, math.MaxInt64).UTC()
 	MinVersion, _ = version.NewVersion("v0-A.0-A.0-A")
 	MaxVersion, _ = version.NewVersion("v9223372036854775807.9223372036854775807.9223372036854775807")
 	MinString     = ""
@@ -195,6 +200,15 @@ const (
 	INTERVAL_FLAG     RegexpFlagType = "INTERVAL"
 	INTERSECTION_FLAG RegexpFlagType = "INTERSECTION"
 	ANYSTRING_FLAG    RegexpFlagType = "ANYSTRING"
+	EMPTY_FLAG        RegexpFlagType = "EMPTY"
+	NONE_FLAG         RegexpFlagType = "NONE"
+)
+
+type SortOrder string
+
+const (
+	ASC_ORDER  SortOrder = "asc"
+	DESC_ORDER SortOrder = "desc"
 )
 
 // dsl key
@@ -243,4 +257,50 @@ const (
 	QUERY_STRING_KEY        = "query_string"
 	MATCH_PHRASE_KEY        = "match_phrase"
 	MATCH_PHRASE_PREFIX_KEY = "match_phrase_prefix"
+
+	GEO_SHAPE_KEY        = "geo_shape"
+	GEO_DISTANCE_KEY     = "geo_distance"
+	GEO_BOUNDING_BOX_KEY = "geo_bounding_box"
+)
+
+// geo query key
+const (
+	LAT_KEY          = "lat"
+	LON_KEY          = "lon"
+	SHAPE_KEY        = "shape"
+	DISTANCE_KEY     = "distance"
+	TOP_LEFT_KEY     = "top_left"
+	COORDINATES_KEY  = "coordinates"
+	BOTTOM_RIGHT_KEY = "bottom_right"
+
+	ENVELOPE_SHAPE_TYPE = "envelope"
+)
+
+// search request key
+const (
+	SIZE_KEY     = "size"
+	FROM_KEY     = "from"
+	SORT_KEY     = "sort"
+	ORDER_KEY    = "order"
+	SOURCE_KEY   = "_source"
+	TIMEOUT_KEY  = "timeout"
+	INCLUDES_KEY = "includes"
+	EXCLUDES_KEY = "excludes"
+
+	SEARCH_AFTER_KEY     = "search_after"
+	RUNTIME_MAPPINGS_KEY = "runtime_mappings"
+	TRACK_TOTAL_HITS_KEY = "track_total_hits"
+)
+
+// highlight key
+const (
+	TYPE_KEY      = "type"
+	FIELDS_KEY    = "fields"
+	PRE_TAGS_KEY  = "pre_tags"
+	POST_TAGS_KEY = "post_tags"
+	HIGHLIGHT_KEY = "highlight"
+
+	FRAGMENT_SIZE_KEY       = "fragment_size"
+	HIGHLIGHT_QUERY_KEY     = "highlight_query"
+	NUMBER_OF_FRAGMENTS_KEY = "number_of_fragments"
 )
@@ -0,0 +1,123 @@
+package dsl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+)
+
+// IGNORED_VALUE replaces literal values in canonical form when values are ignored
+const IGNORED_VALUE = "?"
+
+// literalKeys are keys of literal values in leaf queries
+var literalKeys = map[string]bool{
+	VALUE_KEY:    true,
+	VALUES_KEY:   true,
+	QUERY_KEY:    true,
+	GT.String():  true,
+	GTE.String(): true,
+	LT.String():  true,
+	LTE.String(): true,
+
+	LAT_KEY:         true,
+	LON_KEY:         true,
+	DISTANCE_KEY:    true,
+	COORDINATES_KEY: true,
+}
+
+type fingerprintConfig struct {
+	ignoreValues bool
+}
+
+type FingerprintOption func(*fingerprintConfig)
+
+// WithIgnoreValues specifies whether replacing literal values with "?", so that queries with same shape
+// (i.e. `status:active` and `status:closed`) share a fingerprint
+func WithIgnoreValues(ignoreValues bool) FingerprintOption {
+	return func(c *fingerprintConfig) {
+		c.ignoreValues = ignoreValues
+	}
+}
+
+// Fingerprint hashes ast node in canonical form as hex string of sha256, in which clauses of bool node are sorted
+// and single value range is treated as term, so that equivalent queries (i.e. `a:1 AND b:2`, `b:2 AND a:1` and
+// `a:1 AND b:[2 TO 2]`) share a fingerprint
+func Fingerprint(node AstNode, opts ...FingerprintOption) string {
+	var cfg = &fingerprintConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	var sum = sha256.Sum256([]byte(canonicalJSON(node, cfg)))
+	return hex.EncodeToString(sum[:])
+}
+
+// canonicalJSON returns json of ast node in canonical form
+func canonicalJSON(node AstNode, cfg *fingerprintConfig) string {
+	node = canonicalNode(node)
+	if n, ok := node.(*BoolNode); ok {
+		var res = map[string]interface{}{}
+		for key, nodesMap := range map[string]map[string][]AstNode{
+			MUST_KEY:     n.Must,
+			FILTER_KEY:   n.Filter,
+			SHOULD_KEY:   n.Should,
+			MUST_NOT_KEY: n.MustNot,
+		} {
+			var clauses []json.RawMessage
+			for _, x := range flattenAstNodes(nodesMap) {
+				clauses = append(clauses, json.RawMessage(canonicalJSON(x, cfg)))
+			}
+			sort.Slice(clauses, func(i, j int) bool { return string(clauses[i]) < string(clauses[j]) })
+			if len(clauses) != 0 {
+				res[key] = clauses
+			}
+		}
+		if len(res) != 0 {
+			res[MINIMUM_SHOULD_MATCH_KEY] = n.MinimumShouldMatch
+			data, _ := json.Marshal(DSL{BOOL_KEY: res})
+			return string(data)
+		}
+	}
+	var d interface{} = node.ToDSL()
+	if cfg.ignoreValues {
+		d = ignoreLiteralValues(d)
+	}
+	data, _ := json.Marshal(d)
+	return string(data)
+}
+
+// canonicalNode reduces bool node with single clause and converts single value range node to term node
+func canonicalNode(node AstNode) AstNode {
+	switch n := node.(type) {
+	case *BoolNode:
+		if x := reduceAstNode(n); x != node {
+			return canonicalNode(x)
+		}
+	case *RangeNode:
+		if n.lCmpSym == GTE && n.rCmpSym == LTE && n.lDateMath == "" && n.rDateMath == "" &&
+			!CheckRangeFieldType(n.mType) && CompareAny(n.lValue, n.rValue, n.mType) == 0 {
+			return &TermNode{
+				kvNode:    kvNode{fieldNode: n.fieldNode, valueNode: valueNode{valueType: n.valueType, value: n.lValue}},
+				boostNode: n.boostNode,
+			}
+		}
+	}
+	return node
+}
+
+// ignoreLiteralValues replaces literal values in DSL of leaf node with "?"
+func ignoreLiteralValues(x interface{}) interface{} {
+	if d, ok := x.(DSL); ok {
+		var res = DSL{}
+		for key, value := range d {
+			// literal value is never a DSL, which avoids replacing params of field named as literal key
+			if _, isDSL := value.(DSL); literalKeys[key] && !isDSL {
+				res[key] = IGNORED_VALUE
+			} else {
+				res[key] = ignoreLiteralValues(value)
+			}
+		}
+		return res
+	}
+	return x
+}
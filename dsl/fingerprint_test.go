@@ -0,0 +1,68 @@
+package dsl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	mapping "github.com/zhuliquan/es-mapping"
+)
+
+func TestFingerprint(t *testing.T) {
+	var newTerm = func(field string, value LeafValue) *TermNode {
+		return NewTermNode(NewKVNode(
+			NewFieldNode(NewLfNode(), field),
+			NewValueNode(value, NewValueType(mapping.INTEGER_FIELD_TYPE, false)),
+		))
+	}
+	var newRange = func(field string, lValue, rValue LeafValue, lCmpSym, rCmpSym CompareType) *RangeNode {
+		return NewRangeNode(NewRgNode(
+			NewFieldNode(NewLfNode(), field),
+			NewValueType(mapping.INTEGER_FIELD_TYPE, false),
+			lValue, rValue, lCmpSym, rCmpSym,
+		))
+	}
+	var newAnd = func(nodes ...AstNode) *BoolNode {
+		var n = &BoolNode{opNode: opNode{opType: AND}, Must: map[string][]AstNode{}}
+		for _, node := range nodes {
+			n.Must[OP_KEY] = append(n.Must[OP_KEY], node)
+		}
+		return n
+	}
+
+	var ab = Fingerprint(newAnd(newTerm("a", 1), newTerm("b", 2)))
+	assert.Len(t, ab, 64)
+	assert.Equal(t, ab, Fingerprint(newAnd(newTerm("b", 2), newTerm("a", 1))))
+	assert.Equal(t, ab, Fingerprint(newAnd(newTerm("a", 1), newRange("b", 2, 2, GTE, LTE))))
+	assert.NotEqual(t, ab, Fingerprint(newAnd(newTerm("a", 1), newRange("b", 2, 3, GTE, LTE))))
+	assert.NotEqual(t, ab, Fingerprint(newAnd(newTerm("a", 1), newTerm("b", 3))))
+
+	// single clause bool is same as the clause
+	assert.Equal(t, Fingerprint(newTerm("a", 1)), Fingerprint(newAnd(newTerm("a", 1))))
+	assert.Equal(t, Fingerprint(newTerm("a", 1)), Fingerprint(newRange("a", 1, 1, GTE, LTE)))
+	assert.NotEqual(t, Fingerprint(newTerm("a", 1)), Fingerprint(newRange("a", 1, 1, GT, LTE)))
+
+	// values are ignored
+	var opt = WithIgnoreValues(true)
+	assert.Equal(t, Fingerprint(newTerm("a", 1), opt), Fingerprint(newTerm("a", 2), opt))
+	assert.NotEqual(t, Fingerprint(newTerm("a", 1), opt), Fingerprint(newTerm("b", 1), opt))
+	assert.Equal(t, Fingerprint(newAnd(newTerm("a", 1), newTerm("b", 2)), opt), Fingerprint(newAnd(newTerm("b", 5), newTerm("a", 6)), opt))
+	assert.Equal(t, Fingerprint(newRange("a", 1, 5, GTE, LT), opt), Fingerprint(newRange("a", 3, 9, GTE, LT), opt))
+	assert.NotEqual(t, Fingerprint(newRange("a", 1, 5, GTE, LT), opt), Fingerprint(newRange("a", 1, 5, GT, LT), opt))
+	assert.NotEqual(t, Fingerprint(newTerm("a", 1), opt), Fingerprint(newTerm("a", 1)))
+}
+
+func TestIgnoreLiteralValues(t *testing.T) {
+	assert.Equal(t,
+		DSL{"term": DSL{"value": DSL{"boost": 1.0, "value": "?"}}},
+		ignoreLiteralValues(DSL{"term": DSL{"value": DSL{"boost": 1.0, "value": "foo"}}}),
+	)
+	assert.Equal(t,
+		DSL{"ids": DSL{"values": "?"}},
+		ignoreLiteralValues(DSL{"ids": DSL{"values": []string{"1", "2"}}}),
+	)
+	assert.Equal(t, DSL{"exists": DSL{"field": "foo"}}, ignoreLiteralValues(DSL{"exists": DSL{"field": "foo"}}))
+	assert.Equal(t,
+		DSL{"geo_distance": DSL{"distance": "?", "loc": DSL{"lat": "?", "lon": "?"}, "boost": 1.0}},
+		ignoreLiteralValues(NewGeoDistanceNode(NewFieldNode(NewLfNode(), "loc"), GeoPoint{Lat: 40.7, Lon: -74}, "5km").ToDSL()),
+	)
+}
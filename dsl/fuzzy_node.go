@@ -1,5 +1,12 @@
 package dsl
 
+import (
+	"strconv"
+	"strings"
+
+	"github.com/zhuliquan/lucene-to-dsl/utils"
+)
+
 // fuzzy node represent fuzzy query
 type FuzzyNode struct {
 	kvNode
@@ -57,7 +64,14 @@ func (n *FuzzyNode) UnionJoin(o AstNode) (AstNode, error) {
 	if checkCommonDslType(o.DslType()) {
 		return o.UnionJoin(n)
 	}
+	if n.NodeKey() != o.NodeKey() {
+		return lfNodeUnionJoinLfNode(n.NodeKey(), n, o)
+	}
 	switch o.DslType() {
+	case TERM_DSL_TYPE:
+		return patternNodeUnionJoinTermNode(n, o.(*TermNode))
+	case FUZZY_DSL_TYPE:
+		return fuzzyNodeUnionJoinFuzzyNode(n, o.(*FuzzyNode))
 	default:
 		return lfNodeUnionJoinLfNode(n.NodeKey(), n, o)
 	}
@@ -67,7 +81,14 @@ func (n *FuzzyNode) InterSect(o AstNode) (AstNode, error) {
 	if checkCommonDslType(o.DslType()) {
 		return o.InterSect(n)
 	}
+	if n.NodeKey() != o.NodeKey() {
+		return lfNodeIntersectLfNode(n.NodeKey(), n, o)
+	}
 	switch o.DslType() {
+	case TERM_DSL_TYPE:
+		return patternNodeIntersectTermNode(n, o.(*TermNode))
+	case FUZZY_DSL_TYPE:
+		return fuzzyNodeIntersectFuzzyNode(n, o.(*FuzzyNode))
 	default:
 		return lfNodeIntersectLfNode(n.NodeKey(), n, o)
 	}
@@ -91,3 +112,92 @@ func (n *FuzzyNode) ToDSL() DSL {
 		},
 	}
 }
+
+// Match check text is within fuzziness of value, and has the same first prefix_length chars as value
+func (n *FuzzyNode) Match(text []byte) bool {
+	var maxEdits, ok = n.maxEdits()
+	if !ok {
+		return false
+	}
+	var value, str = []rune(n.value.(string)), []rune(string(text))
+	var prefixLength = n.prefixLength
+	if prefixLength > len(value) {
+		prefixLength = len(value)
+	}
+	if len(str) < prefixLength || string(str[:prefixLength]) != string(value[:prefixLength]) {
+		return false
+	}
+	return minEditDistance(string(value[prefixLength:]), string(str[prefixLength:]), n.transpositions) <= maxEdits
+}
+
+// getMatcher returns fuzzy node itself, which can't be compiled to automaton
+func (n *FuzzyNode) getMatcher() utils.PatternMatcher {
+	return n
+}
+
+// maxEdits resolve fuzziness to max edit distance, i.e. "AUTO", "AUTO:3,6" and "0" / "1" / "2".
+// reference: https://www.elastic.co/guide/en/elasticsearch/reference/current/common-options.html#fuzziness
+func (n *FuzzyNode) maxEdits() (int, bool) {
+	var fuzziness = strings.ToUpper(strings.TrimSpace(n.fuzziness))
+	if strings.HasPrefix(fuzziness, "AUTO") {
+		var low, high = 3, 6
+		if rest := strings.TrimPrefix(fuzziness, "AUTO"); rest != "" {
+			var bounds = strings.Split(strings.TrimPrefix(rest, ":"), ",")
+			if !strings.HasPrefix(rest, ":") || len(bounds) != 2 {
+				return 0, false
+			}
+			var err1, err2 error
+			low, err1 = strconv.Atoi(bounds[0])
+			high, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return 0, false
+			}
+		}
+		var length = len([]rune(n.value.(string)))
+		if length < low {
+			return 0, true
+		} else if length < high {
+			return 1, true
+		} else {
+			return 2, true
+		}
+	}
+	if edits, err := strconv.Atoi(fuzziness); err != nil || edits < 0 {
+		return 0, false
+	} else if edits > 2 {
+		// es limits max edit distance to 2
+		return 2, true
+	} else {
+		return edits, true
+	}
+}
+
+// fuzzyContains check all terms matched by o are matched by n
+func fuzzyContains(n, o *FuzzyNode) bool {
+	var ne, nok = n.maxEdits()
+	var oe, ook = o.maxEdits()
+	return nok && ook && n.value == o.value &&
+		ne >= oe && n.prefixLength <= o.prefixLength &&
+		(n.transpositions || !o.transpositions) &&
+		n.rewrite == o.rewrite && n.getMaxExpands() == o.getMaxExpands()
+}
+
+func fuzzyNodeUnionJoinFuzzyNode(n, o *FuzzyNode) (AstNode, error) {
+	if fuzzyContains(n, o) {
+		return n, nil
+	} else if fuzzyContains(o, n) {
+		return o, nil
+	} else {
+		return lfNodeUnionJoinLfNode(n.NodeKey(), n, o)
+	}
+}
+
+func fuzzyNodeIntersectFuzzyNode(n, o *FuzzyNode) (AstNode, error) {
+	if fuzzyContains(n, o) {
+		return o, nil
+	} else if fuzzyContains(o, n) {
+		return n, nil
+	} else {
+		return lfNodeIntersectLfNode(n.NodeKey(), n, o)
+	}
+}
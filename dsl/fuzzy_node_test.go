@@ -51,3 +51,157 @@ func TestFuzzyNode(t *testing.T) {
 	}, node1.ToDSL())
 
 }
+
+func TestFuzzyNodeMatch(t *testing.T) {
+	var newFuzzyNode = func(value string, opts ...func(AstNode)) *FuzzyNode {
+		return NewFuzzyNode(NewKVNode(
+			NewFieldNode(NewLfNode(), "foo"),
+			NewValueNode(value, NewValueType(mapping.KEYWORD_FIELD_TYPE, false)),
+		), opts...)
+	}
+	tests := []struct {
+		name string
+		node *FuzzyNode
+		text string
+		want bool
+	}{
+		{"auto_short_term_exact", newFuzzyNode("ab"), "ab", true},
+		{"auto_short_term", newFuzzyNode("ab"), "ac", false},
+		{"auto_middle_term", newFuzzyNode("abcd"), "abce", true},
+		{"auto_middle_term_two_edits", newFuzzyNode("abcd"), "abef", false},
+		{"auto_long_term", newFuzzyNode("abcdef"), "abcxyf", true},
+		{"auto_custom_bounds", newFuzzyNode("ab", WithFuzziness("AUTO:1,2")), "ax", true},
+		{"auto_invalid", newFuzzyNode("ab", WithFuzziness("AUTO:1")), "ab", false},
+		{"fuzziness_zero", newFuzzyNode("abcdef", WithFuzziness("0")), "abcdeg", false},
+		{"fuzziness_one", newFuzzyNode("ab", WithFuzziness("1")), "abc", true},
+		{"fuzziness_limited", newFuzzyNode("abcdef", WithFuzziness("5")), "abcxyz", false},
+		{"fuzziness_invalid", newFuzzyNode("ab", WithFuzziness("x")), "ab", false},
+		{"transpositions", newFuzzyNode("abc", WithFuzziness("1")), "acb", true},
+		{"no_transpositions", newFuzzyNode("abc", WithFuzziness("1"), WithTranspositions(false)), "acb", false},
+		{"prefix_length", newFuzzyNode("abcd", WithPrefixLength(1)), "xbcd", false},
+		{"prefix_length_match", newFuzzyNode("abcd", WithPrefixLength(1)), "abce", true},
+		{"prefix_length_longer_than_value", newFuzzyNode("ab", WithFuzziness("1"), WithPrefixLength(3)), "abc", true},
+		{"unicode", newFuzzyNode("你好吗", WithFuzziness("1")), "您好吗", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.node.Match([]byte(tt.text)))
+		})
+	}
+}
+
+func TestFuzzyNodeMergeTermNode(t *testing.T) {
+	var n1 = NewFuzzyNode(NewKVNode(
+		NewFieldNode(NewLfNode(), "foo"),
+		NewValueNode("abcd", NewValueType(mapping.KEYWORD_FIELD_TYPE, false)),
+	))
+	var n2 = NewTermNode(NewKVNode(
+		NewFieldNode(NewLfNode(), "foo"),
+		NewValueNode("abce", NewValueType(mapping.KEYWORD_FIELD_TYPE, false)),
+	))
+	var n3 = NewTermNode(NewKVNode(
+		NewFieldNode(NewLfNode(), "foo"),
+		NewValueNode("xyz", NewValueType(mapping.KEYWORD_FIELD_TYPE, false)),
+	))
+	var n4 = NewTermNode(NewKVNode(
+		NewFieldNode(NewLfNode(), "bar"),
+		NewValueNode("abce", NewValueType(mapping.KEYWORD_FIELD_TYPE, false)),
+	))
+
+	n5, err := n1.UnionJoin(n2)
+	assert.Nil(t, err)
+	assert.Equal(t, n1, n5)
+
+	n5, err = n2.UnionJoin(n1)
+	assert.Nil(t, err)
+	assert.Equal(t, n1, n5)
+
+	n5, err = n1.InterSect(n2)
+	assert.Nil(t, err)
+	assert.Equal(t, n2, n5)
+
+	n5, err = n2.InterSect(n1)
+	assert.Nil(t, err)
+	assert.Equal(t, n2, n5)
+
+	n5, err = n1.UnionJoin(n3)
+	assert.Nil(t, err)
+	assert.Equal(t, &BoolNode{
+		opNode: opNode{opType: OR},
+		Should: map[string][]AstNode{
+			"foo": {n1, n3},
+		},
+		MinimumShouldMatch: 1,
+	}, n5)
+
+	n5, err = n1.InterSect(n3)
+	assert.NotNil(t, err)
+	assert.Nil(t, n5)
+
+	n5, err = n1.UnionJoin(n4)
+	assert.Nil(t, err)
+	assert.Equal(t, &BoolNode{
+		opNode: opNode{opType: OR},
+		Should: map[string][]AstNode{
+			"foo": {n1, n4},
+		},
+		MinimumShouldMatch: 1,
+	}, n5)
+}
+
+func TestFuzzyNodeMergeFuzzyNode(t *testing.T) {
+	var newFuzzyNode = func(value string, opts ...func(AstNode)) *FuzzyNode {
+		return NewFuzzyNode(NewKVNode(
+			NewFieldNode(NewLfNode(), "foo"),
+			NewValueNode(value, NewValueType(mapping.KEYWORD_FIELD_TYPE, true)),
+		), opts...)
+	}
+	var n1 = newFuzzyNode("abcd")
+	var n2 = newFuzzyNode("abcd")
+	var n3 = newFuzzyNode("abcd", WithFuzziness("2"))
+	var n4 = newFuzzyNode("abcd", WithPrefixLength(2), WithTranspositions(false))
+	var n5 = newFuzzyNode("abce")
+
+	n6, err := n1.UnionJoin(n2)
+	assert.Nil(t, err)
+	assert.Equal(t, n1, n6)
+
+	n6, err = n1.InterSect(n2)
+	assert.Nil(t, err)
+	assert.Equal(t, n2, n6)
+
+	n6, err = n1.UnionJoin(n3)
+	assert.Nil(t, err)
+	assert.Equal(t, n3, n6)
+
+	n6, err = n1.InterSect(n3)
+	assert.Nil(t, err)
+	assert.Equal(t, n1, n6)
+
+	n6, err = n4.UnionJoin(n1)
+	assert.Nil(t, err)
+	assert.Equal(t, n1, n6)
+
+	n6, err = n4.InterSect(n1)
+	assert.Nil(t, err)
+	assert.Equal(t, n4, n6)
+
+	n6, err = n1.UnionJoin(n5)
+	assert.Nil(t, err)
+	assert.Equal(t, &BoolNode{
+		opNode: opNode{opType: OR},
+		Should: map[string][]AstNode{
+			"foo": {n1, n5},
+		},
+		MinimumShouldMatch: 1,
+	}, n6)
+
+	n6, err = n1.InterSect(n5)
+	assert.Nil(t, err)
+	assert.Equal(t, &BoolNode{
+		opNode: opNode{opType: AND},
+		Must: map[string][]AstNode{
+			"foo": {n1, n5},
+		},
+	}, n6)
+}
@@ -0,0 +1,62 @@
+package dsl
+
+// geo bounding box node matches geo points within the box, box crosses the dateline if lon of top left is greater than lon of bottom right
+type GeoBoundingBoxNode struct {
+	fieldNode
+	boostNode
+	topLeft     GeoPoint
+	bottomRight GeoPoint
+}
+
+func NewGeoBoundingBoxNode(fieldNode *fieldNode, topLeft, bottomRight GeoPoint, opts ...func(AstNode)) *GeoBoundingBoxNode {
+	var n = &GeoBoundingBoxNode{
+		fieldNode:   *fieldNode,
+		boostNode:   boostNode{boost: 1.0},
+		topLeft:     topLeft,
+		bottomRight: bottomRight,
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+func (n *GeoBoundingBoxNode) DslType() DslType {
+	return GEO_BOUNDING_BOX_DSL_TYPE
+}
+
+func (n *GeoBoundingBoxNode) UnionJoin(o AstNode) (AstNode, error) {
+	if checkCommonDslType(o.DslType()) {
+		return o.UnionJoin(n)
+	}
+	switch o.DslType() {
+	default:
+		return lfNodeUnionJoinLfNode(n.NodeKey(), n, o)
+	}
+}
+
+func (n *GeoBoundingBoxNode) InterSect(o AstNode) (AstNode, error) {
+	if checkCommonDslType(o.DslType()) {
+		return o.InterSect(n)
+	}
+	switch o.DslType() {
+	default:
+		return lfNodeIntersectLfNode(n.NodeKey(), n, o)
+	}
+}
+
+func (n *GeoBoundingBoxNode) Inverse() (AstNode, error) {
+	return inverseNode(n), nil
+}
+
+func (n *GeoBoundingBoxNode) ToDSL() DSL {
+	return DSL{
+		GEO_BOUNDING_BOX_KEY: DSL{
+			n.field: DSL{
+				TOP_LEFT_KEY:     n.topLeft.ToDSL(),
+				BOTTOM_RIGHT_KEY: n.bottomRight.ToDSL(),
+			},
+			BOOST_KEY: n.getBoost(),
+		},
+	}
+}
@@ -0,0 +1,60 @@
+package dsl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeoBoundingBoxNode(t *testing.T) {
+	var node1 = NewGeoBoundingBoxNode(
+		NewFieldNode(NewLfNode(), "loc"),
+		GeoPoint{Lat: 40.9, Lon: -74.2}, GeoPoint{Lat: 40.1, Lon: -73.6},
+		WithBoost(1.5),
+	)
+	var node2 = NewGeoDistanceNode(NewFieldNode(NewLfNode(), "loc"), GeoPoint{Lat: 40.7, Lon: -74}, "5km")
+	var node3 = NewExistsNode(NewFieldNode(NewLfNode(), "loc"))
+	assert.Equal(t, LEAF_NODE_TYPE, node1.AstType())
+	assert.Equal(t, GEO_BOUNDING_BOX_DSL_TYPE, node1.DslType())
+	assert.Equal(t, "loc", node1.NodeKey())
+
+	node4, err := node1.UnionJoin(node2)
+	assert.Nil(t, err)
+	assert.Equal(t, &BoolNode{
+		opNode:             opNode{opType: OR},
+		Should:             map[string][]AstNode{"loc": {node1, node2}},
+		MinimumShouldMatch: 1,
+	}, node4)
+
+	node4, err = node1.InterSect(node2)
+	assert.Nil(t, err)
+	assert.Equal(t, &BoolNode{
+		opNode: opNode{opType: AND},
+		Must:   map[string][]AstNode{"loc": {node1, node2}},
+	}, node4)
+
+	node4, err = node1.UnionJoin(node3)
+	assert.Nil(t, err)
+	assert.Equal(t, node3, node4)
+
+	node4, err = node1.InterSect(node3)
+	assert.Nil(t, err)
+	assert.Equal(t, node1, node4)
+
+	node4, err = node1.Inverse()
+	assert.Nil(t, err)
+	assert.Equal(t, &BoolNode{
+		opNode:  opNode{opType: NOT},
+		MustNot: map[string][]AstNode{"loc": {node1}},
+	}, node4)
+
+	assert.Equal(t, DSL{
+		"geo_bounding_box": DSL{
+			"loc": DSL{
+				"top_left":     DSL{"lat": 40.9, "lon": -74.2},
+				"bottom_right": DSL{"lat": 40.1, "lon": -73.6},
+			},
+			"boost": 1.5,
+		},
+	}, node1.ToDSL())
+}
@@ -0,0 +1,60 @@
+package dsl
+
+// geo distance node matches geo points / shapes within distance (i.e. "5km") of the point
+type GeoDistanceNode struct {
+	fieldNode
+	boostNode
+	point    GeoPoint
+	distance string
+}
+
+func NewGeoDistanceNode(fieldNode *fieldNode, point GeoPoint, distance string, opts ...func(AstNode)) *GeoDistanceNode {
+	var n = &GeoDistanceNode{
+		fieldNode: *fieldNode,
+		boostNode: boostNode{boost: 1.0},
+		point:     point,
+		distance:  distance,
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+func (n *GeoDistanceNode) DslType() DslType {
+	return GEO_DISTANCE_DSL_TYPE
+}
+
+func (n *GeoDistanceNode) UnionJoin(o AstNode) (AstNode, error) {
+	if checkCommonDslType(o.DslType()) {
+		return o.UnionJoin(n)
+	}
+	switch o.DslType() {
+	default:
+		return lfNodeUnionJoinLfNode(n.NodeKey(), n, o)
+	}
+}
+
+func (n *GeoDistanceNode) InterSect(o AstNode) (AstNode, error) {
+	if checkCommonDslType(o.DslType()) {
+		return o.InterSect(n)
+	}
+	switch o.DslType() {
+	default:
+		return lfNodeIntersectLfNode(n.NodeKey(), n, o)
+	}
+}
+
+func (n *GeoDistanceNode) Inverse() (AstNode, error) {
+	return inverseNode(n), nil
+}
+
+func (n *GeoDistanceNode) ToDSL() DSL {
+	return DSL{
+		GEO_DISTANCE_KEY: DSL{
+			n.field:      n.point.ToDSL(),
+			DISTANCE_KEY: n.distance,
+			BOOST_KEY:    n.getBoost(),
+		},
+	}
+}
@@ -0,0 +1,41 @@
+package dsl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	mapping "github.com/zhuliquan/es-mapping"
+)
+
+func TestGeoDistanceNode(t *testing.T) {
+	var node1 = NewGeoDistanceNode(NewFieldNode(NewLfNode(), "loc"), GeoPoint{Lat: 40.7, Lon: -74}, "5km")
+	var node2 = NewTermNode(NewKVNode(
+		NewFieldNode(NewLfNode(), "status"),
+		NewValueNode("open", NewValueType(mapping.KEYWORD_FIELD_TYPE, false)),
+	))
+	assert.Equal(t, LEAF_NODE_TYPE, node1.AstType())
+	assert.Equal(t, GEO_DISTANCE_DSL_TYPE, node1.DslType())
+
+	node3, err := node1.UnionJoin(node2)
+	assert.Nil(t, err)
+	assert.Equal(t, &BoolNode{
+		opNode:             opNode{opType: OR},
+		Should:             map[string][]AstNode{"loc": {node1, node2}},
+		MinimumShouldMatch: 1,
+	}, node3)
+
+	node3, err = node1.Inverse()
+	assert.Nil(t, err)
+	assert.Equal(t, &BoolNode{
+		opNode:  opNode{opType: NOT},
+		MustNot: map[string][]AstNode{"loc": {node1}},
+	}, node3)
+
+	assert.Equal(t, DSL{
+		"geo_distance": DSL{
+			"loc":      DSL{"lat": 40.7, "lon": -74.0},
+			"distance": "5km",
+			"boost":    1.0,
+		},
+	}, node1.ToDSL())
+}
@@ -0,0 +1,71 @@
+package dsl
+
+// geo shape node matches geo shapes having relation (intersects by default) with the envelope of top left and bottom right
+type GeoShapeNode struct {
+	fieldNode
+	boostNode
+	topLeft     GeoPoint
+	bottomRight GeoPoint
+	relation    RelationType
+}
+
+func NewGeoShapeNode(fieldNode *fieldNode, topLeft, bottomRight GeoPoint, opts ...func(AstNode)) *GeoShapeNode {
+	var n = &GeoShapeNode{
+		fieldNode:   *fieldNode,
+		boostNode:   boostNode{boost: 1.0},
+		topLeft:     topLeft,
+		bottomRight: bottomRight,
+		relation:    INTERSECTS,
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+func (n *GeoShapeNode) DslType() DslType {
+	return GEO_SHAPE_DSL_TYPE
+}
+
+func (n *GeoShapeNode) UnionJoin(o AstNode) (AstNode, error) {
+	if checkCommonDslType(o.DslType()) {
+		return o.UnionJoin(n)
+	}
+	switch o.DslType() {
+	default:
+		return lfNodeUnionJoinLfNode(n.NodeKey(), n, o)
+	}
+}
+
+func (n *GeoShapeNode) InterSect(o AstNode) (AstNode, error) {
+	if checkCommonDslType(o.DslType()) {
+		return o.InterSect(n)
+	}
+	switch o.DslType() {
+	default:
+		return lfNodeIntersectLfNode(n.NodeKey(), n, o)
+	}
+}
+
+func (n *GeoShapeNode) Inverse() (AstNode, error) {
+	return inverseNode(n), nil
+}
+
+// ToDSL prints envelope in geojson form, whose coordinates are [[lon, lat] of top left, [lon, lat] of bottom right]
+func (n *GeoShapeNode) ToDSL() DSL {
+	return DSL{
+		GEO_SHAPE_KEY: DSL{
+			n.field: DSL{
+				SHAPE_KEY: DSL{
+					TYPE_KEY: ENVELOPE_SHAPE_TYPE,
+					COORDINATES_KEY: []interface{}{
+						[]interface{}{n.topLeft.Lon, n.topLeft.Lat},
+						[]interface{}{n.bottomRight.Lon, n.bottomRight.Lat},
+					},
+				},
+				RELATION_KEY: n.relation,
+			},
+			BOOST_KEY: n.getBoost(),
+		},
+	}
+}
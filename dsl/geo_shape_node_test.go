@@ -0,0 +1,49 @@
+package dsl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeoShapeNode(t *testing.T) {
+	var node1 = NewGeoShapeNode(
+		NewFieldNode(NewLfNode(), "area"),
+		GeoPoint{Lat: 40.9, Lon: -74.2}, GeoPoint{Lat: 40.1, Lon: -73.6},
+	)
+	var node2 = NewGeoShapeNode(
+		NewFieldNode(NewLfNode(), "area"),
+		GeoPoint{Lat: 10, Lon: 0}, GeoPoint{Lat: 0, Lon: 10},
+		WithRelation(WITHIN),
+	)
+	assert.Equal(t, LEAF_NODE_TYPE, node1.AstType())
+	assert.Equal(t, GEO_SHAPE_DSL_TYPE, node1.DslType())
+	assert.Equal(t, WITHIN, node2.relation)
+
+	node3, err := node1.InterSect(node2)
+	assert.Nil(t, err)
+	assert.Equal(t, &BoolNode{
+		opNode: opNode{opType: AND},
+		Must:   map[string][]AstNode{"area": {node1, node2}},
+	}, node3)
+
+	node3, err = node1.Inverse()
+	assert.Nil(t, err)
+	assert.Equal(t, &BoolNode{
+		opNode:  opNode{opType: NOT},
+		MustNot: map[string][]AstNode{"area": {node1}},
+	}, node3)
+
+	assert.Equal(t, DSL{
+		"geo_shape": DSL{
+			"area": DSL{
+				"shape": DSL{
+					"type":        "envelope",
+					"coordinates": []interface{}{[]interface{}{-74.2, 40.9}, []interface{}{-73.6, 40.1}},
+				},
+				"relation": INTERSECTS,
+			},
+			"boost": 1.0,
+		},
+	}, node1.ToDSL())
+}
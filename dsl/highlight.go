@@ -0,0 +1,177 @@
+package dsl
+
+import (
+	mapping "github.com/zhuliquan/es-mapping"
+)
+
+// highlightFieldSettings are default settings of highlighted field per field type, text is split into
+// fragments by unified highlighter, and whole value of keyword is highlighted as single fragment
+var highlightFieldSettings = map[mapping.FieldType]DSL{
+	mapping.TEXT_FIELD_TYPE: {TYPE_KEY: "unified"},
+	// match_only_text doesn't index positions and offsets, so it's re-analyzed by plain highlighter
+	mapping.MATCH_ONLY_TEXT_FIELD_TYPE:  {TYPE_KEY: "plain"},
+	mapping.KEYWORD_FIELD_TYPE:          {NUMBER_OF_FRAGMENTS_KEY: 0},
+	mapping.CONSTANT_KEYWORD_FIELD_TYPE: {NUMBER_OF_FRAGMENTS_KEY: 0},
+}
+
+type highlightConfig struct {
+	pm                *mapping.PropertyMapping
+	preTags           []string
+	postTags          []string
+	fragmentSize      int
+	numberOfFragments int
+}
+
+type HighlightOption func(*highlightConfig)
+
+// WithHighlightMapping specifies mapping which is used to expand wildcard fields (i.e. "user.*") and alias fields
+// into concrete fields, otherwise fields and field types of ast node are used as is
+func WithHighlightMapping(pm *mapping.PropertyMapping) HighlightOption {
+	return func(c *highlightConfig) {
+		c.pm = pm
+	}
+}
+
+// WithHighlightTags specifies tags wrapping highlighted terms, default tags of es are <em> and </em>
+func WithHighlightTags(preTags, postTags []string) HighlightOption {
+	return func(c *highlightConfig) {
+		c.preTags = preTags
+		c.postTags = postTags
+	}
+}
+
+// WithFragmentSize specifies size of highlighted fragments of text fields in characters
+func WithFragmentSize(fragmentSize int) HighlightOption {
+	return func(c *highlightConfig) {
+		c.fragmentSize = fragmentSize
+	}
+}
+
+// WithNumberOfFragments specifies maximum number of highlighted fragments of text fields
+func WithNumberOfFragments(numberOfFragments int) HighlightOption {
+	return func(c *highlightConfig) {
+		c.numberOfFragments = numberOfFragments
+	}
+}
+
+// Highlight returns highlight section of search request for text / keyword fields queried by ast node,
+// negated clauses are skipped and excluded from highlight_query, so that negated terms aren't highlighted.
+// EmptyDSL is returned if no field can be highlighted
+func Highlight(node AstNode, opts ...HighlightOption) DSL {
+	var cfg = &highlightConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	var fields = DSL{}
+	var should = map[string][]AstNode{}
+	for _, leaf := range positiveLeafNodes(node) {
+		var field, mType, ok = leafFieldType(leaf)
+		if !ok {
+			continue
+		}
+		var highlighted bool
+		for field, mType := range expandHighlightField(field, mType, cfg.pm) {
+			var settings, highlightable = highlightFieldSettings[mType]
+			if !highlightable {
+				continue
+			}
+			if _, ok := fields[field]; !ok {
+				fields[field] = highlightFieldDSL(settings, mType, cfg)
+			}
+			highlighted = true
+		}
+		if highlighted {
+			should[leaf.NodeKey()] = append(should[leaf.NodeKey()], leaf)
+		}
+	}
+	if len(fields) == 0 {
+		return EmptyDSL
+	}
+
+	var query = reduceAstNode(&BoolNode{
+		opNode: opNode{opType: OR},
+		Should: should,
+
+		MinimumShouldMatch: 1,
+	})
+	var res = DSL{
+		FIELDS_KEY:          fields,
+		HIGHLIGHT_QUERY_KEY: query.ToDSL(),
+	}
+	addValueForDSL(res, PRE_TAGS_KEY, cfg.preTags)
+	addValueForDSL(res, POST_TAGS_KEY, cfg.postTags)
+	return res
+}
+
+// expandHighlightField expands wildcard field and alias field of ast node into concrete fields in mapping,
+// field of ast node is returned as is if mapping isn't provided or field doesn't match any mapping
+func expandHighlightField(field string, mType mapping.FieldType, pm *mapping.PropertyMapping) map[string]mapping.FieldType {
+	if pm == nil {
+		return map[string]mapping.FieldType{field: mType}
+	}
+	props, err := pm.GetProperty(field)
+	if err != nil || len(props) == 0 {
+		return map[string]mapping.FieldType{field: mType}
+	}
+	var res = make(map[string]mapping.FieldType, len(props))
+	for key, prop := range props {
+		if prop.Type == "alias" {
+			var path, _ = propertyOption(prop, "path").(string)
+			if targets, err := pm.GetProperty(path); err == nil && targets[path] != nil {
+				res[path] = targets[path].Type
+			}
+		} else {
+			res[key] = prop.Type
+		}
+	}
+	return res
+}
+
+func highlightFieldDSL(settings DSL, mType mapping.FieldType, cfg *highlightConfig) DSL {
+	var res = DSL{}
+	for key, value := range settings {
+		res[key] = value
+	}
+	if mType == mapping.TEXT_FIELD_TYPE || mType == mapping.MATCH_ONLY_TEXT_FIELD_TYPE {
+		addValueForDSL(res, FRAGMENT_SIZE_KEY, cfg.fragmentSize)
+		addValueForDSL(res, NUMBER_OF_FRAGMENTS_KEY, cfg.numberOfFragments)
+	}
+	return res
+}
+
+// positiveLeafNodes returns leaf nodes in must / filter / should clauses, must_not clauses are skipped
+func positiveLeafNodes(node AstNode) []AstNode {
+	if node == nil {
+		return nil
+	}
+	var n, ok = node.(*BoolNode)
+	if !ok {
+		return []AstNode{node}
+	}
+	var res []AstNode
+	for _, nodesMap := range []map[string][]AstNode{n.Must, n.Filter, n.Should} {
+		for _, x := range flattenAstNodes(nodesMap) {
+			res = append(res, positiveLeafNodes(x)...)
+		}
+	}
+	return res
+}
+
+// leafFieldType returns field and field type of leaf node which has both of them
+func leafFieldType(node AstNode) (string, mapping.FieldType, bool) {
+	switch n := node.(type) {
+	case interface {
+		Field() FieldNode
+		Value() ValueNode
+	}:
+		return n.Field().Field(), n.Value().getVType().mType, true
+	case interface {
+		Field() FieldNode
+		ValueType() ValueType
+	}:
+		return n.Field().Field(), n.ValueType().FieldTypeNode(), true
+	default:
+		return "", "", false
+	}
+}
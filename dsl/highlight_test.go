@@ -0,0 +1,184 @@
+package dsl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	mapping "github.com/zhuliquan/es-mapping"
+)
+
+func TestHighlight(t *testing.T) {
+	var status = NewTermNode(NewKVNode(
+		NewFieldNode(NewLfNode(), "status"),
+		NewValueNode("active", NewValueType(mapping.KEYWORD_FIELD_TYPE, false)),
+	))
+	var title = NewMatchNode(NewKVNode(
+		NewFieldNode(NewLfNode(), "title"),
+		NewValueNode("hello", NewValueType(mapping.TEXT_FIELD_TYPE, false)),
+	))
+	var spam = NewMatchNode(NewKVNode(
+		NewFieldNode(NewLfNode(), "title"),
+		NewValueNode("spam", NewValueType(mapping.TEXT_FIELD_TYPE, false)),
+	))
+	var body = NewMatchPhraseNode(NewKVNode(
+		NewFieldNode(NewLfNode(), "body"),
+		NewValueNode("hello world", NewValueType(mapping.MATCH_ONLY_TEXT_FIELD_TYPE, false)),
+	))
+	var count = NewRangeNode(NewRgNode(
+		NewFieldNode(NewLfNode(), "count"),
+		NewValueType(mapping.INTEGER_FIELD_TYPE, false),
+		int64(1), int64(10), GTE, LTE,
+	))
+	var uuid = NewWildCardNode(NewKVNode(
+		NewFieldNode(NewLfNode(), "uuid"),
+		NewValueNode("a*", NewValueType(mapping.WILDCARD_FIELD_TYPE, false)),
+	), nil)
+
+	type testCase struct {
+		name string
+		node AstNode
+		opts []HighlightOption
+		want string
+	}
+	for _, tt := range []testCase{
+		{
+			name: "keyword",
+			node: status,
+			want: `{"fields":{"status":{"number_of_fragments":0}},"highlight_query":{"term":{"status":{"boost":1,"value":"active"}}}}`,
+		},
+		{
+			name: "text_with_options",
+			node: title,
+			opts: []HighlightOption{
+				WithHighlightTags([]string{"[["}, []string{"]]"}),
+				WithFragmentSize(100),
+				WithNumberOfFragments(3),
+			},
+			want: `{"fields":{"title":{"fragment_size":100,"number_of_fragments":3,"type":"unified"}},` +
+				`"highlight_query":{"match":{"title":{"boost":1,"max_expansions":50,"query":"hello"}}},` +
+				`"post_tags":["]]"],"pre_tags":["[["]}`,
+		},
+		{
+			name: "match_only_text",
+			node: body,
+			want: `{"fields":{"body":{"type":"plain"}},"highlight_query":{"match_phrase":{"body":{"boost":1,"query":"hello world"}}}}`,
+		},
+		{
+			name: "skip_negated_and_not_highlightable",
+			node: &BoolNode{
+				opNode: opNode{opType: AND},
+				Must: map[string][]AstNode{
+					"status": {status},
+					"count":  {count},
+					"uuid":   {uuid},
+				},
+				Filter: map[string][]AstNode{
+					"title": {title},
+				},
+				MustNot: map[string][]AstNode{
+					"title": {spam},
+				},
+			},
+			want: `{"fields":{"status":{"number_of_fragments":0},"title":{"type":"unified"}},` +
+				`"highlight_query":{"bool":{"minimum_should_match":1,"should":[` +
+				`{"term":{"status":{"boost":1,"value":"active"}}},` +
+				`{"match":{"title":{"boost":1,"max_expansions":50,"query":"hello"}}}]}}}`,
+		},
+		{
+			name: "nested_negated",
+			node: &BoolNode{
+				opNode: opNode{opType: OR},
+				Should: map[string][]AstNode{
+					"status": {status},
+					"title": {&BoolNode{
+						opNode:  opNode{opType: NOT},
+						MustNot: map[string][]AstNode{"title": {spam}},
+					}},
+				},
+				MinimumShouldMatch: 1,
+			},
+			want: `{"fields":{"status":{"number_of_fragments":0}},"highlight_query":{"term":{"status":{"boost":1,"value":"active"}}}}`,
+		},
+		{
+			name: "no_highlightable_field",
+			node: count,
+			want: `{}`,
+		},
+		{
+			name: "empty",
+			node: &EmptyNode{},
+			want: `{}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Highlight(tt.node, tt.opts...).String())
+		})
+	}
+}
+
+func TestSearchRequestHighlight(t *testing.T) {
+	var node = &BoolNode{
+		opNode: opNode{opType: AND},
+		Must: map[string][]AstNode{
+			"title": {NewMatchNode(NewKVNode(
+				NewFieldNode(NewLfNode(), "title"),
+				NewValueNode("hello", NewValueType(mapping.TEXT_FIELD_TYPE, false)),
+			))},
+		},
+		MustNot: map[string][]AstNode{
+			"status": {NewTermNode(NewKVNode(
+				NewFieldNode(NewLfNode(), "status"),
+				NewValueNode("closed", NewValueType(mapping.KEYWORD_FIELD_TYPE, false)),
+			))},
+		},
+	}
+	var r = NewSearchRequest(node, WithCompactQuery(true), WithHighlight())
+	assert.Equal(t, `{"highlight":{"fields":{"title":{"type":"unified"}},"highlight_query":{"match":{"title":"hello"}}},`+
+		`"query":{"bool":{"must":{"match":{"title":"hello"}},"must_not":{"term":{"status":"closed"}}}}}`, r.ToDSL().String())
+	assert.Equal(t, `{"size":0}`, NewSearchRequest(&EmptyNode{}, WithSize(0), WithHighlight()).ToDSL().String())
+}
+
+func TestHighlightWithMapping(t *testing.T) {
+	pm, err := mapping.LoadMappingData(searchMapping)
+	assert.Nil(t, err)
+
+	var newMatch = func(field string) AstNode {
+		return NewMatchNode(NewKVNode(
+			NewFieldNode(NewLfNode(), field),
+			NewValueNode("hello", NewValueType(mapping.TEXT_FIELD_TYPE, false)),
+		))
+	}
+	type testCase struct {
+		name string
+		node AstNode
+		want string
+	}
+	for _, tt := range []testCase{
+		{
+			name: "wildcard_field",
+			node: newMatch("title*"),
+			want: `{"title":{"type":"unified"},"title.raw":{"number_of_fragments":0}}`,
+		},
+		{
+			name: "alias_field",
+			node: newMatch("headline"),
+			want: `{"title":{"type":"unified"}}`,
+		},
+		{
+			name: "unmapped_field",
+			node: newMatch("missing"),
+			want: `{"missing":{"type":"unified"}}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields = Highlight(tt.node, WithHighlightMapping(pm))[FIELDS_KEY].(DSL)
+			assert.Equal(t, tt.want, fields.String())
+		})
+	}
+
+	t.Run("search_request", func(t *testing.T) {
+		d, err := NewSearchRequest(newMatch("headline"), WithCompactQuery(true), WithHighlight()).Build(pm)
+		assert.Nil(t, err)
+		assert.Equal(t, `{"fields":{"title":{"type":"unified"}},"highlight_query":{"match":{"headline":"hello"}}}`, d[HIGHLIGHT_KEY].(DSL).String())
+	})
+}
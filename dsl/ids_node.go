@@ -73,7 +73,7 @@ func (n *IdsNode) NodeKey() string {
 func (n *IdsNode) ToDSL() DSL {
 	return DSL{
 		IDS_KEY: DSL{
-			VALUES_KEY: n.ids,
+			VALUES_KEY: sortedStrLst(n.ids),
 		},
 	}
 }
@@ -62,5 +62,6 @@ func TestIdsNode(t *testing.T) {
 
 	assert.Equal(t, "_id", node1.NodeKey())
 	assert.Equal(t, DSL{"ids": DSL{"values": []string{"1", "2"}}}, node1.ToDSL())
+	assert.Equal(t, DSL{"ids": DSL{"values": []string{"1", "2", "3"}}}, NewIdsNode(NewLfNode(), []string{"3", "1", "2"}).ToDSL())
 
 }
@@ -0,0 +1,134 @@
+package dsl
+
+import (
+	"sort"
+
+	mapping "github.com/zhuliquan/es-mapping"
+)
+
+// LeafInfo describes a leaf query of converted ast node
+type LeafInfo struct {
+	Field     string            `json:"field,omitempty"`
+	FieldType mapping.FieldType `json:"field_type,omitempty"`
+	// Kind is kind of query in DSL, i.e. "term", "range", "match_phrase"
+	Kind   string        `json:"kind"`
+	Values []interface{} `json:"values,omitempty"`
+	// Bounds are bounds of range query keyed by compare symbol, i.e. {"gte": 1, "lt": 10}
+	Bounds map[string]interface{} `json:"bounds,omitempty"`
+	// Negated indicates whether leaf is under odd number of must_not clauses
+	Negated bool `json:"negated"`
+	// Filter indicates whether leaf is executed in filter context (filter / must_not clause) without scoring
+	Filter bool `json:"filter"`
+	// Depth is number of levels from root to leaf, root leaf is at depth 1
+	Depth int `json:"depth"`
+}
+
+// QueryInfo describes fields, values and operators used by converted ast node
+type QueryInfo struct {
+	Leaves []*LeafInfo `json:"leaves"`
+	// Depth is maximum depth of leaves, which is 0 for empty query
+	Depth int `json:"depth"`
+	// KindCounts are number of leaves per kind of query, i.e. {"term": 2, "range": 1}
+	KindCounts map[string]int `json:"kind_counts"`
+	// ClauseCounts are number of clauses of bool queries per occurrence type, i.e. {"must": 2, "must_not": 1}
+	ClauseCounts map[string]int `json:"clause_counts"`
+}
+
+// Fields returns sorted fields used by leaves
+func (q *QueryInfo) Fields() []string {
+	var set = map[string]bool{}
+	for _, leaf := range q.Leaves {
+		if leaf.Field != "" {
+			set[leaf.Field] = true
+		}
+	}
+	var fields = make([]string, 0, len(set))
+	for field := range set {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Introspect walks ast node and reports every leaf query with its field, type, kind, values and context,
+// leaves are listed in order of DSL (must, filter, should, must_not)
+func Introspect(node AstNode) *QueryInfo {
+	var info = &QueryInfo{
+		Leaves:       []*LeafInfo{},
+		KindCounts:   map[string]int{},
+		ClauseCounts: map[string]int{},
+	}
+	if node != nil {
+		introspectNode(node, info, false, false, 1)
+	}
+	return info
+}
+
+func introspectNode(node AstNode, info *QueryInfo, negated, filter bool, depth int) {
+	if n, ok := node.(*BoolNode); ok {
+		for _, clause := range []struct {
+			key     string
+			nodes   map[string][]AstNode
+			negated bool
+			filter  bool
+		}{
+			{MUST_KEY, n.Must, negated, filter},
+			{FILTER_KEY, n.Filter, negated, true},
+			{SHOULD_KEY, n.Should, negated, filter},
+			{MUST_NOT_KEY, n.MustNot, !negated, true},
+		} {
+			for _, x := range flattenAstNodes(clause.nodes) {
+				info.ClauseCounts[clause.key]++
+				introspectNode(x, info, clause.negated, clause.filter, depth+1)
+			}
+		}
+		return
+	}
+
+	var d = node.ToDSL()
+	if len(d) == 0 {
+		return
+	}
+	var leaf = &LeafInfo{Negated: negated, Filter: filter, Depth: depth}
+	for kind := range d {
+		leaf.Kind = kind
+	}
+	if f, ok := node.(FilterCtxNode); ok && f.GetFilterCtx() {
+		leaf.Filter = true
+	}
+	leaf.Field, leaf.FieldType, _ = leafFieldType(node)
+	switch n := node.(type) {
+	case *RangeNode:
+		leaf.Bounds = rangeBounds(n)
+	case KVNode:
+		leaf.Values = []interface{}{n.Value().(*valueNode).toPrintValue()}
+	case *ExistsNode:
+		leaf.Field = n.field
+	case *GeoBoundingBoxNode, *GeoDistanceNode, *GeoShapeNode:
+		leaf.Field = n.(FieldNode).Field()
+	case *IdsNode:
+		leaf.Field = _ID
+		for _, id := range sortedStrLst(n.ids) {
+			leaf.Values = append(leaf.Values, id)
+		}
+	}
+
+	info.Leaves = append(info.Leaves, leaf)
+	info.KindCounts[leaf.Kind]++
+	if depth > info.Depth {
+		info.Depth = depth
+	}
+}
+
+// rangeBounds returns bounds of range node keyed by compare symbol, infinite bound (i.e. `*` of `count:>10`) is left out
+func rangeBounds(n *RangeNode) map[string]interface{} {
+	var res = map[string]interface{}{}
+	var _, finite = minInf[n.mType]
+	if n.lDateMath != "" || !finite || !isMinInf(n.lValue, n.mType) {
+		res[n.lCmpSym.String()] = n.boundToPrintValue(n.lValue, n.lDateMath, n.lCmpSym == GT)
+	}
+	if n.rDateMath != "" || !finite || !isMaxInf(n.rValue, n.mType) {
+		res[n.rCmpSym.String()] = n.boundToPrintValue(n.rValue, n.rDateMath, n.rCmpSym == LTE)
+	}
+	return res
+}
@@ -0,0 +1,113 @@
+package dsl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	mapping "github.com/zhuliquan/es-mapping"
+)
+
+func TestIntrospect(t *testing.T) {
+	var status = NewTermNode(NewKVNode(
+		NewFieldNode(NewLfNode(), "status"),
+		NewValueNode("active", NewValueType(mapping.KEYWORD_FIELD_TYPE, false)),
+	))
+	var title = NewMatchNode(NewKVNode(
+		NewFieldNode(NewLfNode(), "title"),
+		NewValueNode("hello", NewValueType(mapping.TEXT_FIELD_TYPE, false)),
+	))
+	var count = NewRangeNode(NewRgNode(
+		NewFieldNode(NewLfNode(), "count"),
+		NewValueType(mapping.INTEGER_FIELD_TYPE, false),
+		int64(1), int64(10), GTE, LT,
+	))
+	var filterLf = NewLfNode()
+	filterLf.SetFilterCtx(true)
+	var level = NewTermNode(NewKVNode(
+		NewFieldNode(filterLf, "level"),
+		NewValueNode(int64(3), NewValueType(mapping.BYTE_FIELD_TYPE, false)),
+	))
+
+	t.Run("leaf", func(t *testing.T) {
+		var info = Introspect(status)
+		assert.Equal(t, []*LeafInfo{
+			{Field: "status", FieldType: mapping.KEYWORD_FIELD_TYPE, Kind: TERM_KEY, Values: []interface{}{"active"}, Depth: 1},
+		}, info.Leaves)
+		assert.Equal(t, 1, info.Depth)
+		assert.Equal(t, map[string]int{TERM_KEY: 1}, info.KindCounts)
+		assert.Equal(t, map[string]int{}, info.ClauseCounts)
+	})
+
+	t.Run("bool", func(t *testing.T) {
+		var node = &BoolNode{
+			opNode: opNode{opType: AND},
+			Must: map[string][]AstNode{
+				"title": {title},
+				"level": {level},
+			},
+			Filter: map[string][]AstNode{
+				"count": {count},
+			},
+			MustNot: map[string][]AstNode{
+				"status": {&BoolNode{
+					opNode: opNode{opType: OR},
+					Should: map[string][]AstNode{
+						"status": {status},
+						"ids":    {NewIdsNode(NewLfNode(), []string{"2", "1"})},
+					},
+					MustNot: map[string][]AstNode{
+						"exists": {NewExistsNode(NewFieldNode(NewLfNode(), "deleted"))},
+					},
+					MinimumShouldMatch: 1,
+				}},
+			},
+		}
+		var info = Introspect(node)
+		assert.Equal(t, []*LeafInfo{
+			{Field: "level", FieldType: mapping.BYTE_FIELD_TYPE, Kind: TERM_KEY, Values: []interface{}{int64(3)}, Filter: true, Depth: 2},
+			{Field: "title", FieldType: mapping.TEXT_FIELD_TYPE, Kind: MATCH_KEY, Values: []interface{}{"hello"}, Depth: 2},
+			{Field: "count", FieldType: mapping.INTEGER_FIELD_TYPE, Kind: RANGE_KEY, Bounds: map[string]interface{}{"gte": int64(1), "lt": int64(10)}, Filter: true, Depth: 2},
+			{Field: "_id", Kind: IDS_KEY, Values: []interface{}{"1", "2"}, Negated: true, Filter: true, Depth: 3},
+			{Field: "status", FieldType: mapping.KEYWORD_FIELD_TYPE, Kind: TERM_KEY, Values: []interface{}{"active"}, Negated: true, Filter: true, Depth: 3},
+			{Field: "deleted", Kind: EXISTS_KEY, Negated: false, Filter: true, Depth: 3},
+		}, info.Leaves)
+		assert.Equal(t, 3, info.Depth)
+		assert.Equal(t, map[string]int{TERM_KEY: 2, MATCH_KEY: 1, RANGE_KEY: 1, IDS_KEY: 1, EXISTS_KEY: 1}, info.KindCounts)
+		assert.Equal(t, map[string]int{MUST_KEY: 2, FILTER_KEY: 1, SHOULD_KEY: 2, MUST_NOT_KEY: 2}, info.ClauseCounts)
+		assert.Equal(t, []string{"_id", "count", "deleted", "level", "status", "title"}, info.Fields())
+	})
+
+	t.Run("open_range", func(t *testing.T) {
+		var newRangeNode = func(typ mapping.FieldType, lValue, rValue LeafValue, lCmpSym, rCmpSym CompareType) AstNode {
+			return NewRangeNode(NewRgNode(NewFieldNode(NewLfNode(), "foo"), NewValueType(typ, false), lValue, rValue, lCmpSym, rCmpSym))
+		}
+		for _, tt := range []struct {
+			name string
+			node AstNode
+			want map[string]interface{}
+		}{
+			{"left_open_integer", newRangeNode(mapping.INTEGER_FIELD_TYPE, MinInt[32], int64(10), GT, LTE), map[string]interface{}{"lte": int64(10)}},
+			{"right_open_integer", newRangeNode(mapping.INTEGER_FIELD_TYPE, int64(10), MaxInt[32], GT, LT), map[string]interface{}{"gt": int64(10)}},
+			{"right_open_double", newRangeNode(mapping.DOUBLE_FIELD_TYPE, 1.5, MaxFloat[64], GTE, LT), map[string]interface{}{"gte": 1.5}},
+			{"left_open_keyword", newRangeNode(mapping.KEYWORD_FIELD_TYPE, MinString, "b", GT, LT), map[string]interface{}{"lt": "b"}},
+			{"open_date", newRangeNode(mapping.DATE_FIELD_TYPE, MinTime, MaxTime, GT, LT), map[string]interface{}{}},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				var info = Introspect(tt.node)
+				assert.Equal(t, tt.want, info.Leaves[0].Bounds)
+			})
+		}
+	})
+
+	t.Run("geo", func(t *testing.T) {
+		var info = Introspect(NewGeoDistanceNode(NewFieldNode(NewLfNode(), "loc"), GeoPoint{Lat: 40.7, Lon: -74}, "5km"))
+		assert.Equal(t, []*LeafInfo{{Field: "loc", Kind: GEO_DISTANCE_KEY, Depth: 1}}, info.Leaves)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		var info = Introspect(&EmptyNode{})
+		assert.Equal(t, []*LeafInfo{}, info.Leaves)
+		assert.Equal(t, 0, info.Depth)
+		assert.Equal(t, []string{}, info.Fields())
+	})
+}
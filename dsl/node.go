@@ -246,9 +246,51 @@ type FieldNode interface {
 	Field() string
 }
 
+// scope node interface, nodes of same field in different scopes have different node keys,
+// so that they are never merged (i.e. field is mapped as different types in different indices)
+type ScopeNode interface {
+	setScope(scope string)
+	getScope() string
+}
+
+func WithScope(scope string) func(AstNode) {
+	return func(n AstNode) {
+		if s, ok := n.(ScopeNode); ok {
+			s.setScope(scope)
+		}
+	}
+}
+
+// ScopeAstNode sets scope of every leaf node in node, clauses of bool nodes are keyed by scoped node keys again,
+// so that none of them is merged with nodes of same fields in other scopes
+func ScopeAstNode(node AstNode, scope string) AstNode {
+	if n, ok := node.(*BoolNode); ok {
+		n.Must = scopeAstNodes(n.Must, scope)
+		n.MustNot = scopeAstNodes(n.MustNot, scope)
+		n.Filter = scopeAstNodes(n.Filter, scope)
+		n.Should = scopeAstNodes(n.Should, scope)
+		return n
+	}
+	WithScope(scope)(node)
+	return node
+}
+
+func scopeAstNodes(nodesMap map[string][]AstNode, scope string) map[string][]AstNode {
+	if nodesMap == nil {
+		return nil
+	}
+	var res = make(map[string][]AstNode, len(nodesMap))
+	for _, node := range flattenAstNodes(nodesMap) {
+		node = ScopeAstNode(node, scope)
+		res[node.NodeKey()] = append(res[node.NodeKey()], node)
+	}
+	return res
+}
+
 type fieldNode struct {
 	lfNode
 	field string
+	scope string
 }
 
 func NewFieldNode(lfNode *lfNode, field string) *fieldNode {
@@ -263,9 +305,20 @@ func (n *fieldNode) Field() string {
 }
 
 func (n *fieldNode) NodeKey() string {
+	if n.scope != "" {
+		return n.field + "@" + n.scope
+	}
 	return n.Field()
 }
 
+func (n *fieldNode) setScope(scope string) {
+	n.scope = scope
+}
+
+func (n *fieldNode) getScope() string {
+	return n.scope
+}
+
 type ValueNode interface {
 	getValue() LeafValue
 	getVType() valueType
@@ -376,6 +429,7 @@ func (r *rgNode) RCmpSym() CompareType {
 
 type PatternNode interface {
 	Match([]byte) bool
+	getMatcher() utils.PatternMatcher
 }
 
 type patternNode struct {
@@ -385,3 +439,20 @@ type patternNode struct {
 func (n *patternNode) Match(text []byte) bool {
 	return n.matcher.Match(text)
 }
+
+func (n *patternNode) getMatcher() utils.PatternMatcher {
+	return n.matcher
+}
+
+// GeoPoint is point of geo queries, coordinates are in degrees
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+func (p GeoPoint) ToDSL() DSL {
+	return DSL{
+		LAT_KEY: p.Lat,
+		LON_KEY: p.Lon,
+	}
+}
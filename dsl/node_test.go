@@ -31,6 +31,56 @@ func TestLeafNode(t *testing.T) {
 func TestFieldNode(t *testing.T) {
 	var n = NewFieldNode(NewLfNode(), "foo")
 	assert.Equal(t, "foo", n.NodeKey())
+	n.setScope("idx-1,idx-2")
+	assert.Equal(t, "foo@idx-1,idx-2", n.NodeKey())
+	assert.Equal(t, "foo", n.Field())
+}
+
+func TestScopedNodes(t *testing.T) {
+	var keyword = NewTermNode(NewKVNode(
+		NewFieldNode(NewLfNode(), "foo"),
+		NewValueNode("5", NewValueType(mapping.KEYWORD_FIELD_TYPE, false)),
+	), WithScope("idx-1"))
+	var long = NewTermNode(NewKVNode(
+		NewFieldNode(NewLfNode(), "foo"),
+		NewValueNode(int64(5), NewValueType(mapping.LONG_FIELD_TYPE, false)),
+	), WithScope("idx-2"))
+	assert.Equal(t, "idx-1", keyword.getScope())
+
+	// nodes of different scopes aren't compared or merged, though their values are typed differently
+	var node, err = keyword.UnionJoin(long)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"bool":{"minimum_should_match":1,"should":[{"term":{"foo":{"boost":1,"value":"5"}}},{"term":{"foo":{"boost":1,"value":5}}}]}}`, node.ToDSL().String())
+	node, err = keyword.InterSect(long)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"bool":{"minimum_should_match":0,"must":[{"term":{"foo":{"boost":1,"value":"5"}}},{"term":{"foo":{"boost":1,"value":5}}}]}}`, node.ToDSL().String())
+}
+
+func TestScopeAstNode(t *testing.T) {
+	var newTerm = func(value interface{}, typ mapping.FieldType) AstNode {
+		return NewTermNode(NewKVNode(NewFieldNode(NewLfNode(), "foo"), NewValueNode(value, NewValueType(typ, false))))
+	}
+	var keywords, err = newTerm("1", mapping.KEYWORD_FIELD_TYPE).UnionJoin(newTerm("2", mapping.KEYWORD_FIELD_TYPE))
+	assert.Nil(t, err)
+	longs, err := newTerm(int64(1), mapping.LONG_FIELD_TYPE).UnionJoin(newTerm(int64(2), mapping.LONG_FIELD_TYPE))
+	assert.Nil(t, err)
+	keywords = ScopeAstNode(keywords, "idx-1")
+	longs = ScopeAstNode(longs, "idx-2")
+	for _, nodes := range keywords.(*BoolNode).Should {
+		for _, node := range nodes {
+			assert.Equal(t, "foo@idx-1", node.NodeKey())
+		}
+	}
+
+	// leaves of bool nodes in different scopes aren't compared or merged
+	node, err := keywords.UnionJoin(longs)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"bool":{"should":[{"term":{"foo":"1"}},{"term":{"foo":"2"}},{"term":{"foo":1}},{"term":{"foo":2}}]}}`, Compact(node.ToDSL()).String())
+	inverse, err := longs.Inverse()
+	assert.Nil(t, err)
+	node, err = ScopeAstNode(newTerm("3", mapping.KEYWORD_FIELD_TYPE), "idx-1").InterSect(inverse)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"bool":{"must":{"term":{"foo":"3"}},"must_not":[{"term":{"foo":1}},{"term":{"foo":2}}]}}`, Compact(node.ToDSL()).String())
 }
 
 func TestValueNode(t *testing.T) {
@@ -41,6 +41,8 @@ func (n *PrefixNode) UnionJoin(o AstNode) (AstNode, error) {
 		return patternNodeUnionJoinTermNode(n, o.(*TermNode))
 	case PREFIX_DSL_TYPE:
 		return prefixNodeUnionJoinPrefixNode(n, o.(*PrefixNode))
+	case WILDCARD_DSL_TYPE, REGEXP_DSL_TYPE:
+		return patternNodeUnionJoinPatternNode(n, o)
 	default:
 		return lfNodeUnionJoinLfNode(n.NodeKey(), n, o)
 	}
@@ -58,6 +60,8 @@ func (n *PrefixNode) InterSect(o AstNode) (AstNode, error) {
 		return patternNodeIntersectTermNode(n, o.(*TermNode))
 	case PREFIX_DSL_TYPE:
 		return prefixNodeIntersectPrefixNode(n, o.(*PrefixNode))
+	case WILDCARD_DSL_TYPE, REGEXP_DSL_TYPE:
+		return patternNodeIntersectPatternNode(n, o)
 	default:
 		return lfNodeIntersectLfNode(n.NodeKey(), n, o)
 	}
@@ -190,3 +190,86 @@ func TestPrefixNodeIntersectPrefixNode(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Nil(t, nil, n4)
 }
+
+func TestPrefixNodeMergePatternNode(t *testing.T) {
+	var n1 = NewPrefixNode(NewKVNode(
+		NewFieldNode(NewLfNode(), "foo"),
+		NewValueNode("ab", NewValueType(mapping.KEYWORD_FIELD_TYPE, false)),
+	), utils.NewPrefixPattern("ab"))
+
+	var n2 = NewWildCardNode(NewKVNode(
+		NewFieldNode(NewLfNode(), "foo"),
+		NewValueNode("ab?d", NewValueType(mapping.KEYWORD_FIELD_TYPE, false)),
+	), utils.NewWildCardPattern("ab?d"))
+
+	var n3 = NewWildCardNode(NewKVNode(
+		NewFieldNode(NewLfNode(), "foo"),
+		NewValueNode("f*o", NewValueType(mapping.KEYWORD_FIELD_TYPE, false)),
+	), utils.NewWildCardPattern("f*o"))
+
+	var n4 = NewWildCardNode(NewKVNode(
+		NewFieldNode(NewLfNode(), "foo"),
+		NewValueNode("*b*", NewValueType(mapping.KEYWORD_FIELD_TYPE, false)),
+	), utils.NewWildCardPattern("*b*"))
+
+	pattern, _ := utils.NewRegexpPattern("ab.*", utils.ALL_REGEXP_FLAG)
+	var n5 = NewRegexpNode(NewKVNode(
+		NewFieldNode(NewLfNode(), "foo"),
+		NewValueNode("ab.*", NewValueType(mapping.KEYWORD_FIELD_TYPE, false)),
+	), pattern)
+
+	// ab?d is contained by ab*
+	n6, err := n1.UnionJoin(n2)
+	assert.Nil(t, err)
+	assert.Equal(t, n1, n6)
+
+	n6, err = n2.UnionJoin(n1)
+	assert.Nil(t, err)
+	assert.Equal(t, n1, n6)
+
+	n6, err = n1.InterSect(n2)
+	assert.Nil(t, err)
+	assert.Equal(t, n2, n6)
+
+	n6, err = n2.InterSect(n1)
+	assert.Nil(t, err)
+	assert.Equal(t, n2, n6)
+
+	// ab* and f*o are disjoint
+	n6, err = n1.UnionJoin(n3)
+	assert.Nil(t, err)
+	assert.Equal(t, &BoolNode{
+		opNode: opNode{opType: OR},
+		Should: map[string][]AstNode{
+			"foo": {n1, n3},
+		},
+		MinimumShouldMatch: 1,
+	}, n6)
+
+	n6, err = n1.InterSect(n3)
+	assert.NotNil(t, err)
+	assert.Nil(t, n6)
+
+	// ab* and *b* are overlapped, but neither contains another one
+	n6, err = n2.InterSect(n4)
+	assert.Nil(t, err)
+	assert.Equal(t, n2, n6)
+
+	n6, err = n3.InterSect(n4)
+	assert.Nil(t, err)
+	assert.Equal(t, &BoolNode{
+		opNode: opNode{opType: AND},
+		Must: map[string][]AstNode{
+			"foo": {n3, n4},
+		},
+	}, n6)
+
+	// ab.* is same as ab*
+	n6, err = n1.UnionJoin(n5)
+	assert.Nil(t, err)
+	assert.Equal(t, n1, n6)
+
+	n6, err = n5.InterSect(n1)
+	assert.Nil(t, err)
+	assert.Equal(t, n5, n6)
+}
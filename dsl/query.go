@@ -0,0 +1,1102 @@
+package dsl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Query is strongly typed form of DSL, it's marshaled to same json as DSL and DSL is kept as compatibility layer
+type Query interface {
+	json.Marshaler
+	json.Unmarshaler
+	ToDSL() DSL
+}
+
+// NewQuery converts ast node to typed query, nil is returned for empty node
+func NewQuery(node AstNode) (Query, error) {
+	switch n := node.(type) {
+	case *EmptyNode:
+		return nil, nil
+	case *BoolNode:
+		return newBoolQuery(n)
+	case *TermNode:
+		return &TermQuery{Field: n.field, Value: n.toPrintValue(), Boost: n.getBoost()}, nil
+	case *RangeNode:
+		return n.toQuery(), nil
+	case *PrefixNode:
+		return &PrefixQuery{Field: n.field, Value: n.toPrintValue().(string), Rewrite: n.getRewrite()}, nil
+	case *WildCardNode:
+		return &WildcardQuery{Field: n.field, Value: n.toPrintValue().(string), Boost: n.getBoost(), Rewrite: n.getRewrite()}, nil
+	case *RegexpNode:
+		return &RegexpQuery{
+			Field:                 n.field,
+			Value:                 n.toPrintValue().(string),
+			Rewrite:               n.getRewrite(),
+			Flags:                 n.flags,
+			MaxDeterminizedStates: n.getMaxDeterminizedStates(),
+		}, nil
+	case *FuzzyNode:
+		return &FuzzyQuery{
+			Field:          n.field,
+			Value:          n.toPrintValue(),
+			Rewrite:        n.rewrite,
+			Fuzziness:      n.fuzziness,
+			PrefixLength:   n.prefixLength,
+			MaxExpansions:  n.getMaxExpands(),
+			Transpositions: n.transpositions,
+		}, nil
+	case *ExistsNode:
+		return &ExistsQuery{Field: n.field}, nil
+	case *IdsNode:
+		return &IdsQuery{Values: sortedStrLst(n.ids)}, nil
+	case *MatchNode:
+		return &MatchQuery{
+			Field:         n.field,
+			Query:         n.toPrintValue(),
+			Boost:         n.getBoost(),
+			MaxExpansions: n.getMaxExpands(),
+			Analyzer:      n.getAnaLyzer(),
+		}, nil
+	case *MatchPhraseNode:
+		return &MatchPhraseQuery{Field: n.field, Query: n.getValue(), Boost: n.getBoost(), Analyzer: n.getAnaLyzer()}, nil
+	case *MatchPhrasePrefixNode:
+		return &MatchPhrasePrefixQuery{
+			Field:         n.field,
+			Query:         n.toPrintValue(),
+			Slop:          n.getSlop(),
+			MaxExpansions: n.getMaxExpands(),
+			Analyzer:      n.getAnaLyzer(),
+		}, nil
+	case *QueryStringNode:
+		return &QueryStringQuery{
+			Query:        n.toPrintValue(),
+			Boost:        n.getBoost(),
+			Rewrite:      n.getRewrite(),
+			DefaultField: n.field,
+			Analyzer:     n.getAnaLyzer(),
+		}, nil
+	case *MatchAllNode:
+		return &MatchAllQuery{}, nil
+	case *GeoBoundingBoxNode:
+		return &GeoBoundingBoxQuery{Field: n.field, TopLeft: n.topLeft, BottomRight: n.bottomRight, Boost: n.getBoost()}, nil
+	case *GeoDistanceNode:
+		return &GeoDistanceQuery{Field: n.field, Point: n.point, Distance: n.distance, Boost: n.getBoost()}, nil
+	case *GeoShapeNode:
+		return &GeoShapeQuery{
+			Field: n.field,
+			Shape: map[string]interface{}{
+				TYPE_KEY: ENVELOPE_SHAPE_TYPE,
+				COORDINATES_KEY: []interface{}{
+					[]interface{}{n.topLeft.Lon, n.topLeft.Lat},
+					[]interface{}{n.bottomRight.Lon, n.bottomRight.Lat},
+				},
+			},
+			Relation: n.relation,
+			Boost:    n.getBoost(),
+		}, nil
+	default:
+		return nil, fmt.Errorf("node: %T can't be converted to typed query", node)
+	}
+}
+
+// newBoolQuery converts bool node to typed query, clauses are ordered same as DSL of bool node
+func newBoolQuery(n *BoolNode) (Query, error) {
+	var res = &BoolQuery{MinimumShouldMatch: n.MinimumShouldMatch}
+	for _, clause := range []struct {
+		nodes   map[string][]AstNode
+		queries *[]Query
+	}{
+		{n.Must, &res.Must},
+		{n.Filter, &res.Filter},
+		{n.Should, &res.Should},
+		{n.MustNot, &res.MustNot},
+	} {
+		for _, node := range flattenAstNodes(clause.nodes) {
+			q, err := NewQuery(node)
+			if err != nil {
+				return nil, err
+			}
+			*clause.queries = append(*clause.queries, q)
+		}
+	}
+	if len(res.Must) == 0 && len(res.Filter) == 0 && len(res.Should) == 0 && len(res.MustNot) == 0 {
+		return nil, nil
+	}
+	return res, nil
+}
+
+// DSLToQuery converts DSL map to typed query, nil is returned for empty DSL
+func DSLToQuery(d DSL) (Query, error) {
+	if len(d) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalQuery(data)
+}
+
+// UnmarshalQuery parses json of query (i.e. `{"term": {"foo": {"value": "bar"}}}`) to typed query
+func UnmarshalQuery(data []byte) (Query, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if len(m) != 1 {
+		return nil, fmt.Errorf("expect query with single kind, but got: %s", data)
+	}
+	var q Query
+	for kind := range m {
+		switch kind {
+		case BOOL_KEY:
+			q = &BoolQuery{}
+		case TERM_KEY:
+			q = &TermQuery{}
+		case TERMS_KEY:
+			q = &TermsQuery{}
+		case RANGE_KEY:
+			q = &RangeQuery{}
+		case PREFIX_KEY:
+			q = &PrefixQuery{}
+		case WILDCARD_KEY:
+			q = &WildcardQuery{}
+		case REGEXP_KEY:
+			q = &RegexpQuery{}
+		case FUZZY_KEY:
+			q = &FuzzyQuery{}
+		case EXISTS_KEY:
+			q = &ExistsQuery{}
+		case IDS_KEY:
+			q = &IdsQuery{}
+		case MATCH_KEY:
+			q = &MatchQuery{}
+		case MATCH_PHRASE_KEY:
+			q = &MatchPhraseQuery{}
+		case MATCH_PHRASE_PREFIX_KEY:
+			q = &MatchPhrasePrefixQuery{}
+		case QUERY_STRING_KEY:
+			q = &QueryStringQuery{}
+		case MATCH_ALL_KEY:
+			q = &MatchAllQuery{}
+		case GEO_BOUNDING_BOX_KEY:
+			q = &GeoBoundingBoxQuery{}
+		case GEO_DISTANCE_KEY:
+			q = &GeoDistanceQuery{}
+		case GEO_SHAPE_KEY:
+			q = &GeoShapeQuery{}
+		default:
+			return nil, fmt.Errorf("query kind: %s is unsupported", kind)
+		}
+	}
+	if err := q.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// BoolQuery represents bool query, clause with single query is printed as object instead of array
+type BoolQuery struct {
+	Must               []Query
+	Filter             []Query
+	Should             []Query
+	MustNot            []Query
+	MinimumShouldMatch int
+}
+
+func (q *BoolQuery) ToDSL() DSL {
+	var res = DSL{}
+	if len(q.Must) != 0 {
+		res[MUST_KEY] = reduceDSLList(queriesToDSLList(q.Must))
+	}
+	if len(q.Filter) != 0 {
+		res[FILTER_KEY] = reduceDSLList(queriesToDSLList(q.Filter))
+	}
+	if len(q.Should) != 0 {
+		res[SHOULD_KEY] = reduceDSLList(queriesToDSLList(q.Should))
+	}
+	if len(q.MustNot) != 0 {
+		res[MUST_NOT_KEY] = reduceDSLList(queriesToDSLList(q.MustNot))
+	}
+	if len(res) == 0 {
+		return EmptyDSL
+	}
+	res[MINIMUM_SHOULD_MATCH_KEY] = q.MinimumShouldMatch
+	return DSL{BOOL_KEY: res}
+}
+
+func (q *BoolQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.ToDSL())
+}
+
+func (q *BoolQuery) UnmarshalJSON(data []byte) error {
+	body, err := unwrapQuery(data, BOOL_KEY)
+	if err != nil {
+		return err
+	}
+	var params struct {
+		Must               json.RawMessage `json:"must"`
+		Filter             json.RawMessage `json:"filter"`
+		Should             json.RawMessage `json:"should"`
+		MustNot            json.RawMessage `json:"must_not"`
+		MinimumShouldMatch *int            `json:"minimum_should_match"`
+	}
+	if err := json.Unmarshal(body, &params); err != nil {
+		return err
+	}
+	var res = BoolQuery{}
+	if res.Must, err = unmarshalClauses(params.Must); err != nil {
+		return err
+	}
+	if res.Filter, err = unmarshalClauses(params.Filter); err != nil {
+		return err
+	}
+	if res.Should, err = unmarshalClauses(params.Should); err != nil {
+		return err
+	}
+	if res.MustNot, err = unmarshalClauses(params.MustNot); err != nil {
+		return err
+	}
+	// minimum_should_match is 1 by default if bool query has should clauses but no must or filter clauses, otherwise 0
+	if params.MinimumShouldMatch != nil {
+		res.MinimumShouldMatch = *params.MinimumShouldMatch
+	} else if len(res.Should) != 0 && len(res.Must) == 0 && len(res.Filter) == 0 {
+		res.MinimumShouldMatch = 1
+	}
+	*q = res
+	return nil
+}
+
+// TermQuery represents term query
+type TermQuery struct {
+	Field string
+	Value interface{}
+	Boost float64
+}
+
+func (q *TermQuery) ToDSL() DSL {
+	var d = DSL{VALUE_KEY: q.Value}
+	addValueForDSL(d, BOOST_KEY, q.Boost)
+	return DSL{TERM_KEY: DSL{q.Field: d}}
+}
+
+func (q *TermQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.ToDSL())
+}
+
+func (q *TermQuery) UnmarshalJSON(data []byte) error {
+	field, body, err := unwrapFieldQuery(data, TERM_KEY)
+	if err != nil {
+		return err
+	}
+	var params struct {
+		Value interface{} `json:"value"`
+		Boost float64     `json:"boost"`
+	}
+	if err := decodeParams(TERM_KEY, body, &params); err != nil {
+		return err
+	}
+	*q = TermQuery{Field: field, Value: params.Value, Boost: params.Boost}
+	return nil
+}
+
+// TermsQuery represents terms query, values are printed in order of their json
+type TermsQuery struct {
+	Field  string
+	Values []interface{}
+	Boost  float64
+}
+
+func (q *TermsQuery) ToDSL() DSL {
+	var d = DSL{q.Field: sortedJSONValues(q.Values)}
+	addValueForDSL(d, BOOST_KEY, q.Boost)
+	return DSL{TERMS_KEY: d}
+}
+
+func (q *TermsQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.ToDSL())
+}
+
+func (q *TermsQuery) UnmarshalJSON(data []byte) error {
+	body, err := unwrapQuery(data, TERMS_KEY)
+	if err != nil {
+		return err
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(body, &m); err != nil {
+		return err
+	}
+	var res = TermsQuery{}
+	for key, value := range m {
+		if key == BOOST_KEY {
+			if err := json.Unmarshal(value, &res.Boost); err != nil {
+				return err
+			}
+		} else if res.Field != "" {
+			return fmt.Errorf("expect terms query on single field, but got: %s", data)
+		} else {
+			res.Field = key
+			if err := decodeJSON(value, &res.Values); err != nil {
+				return err
+			}
+		}
+	}
+	*q = res
+	return nil
+}
+
+// RangeQuery represents range query, nil bound is omitted
+type RangeQuery struct {
+	Field    string
+	Gt       interface{}
+	Gte      interface{}
+	Lt       interface{}
+	Lte      interface{}
+	Boost    float64
+	Relation RelationType
+	Format   string
+	TimeZone string
+}
+
+func (q *RangeQuery) ToDSL() DSL {
+	var res = DSL{}
+	addValueForDSL(res, BOOST_KEY, q.Boost)
+	addValueForDSL(res, RELATION_KEY, q.Relation)
+	for cmpSym, value := range map[CompareType]interface{}{GT: q.Gt, GTE: q.Gte, LT: q.Lt, LTE: q.Lte} {
+		if value != nil {
+			res[cmpSym.String()] = value
+		}
+	}
+	addValueForDSL(res, FORMAT_KEY, q.Format)
+	addValueForDSL(res, TIME_ZONE_KEY, q.TimeZone)
+	return DSL{RANGE_KEY: DSL{q.Field: res}}
+}
+
+func (q *RangeQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.ToDSL())
+}
+
+func (q *RangeQuery) UnmarshalJSON(data []byte) error {
+	field, body, err := unwrapFieldQuery(data, RANGE_KEY)
+	if err != nil {
+		return err
+	}
+	var params struct {
+		Gt       interface{}  `json:"gt"`
+		Gte      interface{}  `json:"gte"`
+		Lt       interface{}  `json:"lt"`
+		Lte      interface{}  `json:"lte"`
+		Boost    float64      `json:"boost"`
+		Relation RelationType `json:"relation"`
+		Format   string       `json:"format"`
+		TimeZone string       `json:"time_zone"`
+	}
+	if err := decodeParams(RANGE_KEY, body, &params); err != nil {
+		return err
+	}
+	*q = RangeQuery{
+		Field:    field,
+		Gt:       params.Gt,
+		Gte:      params.Gte,
+		Lt:       params.Lt,
+		Lte:      params.Lte,
+		Boost:    params.Boost,
+		Relation: params.Relation,
+		Format:   params.Format,
+		TimeZone: params.TimeZone,
+	}
+	return nil
+}
+
+// PrefixQuery represents prefix query
+type PrefixQuery struct {
+	Field   string
+	Value   string
+	Rewrite RewriteType
+}
+
+func (q *PrefixQuery) ToDSL() DSL {
+	var d = DSL{VALUE_KEY: q.Value}
+	addValueForDSL(d, REWRITE_KEY, q.Rewrite)
+	return DSL{PREFIX_KEY: DSL{q.Field: d}}
+}
+
+func (q *PrefixQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.ToDSL())
+}
+
+func (q *PrefixQuery) UnmarshalJSON(data []byte) error {
+	field, body, err := unwrapFieldQuery(data, PREFIX_KEY)
+	if err != nil {
+		return err
+	}
+	var params struct {
+		Value   string      `json:"value"`
+		Rewrite RewriteType `json:"rewrite"`
+	}
+	if err := decodeParams(PREFIX_KEY, body, &params); err != nil {
+		return err
+	}
+	*q = PrefixQuery{Field: field, Value: params.Value, Rewrite: params.Rewrite}
+	return nil
+}
+
+// WildcardQuery represents wildcard query
+type WildcardQuery struct {
+	Field   string
+	Value   string
+	Boost   float64
+	Rewrite RewriteType
+}
+
+func (q *WildcardQuery) ToDSL() DSL {
+	var d = DSL{VALUE_KEY: q.Value}
+	addValueForDSL(d, BOOST_KEY, q.Boost)
+	addValueForDSL(d, REWRITE_KEY, q.Rewrite)
+	return DSL{WILDCARD_KEY: DSL{q.Field: d}}
+}
+
+func (q *WildcardQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.ToDSL())
+}
+
+func (q *WildcardQuery) UnmarshalJSON(data []byte) error {
+	field, body, err := unwrapFieldQuery(data, WILDCARD_KEY)
+	if err != nil {
+		return err
+	}
+	var params struct {
+		Value   string      `json:"value"`
+		Boost   float64     `json:"boost"`
+		Rewrite RewriteType `json:"rewrite"`
+	}
+	if err := decodeParams(WILDCARD_KEY, body, &params); err != nil {
+		return err
+	}
+	*q = WildcardQuery{Field: field, Value: params.Value, Boost: params.Boost, Rewrite: params.Rewrite}
+	return nil
+}
+
+// RegexpQuery represents regexp query
+type RegexpQuery struct {
+	Field                 string
+	Value                 string
+	Rewrite               RewriteType
+	Flags                 RegexpFlagType
+	MaxDeterminizedStates int
+}
+
+func (q *RegexpQuery) ToDSL() DSL {
+	var d = DSL{VALUE_KEY: q.Value}
+	addValueForDSL(d, REWRITE_KEY, q.Rewrite)
+	addValueForDSL(d, FLAGS_KEY, q.Flags)
+	addValueForDSL(d, MAX_DETERMINIZED_STATES_KEY, q.MaxDeterminizedStates)
+	return DSL{REGEXP_KEY: DSL{q.Field: d}}
+}
+
+func (q *RegexpQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.ToDSL())
+}
+
+func (q *RegexpQuery) UnmarshalJSON(data []byte) error {
+	field, body, err := unwrapFieldQuery(data, REGEXP_KEY)
+	if err != nil {
+		return err
+	}
+	var params struct {
+		Value                 string         `json:"value"`
+		Rewrite               RewriteType    `json:"rewrite"`
+		Flags                 RegexpFlagType `json:"flags"`
+		MaxDeterminizedStates int            `json:"max_determinized_states"`
+	}
+	if err := decodeParams(REGEXP_KEY, body, &params); err != nil {
+		return err
+	}
+	*q = RegexpQuery{
+		Field:                 field,
+		Value:                 params.Value,
+		Rewrite:               params.Rewrite,
+		Flags:                 params.Flags,
+		MaxDeterminizedStates: params.MaxDeterminizedStates,
+	}
+	return nil
+}
+
+// FuzzyQuery represents fuzzy query
+type FuzzyQuery struct {
+	Field          string
+	Value          interface{}
+	Rewrite        RewriteType
+	Fuzziness      string
+	PrefixLength   int
+	MaxExpansions  int
+	Transpositions bool
+}
+
+func (q *FuzzyQuery) ToDSL() DSL {
+	var d = DSL{
+		VALUE_KEY:          q.Value,
+		PREFIX_LENGTH_KEY:  q.PrefixLength,
+		TRANSPOSITIONS_KEY: q.Transpositions,
+	}
+	addValueForDSL(d, REWRITE_KEY, q.Rewrite)
+	addValueForDSL(d, FUZZINESS_KEY, q.Fuzziness)
+	addValueForDSL(d, MAX_EXPANSIONS_KEY, q.MaxExpansions)
+	return DSL{FUZZY_KEY: DSL{q.Field: d}}
+}
+
+func (q *FuzzyQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.ToDSL())
+}
+
+func (q *FuzzyQuery) UnmarshalJSON(data []byte) error {
+	field, body, err := unwrapFieldQuery(data, FUZZY_KEY)
+	if err != nil {
+		return err
+	}
+	var params struct {
+		Value          interface{} `json:"value"`
+		Rewrite        RewriteType `json:"rewrite"`
+		Fuzziness      string      `json:"fuzziness"`
+		PrefixLength   int         `json:"prefix_length"`
+		MaxExpansions  int         `json:"max_expansions"`
+		Transpositions bool        `json:"transpositions"`
+	}
+	if err := decodeParams(FUZZY_KEY, body, &params); err != nil {
+		return err
+	}
+	*q = FuzzyQuery{
+		Field:          field,
+		Value:          params.Value,
+		Rewrite:        params.Rewrite,
+		Fuzziness:      params.Fuzziness,
+		PrefixLength:   params.PrefixLength,
+		MaxExpansions:  params.MaxExpansions,
+		Transpositions: params.Transpositions,
+	}
+	return nil
+}
+
+// ExistsQuery represents exists query
+type ExistsQuery struct {
+	Field string
+}
+
+func (q *ExistsQuery) ToDSL() DSL {
+	return DSL{
+		EXISTS_KEY: DSL{
+			FIELD_KEY: q.Field,
+		},
+	}
+}
+
+func (q *ExistsQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.ToDSL())
+}
+
+func (q *ExistsQuery) UnmarshalJSON(data []byte) error {
+	body, err := unwrapQuery(data, EXISTS_KEY)
+	if err != nil {
+		return err
+	}
+	var params struct {
+		Field string `json:"field"`
+	}
+	if err := json.Unmarshal(body, &params); err != nil {
+		return err
+	}
+	*q = ExistsQuery{Field: params.Field}
+	return nil
+}
+
+// IdsQuery represents ids query
+type IdsQuery struct {
+	Values []string
+}
+
+func (q *IdsQuery) ToDSL() DSL {
+	return DSL{
+		IDS_KEY: DSL{
+			VALUES_KEY: sortedStrLst(q.Values),
+		},
+	}
+}
+
+func (q *IdsQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.ToDSL())
+}
+
+func (q *IdsQuery) UnmarshalJSON(data []byte) error {
+	body, err := unwrapQuery(data, IDS_KEY)
+	if err != nil {
+		return err
+	}
+	var params struct {
+		Values []string `json:"values"`
+	}
+	if err := json.Unmarshal(body, &params); err != nil {
+		return err
+	}
+	*q = IdsQuery{Values: params.Values}
+	return nil
+}
+
+// MatchQuery represents match query
+type MatchQuery struct {
+	Field         string
+	Query         interface{}
+	Boost         float64
+	MaxExpansions int
+	Analyzer      string
+}
+
+func (q *MatchQuery) ToDSL() DSL {
+	d := DSL{QUERY_KEY: q.Query}
+	addValueForDSL(d, BOOST_KEY, q.Boost)
+	addValueForDSL(d, MAX_EXPANSIONS_KEY, q.MaxExpansions)
+	addValueForDSL(d, ANALYZER_KEY, q.Analyzer)
+	return DSL{MATCH_KEY: DSL{q.Field: d}}
+}
+
+func (q *MatchQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.ToDSL())
+}
+
+func (q *MatchQuery) UnmarshalJSON(data []byte) error {
+	field, body, err := unwrapFieldQuery(data, MATCH_KEY)
+	if err != nil {
+		return err
+	}
+	var params struct {
+		Query         interface{} `json:"query"`
+		Boost         float64     `json:"boost"`
+		MaxExpansions int         `json:"max_expansions"`
+		Analyzer      string      `json:"analyzer"`
+	}
+	if err := decodeParams(MATCH_KEY, body, &params); err != nil {
+		return err
+	}
+	*q = MatchQuery{
+		Field:         field,
+		Query:         params.Query,
+		Boost:         params.Boost,
+		MaxExpansions: params.MaxExpansions,
+		Analyzer:      params.Analyzer,
+	}
+	return nil
+}
+
+// MatchPhraseQuery represents match_phrase query
+type MatchPhraseQuery struct {
+	Field    string
+	Query    interface{}
+	Boost    float64
+	Analyzer string
+}
+
+func (q *MatchPhraseQuery) ToDSL() DSL {
+	d := DSL{QUERY_KEY: q.Query}
+	addValueForDSL(d, BOOST_KEY, q.Boost)
+	addValueForDSL(d, ANALYZER_KEY, q.Analyzer)
+	return DSL{MATCH_PHRASE_KEY: DSL{q.Field: d}}
+}
+
+func (q *MatchPhraseQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.ToDSL())
+}
+
+func (q *MatchPhraseQuery) UnmarshalJSON(data []byte) error {
+	field, body, err := unwrapFieldQuery(data, MATCH_PHRASE_KEY)
+	if err != nil {
+		return err
+	}
+	var params struct {
+		Query    interface{} `json:"query"`
+		Boost    float64     `json:"boost"`
+		Analyzer string      `json:"analyzer"`
+	}
+	if err := decodeParams(MATCH_PHRASE_KEY, body, &params); err != nil {
+		return err
+	}
+	*q = MatchPhraseQuery{Field: field, Query: params.Query, Boost: params.Boost, Analyzer: params.Analyzer}
+	return nil
+}
+
+// MatchPhrasePrefixQuery represents match_phrase_prefix query
+type MatchPhrasePrefixQuery struct {
+	Field         string
+	Query         interface{}
+	Slop          int
+	MaxExpansions int
+	Analyzer      string
+}
+
+func (q *MatchPhrasePrefixQuery) ToDSL() DSL {
+	d := DSL{
+		QUERY_KEY: q.Query,
+		SLOP_KEY:  q.Slop,
+	}
+	addValueForDSL(d, MAX_EXPANSIONS_KEY, q.MaxExpansions)
+	addValueForDSL(d, ANALYZER_KEY, q.Analyzer)
+	return DSL{MATCH_PHRASE_PREFIX_KEY: DSL{q.Field: d}}
+}
+
+func (q *MatchPhrasePrefixQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.ToDSL())
+}
+
+func (q *MatchPhrasePrefixQuery) UnmarshalJSON(data []byte) error {
+	field, body, err := unwrapFieldQuery(data, MATCH_PHRASE_PREFIX_KEY)
+	if err != nil {
+		return err
+	}
+	var params struct {
+		Query         interface{} `json:"query"`
+		Slop          int         `json:"slop"`
+		MaxExpansions int         `json:"max_expansions"`
+		Analyzer      string      `json:"analyzer"`
+	}
+	if err := decodeParams(MATCH_PHRASE_PREFIX_KEY, body, &params); err != nil {
+		return err
+	}
+	*q = MatchPhrasePrefixQuery{
+		Field:         field,
+		Query:         params.Query,
+		Slop:          params.Slop,
+		MaxExpansions: params.MaxExpansions,
+		Analyzer:      params.Analyzer,
+	}
+	return nil
+}
+
+// QueryStringQuery represents query_string query
+type QueryStringQuery struct {
+	Query        interface{}
+	Boost        float64
+	Rewrite      RewriteType
+	DefaultField string
+	Analyzer     string
+}
+
+func (q *QueryStringQuery) ToDSL() DSL {
+	d := DSL{QUERY_KEY: q.Query}
+	addValueForDSL(d, BOOST_KEY, q.Boost)
+	addValueForDSL(d, REWRITE_KEY, q.Rewrite)
+	addValueForDSL(d, DEFAULT_FIELD_KEY, q.DefaultField)
+	addValueForDSL(d, ANALYZER_KEY, q.Analyzer)
+	return DSL{QUERY_STRING_KEY: d}
+}
+
+func (q *QueryStringQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.ToDSL())
+}
+
+func (q *QueryStringQuery) UnmarshalJSON(data []byte) error {
+	body, err := unwrapQuery(data, QUERY_STRING_KEY)
+	if err != nil {
+		return err
+	}
+	var params struct {
+		Query        interface{} `json:"query"`
+		Boost        float64     `json:"boost"`
+		Rewrite      RewriteType `json:"rewrite"`
+		DefaultField string      `json:"default_field"`
+		Analyzer     string      `json:"analyzer"`
+	}
+	if err := decodeParams(QUERY_STRING_KEY, body, &params); err != nil {
+		return err
+	}
+	*q = QueryStringQuery{
+		Query:        params.Query,
+		Boost:        params.Boost,
+		Rewrite:      params.Rewrite,
+		DefaultField: params.DefaultField,
+		Analyzer:     params.Analyzer,
+	}
+	return nil
+}
+
+// MatchAllQuery represents match_all query
+type MatchAllQuery struct{}
+
+func (q *MatchAllQuery) ToDSL() DSL {
+	return DSL{MATCH_ALL_KEY: DSL{}}
+}
+
+func (q *MatchAllQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.ToDSL())
+}
+
+func (q *MatchAllQuery) UnmarshalJSON(data []byte) error {
+	_, err := unwrapQuery(data, MATCH_ALL_KEY)
+	return err
+}
+
+// GeoBoundingBoxQuery represents geo_bounding_box query, points are in form of {"lat": <lat>, "lon": <lon>}
+type GeoBoundingBoxQuery struct {
+	Field       string
+	TopLeft     GeoPoint
+	BottomRight GeoPoint
+	Boost       float64
+}
+
+func (q *GeoBoundingBoxQuery) ToDSL() DSL {
+	var d = DSL{
+		q.Field: DSL{
+			TOP_LEFT_KEY:     q.TopLeft.ToDSL(),
+			BOTTOM_RIGHT_KEY: q.BottomRight.ToDSL(),
+		},
+	}
+	addValueForDSL(d, BOOST_KEY, q.Boost)
+	return DSL{GEO_BOUNDING_BOX_KEY: d}
+}
+
+func (q *GeoBoundingBoxQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.ToDSL())
+}
+
+func (q *GeoBoundingBoxQuery) UnmarshalJSON(data []byte) error {
+	field, params, boost, err := unwrapGeoQuery(data, GEO_BOUNDING_BOX_KEY)
+	if err != nil {
+		return err
+	}
+	var box struct {
+		TopLeft     GeoPoint `json:"top_left"`
+		BottomRight GeoPoint `json:"bottom_right"`
+	}
+	if err := json.Unmarshal(params[field], &box); err != nil {
+		return err
+	}
+	*q = GeoBoundingBoxQuery{Field: field, TopLeft: box.TopLeft, BottomRight: box.BottomRight, Boost: boost}
+	return nil
+}
+
+// GeoDistanceQuery represents geo_distance query, distance is number with unit, i.e. "5km"
+type GeoDistanceQuery struct {
+	Field    string
+	Point    GeoPoint
+	Distance string
+	Boost    float64
+}
+
+func (q *GeoDistanceQuery) ToDSL() DSL {
+	var d = DSL{
+		q.Field:      q.Point.ToDSL(),
+		DISTANCE_KEY: q.Distance,
+	}
+	addValueForDSL(d, BOOST_KEY, q.Boost)
+	return DSL{GEO_DISTANCE_KEY: d}
+}
+
+func (q *GeoDistanceQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.ToDSL())
+}
+
+func (q *GeoDistanceQuery) UnmarshalJSON(data []byte) error {
+	field, params, boost, err := unwrapGeoQuery(data, GEO_DISTANCE_KEY, DISTANCE_KEY)
+	if err != nil {
+		return err
+	}
+	var res = GeoDistanceQuery{Field: field, Boost: boost}
+	if err := json.Unmarshal(params[field], &res.Point); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(params[DISTANCE_KEY], &res.Distance); err != nil {
+		return err
+	}
+	*q = res
+	return nil
+}
+
+// GeoShapeQuery represents geo_shape query with inline shape in geojson form
+type GeoShapeQuery struct {
+	Field    string
+	Shape    map[string]interface{}
+	Relation RelationType
+	Boost    float64
+}
+
+func (q *GeoShapeQuery) ToDSL() DSL {
+	var params = DSL{SHAPE_KEY: q.Shape}
+	addValueForDSL(params, RELATION_KEY, q.Relation)
+	var d = DSL{q.Field: params}
+	addValueForDSL(d, BOOST_KEY, q.Boost)
+	return DSL{GEO_SHAPE_KEY: d}
+}
+
+func (q *GeoShapeQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.ToDSL())
+}
+
+func (q *GeoShapeQuery) UnmarshalJSON(data []byte) error {
+	field, params, boost, err := unwrapGeoQuery(data, GEO_SHAPE_KEY)
+	if err != nil {
+		return err
+	}
+	var shape struct {
+		Shape    map[string]interface{} `json:"shape"`
+		Relation RelationType           `json:"relation"`
+	}
+	if err := decodeJSON(params[field], &shape); err != nil {
+		return err
+	}
+	*q = GeoShapeQuery{Field: field, Shape: shape.Shape, Relation: shape.Relation, Boost: boost}
+	return nil
+}
+
+func queriesToDSLList(queries []Query) []DSL {
+	var dslList []DSL
+	for _, q := range queries {
+		dslList = append(dslList, q.ToDSL())
+	}
+	return dslList
+}
+
+// sortedJSONValues returns copy of values sorted by their json, which is canonical order of values with any types
+func sortedJSONValues(values []interface{}) []interface{} {
+	var keys = make([]string, len(values))
+	var res = make([]interface{}, len(values))
+	for i, v := range values {
+		data, _ := json.Marshal(v)
+		keys[i] = string(data)
+		res[i] = v
+	}
+	sort.Sort(jsonValues{keys: keys, values: res})
+	return res
+}
+
+type jsonValues struct {
+	keys   []string
+	values []interface{}
+}
+
+func (v jsonValues) Len() int           { return len(v.keys) }
+func (v jsonValues) Less(i, j int) bool { return v.keys[i] < v.keys[j] }
+func (v jsonValues) Swap(i, j int) {
+	v.keys[i], v.keys[j] = v.keys[j], v.keys[i]
+	v.values[i], v.values[j] = v.values[j], v.values[i]
+}
+
+// unmarshalClauses parses clause of bool query, which is single query or array of queries
+func unmarshalClauses(data json.RawMessage) ([]Query, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return nil, nil
+	}
+	var items []json.RawMessage
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, err
+		}
+	} else {
+		items = []json.RawMessage{data}
+	}
+	var queries []Query
+	for _, item := range items {
+		q, err := UnmarshalQuery(item)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, q)
+	}
+	return queries, nil
+}
+
+// decodeJSON decodes json and keeps numbers as json.Number, so that long values don't lose precision
+func decodeJSON(data []byte, v interface{}) error {
+	var d = json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+// decodeParams decodes params of query, short form of query (i.e. `{"term": {"foo": "bar"}}`) is expanded and
+// absent params are set to es defaults, so that compact DSL is parsed to same query as the full DSL
+func decodeParams(kind string, params json.RawMessage, v interface{}) error {
+	params = bytes.TrimSpace(params)
+	if valueKey, ok := shortFormKeys[kind]; ok && len(params) != 0 && params[0] != '{' {
+		data, err := json.Marshal(map[string]json.RawMessage{valueKey: params})
+		if err != nil {
+			return err
+		}
+		params = data
+	}
+	if defaults, ok := defaultParams[kind]; ok {
+		data, err := json.Marshal(defaults)
+		if err != nil {
+			return err
+		}
+		if err := decodeJSON(data, v); err != nil {
+			return err
+		}
+	}
+	return decodeJSON(params, v)
+}
+
+// unwrapQuery gets body of query with form {"<kind>": <body>}
+func unwrapQuery(data []byte, kind string) (json.RawMessage, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if body, ok := m[kind]; !ok || len(m) != 1 {
+		return nil, fmt.Errorf("expect %s query, but got: %s", kind, data)
+	} else {
+		return body, nil
+	}
+}
+
+// unwrapFieldQuery gets field and params of query with form {"<kind>": {"<field>": <params>}}
+func unwrapFieldQuery(data []byte, kind string) (string, json.RawMessage, error) {
+	body, err := unwrapQuery(data, kind)
+	if err != nil {
+		return "", nil, err
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(body, &m); err != nil {
+		return "", nil, err
+	}
+	if len(m) != 1 {
+		return "", nil, fmt.Errorf("expect %s query on single field, but got: %s", kind, data)
+	}
+	for field, params := range m {
+		return field, params, nil
+	}
+	return "", nil, nil
+}
+
+// unwrapGeoQuery gets field, params and boost of geo query with form {"<kind>": {"<field>": <params>, "boost": <boost>, ...}},
+// keys are keys of params beside field (i.e. "distance" of geo_distance query)
+func unwrapGeoQuery(data []byte, kind string, keys ...string) (string, map[string]json.RawMessage, float64, error) {
+	body, err := unwrapQuery(data, kind)
+	if err != nil {
+		return "", nil, 0, err
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(body, &m); err != nil {
+		return "", nil, 0, err
+	}
+	var paramKeys = map[string]bool{}
+	for _, key := range keys {
+		paramKeys[key] = true
+	}
+	var field string
+	var boost, _ = defaultParams[kind][BOOST_KEY].(float64)
+	for key, value := range m {
+		if key == BOOST_KEY {
+			if err := json.Unmarshal(value, &boost); err != nil {
+				return "", nil, 0, err
+			}
+		} else if !paramKeys[key] {
+			if field != "" {
+				return "", nil, 0, fmt.Errorf("expect %s query on single field, but got: %s", kind, data)
+			}
+			field = key
+		}
+	}
+	if field == "" {
+		return "", nil, 0, fmt.Errorf("expect %s query on single field, but got: %s", kind, data)
+	}
+	return field, m, boost, nil
+}
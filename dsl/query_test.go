@@ -0,0 +1,355 @@
+package dsl
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	mapping "github.com/zhuliquan/es-mapping"
+	"github.com/zhuliquan/lucene-to-dsl/utils"
+)
+
+func TestNewQuery(t *testing.T) {
+	var term = NewTermNode(NewKVNode(
+		NewFieldNode(NewLfNode(), "foo"),
+		NewValueNode("bar", NewValueType(mapping.KEYWORD_FIELD_TYPE, false)),
+	), WithBoost(1.2))
+	var rangeNode = NewRangeNode(NewRgNode(
+		NewFieldNode(NewLfNode(), "x"),
+		NewValueType(mapping.LONG_FIELD_TYPE, false),
+		int64(1), int64(9007199254740993), GTE, LT,
+	))
+	var prefix = NewPrefixNode(NewKVNode(
+		NewFieldNode(NewLfNode(), "foo"),
+		NewValueNode("ab", NewValueType(mapping.KEYWORD_FIELD_TYPE, false)),
+	), utils.NewPrefixPattern("ab"))
+	var boolNode = &BoolNode{
+		opNode: opNode{opType: AND},
+		Must: map[string][]AstNode{
+			"foo": {term, rangeNode},
+		},
+		MustNot: map[string][]AstNode{
+			"bar": {NewExistsNode(NewFieldNode(NewLfNode(), "bar"))},
+		},
+	}
+
+	type testCase struct {
+		name string
+		node AstNode
+		want Query
+	}
+	for _, tt := range []testCase{
+		{
+			name: "term",
+			node: term,
+			want: &TermQuery{Field: "foo", Value: "bar", Boost: 1.2},
+		},
+		{
+			name: "range",
+			node: rangeNode,
+			want: &RangeQuery{Field: "x", Gte: int64(1), Lt: int64(9007199254740993), Boost: 1.0, Relation: INTERSECTS},
+		},
+		{
+			name: "prefix",
+			node: prefix,
+			want: &PrefixQuery{Field: "foo", Value: "ab", Rewrite: CONSTANT_SCORE},
+		},
+		{
+			name: "fuzzy",
+			node: NewFuzzyNode(NewKVNode(
+				NewFieldNode(NewLfNode(), "foo"),
+				NewValueNode("bar", NewValueType(mapping.TEXT_FIELD_TYPE, false)),
+			), WithMaxExpands(30), WithFuzziness("AUTO:1,3"), WithPrefixLength(1)),
+			want: &FuzzyQuery{Field: "foo", Value: "bar", Rewrite: CONSTANT_SCORE, Fuzziness: "AUTO:1,3", PrefixLength: 1, MaxExpansions: 30, Transpositions: true},
+		},
+		{
+			name: "match",
+			node: NewMatchNode(NewKVNode(
+				NewFieldNode(NewLfNode(), "foo"),
+				NewValueNode("bar baz", NewValueType(mapping.TEXT_FIELD_TYPE, false)),
+			)),
+			want: &MatchQuery{Field: "foo", Query: "bar baz", Boost: 1.0, MaxExpansions: 50},
+		},
+		{
+			name: "geo_distance",
+			node: NewGeoDistanceNode(NewFieldNode(NewLfNode(), "loc"), GeoPoint{Lat: 40.7, Lon: -74}, "5km"),
+			want: &GeoDistanceQuery{Field: "loc", Point: GeoPoint{Lat: 40.7, Lon: -74}, Distance: "5km", Boost: 1.0},
+		},
+		{
+			name: "ids",
+			node: NewIdsNode(NewLfNode(), []string{"1", "2"}),
+			want: &IdsQuery{Values: []string{"1", "2"}},
+		},
+		{
+			name: "match_all",
+			node: &MatchAllNode{},
+			want: &MatchAllQuery{},
+		},
+		{
+			name: "empty",
+			node: &EmptyNode{},
+			want: nil,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := NewQuery(tt.node)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, q)
+			if q != nil {
+				data, err := json.Marshal(q)
+				assert.Nil(t, err)
+				assert.Equal(t, tt.node.ToDSL().String(), string(data))
+			}
+		})
+	}
+
+	t.Run("bool", func(t *testing.T) {
+		q, err := NewQuery(boolNode)
+		assert.Nil(t, err)
+		data, err := json.Marshal(q)
+		assert.Nil(t, err)
+		assert.Equal(t, boolNode.ToDSL().String(), string(data))
+		var boolQuery = q.(*BoolQuery)
+		assert.Equal(t, 2, len(boolQuery.Must))
+		assert.Equal(t, []Query{&ExistsQuery{Field: "bar"}}, boolQuery.MustNot)
+	})
+}
+
+func TestUnmarshalQuery(t *testing.T) {
+	type testCase struct {
+		name    string
+		data    string
+		want    Query
+		wantErr bool
+	}
+	for _, tt := range []testCase{
+		{
+			name: "terms",
+			data: `{"terms":{"boost":2,"foo":["a",1]}}`,
+			want: &TermsQuery{Field: "foo", Values: []interface{}{"a", json.Number("1")}, Boost: 2},
+		},
+		{
+			name: "terms_without_boost",
+			data: `{"terms":{"foo":["a","b"]}}`,
+			want: &TermsQuery{Field: "foo", Values: []interface{}{"a", "b"}},
+		},
+		{
+			name: "range_with_date_math",
+			data: `{"range":{"ts":{"boost":1,"format":"strict_date_optional_time||epoch_millis","gte":"now-1h/h","lt":"now","relation":"INTERSECTS","time_zone":"+08:00"}}}`,
+			want: &RangeQuery{Field: "ts", Gte: "now-1h/h", Lt: "now", Boost: 1, Relation: INTERSECTS, Format: "strict_date_optional_time||epoch_millis", TimeZone: "+08:00"},
+		},
+		{
+			name: "wildcard",
+			data: `{"wildcard":{"foo":{"boost":1,"rewrite":"constant_score","value":"a*b"}}}`,
+			want: &WildcardQuery{Field: "foo", Value: "a*b", Boost: 1, Rewrite: CONSTANT_SCORE},
+		},
+		{
+			name: "regexp",
+			data: `{"regexp":{"foo":{"flags":"ALL","max_determinized_states":10000,"rewrite":"constant_score","value":"a.*"}}}`,
+			want: &RegexpQuery{Field: "foo", Value: "a.*", Rewrite: CONSTANT_SCORE, Flags: ALL_FLAG, MaxDeterminizedStates: 10000},
+		},
+		{
+			name: "fuzzy",
+			data: `{"fuzzy":{"foo":{"fuzziness":"AUTO","max_expansions":50,"prefix_length":1,"rewrite":"constant_score","transpositions":true,"value":"bar"}}}`,
+			want: &FuzzyQuery{Field: "foo", Value: "bar", Rewrite: CONSTANT_SCORE, Fuzziness: "AUTO", PrefixLength: 1, MaxExpansions: 50, Transpositions: true},
+		},
+		{
+			name: "match",
+			data: `{"match":{"foo":{"analyzer":"standard","boost":1,"max_expansions":50,"query":"bar"}}}`,
+			want: &MatchQuery{Field: "foo", Query: "bar", Boost: 1, MaxExpansions: 50, Analyzer: "standard"},
+		},
+		{
+			name: "match_phrase",
+			data: `{"match_phrase":{"foo":{"boost":1,"query":"bar baz"}}}`,
+			want: &MatchPhraseQuery{Field: "foo", Query: "bar baz", Boost: 1},
+		},
+		{
+			name: "match_phrase_prefix",
+			data: `{"match_phrase_prefix":{"foo":{"max_expansions":50,"query":"bar ba","slop":2}}}`,
+			want: &MatchPhrasePrefixQuery{Field: "foo", Query: "bar ba", Slop: 2, MaxExpansions: 50},
+		},
+		{
+			name: "query_string",
+			data: `{"query_string":{"boost":1,"default_field":"foo","query":"bar*","rewrite":"constant_score"}}`,
+			want: &QueryStringQuery{Query: "bar*", Boost: 1, Rewrite: CONSTANT_SCORE, DefaultField: "foo"},
+		},
+		{
+			name: "bool",
+			data: `{"bool":{"minimum_should_match":1,"should":[{"exists":{"field":"foo"}},{"ids":{"values":["1"]}}]}}`,
+			want: &BoolQuery{Should: []Query{&ExistsQuery{Field: "foo"}, &IdsQuery{Values: []string{"1"}}}, MinimumShouldMatch: 1},
+		},
+		{
+			name: "geo_bounding_box",
+			data: `{"geo_bounding_box":{"boost":1,"loc":{"bottom_right":{"lat":40.1,"lon":-73.6},"top_left":{"lat":40.9,"lon":-74.2}}}}`,
+			want: &GeoBoundingBoxQuery{Field: "loc", TopLeft: GeoPoint{Lat: 40.9, Lon: -74.2}, BottomRight: GeoPoint{Lat: 40.1, Lon: -73.6}, Boost: 1},
+		},
+		{
+			name: "geo_distance",
+			data: `{"geo_distance":{"boost":2,"distance":"5km","loc":{"lat":40.7,"lon":-74}}}`,
+			want: &GeoDistanceQuery{Field: "loc", Point: GeoPoint{Lat: 40.7, Lon: -74}, Distance: "5km", Boost: 2},
+		},
+		{
+			name: "geo_shape",
+			data: `{"geo_shape":{"area":{"relation":"INTERSECTS","shape":{"coordinates":[[-74.2,40.9],[-73.6,40.1]],"type":"envelope"}},"boost":1}}`,
+			want: &GeoShapeQuery{Field: "area", Shape: map[string]interface{}{
+				"type":        "envelope",
+				"coordinates": []interface{}{[]interface{}{json.Number("-74.2"), json.Number("40.9")}, []interface{}{json.Number("-73.6"), json.Number("40.1")}},
+			}, Relation: INTERSECTS, Boost: 1},
+		},
+		{
+			name:    "geo_distance_without_field",
+			data:    `{"geo_distance":{"distance":"5km"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "geo_bounding_box_multi_fields",
+			data:    `{"geo_bounding_box":{"a":{},"b":{}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "unsupported_kind",
+			data:    `{"geo_polygon":{"foo":{}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "multi_kinds",
+			data:    `{"term":{"foo":{"value":"bar"}},"exists":{"field":"foo"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "multi_fields",
+			data:    `{"term":{"foo":{"value":"bar"},"bar":{"value":"foo"}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid_clause",
+			data:    `{"bool":{"must":{"foo":{}},"minimum_should_match":0}}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid_json",
+			data:    `{"term":`,
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := UnmarshalQuery([]byte(tt.data))
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, q)
+			data, err := json.Marshal(q)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.data, string(data))
+		})
+	}
+}
+
+func TestUnmarshalCompactQuery(t *testing.T) {
+	type testCase struct {
+		name string
+		data string
+		want Query
+		full string
+	}
+	for _, tt := range []testCase{
+		{
+			name: "term",
+			data: `{"term":{"status":"active"}}`,
+			want: &TermQuery{Field: "status", Value: "active", Boost: 1},
+			full: `{"term":{"status":{"boost":1,"value":"active"}}}`,
+		},
+		{
+			name: "range",
+			data: `{"range":{"x":{"gte":1}}}`,
+			want: &RangeQuery{Field: "x", Gte: json.Number("1"), Boost: 1, Relation: INTERSECTS},
+			full: `{"range":{"x":{"boost":1,"gte":1,"relation":"INTERSECTS"}}}`,
+		},
+		{
+			name: "regexp",
+			data: `{"regexp":{"foo":"a.*"}}`,
+			want: &RegexpQuery{Field: "foo", Value: "a.*", Rewrite: CONSTANT_SCORE, Flags: ALL_FLAG, MaxDeterminizedStates: 10000},
+			full: `{"regexp":{"foo":{"flags":"ALL","max_determinized_states":10000,"rewrite":"constant_score","value":"a.*"}}}`,
+		},
+		{
+			name: "fuzzy",
+			data: `{"fuzzy":{"foo":{"rewrite":"constant_score","value":"bar"}}}`,
+			want: &FuzzyQuery{Field: "foo", Value: "bar", Rewrite: CONSTANT_SCORE, Fuzziness: "AUTO", MaxExpansions: 50, Transpositions: true},
+			full: `{"fuzzy":{"foo":{"fuzziness":"AUTO","max_expansions":50,"prefix_length":0,"rewrite":"constant_score","transpositions":true,"value":"bar"}}}`,
+		},
+		{
+			name: "match_phrase",
+			data: `{"match_phrase":{"foo":"bar baz"}}`,
+			want: &MatchPhraseQuery{Field: "foo", Query: "bar baz", Boost: 1},
+			full: `{"match_phrase":{"foo":{"boost":1,"query":"bar baz"}}}`,
+		},
+		{
+			name: "bool_should",
+			data: `{"bool":{"should":[{"term":{"a":1}},{"term":{"b":2}}]}}`,
+			want: &BoolQuery{Should: []Query{
+				&TermQuery{Field: "a", Value: json.Number("1"), Boost: 1},
+				&TermQuery{Field: "b", Value: json.Number("2"), Boost: 1},
+			}, MinimumShouldMatch: 1},
+			full: `{"bool":{"minimum_should_match":1,"should":[{"term":{"a":{"boost":1,"value":1}}},{"term":{"b":{"boost":1,"value":2}}}]}}`,
+		},
+		{
+			name: "geo_distance",
+			data: `{"geo_distance":{"distance":"5km","loc":{"lat":40.7,"lon":-74}}}`,
+			want: &GeoDistanceQuery{Field: "loc", Point: GeoPoint{Lat: 40.7, Lon: -74}, Distance: "5km", Boost: 1},
+			full: `{"geo_distance":{"boost":1,"distance":"5km","loc":{"lat":40.7,"lon":-74}}}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := UnmarshalQuery([]byte(tt.data))
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, q)
+			data, err := json.Marshal(q)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.full, string(data))
+			assert.Equal(t, tt.data, Compact(q.ToDSL()).String())
+		})
+	}
+}
+
+func TestQueryToDSL(t *testing.T) {
+	var q = &BoolQuery{
+		Must:   []Query{&TermQuery{Field: "foo", Value: "bar", Boost: 1}},
+		Filter: []Query{&MatchAllQuery{}},
+	}
+	assert.Equal(t, DSL{
+		"bool": DSL{
+			"must":                 DSL{"term": DSL{"foo": DSL{"value": "bar", "boost": 1.0}}},
+			"filter":               DSL{"match_all": DSL{}},
+			"minimum_should_match": 0,
+		},
+	}, q.ToDSL())
+	assert.Equal(t, EmptyDSL, (&BoolQuery{}).ToDSL())
+
+	// zero-valued params are omitted instead of printing invalid values
+	for _, tt := range []struct {
+		q    Query
+		want string
+	}{
+		{&TermQuery{Field: "foo", Value: "bar"}, `{"term":{"foo":{"value":"bar"}}}`},
+		{&RangeQuery{Field: "x", Gte: 1}, `{"range":{"x":{"gte":1}}}`},
+		{&PrefixQuery{Field: "foo", Value: "ab"}, `{"prefix":{"foo":{"value":"ab"}}}`},
+		{&WildcardQuery{Field: "foo", Value: "a*"}, `{"wildcard":{"foo":{"value":"a*"}}}`},
+		{&RegexpQuery{Field: "foo", Value: "a.*"}, `{"regexp":{"foo":{"value":"a.*"}}}`},
+		{&FuzzyQuery{Field: "foo", Value: "bar"}, `{"fuzzy":{"foo":{"prefix_length":0,"transpositions":false,"value":"bar"}}}`},
+		{&MatchQuery{Field: "foo", Query: "bar"}, `{"match":{"foo":{"query":"bar"}}}`},
+		{&QueryStringQuery{Query: "bar*"}, `{"query_string":{"query":"bar*"}}`},
+		{&GeoShapeQuery{Field: "area", Shape: map[string]interface{}{"type": "envelope"}}, `{"geo_shape":{"area":{"shape":{"type":"envelope"}}}}`},
+	} {
+		assert.Equal(t, tt.want, tt.q.ToDSL().String())
+	}
+}
+
+func TestQueryValuesOrder(t *testing.T) {
+	var terms = &TermsQuery{Field: "foo", Values: []interface{}{"b", json.Number("2"), "a", json.Number("1")}}
+	assert.Equal(t, `{"terms":{"foo":["a","b",1,2]}}`, terms.ToDSL().String())
+	assert.Equal(t, []interface{}{"b", json.Number("2"), "a", json.Number("1")}, terms.Values)
+	var ids = &IdsQuery{Values: []string{"2", "1"}}
+	assert.Equal(t, `{"ids":{"values":["1","2"]}}`, ids.ToDSL().String())
+}
@@ -3,22 +3,36 @@ package dsl
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/zhuliquan/datemath_parser"
 	mapping "github.com/zhuliquan/es-mapping"
+	"github.com/zhuliquan/lucene-to-dsl/utils"
 )
 
 type RangeNode struct {
 	rgNode
 	boostNode
-	// format   string // 如果是 date类型直接变为epoch_millis
-	relation RelationType
-	timeZone string
+	// format is date format of date math expr, date value is printed as epoch_millis if expr isn't kept
+	format string
+	// dateFormat is candidate formats of printing resolved date value separated by "||", which is epoch_millis
+	// for date and strict_date_optional_time_nanos for date_nanos by default
+	dateFormat string
+	relation   RelationType
+	timeZone   string
+	// lDateMath and rDateMath are original date math expr of bounds, e.g. "now-1h/h",
+	// lValue and rValue are resolved value of them, which are used for merging range nodes
+	lDateMath string
+	rDateMath string
 }
 
 func WithRelation(relation RelationType) func(AstNode) {
 	return func(n AstNode) {
-		if f, ok := n.(*RangeNode); ok {
+		switch f := n.(type) {
+		case *RangeNode:
+			f.relation = relation
+		case *GeoShapeNode:
 			f.relation = relation
 		}
 	}
@@ -32,6 +46,35 @@ func WithTimeZone(timeZone string) func(AstNode) {
 	}
 }
 
+// WithFormat specifies date format used by es to parse date math expr of range query
+func WithFormat(format string) func(AstNode) {
+	return func(n AstNode) {
+		if f, ok := n.(*RangeNode); ok {
+			f.format = format
+		}
+	}
+}
+
+// WithDateFormat specifies candidate formats of printing resolved date value, e.g. "strict_date_optional_time", "yyyy-MM-dd||epoch_second",
+// the first format printing the value exactly is used
+func WithDateFormat(dateFormat string) func(AstNode) {
+	return func(n AstNode) {
+		if f, ok := n.(*RangeNode); ok {
+			f.dateFormat = dateFormat
+		}
+	}
+}
+
+// WithDateMath keeps original date math expr of left and right bounds, empty expr means the bound is printed as epoch_millis
+func WithDateMath(lDateMath, rDateMath string) func(AstNode) {
+	return func(n AstNode) {
+		if f, ok := n.(*RangeNode); ok {
+			f.lDateMath = lDateMath
+			f.rDateMath = rDateMath
+		}
+	}
+}
+
 func NewRangeNode(RgNode *rgNode, opts ...func(AstNode)) *RangeNode {
 	var n = &RangeNode{
 		rgNode:    *RgNode,
@@ -107,6 +150,10 @@ func (n *RangeNode) InterSect(o AstNode) (AstNode, error) {
 }
 
 func (n *RangeNode) Inverse() (AstNode, error) {
+	if CheckRangeFieldType(n.mType) {
+		// field value is a range, complement of query range doesn't match documents which don't match the query
+		return inverseNode(n), nil
+	}
 	var (
 		lCmpSym = LT
 		rCmpSym = GT
@@ -129,9 +176,12 @@ func (n *RangeNode) Inverse() (AstNode, error) {
 				lCmpSym:   GT,
 				rCmpSym:   lCmpSym,
 			},
-			timeZone:  n.timeZone,
-			relation:  n.relation,
-			boostNode: n.boostNode,
+			format:     n.format,
+			dateFormat: n.dateFormat,
+			rDateMath:  n.lDateMath,
+			timeZone:   n.timeZone,
+			relation:   n.relation,
+			boostNode:  n.boostNode,
 		}
 		rightNode = &RangeNode{
 			rgNode: rgNode{
@@ -142,9 +192,12 @@ func (n *RangeNode) Inverse() (AstNode, error) {
 				lCmpSym:   rCmpSym,
 				rCmpSym:   LT,
 			},
-			timeZone:  n.timeZone,
-			relation:  n.relation,
-			boostNode: n.boostNode,
+			format:     n.format,
+			dateFormat: n.dateFormat,
+			lDateMath:  n.rDateMath,
+			timeZone:   n.timeZone,
+			relation:   n.relation,
+			boostNode:  n.boostNode,
 		}
 	)
 
@@ -166,20 +219,158 @@ func (n *RangeNode) Inverse() (AstNode, error) {
 }
 
 func (n *RangeNode) ToDSL() DSL {
+	var q = n.toQuery()
 	var res = DSL{
-		BOOST_KEY:          n.getBoost(),
-		RELATION_KEY:       n.relation,
-		n.lCmpSym.String(): leafValueToPrintValue(n.lValue, n.mType),
-		n.rCmpSym.String(): leafValueToPrintValue(n.rValue, n.mType),
+		BOOST_KEY:    q.Boost,
+		RELATION_KEY: q.Relation,
 	}
-	if mapping.CheckDateType(n.mType) {
-		addValueForDSL(res, FORMAT_KEY, datemath_parser.EPOCH_MILLIS)
+	for cmpSym, value := range map[CompareType]interface{}{GT: q.Gt, GTE: q.Gte, LT: q.Lt, LTE: q.Lte} {
+		if value != nil {
+			res[cmpSym.String()] = value
+		}
 	}
-	addValueForDSL(res, TIME_ZONE_KEY, n.timeZone)
+	addValueForDSL(res, FORMAT_KEY, q.Format)
+	addValueForDSL(res, TIME_ZONE_KEY, q.TimeZone)
 	return DSL{RANGE_KEY: DSL{n.field: res}}
 }
 
+// toQuery converts range node to typed query
+func (n *RangeNode) toQuery() *RangeQuery {
+	var res = &RangeQuery{Field: n.field, Boost: n.getBoost(), Relation: n.relation, TimeZone: n.timeZone}
+	var bounds = map[CompareType]*interface{}{GT: &res.Gt, GTE: &res.Gte, LT: &res.Lt, LTE: &res.Lte}
+	var formats []string
+	// infinite bound of date is omitted, because MinTime / MaxTime don't cover all dates accepted by es
+	if n.lDateMath != "" || !mapping.CheckDateType(n.mType) || !isMinInf(n.lValue, n.mType) {
+		var v, format = n.printBound(n.lValue, n.lDateMath, n.lCmpSym == GT)
+		*bounds[n.lCmpSym], formats = v, append(formats, format)
+	}
+	if n.rDateMath != "" || !mapping.CheckDateType(n.mType) || !isMaxInf(n.rValue, n.mType) {
+		var v, format = n.printBound(n.rValue, n.rDateMath, n.rCmpSym == LTE)
+		*bounds[n.rCmpSym], formats = v, append(formats, format)
+	}
+	if n.lDateMath != "" || n.rDateMath != "" {
+		// es rounds expr of gt / lte up and expr of gte / lt down, which is same as resolved value,
+		// empty format means that es parses expr with default formats of the field, which contain formats of resolved value
+		if n.format != "" {
+			res.Format = appendDateFormats(n.format, formats)
+		}
+	} else if mapping.CheckDateType(n.mType) {
+		res.Format = appendDateFormats(n.getFormat(), formats)
+	}
+	return res
+}
+
+// getFormat returns formats used by es to parse printed date values, which are formats of printing them by default
+func (n *RangeNode) getFormat() string {
+	if n.format != "" {
+		return n.format
+	}
+	return n.getDateFormats()
+}
+
+// getDateFormats returns candidate formats of printing resolved date values separated by "||",
+// which is epoch_millis for date and strict_date_optional_time_nanos for date_nanos by default
+func (n *RangeNode) getDateFormats() string {
+	if n.dateFormat != "" {
+		return n.dateFormat
+	} else if n.mType == mapping.DATE_NANOS_FIELD_TYPE {
+		return utils.DATE_NANOS_FORMAT
+	} else {
+		return utils.EPOCH_MILLIS_FORMAT
+	}
+}
+
+// getDateFormat returns the first candidate format printing date value exactly, number printed by format is ambiguous
+// if es parses it with epoch format before the format. strict_date_optional_time(_nanos) is returned if none of them does.
+func (n *RangeNode) getDateFormat(t time.Time, roundUp bool) (string, interface{}) {
+	var precision = time.Millisecond
+	if n.mType == mapping.DATE_NANOS_FIELD_TYPE {
+		precision = time.Nanosecond
+	}
+	for _, format := range strings.Split(n.getDateFormats(), "||") {
+		if s, err := utils.FormatDate(t, format); err != nil || !utils.IsExactDateFormat(t, format, roundUp, precision) {
+			continue
+		} else if isNumericDate(s) && hasEpochFormatBefore(n.getFormat(), format) {
+			continue
+		} else {
+			return format, s
+		}
+	}
+	var format = utils.DATE_FORMAT
+	if n.mType == mapping.DATE_NANOS_FIELD_TYPE {
+		format = utils.DATE_NANOS_FORMAT
+	}
+	var s, _ = utils.FormatDate(t, format)
+	return format, s
+}
+
+func (n *RangeNode) boundToPrintValue(v LeafValue, dateMath string, roundUp bool) interface{} {
+	var res, _ = n.printBound(v, dateMath, roundUp)
+	return res
+}
+
+// printBound returns print value of bound and format of printing the value, format is empty if value isn't resolved date,
+// roundUp is true for gt / lte bounds, whose missing date components are filled by rounding up in es.
+func (n *RangeNode) printBound(v LeafValue, dateMath string, roundUp bool) (interface{}, string) {
+	if dateMath != "" {
+		return dateMath, ""
+	}
+	if !mapping.CheckDateType(n.mType) {
+		return leafValueToPrintValue(v, n.mType), ""
+	}
+	// date string is printed in time zone of query, which is used by es to parse the string
+	var loc = time.UTC
+	if l, err := utils.LoadTimeZone(n.timeZone); err == nil && l != nil {
+		loc = l
+	}
+	var format, s = n.getDateFormat(v.(time.Time).In(loc), roundUp)
+	return s, format
+}
+
+// appendDateFormats appends formats which aren't in dateFormats, empty format is ignored
+func appendDateFormats(dateFormats string, formats []string) string {
+	var exists = map[string]bool{}
+	for _, format := range strings.Split(dateFormats, "||") {
+		exists[format] = true
+	}
+	for _, format := range formats {
+		if format != "" && !exists[format] {
+			exists[format] = true
+			dateFormats += "||" + format
+		}
+	}
+	return dateFormats
+}
+
+// hasEpochFormatBefore checks whether there is epoch format before format in dateFormats,
+// all formats of dateFormats are before format if format isn't in it
+func hasEpochFormatBefore(dateFormats string, format string) bool {
+	for _, f := range strings.Split(dateFormats, "||") {
+		if f == format {
+			return false
+		} else if f == utils.EPOCH_MILLIS_FORMAT || f == utils.EPOCH_SECOND_FORMAT {
+			return true
+		}
+	}
+	return false
+}
+
+func isNumericDate(s interface{}) bool {
+	switch v := s.(type) {
+	case int64:
+		return true
+	case string:
+		var _, err = strconv.ParseInt(v, 10, 64)
+		return err == nil
+	default:
+		return false
+	}
+}
+
 func rangeNodeUnionJoinTermNode(n *RangeNode, t *TermNode) (AstNode, error) {
+	if CheckRangeFieldType(n.mType) {
+		return lfNodeUnionJoinLfNode(n.NodeKey(), n, t)
+	}
 	if !checkRangeInclude(n, t.value) {
 		if CompareAny(n.lValue, t.value, n.mType) == 0 && n.lCmpSym == GT {
 			return &RangeNode{
@@ -191,6 +382,10 @@ func rangeNodeUnionJoinTermNode(n *RangeNode, t *TermNode) (AstNode, error) {
 					lCmpSym:   GTE,
 					rCmpSym:   n.rCmpSym,
 				},
+				format:     n.format,
+				dateFormat: n.dateFormat,
+				// expr is rounded in another direction with GTE, keep resolved value instead
+				rDateMath: n.rDateMath,
 				timeZone:  n.timeZone,
 				relation:  n.relation,
 				boostNode: n.boostNode,
@@ -206,6 +401,10 @@ func rangeNodeUnionJoinTermNode(n *RangeNode, t *TermNode) (AstNode, error) {
 					lCmpSym:   n.lCmpSym,
 					rCmpSym:   LTE,
 				},
+				format:     n.format,
+				dateFormat: n.dateFormat,
+				// expr is rounded in another direction with LTE, keep resolved value instead
+				lDateMath: n.lDateMath,
 				timeZone:  n.timeZone,
 				relation:  n.relation,
 				boostNode: n.boostNode,
@@ -218,6 +417,20 @@ func rangeNodeUnionJoinTermNode(n *RangeNode, t *TermNode) (AstNode, error) {
 }
 
 func rangeNodeUnionJoinRangeNode(n, t *RangeNode) (AstNode, error) {
+	// nodes with different relations match different documents for the same range
+	if n.relation != t.relation {
+		return lfNodeUnionJoinLfNode(n.NodeKey(), n, t)
+	}
+	if CheckRangeFieldType(n.mType) {
+		switch n.relation {
+		case WITHIN:
+			// documents within one of ranges, union of ranges isn't equal
+			return rangeNodeKeepOuter(n, t, lfNodeUnionJoinLfNode)
+		case CONTAINS:
+			// documents contain one of ranges, which is equal to contain the inner range
+			return rangeNodeKeepInner(n, t, lfNodeUnionJoinLfNode)
+		}
+	}
 	// first check overlap, if no overlap, return or ast node
 	if !checkRangeOverlap(n, t) {
 		return &BoolNode{
@@ -235,9 +448,11 @@ func rangeNodeUnionJoinRangeNode(n, t *RangeNode) (AstNode, error) {
 			fieldNode: n.fieldNode,
 			valueType: n.valueType,
 		},
-		timeZone:  n.timeZone,
-		relation:  n.relation,
-		boostNode: n.boostNode,
+		format:     n.format,
+		dateFormat: n.dateFormat,
+		timeZone:   n.timeZone,
+		relation:   n.relation,
+		boostNode:  n.boostNode,
 	}
 
 	unionCmpLeft(n, t, dst)
@@ -249,15 +464,19 @@ func unionCmpLeft(n, t, dst *RangeNode) {
 	var leftFlag = CompareAny(t.lValue, n.lValue, n.mType)
 	if leftFlag < 0 {
 		dst.lValue = t.lValue
+		dst.lDateMath = t.lDateMath
 		dst.lCmpSym = t.lCmpSym
 	} else if leftFlag > 0 {
 		dst.lValue = n.lValue
+		dst.lDateMath = n.lDateMath
 		dst.lCmpSym = n.lCmpSym
 	} else {
 		dst.lValue = n.lValue
 		if t.lCmpSym == GTE {
+			dst.lDateMath = t.lDateMath
 			dst.lCmpSym = t.lCmpSym
 		} else {
+			dst.lDateMath = n.lDateMath
 			dst.lCmpSym = n.lCmpSym
 		}
 	}
@@ -267,21 +486,28 @@ func unionCmpRight(n, t, dst *RangeNode) {
 	var rightFlag = CompareAny(t.rValue, n.rValue, n.mType)
 	if rightFlag > 0 {
 		dst.rValue = t.rValue
+		dst.rDateMath = t.rDateMath
 		dst.rCmpSym = t.rCmpSym
 	} else if rightFlag < 0 {
 		dst.rValue = n.rValue
+		dst.rDateMath = n.rDateMath
 		dst.rCmpSym = n.rCmpSym
 	} else {
 		dst.rValue = n.rValue
 		if t.rCmpSym == LTE {
+			dst.rDateMath = t.rDateMath
 			dst.rCmpSym = t.rCmpSym
 		} else {
+			dst.rDateMath = n.rDateMath
 			dst.rCmpSym = n.rCmpSym
 		}
 	}
 }
 
 func rangeNodeIntersectTermNode(n *RangeNode, t *TermNode) (AstNode, error) {
+	if CheckRangeFieldType(n.mType) {
+		return lfNodeIntersectLfNode(n.NodeKey(), n, t)
+	}
 	if checkRangeInclude(n, t.value) {
 		return t, nil
 	} else if n.IsArrayType() {
@@ -292,6 +518,33 @@ func rangeNodeIntersectTermNode(n *RangeNode, t *TermNode) (AstNode, error) {
 }
 
 func rangeNodeIntersectRangeNode(n, t *RangeNode) (AstNode, error) {
+	// nodes with different relations match different documents for the same range
+	if n.relation != t.relation {
+		return lfNodeIntersectLfNode(n.NodeKey(), n, t)
+	}
+	if CheckRangeFieldType(n.mType) {
+		switch n.relation {
+		case INTERSECTS:
+			// documents intersect with both ranges may not intersect with intersection of ranges
+			return rangeNodeKeepInner(n, t, lfNodeIntersectLfNode)
+		case CONTAINS:
+			// documents contain both ranges must contain the smallest range covering them
+			var dst = &RangeNode{
+				rgNode: rgNode{
+					fieldNode: n.fieldNode,
+					valueType: n.valueType,
+				},
+				format:     n.format,
+				dateFormat: n.dateFormat,
+				timeZone:   n.timeZone,
+				relation:   n.relation,
+				boostNode:  n.boostNode,
+			}
+			unionCmpLeft(n, t, dst)
+			unionCmpRight(n, t, dst)
+			return dst, nil
+		}
+	}
 	// first check have range overlap zone
 	if !checkRangeOverlap(n, t) {
 		if n.IsArrayType() {
@@ -307,9 +560,11 @@ func rangeNodeIntersectRangeNode(n, t *RangeNode) (AstNode, error) {
 			fieldNode: n.fieldNode,
 			valueType: n.valueType,
 		},
-		timeZone:  n.timeZone,
-		relation:  n.relation,
-		boostNode: n.boostNode,
+		format:     n.format,
+		dateFormat: n.dateFormat,
+		timeZone:   n.timeZone,
+		relation:   n.relation,
+		boostNode:  n.boostNode,
 	}
 	intersectCmpLeft(t, n, dst)
 	intersectCmpRight(t, n, dst)
@@ -321,15 +576,19 @@ func intersectCmpLeft(n, t, dst *RangeNode) {
 	var leftFlag = CompareAny(t.lValue, n.lValue, n.mType)
 	if leftFlag > 0 {
 		dst.lValue = t.lValue
+		dst.lDateMath = t.lDateMath
 		dst.lCmpSym = t.lCmpSym
 	} else if leftFlag < 0 {
 		dst.lValue = n.lValue
+		dst.lDateMath = n.lDateMath
 		dst.lCmpSym = n.lCmpSym
 	} else {
 		dst.lValue = n.lValue
 		if t.lCmpSym == GT {
+			dst.lDateMath = t.lDateMath
 			dst.lCmpSym = t.lCmpSym
 		} else {
+			dst.lDateMath = n.lDateMath
 			dst.lCmpSym = n.lCmpSym
 		}
 	}
@@ -339,20 +598,54 @@ func intersectCmpRight(n, t, dst *RangeNode) {
 	var rightFlag = CompareAny(t.rValue, n.rValue, n.mType)
 	if rightFlag < 0 {
 		dst.rValue = t.rValue
+		dst.rDateMath = t.rDateMath
 		dst.rCmpSym = t.rCmpSym
 	} else if rightFlag > 0 {
 		dst.rValue = n.rValue
+		dst.rDateMath = n.rDateMath
 		dst.rCmpSym = n.rCmpSym
 	} else {
 		dst.rValue = n.rValue
 		if t.rCmpSym == LT {
+			dst.rDateMath = t.rDateMath
 			dst.rCmpSym = t.rCmpSym
 		} else {
+			dst.rDateMath = n.rDateMath
 			dst.rCmpSym = n.rCmpSym
 		}
 	}
 }
 
+// rangeNodeKeepOuter returns the range containing another one, otherwise combines them by merge func
+func rangeNodeKeepOuter(n, t *RangeNode, merge func(string, AstNode, AstNode) (AstNode, error)) (AstNode, error) {
+	if checkRangeContain(n, t) {
+		return n, nil
+	} else if checkRangeContain(t, n) {
+		return t, nil
+	} else {
+		return merge(n.NodeKey(), n, t)
+	}
+}
+
+// rangeNodeKeepInner returns the range contained by another one, otherwise combines them by merge func
+func rangeNodeKeepInner(n, t *RangeNode, merge func(string, AstNode, AstNode) (AstNode, error)) (AstNode, error) {
+	if checkRangeContain(n, t) {
+		return t, nil
+	} else if checkRangeContain(t, n) {
+		return n, nil
+	} else {
+		return merge(n.NodeKey(), n, t)
+	}
+}
+
+// check range a contains range b
+func checkRangeContain(a, b *RangeNode) bool {
+	var leftCmpRes = CompareAny(a.lValue, b.lValue, a.mType)
+	var rightCmpRes = CompareAny(a.rValue, b.rValue, a.mType)
+	return (leftCmpRes < 0 || (leftCmpRes == 0 && (a.lCmpSym == GTE || b.lCmpSym == GT))) &&
+		(rightCmpRes > 0 || (rightCmpRes == 0 && (a.rCmpSym == LTE || b.rCmpSym == LT)))
+}
+
 // check range node include a value
 func checkRangeInclude(n *RangeNode, v LeafValue) bool {
 	var leftCmpRes = CompareAny(v, n.lValue, n.mType)
@@ -1173,3 +1173,330 @@ func TestRangeNodeToDsl(t *testing.T) {
 	}, node4.ToDSL())
 	t.Log(node4.ToDSL().String())
 }
+
+func TestRangeNodeWithRelation(t *testing.T) {
+	var newRangeNode = func(typ mapping.FieldType, l, r int, relation RelationType) *RangeNode {
+		return NewRangeNode(
+			NewRgNode(
+				NewFieldNode(NewLfNode(), "foo"),
+				NewValueType(typ, false),
+				l, r, GTE, LTE,
+			),
+			WithRelation(relation),
+		)
+	}
+	var (
+		intersects1 = newRangeNode(mapping.INTEGER_RANGE_FIELD_TYPE, 1, 10, INTERSECTS)
+		intersects2 = newRangeNode(mapping.INTEGER_RANGE_FIELD_TYPE, 5, 20, INTERSECTS)
+		intersects3 = newRangeNode(mapping.INTEGER_RANGE_FIELD_TYPE, 2, 8, INTERSECTS)
+		within1     = newRangeNode(mapping.INTEGER_RANGE_FIELD_TYPE, 1, 10, WITHIN)
+		within2     = newRangeNode(mapping.INTEGER_RANGE_FIELD_TYPE, 5, 20, WITHIN)
+		within3     = newRangeNode(mapping.INTEGER_RANGE_FIELD_TYPE, 2, 8, WITHIN)
+		contains1   = newRangeNode(mapping.INTEGER_RANGE_FIELD_TYPE, 1, 10, CONTAINS)
+		contains2   = newRangeNode(mapping.INTEGER_RANGE_FIELD_TYPE, 15, 20, CONTAINS)
+		contains3   = newRangeNode(mapping.INTEGER_RANGE_FIELD_TYPE, 2, 8, CONTAINS)
+	)
+
+	t.Run("different_relation", func(t *testing.T) {
+		res, err := intersects1.UnionJoin(within1)
+		assert.Nil(t, err)
+		assert.Equal(t, &BoolNode{
+			opNode:             opNode{opType: OR},
+			Should:             map[string][]AstNode{"foo": {intersects1, within1}},
+			MinimumShouldMatch: 1,
+		}, res)
+
+		res, err = within1.InterSect(contains1)
+		assert.Nil(t, err)
+		assert.Equal(t, &BoolNode{
+			opNode: opNode{opType: AND},
+			Must:   map[string][]AstNode{"foo": {within1, contains1}},
+		}, res)
+	})
+
+	t.Run("intersects", func(t *testing.T) {
+		res, err := intersects1.UnionJoin(intersects2)
+		assert.Nil(t, err)
+		assert.Equal(t, newRangeNode(mapping.INTEGER_RANGE_FIELD_TYPE, 1, 20, INTERSECTS), res)
+
+		res, err = intersects1.InterSect(intersects3)
+		assert.Nil(t, err)
+		assert.Equal(t, intersects3, res)
+
+		res, err = intersects1.InterSect(intersects2)
+		assert.Nil(t, err)
+		assert.Equal(t, &BoolNode{
+			opNode: opNode{opType: AND},
+			Must:   map[string][]AstNode{"foo": {intersects1, intersects2}},
+		}, res)
+	})
+
+	t.Run("within", func(t *testing.T) {
+		res, err := within1.UnionJoin(within3)
+		assert.Nil(t, err)
+		assert.Equal(t, within1, res)
+
+		res, err = within1.UnionJoin(within2)
+		assert.Nil(t, err)
+		assert.Equal(t, &BoolNode{
+			opNode:             opNode{opType: OR},
+			Should:             map[string][]AstNode{"foo": {within1, within2}},
+			MinimumShouldMatch: 1,
+		}, res)
+
+		res, err = within1.InterSect(within2)
+		assert.Nil(t, err)
+		assert.Equal(t, newRangeNode(mapping.INTEGER_RANGE_FIELD_TYPE, 5, 10, WITHIN), res)
+	})
+
+	t.Run("contains", func(t *testing.T) {
+		res, err := contains1.UnionJoin(contains3)
+		assert.Nil(t, err)
+		assert.Equal(t, contains3, res)
+
+		res, err = contains1.UnionJoin(contains2)
+		assert.Nil(t, err)
+		assert.Equal(t, &BoolNode{
+			opNode:             opNode{opType: OR},
+			Should:             map[string][]AstNode{"foo": {contains1, contains2}},
+			MinimumShouldMatch: 1,
+		}, res)
+
+		res, err = contains1.InterSect(contains2)
+		assert.Nil(t, err)
+		assert.Equal(t, newRangeNode(mapping.INTEGER_RANGE_FIELD_TYPE, 1, 20, CONTAINS), res)
+	})
+
+	t.Run("inverse", func(t *testing.T) {
+		res, err := intersects1.Inverse()
+		assert.Nil(t, err)
+		assert.Equal(t, &BoolNode{
+			opNode:  opNode{opType: NOT},
+			MustNot: map[string][]AstNode{"foo": {intersects1}},
+		}, res)
+	})
+
+	t.Run("term_on_range_field", func(t *testing.T) {
+		var term = NewTermNode(NewKVNode(
+			NewFieldNode(NewLfNode(), "foo"),
+			NewValueNode(5, NewValueType(mapping.INTEGER_RANGE_FIELD_TYPE, false)),
+		))
+		res, err := intersects1.InterSect(term)
+		assert.Nil(t, err)
+		assert.Equal(t, &BoolNode{
+			opNode: opNode{opType: AND},
+			Must:   map[string][]AstNode{"foo": {intersects1, term}},
+		}, res)
+	})
+}
+
+func TestRangeNodeWithDateMath(t *testing.T) {
+	var (
+		today     = time.Date(2022, 01, 02, 0, 0, 0, 0, time.UTC)
+		todayEnd  = time.Date(2022, 01, 02, 23, 59, 59, 999999999, time.UTC)
+		yesterday = time.Date(2022, 01, 01, 0, 0, 0, 0, time.UTC)
+	)
+	var newRangeNode = func(l, r time.Time, lCmpSym, rCmpSym CompareType, lDateMath, rDateMath string) *RangeNode {
+		return NewRangeNode(
+			NewRgNode(
+				NewFieldNode(NewLfNode(), "foo"),
+				NewValueType(mapping.DATE_FIELD_TYPE, true),
+				l, r, lCmpSym, rCmpSym,
+			),
+			WithDateMath(lDateMath, rDateMath),
+			WithFormat("yyyy-MM-dd||epoch_millis"),
+		)
+	}
+
+	t.Run("to_dsl", func(t *testing.T) {
+		var node = newRangeNode(yesterday, todayEnd, GTE, LTE, "now-1d/d", "now/d")
+		assert.Equal(t, DSL{
+			"range": DSL{
+				"foo": DSL{
+					GTE.String(): "now-1d/d",
+					LTE.String(): "now/d",
+					"format":     "yyyy-MM-dd||epoch_millis",
+					"relation":   INTERSECTS,
+					"boost":      1.0,
+				},
+			},
+		}, node.ToDSL())
+
+		node = newRangeNode(today, MaxTime, GTE, LT, "now/d", "")
+		assert.Equal(t, DSL{
+			"range": DSL{
+				"foo": DSL{
+					GTE.String(): "now/d",
+					"format":     "yyyy-MM-dd||epoch_millis",
+					"relation":   INTERSECTS,
+					"boost":      1.0,
+				},
+			},
+		}, node.ToDSL())
+
+		node = newRangeNode(MinTime, today, GT, LTE, "", "")
+		assert.Equal(t, DSL{
+			"range": DSL{
+				"foo": DSL{
+					LTE.String(): today.UnixNano() / 1e6,
+					"format":     "yyyy-MM-dd||epoch_millis",
+					"relation":   INTERSECTS,
+					"boost":      1.0,
+				},
+			},
+		}, node.ToDSL())
+	})
+
+	t.Run("union_join", func(t *testing.T) {
+		var node1 = newRangeNode(yesterday, today, GTE, LTE, "now-1d/d", "2022-01-02T00:00:00")
+		var node2 = newRangeNode(today, todayEnd, GTE, LTE, "now/d", "now/d")
+		res, err := node1.UnionJoin(node2)
+		assert.Nil(t, err)
+		assert.Equal(t, newRangeNode(yesterday, todayEnd, GTE, LTE, "now-1d/d", "now/d"), res)
+	})
+
+	t.Run("intersect", func(t *testing.T) {
+		var node1 = newRangeNode(yesterday, todayEnd, GTE, LTE, "now-1d/d", "now/d")
+		var node2 = newRangeNode(today, MaxTime, GTE, LT, "now/d", "")
+		res, err := node1.InterSect(node2)
+		assert.Nil(t, err)
+		assert.Equal(t, newRangeNode(today, todayEnd, GTE, LTE, "now/d", "now/d"), res)
+
+		// resolved value is equal, expr is taken with its compare symbol
+		node2 = newRangeNode(yesterday, MaxTime, GT, LT, "2022-01-01T00:00:00", "")
+		res, err = node1.InterSect(node2)
+		assert.Nil(t, err)
+		assert.Equal(t, newRangeNode(yesterday, todayEnd, GT, LTE, "2022-01-01T00:00:00", "now/d"), res)
+	})
+
+	t.Run("union_join_term", func(t *testing.T) {
+		var node = newRangeNode(yesterday, todayEnd, GT, LTE, "now-2d/d", "now/d")
+		res, err := node.UnionJoin(NewTermNode(NewKVNode(
+			NewFieldNode(NewLfNode(), "foo"),
+			NewValueNode(yesterday, NewValueType(mapping.DATE_FIELD_TYPE, true)),
+		)))
+		assert.Nil(t, err)
+		// expr of gt is rounded up, it's different from gte, so resolved value is kept
+		assert.Equal(t, newRangeNode(yesterday, todayEnd, GTE, LTE, "", "now/d"), res)
+	})
+
+	t.Run("inverse", func(t *testing.T) {
+		var node = newRangeNode(yesterday, todayEnd, GTE, LTE, "now-1d/d", "now/d")
+		res, err := node.Inverse()
+		assert.Nil(t, err)
+		assert.Equal(t, &BoolNode{
+			opNode: opNode{opType: OR},
+			Should: map[string][]AstNode{
+				"foo": {
+					newRangeNode(MinTime, yesterday, GT, LT, "", "now-1d/d"),
+					newRangeNode(todayEnd, MaxTime, GT, LT, "now/d", ""),
+				},
+			},
+			MinimumShouldMatch: 1,
+		}, res)
+	})
+}
+
+func TestRangeNodeWithDateFormat(t *testing.T) {
+	var (
+		from = time.Date(2024, 01, 01, 0, 0, 0, 1, time.UTC)
+		to   = time.Date(2024, 01, 02, 0, 0, 0, 0, time.UTC)
+	)
+	var newRangeNode = func(typ mapping.FieldType, opts ...func(AstNode)) *RangeNode {
+		return NewRangeNode(
+			NewRgNode(
+				NewFieldNode(NewLfNode(), "foo"),
+				NewValueType(typ, true),
+				from, to, GTE, LTE,
+			),
+			opts...,
+		)
+	}
+
+	// date_nanos is printed with nanosecond precision
+	assert.Equal(t, DSL{
+		"range": DSL{
+			"foo": DSL{
+				GTE.String(): "2024-01-01T00:00:00.000000001Z",
+				LTE.String(): "2024-01-02T00:00:00.000000000Z",
+				"format":     "strict_date_optional_time_nanos",
+				"relation":   INTERSECTS,
+				"boost":      1.0,
+			},
+		},
+	}, newRangeNode(mapping.DATE_NANOS_FIELD_TYPE).ToDSL())
+
+	// date is printed with the first date format printing it exactly in time zone of query,
+	// es rounds lte "2024/01/02 08" up to 08:59:59.999, so it's printed as epoch_millis
+	assert.Equal(t, DSL{
+		"range": DSL{
+			"foo": DSL{
+				GTE.String(): "2024/01/01 08",
+				LTE.String(): int64(1704153600000),
+				"format":     "yyyy/MM/dd HH||epoch_millis",
+				"relation":   INTERSECTS,
+				"time_zone":  "+08:00",
+				"boost":      1.0,
+			},
+		},
+	}, newRangeNode(mapping.DATE_FIELD_TYPE,
+		WithDateFormat("yyyy/MM/dd HH||epoch_millis"), WithFormat("yyyy/MM/dd HH||epoch_millis"), WithTimeZone("+08:00"),
+	).ToDSL())
+
+	// date is printed as strict_date_optional_time if none of date formats prints it exactly,
+	// number printed by epoch_millis is ambiguous because es parses it with epoch_second first
+	for _, dateFormat := range []string{"yyyy-MM-dd", "epoch_second||epoch_millis"} {
+		assert.Equal(t, DSL{
+			"range": DSL{
+				"foo": DSL{
+					GT.String():  "2024-01-01T00:00:00.001Z",
+					LTE.String(): "2024-01-02T00:00:00.000Z",
+					"format":     dateFormat + "||strict_date_optional_time",
+					"relation":   INTERSECTS,
+					"boost":      1.0,
+				},
+			},
+		}, NewRangeNode(
+			NewRgNode(
+				NewFieldNode(NewLfNode(), "foo"),
+				NewValueType(mapping.DATE_FIELD_TYPE, true),
+				time.Date(2024, 01, 01, 0, 0, 0, 1e6, time.UTC), to, GT, LTE,
+			),
+			WithDateFormat(dateFormat), WithFormat(dateFormat),
+		).ToDSL(), dateFormat)
+	}
+
+	// format of printing date is used as format parameter if it isn't specified
+	assert.Equal(t, DSL{
+		"range": DSL{
+			"foo": DSL{
+				GTE.String(): "2024-01-01T00:00:00.000Z",
+				LTE.String(): "2024-01-02T00:00:00.000Z",
+				"format":     "strict_date_optional_time",
+				"relation":   INTERSECTS,
+				"boost":      1.0,
+			},
+		},
+	}, newRangeNode(mapping.DATE_FIELD_TYPE, WithDateFormat("strict_date_optional_time")).ToDSL())
+
+	// merging is done with full precision
+	var node = newRangeNode(mapping.DATE_NANOS_FIELD_TYPE)
+	res, err := node.InterSect(NewRangeNode(
+		NewRgNode(
+			NewFieldNode(NewLfNode(), "foo"),
+			NewValueType(mapping.DATE_NANOS_FIELD_TYPE, true),
+			time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC), time.Date(2024, 01, 01, 0, 0, 0, 1, time.UTC), GTE, LT,
+		),
+	))
+	assert.Nil(t, err)
+	assert.Equal(t, &BoolNode{
+		opNode: opNode{opType: AND},
+		Must: map[string][]AstNode{"foo": {node, NewRangeNode(
+			NewRgNode(
+				NewFieldNode(NewLfNode(), "foo"),
+				NewValueType(mapping.DATE_NANOS_FIELD_TYPE, true),
+				time.Date(2024, 01, 01, 0, 0, 0, 0, time.UTC), time.Date(2024, 01, 01, 0, 0, 0, 1, time.UTC), GTE, LT,
+			),
+		)}},
+	}, res)
+}
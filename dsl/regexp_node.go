@@ -1,7 +1,7 @@
 package dsl
 
 import (
-	"regexp"
+	"github.com/zhuliquan/lucene-to-dsl/utils"
 )
 
 type RegexpNode struct {
@@ -20,7 +20,7 @@ func WithFlags(flags RegexpFlagType) func(AstNode) {
 	}
 }
 
-func NewRegexpNode(kvNode *kvNode, pattern *regexp.Regexp, opts ...func(AstNode)) *RegexpNode {
+func NewRegexpNode(kvNode *kvNode, pattern utils.PatternMatcher, opts ...func(AstNode)) *RegexpNode {
 	var n = &RegexpNode{
 		kvNode:      *kvNode,
 		rewriteNode: rewriteNode{rewrite: CONSTANT_SCORE},
@@ -42,11 +42,14 @@ func (n *RegexpNode) UnionJoin(o AstNode) (AstNode, error) {
 	if checkCommonDslType(o.DslType()) {
 		return o.UnionJoin(n)
 	}
+	if n.NodeKey() != o.NodeKey() {
+		return lfNodeUnionJoinLfNode(n.NodeKey(), n, o)
+	}
 	switch o.DslType() {
 	case TERM_DSL_TYPE:
 		return patternNodeUnionJoinTermNode(n, o.(*TermNode))
-	case REGEXP_DSL_TYPE:
-		return valueNodeUnionJoinValueNode(n, o)
+	case PREFIX_DSL_TYPE, WILDCARD_DSL_TYPE, REGEXP_DSL_TYPE:
+		return patternNodeUnionJoinPatternNode(n, o)
 	default:
 		return lfNodeUnionJoinLfNode(n.NodeKey(), n, o)
 	}
@@ -56,11 +59,14 @@ func (n *RegexpNode) InterSect(o AstNode) (AstNode, error) {
 	if checkCommonDslType(o.DslType()) {
 		return o.InterSect(n)
 	}
+	if n.NodeKey() != o.NodeKey() {
+		return lfNodeIntersectLfNode(n.NodeKey(), n, o)
+	}
 	switch o.DslType() {
 	case TERM_DSL_TYPE:
 		return patternNodeIntersectTermNode(n, o.(*TermNode))
-	case REGEXP_DSL_TYPE:
-		return valueNodeIntersectValueNode(n, o)
+	case PREFIX_DSL_TYPE, WILDCARD_DSL_TYPE, REGEXP_DSL_TYPE:
+		return patternNodeIntersectPatternNode(n, o)
 	default:
 		return lfNodeIntersectLfNode(n.NodeKey(), n, o)
 	}
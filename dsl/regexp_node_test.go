@@ -6,6 +6,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	mapping "github.com/zhuliquan/es-mapping"
+	"github.com/zhuliquan/lucene-to-dsl/utils"
 )
 
 func TestRegexpNode(t *testing.T) {
@@ -193,3 +194,65 @@ func TestRegexpNodeMergeRegexpNode(t *testing.T) {
 		},
 	}, n5)
 }
+
+func TestRegexpNodeMergePatternNode(t *testing.T) {
+	var newRegexpNode = func(value string, opts ...func(AstNode)) *RegexpNode {
+		pattern, err := utils.NewRegexpPattern(value, utils.ALL_REGEXP_FLAG)
+		assert.Nil(t, err)
+		return NewRegexpNode(NewKVNode(
+			NewFieldNode(NewLfNode(), "foo"),
+			NewValueNode(value, NewValueType(mapping.KEYWORD_FIELD_TYPE, false)),
+		), pattern, opts...)
+	}
+	var n1 = newRegexpNode("foo<1-100>")
+	var n2 = newRegexpNode("foo<10-20>")
+	var n3 = newRegexpNode("bar[0-9]+")
+	var n4 = newRegexpNode("(a|b)*a(a|b){20}")
+	var n5 = newRegexpNode("(a|b)*", WithMaxDeterminizedStates(1))
+	var n6 = NewWildCardNode(NewKVNode(
+		NewFieldNode(NewLfNode(), "foo"),
+		NewValueNode("foo?", NewValueType(mapping.KEYWORD_FIELD_TYPE, false)),
+	), utils.NewWildCardPattern("foo?"))
+
+	n7, err := n1.UnionJoin(n2)
+	assert.Nil(t, err)
+	assert.Equal(t, n1, n7)
+
+	n7, err = n1.InterSect(n2)
+	assert.Nil(t, err)
+	assert.Equal(t, n2, n7)
+
+	n7, err = n1.InterSect(n3)
+	assert.NotNil(t, err)
+	assert.Nil(t, n7)
+
+	// foo? and foo<1-100> are overlapped on foo1 ~ foo9
+	n7, err = n6.InterSect(n1)
+	assert.Nil(t, err)
+	assert.Equal(t, &BoolNode{
+		opNode: opNode{opType: AND},
+		Must: map[string][]AstNode{
+			"foo": {n6, n1},
+		},
+	}, n7)
+
+	// relation can't be decided when automaton exceeds max determinized states
+	n7, err = n4.InterSect(n3)
+	assert.Nil(t, err)
+	assert.Equal(t, &BoolNode{
+		opNode: opNode{opType: AND},
+		Must: map[string][]AstNode{
+			"foo": {n4, n3},
+		},
+	}, n7)
+
+	n7, err = n5.UnionJoin(n3)
+	assert.Nil(t, err)
+	assert.Equal(t, &BoolNode{
+		opNode: opNode{opType: OR},
+		Should: map[string][]AstNode{
+			"foo": {n5, n3},
+		},
+		MinimumShouldMatch: 1,
+	}, n7)
+}
@@ -0,0 +1,422 @@
+package dsl
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	mapping "github.com/zhuliquan/es-mapping"
+)
+
+// metaSortFields are fields which can be sorted by without mapping
+var metaSortFields = map[string]bool{
+	"_score":     true,
+	"_doc":       true,
+	"_shard_doc": true,
+	"_index":     true,
+}
+
+// unsortableTypes are types of fields which can't be sorted by, geo_point is sorted by _geo_distance instead of field
+var unsortableTypes = map[mapping.FieldType]bool{
+	mapping.OBJECT_FIELD_TYPE:          true,
+	mapping.NESTED_FIELD_TYPE:          true,
+	mapping.BINARY_FIELD_TYPE:          true,
+	mapping.GEO_POINT_FIELD_TYPE:       true,
+	mapping.GEO_SHAPE_FIELD_TYPE:       true,
+	mapping.SHAPE_FIELD_TYPE:           true,
+	mapping.POINT_FIELD_TYPE:           true,
+	mapping.PERCOLATOR_FIELD_TYPE:      true,
+	mapping.MATCH_ONLY_TEXT_FIELD_TYPE: true,
+}
+
+// timeoutPattern is pattern of es time units, e.g. "500ms", "10s", "1m"
+var timeoutPattern = regexp.MustCompile(`^\d+(d|h|m|s|ms|micros|nanos)$`)
+
+// Sort is sort clause of search request, default order of es is used if order is empty
+type Sort struct {
+	Field string
+	Order SortOrder
+}
+
+// SearchRequest is complete body of es _search request around the converted query
+type SearchRequest struct {
+	query          AstNode
+	size           *int
+	from           *int
+	sorts          []Sort
+	fetchSource    *bool
+	includes       []string
+	excludes       []string
+	trackTotalHits interface{}
+	searchAfter    []interface{}
+	timeout        string
+	compact        bool
+	highlight      bool
+	highlightOpts  []HighlightOption
+	runtimeFields  map[string]interface{}
+}
+
+type SearchRequestOption func(*SearchRequest)
+
+func WithSize(size int) SearchRequestOption {
+	return func(r *SearchRequest) {
+		r.size = &size
+	}
+}
+
+func WithFrom(from int) SearchRequestOption {
+	return func(r *SearchRequest) {
+		r.from = &from
+	}
+}
+
+// WithSort appends sort clauses of search request
+func WithSort(sorts ...Sort) SearchRequestOption {
+	return func(r *SearchRequest) {
+		r.sorts = append(r.sorts, sorts...)
+	}
+}
+
+// WithSource specifies paths of _source included in / excluded from hits, wildcard is supported (i.e. "user.*")
+func WithSource(includes, excludes []string) SearchRequestOption {
+	return func(r *SearchRequest) {
+		r.includes = includes
+		r.excludes = excludes
+	}
+}
+
+// WithFetchSource specifies whether returning _source in hits
+func WithFetchSource(fetchSource bool) SearchRequestOption {
+	return func(r *SearchRequest) {
+		r.fetchSource = &fetchSource
+	}
+}
+
+// WithTrackTotalHits specifies whether tracking accurate total hits
+func WithTrackTotalHits(track bool) SearchRequestOption {
+	return func(r *SearchRequest) {
+		r.trackTotalHits = track
+	}
+}
+
+// WithTrackTotalHitsUpTo specifies tracking accurate total hits up to given number
+func WithTrackTotalHitsUpTo(upTo int) SearchRequestOption {
+	return func(r *SearchRequest) {
+		r.trackTotalHits = upTo
+	}
+}
+
+// WithSearchAfter specifies sort values of last hit of previous page, one value per sort clause
+func WithSearchAfter(values ...interface{}) SearchRequestOption {
+	return func(r *SearchRequest) {
+		r.searchAfter = values
+	}
+}
+
+// WithTimeout specifies timeout of search request in es time units, e.g. "500ms", "10s"
+func WithTimeout(timeout string) SearchRequestOption {
+	return func(r *SearchRequest) {
+		r.timeout = timeout
+	}
+}
+
+// WithCompactQuery specifies whether query of search request is printed in compact form (see Compact)
+func WithCompactQuery(compact bool) SearchRequestOption {
+	return func(r *SearchRequest) {
+		r.compact = compact
+	}
+}
+
+// WithHighlight specifies emitting highlight section for text / keyword fields queried by search request (see Highlight)
+func WithHighlight(opts ...HighlightOption) SearchRequestOption {
+	return func(r *SearchRequest) {
+		r.highlight = true
+		r.highlightOpts = opts
+	}
+}
+
+// WithRuntimeMappings specifies search-time runtime fields keyed by name, i.e. `{"day_of_week": {"type": "keyword", "script": ...}}`,
+// which can be sorted by as well as fields of mapping, only runtime fields referenced by query or sort are emitted
+func WithRuntimeMappings(runtimeMappings map[string]interface{}) SearchRequestOption {
+	return func(r *SearchRequest) {
+		r.runtimeFields = runtimeMappings
+	}
+}
+
+func NewSearchRequest(node AstNode, opts ...SearchRequestOption) *SearchRequest {
+	var r = &SearchRequest{query: node}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Validate checks settings of search request, sort fields and _source paths are checked against mapping if it's provided
+func (r *SearchRequest) Validate(pm *mapping.PropertyMapping) error {
+	if r.size != nil && *r.size < 0 {
+		return fmt.Errorf("size: %d is negative", *r.size)
+	}
+	if r.from != nil && *r.from < 0 {
+		return fmt.Errorf("from: %d is negative", *r.from)
+	}
+	if upTo, ok := r.trackTotalHits.(int); ok && upTo < 0 {
+		return fmt.Errorf("track_total_hits: %d is negative", upTo)
+	}
+	if r.timeout != "" && !timeoutPattern.MatchString(r.timeout) {
+		return fmt.Errorf("timeout: %s is invalid, expect time units like 500ms or 10s", r.timeout)
+	}
+	if len(r.searchAfter) != 0 {
+		if len(r.searchAfter) != len(r.sorts) {
+			return fmt.Errorf("search_after has %d values, but sort has %d clauses", len(r.searchAfter), len(r.sorts))
+		}
+		if r.from != nil && *r.from != 0 {
+			return fmt.Errorf("from must be 0 when search_after is used")
+		}
+	}
+	for _, sort := range r.sorts {
+		if err := r.validateRuntimeSort(sort); err == nil {
+			continue
+		} else if err != errNotRuntimeField {
+			return err
+		}
+		if err := validateSort(sort, pm); err != nil {
+			return err
+		}
+	}
+	if pm != nil {
+		for _, path := range append(append([]string{}, r.includes...), r.excludes...) {
+			if err := validateSourcePath(path, pm); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateIndices checks search request against mappings of multiple indices keyed by index name, sort field must be
+// sortable in every index as es requires, and _source path must match fields of any index
+func (r *SearchRequest) ValidateIndices(mappings map[string]*mapping.PropertyMapping) error {
+	if err := r.Validate(nil); err != nil {
+		return err
+	}
+	var indices = make([]string, 0, len(mappings))
+	for index := range mappings {
+		indices = append(indices, index)
+	}
+	sort.Strings(indices)
+	for _, sortClause := range r.sorts {
+		if err := r.validateRuntimeSort(sortClause); err == nil {
+			continue
+		} else if err != errNotRuntimeField {
+			return err
+		}
+		for _, index := range indices {
+			if err := validateSort(sortClause, mappings[index]); err != nil {
+				return fmt.Errorf("index: %s, err: %v", index, err)
+			}
+		}
+	}
+	for _, path := range append(append([]string{}, r.includes...), r.excludes...) {
+		var err error
+		for _, index := range indices {
+			if err = validateSourcePath(path, mappings[index]); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ToDSL returns complete body of es _search request, query is omitted for empty node which matches all documents
+func (r *SearchRequest) ToDSL() DSL {
+	return r.toDSL(nil)
+}
+
+// toDSL returns complete body of es _search request, highlighted fields are expanded by mapping if it's provided
+func (r *SearchRequest) toDSL(pm *mapping.PropertyMapping) DSL {
+	var res = DSL{}
+	if r.query != nil {
+		var query = r.query.ToDSL()
+		if r.compact {
+			query = Compact(query)
+		}
+		if len(query) != 0 {
+			res[QUERY_KEY] = query
+		}
+	}
+	if r.size != nil {
+		res[SIZE_KEY] = *r.size
+	}
+	if r.from != nil {
+		res[FROM_KEY] = *r.from
+	}
+	if len(r.sorts) != 0 {
+		var sorts = make([]interface{}, 0, len(r.sorts))
+		for _, sort := range r.sorts {
+			if sort.Order == "" {
+				sorts = append(sorts, sort.Field)
+			} else {
+				sorts = append(sorts, DSL{sort.Field: DSL{ORDER_KEY: sort.Order}})
+			}
+		}
+		res[SORT_KEY] = sorts
+	}
+	if len(r.includes) != 0 || len(r.excludes) != 0 {
+		var source = DSL{}
+		addValueForDSL(source, INCLUDES_KEY, r.includes)
+		addValueForDSL(source, EXCLUDES_KEY, r.excludes)
+		res[SOURCE_KEY] = source
+	} else if r.fetchSource != nil {
+		res[SOURCE_KEY] = *r.fetchSource
+	}
+	if r.trackTotalHits != nil {
+		res[TRACK_TOTAL_HITS_KEY] = r.trackTotalHits
+	}
+	if runtimeFields := r.referencedRuntimeFields(); len(runtimeFields) != 0 {
+		res[RUNTIME_MAPPINGS_KEY] = runtimeFields
+	}
+	if r.highlight && r.query != nil {
+		var highlightOpts = r.highlightOpts
+		if pm != nil {
+			highlightOpts = append([]HighlightOption{WithHighlightMapping(pm)}, highlightOpts...)
+		}
+		var highlight = Highlight(r.query, highlightOpts...)
+		if query, ok := highlight[HIGHLIGHT_QUERY_KEY].(DSL); ok && r.compact {
+			highlight[HIGHLIGHT_QUERY_KEY] = Compact(query)
+		}
+		if len(highlight) != 0 {
+			res[HIGHLIGHT_KEY] = highlight
+		}
+	}
+	addValueForDSL(res, SEARCH_AFTER_KEY, r.searchAfter)
+	addValueForDSL(res, TIMEOUT_KEY, r.timeout)
+	return res
+}
+
+// Build validates search request and returns complete body of es _search request, mapping is also used to
+// expand highlighted fields
+func (r *SearchRequest) Build(pm *mapping.PropertyMapping) (DSL, error) {
+	if err := r.Validate(pm); err != nil {
+		return nil, err
+	}
+	return r.toDSL(pm), nil
+}
+
+// referencedRuntimeFields returns runtime fields referenced by fields of query (wildcard is supported) or sort,
+// composite runtime field is referenced by its sub fields
+func (r *SearchRequest) referencedRuntimeFields() map[string]interface{} {
+	if len(r.runtimeFields) == 0 {
+		return nil
+	}
+	var fields []string
+	if r.query != nil {
+		fields = Introspect(r.query).Fields()
+	}
+	for _, sort := range r.sorts {
+		fields = append(fields, sort.Field)
+	}
+	var res = map[string]interface{}{}
+	for name, definition := range r.runtimeFields {
+		for _, field := range fields {
+			if ok, _ := path.Match(field, name); ok || field == name || strings.HasPrefix(field, name+".") {
+				res[name] = definition
+				break
+			}
+		}
+	}
+	return res
+}
+
+var errNotRuntimeField = fmt.Errorf("not runtime field")
+
+// validateRuntimeSort checks sort clause on search-time runtime field (or sub field of composite runtime field),
+// errNotRuntimeField is returned if sort field isn't runtime field
+func (r *SearchRequest) validateRuntimeSort(sort Sort) error {
+	var name = sort.Field
+	var definition, ok = r.runtimeFields[name].(map[string]interface{})
+	if idx := strings.LastIndexByte(name, '.'); !ok && idx > 0 {
+		if parent, isParent := r.runtimeFields[name[:idx]].(map[string]interface{}); isParent {
+			var fields, _ = parent[FIELDS_KEY].(map[string]interface{})
+			definition, ok = fields[name[idx+1:]].(map[string]interface{})
+		}
+	}
+	if !ok {
+		return errNotRuntimeField
+	}
+	if sort.Order != "" && sort.Order != ASC_ORDER && sort.Order != DESC_ORDER {
+		return fmt.Errorf("sort order: %s is invalid, expect asc or desc", sort.Order)
+	}
+	var typ, _ = definition[TYPE_KEY].(string)
+	if unsortableTypes[mapping.FieldType(typ)] || typ == "composite" || typ == "lookup" {
+		return fmt.Errorf("sort field: %s, type: %s is not sortable", sort.Field, typ)
+	}
+	return nil
+}
+
+func validateSort(sort Sort, pm *mapping.PropertyMapping) error {
+	if sort.Field == "" {
+		return fmt.Errorf("sort field is empty")
+	}
+	if sort.Order != "" && sort.Order != ASC_ORDER && sort.Order != DESC_ORDER {
+		return fmt.Errorf("sort order: %s is invalid, expect asc or desc", sort.Order)
+	}
+	if strings.ContainsAny(sort.Field, "*?") {
+		return fmt.Errorf("sort field: %s can't contain wildcard", sort.Field)
+	}
+	if metaSortFields[sort.Field] || pm == nil {
+		return nil
+	}
+	props, err := pm.GetProperty(sort.Field)
+	if err != nil {
+		return err
+	}
+	var prop, ok = props[sort.Field]
+	if !ok {
+		// field mapped by dynamic mapping or dynamic templates after indexing isn't in provided mapping
+		return fmt.Errorf("sort field: %s isn't defined in mapping, field only mapped by dynamic mapping or "+
+			"dynamic templates must be added to mapping or runtime mappings to be sorted by", sort.Field)
+	}
+	if prop.Type == "" || unsortableTypes[prop.Type] || CheckRangeFieldType(prop.Type) {
+		return fmt.Errorf("sort field: %s, type: %s is not sortable", sort.Field, prop.Type)
+	}
+	if prop.Type == mapping.TEXT_FIELD_TYPE {
+		if enabled, _ := propertyOption(prop, "fielddata").(bool); !enabled {
+			return fmt.Errorf("sort field: %s is text field without fielddata, sort by its keyword sub field instead", sort.Field)
+		}
+	} else if enabled, ok := propertyOption(prop, "doc_values").(bool); ok && !enabled {
+		return fmt.Errorf("sort field: %s is not sortable because doc_values is disabled", sort.Field)
+	}
+	return nil
+}
+
+// validateSourcePath checks whether path of _source matches any field or object in mapping
+func validateSourcePath(path string, pm *mapping.PropertyMapping) error {
+	for _, pattern := range []string{path, path + ".*"} {
+		props, err := pm.GetProperty(pattern)
+		if err != nil {
+			return err
+		}
+		if len(props) != 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("_source path: %s don't match any es mapping", path)
+}
+
+// propertyOption gets option (i.e. fielddata, doc_values) of field mapping, which is defined in mapping or its ext_properties
+func propertyOption(prop *mapping.Property, key string) interface{} {
+	if v, ok := prop.ExtProperties[key]; ok {
+		return v
+	}
+	var m map[string]interface{}
+	if data, err := json.Marshal(prop); err == nil && json.Unmarshal(data, &m) == nil {
+		return m[key]
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package dsl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	mapping "github.com/zhuliquan/es-mapping"
+)
+
+var searchMapping = []byte(`{
+  "properties": {
+    "status": {"type": "keyword"},
+    "title": {"type": "text", "fields": {"raw": {"type": "keyword"}}},
+    "body": {"type": "text", "ext_properties": {"fielddata": true}},
+    "count": {"type": "integer"},
+    "hidden": {"type": "long", "ext_properties": {"doc_values": false}},
+    "period": {"type": "date_range"},
+    "user": {"properties": {"name": {"type": "keyword"}, "age": {"type": "integer"}}},
+    "meta": {"type": "object", "properties": {"tag": {"type": "keyword"}}},
+    "headline": {"type": "alias", "ext_properties": {"path": "title"}}
+  }
+}`)
+
+func TestSearchRequestToDSL(t *testing.T) {
+	var term = NewTermNode(NewKVNode(
+		NewFieldNode(NewLfNode(), "status"),
+		NewValueNode("active", NewValueType(mapping.KEYWORD_FIELD_TYPE, false)),
+	))
+
+	type testCase struct {
+		name string
+		node AstNode
+		opts []SearchRequestOption
+		want string
+	}
+	for _, tt := range []testCase{
+		{
+			name: "query_only",
+			node: term,
+			want: `{"query":{"term":{"status":{"boost":1,"value":"active"}}}}`,
+		},
+		{
+			name: "compact_query",
+			node: term,
+			opts: []SearchRequestOption{WithCompactQuery(true)},
+			want: `{"query":{"term":{"status":"active"}}}`,
+		},
+		{
+			name: "empty_query",
+			node: &EmptyNode{},
+			opts: []SearchRequestOption{WithSize(0)},
+			want: `{"size":0}`,
+		},
+		{
+			name: "full",
+			node: term,
+			opts: []SearchRequestOption{
+				WithCompactQuery(true),
+				WithSize(10),
+				WithFrom(0),
+				WithSort(Sort{Field: "count", Order: DESC_ORDER}, Sort{Field: "_doc"}),
+				WithSource([]string{"status", "user.*"}, []string{"body"}),
+				WithTrackTotalHitsUpTo(1000),
+				WithSearchAfter(10, "42"),
+				WithTimeout("500ms"),
+			},
+			want: `{"_source":{"excludes":["body"],"includes":["status","user.*"]},"from":0,` +
+				`"query":{"term":{"status":"active"}},"search_after":[10,"42"],"size":10,` +
+				`"sort":[{"count":{"order":"desc"}},"_doc"],"timeout":"500ms","track_total_hits":1000}`,
+		},
+		{
+			name: "runtime_mappings",
+			node: term,
+			opts: []SearchRequestOption{
+				WithCompactQuery(true),
+				WithSort(Sort{Field: "client.port"}),
+				WithRuntimeMappings(map[string]interface{}{
+					"status": map[string]interface{}{"type": "keyword"},
+					"client": map[string]interface{}{"type": "composite", "fields": map[string]interface{}{"port": map[string]interface{}{"type": "long"}}},
+					"unused": map[string]interface{}{"type": "long"},
+				}),
+			},
+			want: `{"query":{"term":{"status":"active"}},"runtime_mappings":{"client":{"fields":{"port":{"type":"long"}},"type":"composite"},` +
+				`"status":{"type":"keyword"}},"sort":["client.port"]}`,
+		},
+		{
+			name: "unreferenced_runtime_mappings",
+			node: term,
+			opts: []SearchRequestOption{WithCompactQuery(true), WithRuntimeMappings(map[string]interface{}{"unused": map[string]interface{}{"type": "long"}})},
+			want: `{"query":{"term":{"status":"active"}}}`,
+		},
+		{
+			name: "fetch_source",
+			node: term,
+			opts: []SearchRequestOption{WithCompactQuery(true), WithFetchSource(false), WithTrackTotalHits(true)},
+			want: `{"_source":false,"query":{"term":{"status":"active"}},"track_total_hits":true}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, NewSearchRequest(tt.node, tt.opts...).ToDSL().String())
+		})
+	}
+}
+
+func TestSearchRequestValidate(t *testing.T) {
+	pm, err := mapping.LoadMappingData(searchMapping)
+	assert.Nil(t, err)
+	var runtimeMappings = map[string]interface{}{
+		"day":      map[string]interface{}{"type": "keyword"},
+		"location": map[string]interface{}{"type": "geo_point"},
+		"client":   map[string]interface{}{"type": "composite", "fields": map[string]interface{}{"port": map[string]interface{}{"type": "long"}}},
+	}
+
+	type testCase struct {
+		name    string
+		opts    []SearchRequestOption
+		noPM    bool
+		wantErr bool
+	}
+	for _, tt := range []testCase{
+		{name: "no_settings"},
+		{name: "negative_size", opts: []SearchRequestOption{WithSize(-1)}, wantErr: true},
+		{name: "negative_from", opts: []SearchRequestOption{WithFrom(-1)}, wantErr: true},
+		{name: "negative_track_total_hits", opts: []SearchRequestOption{WithTrackTotalHitsUpTo(-1)}, wantErr: true},
+		{name: "valid_timeout", opts: []SearchRequestOption{WithTimeout("10s")}},
+		{name: "invalid_timeout", opts: []SearchRequestOption{WithTimeout("10 seconds")}, wantErr: true},
+		{
+			name: "search_after",
+			opts: []SearchRequestOption{WithSort(Sort{Field: "count"}, Sort{Field: "status"}), WithSearchAfter(1, "a")},
+		},
+		{
+			name:    "search_after_mismatch_sort",
+			opts:    []SearchRequestOption{WithSort(Sort{Field: "count"}), WithSearchAfter(1, "a")},
+			wantErr: true,
+		},
+		{
+			name:    "search_after_with_from",
+			opts:    []SearchRequestOption{WithSort(Sort{Field: "count"}), WithSearchAfter(1), WithFrom(10)},
+			wantErr: true,
+		},
+		{name: "sort_keyword", opts: []SearchRequestOption{WithSort(Sort{Field: "status", Order: ASC_ORDER})}},
+		{name: "sort_keyword_sub_field", opts: []SearchRequestOption{WithSort(Sort{Field: "title.raw"})}},
+		{name: "sort_text_with_fielddata", opts: []SearchRequestOption{WithSort(Sort{Field: "body"})}},
+		{name: "sort_object_property", opts: []SearchRequestOption{WithSort(Sort{Field: "user.age"})}},
+		{name: "sort_score", opts: []SearchRequestOption{WithSort(Sort{Field: "_score", Order: DESC_ORDER})}},
+		{name: "sort_index", opts: []SearchRequestOption{WithSort(Sort{Field: "_index"})}},
+		{name: "sort_text", opts: []SearchRequestOption{WithSort(Sort{Field: "title"})}, wantErr: true},
+		{name: "sort_doc_values_disabled", opts: []SearchRequestOption{WithSort(Sort{Field: "hidden"})}, wantErr: true},
+		{name: "sort_range_type", opts: []SearchRequestOption{WithSort(Sort{Field: "period"})}, wantErr: true},
+		{name: "sort_object", opts: []SearchRequestOption{WithSort(Sort{Field: "meta"})}, wantErr: true},
+		{name: "sort_unknown_field", opts: []SearchRequestOption{WithSort(Sort{Field: "missing"})}, wantErr: true},
+		{name: "sort_wildcard_field", opts: []SearchRequestOption{WithSort(Sort{Field: "user.*"})}, wantErr: true},
+		{name: "sort_empty_field", opts: []SearchRequestOption{WithSort(Sort{})}, wantErr: true},
+		{name: "sort_invalid_order", opts: []SearchRequestOption{WithSort(Sort{Field: "count", Order: "up"})}, wantErr: true},
+		{name: "sort_without_mapping", opts: []SearchRequestOption{WithSort(Sort{Field: "title"})}, noPM: true},
+		{
+			name: "sort_runtime_field",
+			opts: []SearchRequestOption{
+				WithSort(Sort{Field: "day", Order: DESC_ORDER}, Sort{Field: "client.port"}),
+				WithRuntimeMappings(runtimeMappings),
+			},
+		},
+		{
+			name:    "sort_runtime_invalid_order",
+			opts:    []SearchRequestOption{WithSort(Sort{Field: "day", Order: "up"}), WithRuntimeMappings(runtimeMappings)},
+			wantErr: true,
+		},
+		{
+			name:    "sort_runtime_composite",
+			opts:    []SearchRequestOption{WithSort(Sort{Field: "client"}), WithRuntimeMappings(runtimeMappings)},
+			wantErr: true,
+		},
+		{
+			name:    "sort_runtime_geo_point",
+			opts:    []SearchRequestOption{WithSort(Sort{Field: "location"}), WithRuntimeMappings(runtimeMappings)},
+			wantErr: true,
+		},
+		{name: "source_fields", opts: []SearchRequestOption{WithSource([]string{"status", "title.raw"}, nil)}},
+		{name: "source_object", opts: []SearchRequestOption{WithSource([]string{"user"}, []string{"meta"})}},
+		{name: "source_wildcard", opts: []SearchRequestOption{WithSource([]string{"user.*"}, nil)}},
+		{name: "source_unknown_include", opts: []SearchRequestOption{WithSource([]string{"missing"}, nil)}, wantErr: true},
+		{name: "source_unknown_exclude", opts: []SearchRequestOption{WithSource(nil, []string{"user.missing"})}, wantErr: true},
+		{name: "source_without_mapping", opts: []SearchRequestOption{WithSource([]string{"missing"}, nil)}, noPM: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var p = pm
+			if tt.noPM {
+				p = nil
+			}
+			d, err := NewSearchRequest(&EmptyNode{}, tt.opts...).Build(p)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				assert.Nil(t, d)
+			} else {
+				assert.Nil(t, err)
+				assert.NotNil(t, d)
+			}
+		})
+	}
+
+	_, err = NewSearchRequest(&EmptyNode{}, WithSort(Sort{Field: "missing"})).Build(pm)
+	assert.EqualError(t, err, "sort field: missing isn't defined in mapping, field only mapped by dynamic mapping or "+
+		"dynamic templates must be added to mapping or runtime mappings to be sorted by")
+}
+
+func TestSearchRequestValidateIndices(t *testing.T) {
+	pm1, err := mapping.LoadMappingData([]byte(`{"properties": {"x": {"type": "long"}, "name": {"type": "keyword"}, "a": {"type": "keyword"}}}`))
+	assert.Nil(t, err)
+	pm2, err := mapping.LoadMappingData([]byte(`{"properties": {"x": {"type": "text"}, "name": {"type": "keyword"}, "b": {"type": "keyword"}}}`))
+	assert.Nil(t, err)
+	var mappings = map[string]*mapping.PropertyMapping{"logs-1": pm1, "logs-2": pm2}
+
+	for _, tt := range []struct {
+		name    string
+		opts    []SearchRequestOption
+		wantErr string
+	}{
+		{name: "sortable_in_all_indices", opts: []SearchRequestOption{WithSort(Sort{Field: "name"})}},
+		{name: "meta_field", opts: []SearchRequestOption{WithSort(Sort{Field: "_index"})}},
+		{
+			name:    "unsortable_in_one_index",
+			opts:    []SearchRequestOption{WithSort(Sort{Field: "x"})},
+			wantErr: "index: logs-2, err: sort field: x is text field without fielddata, sort by its keyword sub field instead",
+		},
+		{
+			name: "missing_in_one_index",
+			opts: []SearchRequestOption{WithSort(Sort{Field: "a"})},
+			wantErr: "index: logs-2, err: sort field: a isn't defined in mapping, field only mapped by dynamic mapping or " +
+				"dynamic templates must be added to mapping or runtime mappings to be sorted by",
+		},
+		{name: "source_in_any_index", opts: []SearchRequestOption{WithSource([]string{"a", "b"}, nil)}},
+		{name: "source_in_no_index", opts: []SearchRequestOption{WithSource([]string{"c"}, nil)}, wantErr: "_source path: c don't match any es mapping"},
+		{name: "invalid_settings", opts: []SearchRequestOption{WithSize(-1)}, wantErr: "size: -1 is negative"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewSearchRequest(&EmptyNode{}, tt.opts...).ValidateIndices(mappings)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
@@ -37,7 +37,7 @@ func (n *TermNode) UnionJoin(o AstNode) (AstNode, error) {
 	switch o.DslType() {
 	case TERM_DSL_TYPE:
 		return termNodeUnionJoinTermNode(n, o.(*TermNode))
-	case RANGE_DSL_TYPE, PREFIX_DSL_TYPE, REGEXP_DSL_TYPE, WILDCARD_DSL_TYPE, IDS_DSL_TYPE:
+	case RANGE_DSL_TYPE, PREFIX_DSL_TYPE, REGEXP_DSL_TYPE, WILDCARD_DSL_TYPE, FUZZY_DSL_TYPE, IDS_DSL_TYPE:
 		return o.UnionJoin(n)
 	default:
 		return lfNodeUnionJoinLfNode(n.NodeKey(), n, o)
@@ -59,7 +59,7 @@ func (n *TermNode) InterSect(o AstNode) (AstNode, error) {
 	switch o.DslType() {
 	case TERM_DSL_TYPE:
 		return termNodeIntersectTermNode(n, o.(*TermNode))
-	case RANGE_DSL_TYPE, PREFIX_DSL_TYPE, REGEXP_DSL_TYPE, WILDCARD_DSL_TYPE, IDS_DSL_TYPE:
+	case RANGE_DSL_TYPE, PREFIX_DSL_TYPE, REGEXP_DSL_TYPE, WILDCARD_DSL_TYPE, FUZZY_DSL_TYPE, IDS_DSL_TYPE:
 		return o.InterSect(n)
 	default:
 		return lfNodeIntersectLfNode(n.NodeKey(), n, o)
@@ -6,11 +6,13 @@ import (
 	"math"
 	"net"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/go-version"
 	"github.com/x448/float16"
 	mapping "github.com/zhuliquan/es-mapping"
+	"github.com/zhuliquan/lucene-to-dsl/utils"
 	"github.com/zhuliquan/scaled_float"
 )
 
@@ -315,6 +317,16 @@ func compareVersion(a, b LeafValue) int {
 	return av.Compare(bv)
 }
 
+// ParseRelation parses relation of range query case-insensitively, which is one of INTERSECTS, CONTAINS and WITHIN
+func ParseRelation(relation string) (RelationType, error) {
+	switch r := RelationType(strings.ToUpper(relation)); r {
+	case INTERSECTS, CONTAINS, WITHIN:
+		return r, nil
+	default:
+		return "", fmt.Errorf("relation: %q is invalid, expect one of INTERSECTS, CONTAINS and WITHIN", relation)
+	}
+}
+
 // CheckValidRangeNode check if range node is valid
 // return error if range is conflict (left > right or left == right and left cmp sym is GT or right cmp sym is LT)
 func CheckValidRangeNode(node *RangeNode) error {
@@ -387,6 +399,18 @@ var maxInf = map[mapping.FieldType]LeafValue{
 	mapping.CONSTANT_KEYWORD_FIELD_TYPE: MaxString,
 }
 
+// CheckRangeFieldType check field type is range field type, whose value is a range instead of a single value
+func CheckRangeFieldType(t mapping.FieldType) bool {
+	switch t {
+	case mapping.INTEGER_RANGE_FIELD_TYPE, mapping.LONG_RANGE_FIELD_TYPE,
+		mapping.FLOAT_RANGE_FIELD_TYPE, mapping.DOUBLE_RANGE_FIELD_TYPE,
+		mapping.DATE_RANGE_FIELD_TYPE, mapping.IP_RANGE_FIELD_TYPE:
+		return true
+	default:
+		return false
+	}
+}
+
 // lfNodeUnionJoinLfNode union join two leaf node
 func lfNodeUnionJoinLfNode(key string, a, b AstNode) (AstNode, error) {
 	orNode := newDefaultBoolNode(OR)
@@ -421,15 +445,34 @@ func lfNodeIntersectLfNode(key string, a, b AstNode) (AstNode, error) {
 	return andNode, nil
 }
 
-// flattenAstNodes flatten nodes map to nodes list
+// flattenAstNodes flatten nodes map to nodes list, nodes are ordered by node key and bool nodes are put at last,
+// so that same query always produces same clauses order
 func flattenAstNodes(nodesMap map[string][]AstNode) []AstNode {
+	var keys = make([]string, 0, len(nodesMap))
+	for key := range nodesMap {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if (keys[i] == OP_KEY) != (keys[j] == OP_KEY) {
+			return keys[j] == OP_KEY
+		}
+		return keys[i] < keys[j]
+	})
 	var nodes []AstNode
-	for _, nodeList := range nodesMap {
-		nodes = append(nodes, nodeList...)
+	for _, key := range keys {
+		nodes = append(nodes, nodesMap[key]...)
 	}
 	return nodes
 }
 
+// sortedStrLst returns sorted copy of string list
+func sortedStrLst(l []string) []string {
+	var res = make([]string, len(l))
+	copy(res, l)
+	sort.Strings(res)
+	return res
+}
+
 // reduceAstNode reduce AstNode to smallest possible AstNode
 func reduceAstNode(x AstNode) AstNode {
 	if x.AstType() == OP_NODE_TYPE {
@@ -546,10 +589,15 @@ func inverseNode(node AstNode) AstNode {
 	return boolNode
 }
 
-func minEditDistance(termWord1, termWord2 string) int {
+// minEditDistance returns levenshtein distance of two words on unicode chars,
+// swap of two adjacent chars is counted as one edit if transpositions is true
+func minEditDistance(termWord1, termWord2 string, transpositions bool) int {
 	var (
-		l1 int16 = int16(len(termWord1))
-		l2 int16 = int16(len(termWord2))
+		w1 = []rune(termWord1)
+		w2 = []rune(termWord2)
+
+		l1 int16 = int16(len(w1))
+		l2 int16 = int16(len(w2))
 
 		i int16 = 0
 		j int16 = 0
@@ -568,11 +616,14 @@ func minEditDistance(termWord1, termWord2 string) int {
 
 	for i = 1; i <= l1; i++ {
 		for j = 1; j <= l2; j++ {
-			if termWord1[i-1] == termWord2[j-1] {
+			if w1[i-1] == w2[j-1] {
 				dp[i][j] = dp[i-1][j-1]
 			} else {
 				dp[i][j] = minInt16(dp[i-1][j-1], minInt16(dp[i-1][j], dp[i][j-1])) + 1
 			}
+			if transpositions && i > 1 && j > 1 && w1[i-1] == w2[j-2] && w1[i-2] == w2[j-1] {
+				dp[i][j] = minInt16(dp[i][j], dp[i-2][j-2]+1)
+			}
 		}
 	}
 	return int(dp[l1][l2])
@@ -587,7 +638,10 @@ func minInt16(a, b int16) int16 {
 }
 
 func leafValueToPrintValue(x LeafValue, t mapping.FieldType) interface{} {
-	if mapping.CheckDateType(t) {
+	if t == mapping.DATE_NANOS_FIELD_TYPE {
+		var s, _ = utils.FormatDate(x.(time.Time).UTC(), utils.DATE_NANOS_FORMAT)
+		return s
+	} else if mapping.CheckDateType(t) {
 		return x.(time.Time).UnixNano() / 1e6
 	} else if mapping.CheckIPType(t) {
 		return x.(net.IP).String()
@@ -624,6 +678,55 @@ func patternNodeIntersectTermNode(n PatternNode, o *TermNode) (AstNode, error) {
 	}
 }
 
+// comparePatternNode decides relation between languages of two pattern nodes on the same field,
+// the smaller max determinized states of nodes is used to limit size of automaton
+func comparePatternNode(n, o AstNode) (utils.PatternRelation, error) {
+	var maxStates = utils.DEFAULT_MAX_DETERMINIZED_STATES
+	for _, x := range []AstNode{n, o} {
+		if s, ok := x.(StatesNode); ok && s.getMaxDeterminizedStates() < maxStates {
+			maxStates = s.getMaxDeterminizedStates()
+		}
+	}
+	return utils.ComparePattern(n.(PatternNode).getMatcher(), o.(PatternNode).getMatcher(), maxStates)
+}
+
+// patternNodeUnionJoinPatternNode union join prefix / wildcard / regexp nodes, the node which contains another one is kept
+func patternNodeUnionJoinPatternNode(n, o AstNode) (AstNode, error) {
+	var relation, err = comparePatternNode(n, o)
+	if err == utils.ErrUnsupportedAutomaton && n.DslType() == o.DslType() {
+		return valueNodeUnionJoinValueNode(n, o)
+	}
+	switch relation {
+	case utils.EQUAL_RELATION, utils.SUPERSET_RELATION:
+		return n, nil
+	case utils.SUBSET_RELATION:
+		return o, nil
+	default:
+		return lfNodeUnionJoinLfNode(n.NodeKey(), n, o)
+	}
+}
+
+// patternNodeIntersectPatternNode intersect prefix / wildcard / regexp nodes, the node which is contained by another one is kept
+func patternNodeIntersectPatternNode(n, o AstNode) (AstNode, error) {
+	var relation, err = comparePatternNode(n, o)
+	if err == utils.ErrUnsupportedAutomaton && n.DslType() == o.DslType() {
+		return valueNodeIntersectValueNode(n, o)
+	}
+	switch relation {
+	case utils.EQUAL_RELATION, utils.SUBSET_RELATION:
+		return n, nil
+	case utils.SUPERSET_RELATION:
+		return o, nil
+	case utils.DISJOINT_RELATION:
+		if n.(ArrayTypeNode).IsArrayType() {
+			return lfNodeIntersectLfNode(n.NodeKey(), n, o)
+		}
+		return nil, fmt.Errorf("failed to intersect %v and %v, err: pattern is conflict", n.ToDSL(), o.ToDSL())
+	default:
+		return lfNodeIntersectLfNode(n.NodeKey(), n, o)
+	}
+}
+
 func valueNodeUnionJoinValueNode(n, o AstNode) (AstNode, error) {
 	nn := n.(ValueNode)
 	on := o.(ValueNode)
@@ -1017,8 +1017,9 @@ func TestNodeAndMergeNode(t *testing.T) {
 
 func TestMinEditDistance(t *testing.T) {
 	type args struct {
-		termWord1 string
-		termWord2 string
+		termWord1      string
+		termWord2      string
+		transpositions bool
 	}
 	tests := []struct {
 		name string
@@ -1045,10 +1046,25 @@ func TestMinEditDistance(t *testing.T) {
 			args: args{termWord1: "bc", termWord2: "abc"},
 			want: 1,
 		},
+		{
+			name: "test_transposition",
+			args: args{termWord1: "abc", termWord2: "acb", transpositions: true},
+			want: 1,
+		},
+		{
+			name: "test_transposition_and_change",
+			args: args{termWord1: "abcd", termWord2: "bace", transpositions: true},
+			want: 2,
+		},
+		{
+			name: "test_unicode",
+			args: args{termWord1: "你好", termWord2: "您好"},
+			want: 1,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := minEditDistance(tt.args.termWord1, tt.args.termWord2); got != tt.want {
+			if got := minEditDistance(tt.args.termWord1, tt.args.termWord2, tt.args.transpositions); got != tt.want {
 				t.Errorf("minEditDistance() = %v, want %v", got, tt.want)
 			}
 		})
@@ -1108,3 +1124,38 @@ func TestRestAstNodes(t *testing.T) {
 		})
 	}
 }
+
+func TestFlattenAstNodes(t *testing.T) {
+	var n1 = &ExistsNode{fieldNode: fieldNode{field: "foo1"}}
+	var n2 = &ExistsNode{fieldNode: fieldNode{field: "foo2"}}
+	var n3 = &ExistsNode{fieldNode: fieldNode{field: "foo3"}}
+	var n4 = &BoolNode{opNode: opNode{opType: NOT}, MustNot: map[string][]AstNode{"foo4": {n1}}}
+	var nodesMap = map[string][]AstNode{
+		OP_KEY: {n4},
+		"foo3": {n3},
+		"_id":  {n2},
+		"foo1": {n1, n3},
+	}
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, []AstNode{n2, n1, n3, n3, n4}, flattenAstNodes(nodesMap))
+	}
+	assert.Nil(t, flattenAstNodes(nil))
+}
+
+func TestSortedStrLst(t *testing.T) {
+	var l = []string{"b", "c", "a"}
+	assert.Equal(t, []string{"a", "b", "c"}, sortedStrLst(l))
+	assert.Equal(t, []string{"b", "c", "a"}, l)
+}
+
+func TestParseRelation(t *testing.T) {
+	for relation, want := range map[string]RelationType{"INTERSECTS": INTERSECTS, "contains": CONTAINS, "Within": WITHIN} {
+		got, err := ParseRelation(relation)
+		assert.Nil(t, err)
+		assert.Equal(t, want, got)
+	}
+	for _, relation := range []string{"", "DISJOINT", "intersect"} {
+		_, err := ParseRelation(relation)
+		assert.NotNil(t, err, relation)
+	}
+}
@@ -43,8 +43,8 @@ func (n *WildCardNode) UnionJoin(o AstNode) (AstNode, error) {
 	switch o.DslType() {
 	case TERM_DSL_TYPE:
 		return patternNodeUnionJoinTermNode(n, o.(*TermNode))
-	case WILDCARD_DSL_TYPE:
-		return valueNodeUnionJoinValueNode(n, o)
+	case PREFIX_DSL_TYPE, WILDCARD_DSL_TYPE, REGEXP_DSL_TYPE:
+		return patternNodeUnionJoinPatternNode(n, o)
 	default:
 		return lfNodeUnionJoinLfNode(n.NodeKey(), n, o)
 	}
@@ -65,8 +65,8 @@ func (n *WildCardNode) InterSect(o AstNode) (AstNode, error) {
 	switch o.DslType() {
 	case TERM_DSL_TYPE:
 		return patternNodeIntersectTermNode(n, o.(*TermNode))
-	case WILDCARD_DSL_TYPE:
-		return valueNodeIntersectValueNode(n, o)
+	case PREFIX_DSL_TYPE, WILDCARD_DSL_TYPE, REGEXP_DSL_TYPE:
+		return patternNodeIntersectPatternNode(n, o)
 	default:
 		return lfNodeIntersectLfNode(n.NodeKey(), n, o)
 	}
@@ -10,21 +10,74 @@ import (
 )
 
 type Config struct {
-	mappingData    []byte
-	customFuncs    map[string]convert.ConvertFunc
-	filterPatterns []string
+	mappingData        []byte
+	customFuncs        map[string]convert.ConvertFunc
+	filterPatterns     []string
+	regexpFlags        dsl.RegexpFlagType
+	rangeRelation      dsl.RelationType
+	fieldRelations     map[string]dsl.RelationType
+	preserveDateMath   bool
+	timeZone           string
+	fieldTimeZones     map[string]string
+	dateString         bool
+	ignoreValues       bool
+	compact            bool
+	searchOpts         []dsl.SearchRequestOption
+	indexMappings      map[string][]byte
+	mappingResponse    []byte
+	indexScope         bool
+	indexTemplate      []byte
+	componentTemplates []byte
+	runtimeMappings    []byte
+	maxFieldExpansion  int
+	numericInference   bool
+	keywordFields      []string
+	inferenceRules     []convert.InferenceRule
+	sampleDocuments    [][]byte
 }
 
 type Option func(*Config)
 
-
-// WithMappingData provides es mapping data as []byte for the converter
+// WithMappingData provides es mapping data as []byte for the converter, besides mapping body (i.e. `{"properties": {...}}`),
+// response of `GET <index>/_mapping` api, legacy typed mapping and index template (or component template) are accepted
 func WithMappingData(data []byte) Option {
 	return func(o *Config) {
 		o.mappingData = data
 	}
 }
 
+// WithIndexTemplate provides es index template composed of component templates, which is used instead of WithMappingData,
+// componentTemplates is response of `GET _component_template` api or object of component templates keyed by name
+func WithIndexTemplate(indexTemplate, componentTemplates []byte) Option {
+	return func(o *Config) {
+		o.indexTemplate = indexTemplate
+		o.componentTemplates = componentTemplates
+	}
+}
+
+// WithIndexMappings provides es mapping data of multiple indices keyed by index name, which is used instead of WithMappingData,
+// field mapped as different types in different indices is converted into a clause per type
+func WithIndexMappings(mappings map[string][]byte) Option {
+	return func(o *Config) {
+		o.indexMappings = mappings
+	}
+}
+
+// WithIndexMappingsResponse provides response of es `GET <index-pattern>/_mapping` api with mappings of multiple indices
+func WithIndexMappingsResponse(data []byte) Option {
+	return func(o *Config) {
+		o.mappingResponse = data
+	}
+}
+
+// WithIndexScope provides scoping clause of field which isn't mapped by all indices with `_index` term,
+// so that es doesn't error on indices whose field type mismatches the clause
+func WithIndexScope(indexScope bool) Option {
+	return func(o *Config) {
+		o.indexScope = indexScope
+	}
+}
+
 // WithCustomConvertFunc provides custom field value conversion functions
 func WithCustomConvertFunc(funcs map[string]convert.ConvertFunc) Option {
 	return func(o *Config) {
@@ -39,33 +92,340 @@ func WithFilterContext(patterns []string) Option {
 	}
 }
 
+// WithRegexpFlags provides enabled optional operators of regexp query (i.e. "COMPLEMENT|INTERVAL"), default is "ALL"
+func WithRegexpFlags(flags dsl.RegexpFlagType) Option {
+	return func(o *Config) {
+		o.regexpFlags = flags
+	}
+}
+
+// WithRangeRelation provides default relation (INTERSECTS, CONTAINS, WITHIN) of range query on range type fields, default is INTERSECTS
+func WithRangeRelation(relation dsl.RelationType) Option {
+	return func(o *Config) {
+		o.rangeRelation = relation
+	}
+}
+
+// WithFieldRangeRelations provides relation of range query for specific range type fields, which overrides default relation
+func WithFieldRangeRelations(relations map[string]dsl.RelationType) Option {
+	return func(o *Config) {
+		o.fieldRelations = relations
+	}
+}
+
+// WithPreserveDateMath provides keeping original date math expr (i.e. "now-1h/h") in range query instead of epoch_millis
+func WithPreserveDateMath(preserve bool) Option {
+	return func(o *Config) {
+		o.preserveDateMath = preserve
+	}
+}
+
+// WithTimeZone provides time zone (i.e. "Asia/Shanghai", "+08:00") for parsing and rounding date values, default is UTC
+func WithTimeZone(timeZone string) Option {
+	return func(o *Config) {
+		o.timeZone = timeZone
+	}
+}
+
+// WithFieldTimeZones provides time zone for specific date fields, which overrides default time zone
+func WithFieldTimeZones(timeZones map[string]string) Option {
+	return func(o *Config) {
+		o.fieldTimeZones = timeZones
+	}
+}
+
+// WithDateString provides printing date values of range query as strings in the first format of mapping printing them exactly instead of epoch_millis,
+// strict_date_optional_time(_nanos) is used if none of formats does
+func WithDateString(dateString bool) Option {
+	return func(o *Config) {
+		o.dateString = dateString
+	}
+}
+
+// WithIgnoreValues provides ignoring literal values when computing fingerprint of query, i.e. `status:active` is same as `status:?`
+func WithIgnoreValues(ignoreValues bool) Option {
+	return func(o *Config) {
+		o.ignoreValues = ignoreValues
+	}
+}
+
+// WithCompact provides omitting params equal to ES defaults and using short forms in DSL, i.e. `{"term":{"f":"v"}}`
+func WithCompact(compact bool) Option {
+	return func(o *Config) {
+		o.compact = compact
+	}
+}
+
+// WithRuntimeMappings provides search-time runtime fields as `runtime_mappings` of search request (or definitions keyed by name),
+// which shadow fields of mapping with same names, runtime fields referenced by query are emitted by LuceneToSearchRequest
+func WithRuntimeMappings(data []byte) Option {
+	return func(o *Config) {
+		o.runtimeMappings = data
+	}
+}
+
+// WithMaxFieldExpansion specifies maximum number of fields which object field of `_exists_` or wildcard field pattern
+// (i.e. `user.*`) expands to by mapping, conversion errors if expansion exceeds it, it's 1024 if not specified
+func WithMaxFieldExpansion(maxExpansion int) Option {
+	return func(o *Config) {
+		o.maxFieldExpansion = maxExpansion
+	}
+}
+
+// WithNumericInference provides inferring integers as long and decimals as double instead of keyword when mapping
+// isn't provided, so that `views:>100` and `views:[9 TO 100]` compare values numerically
+func WithNumericInference(numericInference bool) Option {
+	return func(o *Config) {
+		o.numericInference = numericInference
+	}
+}
+
+// WithKeywordFields provides fields (wildcard is supported, i.e. `*_id`) whose numeric values are still inferred
+// as keyword with WithNumericInference, which keeps leading zeros of ID-like values
+func WithKeywordFields(patterns []string) Option {
+	return func(o *Config) {
+		o.keywordFields = patterns
+	}
+}
+
+// WithInferenceRules provides rules inferring type of field by its name (i.e. `*_at` is date) when mapping isn't provided,
+// which are checked before inferring type from query value, see convert.InferenceRule
+func WithInferenceRules(rules []convert.InferenceRule) Option {
+	return func(o *Config) {
+		o.inferenceRules = rules
+	}
+}
+
+// WithSampleDocuments provides sample json documents, whose mapping is inferred (see convert.InferMappingFromDocuments)
+// and used if mapping isn't provided by WithMappingData or WithIndexTemplate
+func WithSampleDocuments(docs [][]byte) Option {
+	return func(o *Config) {
+		o.sampleDocuments = docs
+	}
+}
+
+// WithSearchOptions provides settings of search request (i.e. size, sort, _source) for LuceneToSearchRequest
+func WithSearchOptions(opts ...dsl.SearchRequestOption) Option {
+	return func(o *Config) {
+		o.searchOpts = append(o.searchOpts, opts...)
+	}
+}
+
 // LuceneToDSL converts lucene query string to ES DSL
 func LuceneToDSL(
 	query string,
 	opts ...Option,
 ) (dsl.DSL, error) {
+	cfg := newConfig(opts...)
+	nod, err := luceneToAstNode(query, cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.compact {
+		return dsl.Compact(nod.ToDSL()), nil
+	}
+	return nod.ToDSL(), nil
+}
+
+// LuceneToFingerprint converts lucene query string to fingerprint of optimized query in canonical form,
+// equivalent queries (i.e. `a:1 AND b:2` and `b:2 AND a:1`) share a fingerprint, which is used as cache key
+// or grouping key of query shape with WithIgnoreValues
+func LuceneToFingerprint(
+	query string,
+	opts ...Option,
+) (string, error) {
+	cfg := newConfig(opts...)
+	nod, err := luceneToAstNode(query, cfg, nil)
+	if err != nil {
+		return "", err
+	}
+	return dsl.Fingerprint(nod, dsl.WithIgnoreValues(cfg.ignoreValues)), nil
+}
+
+// LuceneToQueryInfo converts lucene query string and reports fields, values and operators used by optimized query
+// (see dsl.Introspect), which is used for auditing, routing and UI chips
+func LuceneToQueryInfo(
+	query string,
+	opts ...Option,
+) (*dsl.QueryInfo, error) {
+	nod, err := luceneToAstNode(query, newConfig(opts...), nil)
+	if err != nil {
+		return nil, err
+	}
+	return dsl.Introspect(nod), nil
+}
+
+// LuceneToSearchRequest converts lucene query string to complete body of ES _search request with settings of
+// WithSearchOptions, sort fields and _source paths are validated against mapping provided by WithMappingData,
+// or against mappings of all indices provided by WithIndexMappings
+func LuceneToSearchRequest(
+	query string,
+	opts ...Option,
+) (dsl.DSL, error) {
+	cfg := newConfig(opts...)
+	ms, err := loadMappings(cfg)
+	if err != nil {
+		return nil, err
+	}
+	nod, err := luceneToAstNode(query, cfg, ms)
+	if err != nil {
+		return nil, err
+	}
+	var searchOpts = append(append([]dsl.SearchRequestOption{}, cfg.searchOpts...), dsl.WithCompactQuery(cfg.compact))
+	if ms.searchRF != nil {
+		searchOpts = append(searchOpts, dsl.WithRuntimeMappings(ms.searchRF.Definitions()))
+	}
+	var req = dsl.NewSearchRequest(nod, searchOpts...)
+	if ms.pm == nil && ms.im != nil {
+		// sort fields and _source paths are validated against mapping of every index
+		if err := req.ValidateIndices(ms.im.Mappings()); err != nil {
+			return nil, err
+		}
+	}
+	return req.Build(ms.pm)
+}
+
+func newConfig(opts ...Option) *Config {
 	cfg := &Config{}
 	for _, opt := range opts {
 		opt(cfg)
 	}
+	return cfg
+}
+
+// mappings are es mappings of config, which are loaded once per conversion
+type mappings struct {
+	pm *mapping.PropertyMapping
+	im *convert.IndexMappings
+	dm *convert.DynamicMapping
+	// rf are runtime fields of mapping shadowed by search-time runtime mappings
+	rf *convert.RuntimeFields
+	// searchRF are search-time runtime mappings, which are emitted in search request
+	searchRF *convert.RuntimeFields
+}
 
-	var pm *mapping.PropertyMapping
+// loadMappings loads es mapping data, index mappings and runtime mappings of config, mapping payload (or index template)
+// is decoded once for property mapping, dynamic settings and runtime fields
+func loadMappings(cfg *Config) (*mappings, error) {
+	var ms = &mappings{}
+	var loaded *convert.Mappings
 	var err error
-	if len(cfg.mappingData) != 0 {
-		pm, err = mapping.LoadMappingData(cfg.mappingData)
-		if err != nil {
+	if len(cfg.indexTemplate) != 0 {
+		if loaded, err = convert.LoadIndexTemplateMappings(cfg.indexTemplate, cfg.componentTemplates); err != nil {
+			return nil, fmt.Errorf("failed to load index template, err: %v", err)
+		}
+	} else if len(cfg.mappingData) != 0 {
+		if loaded, err = convert.LoadMappings(cfg.mappingData); err != nil {
 			return nil, fmt.Errorf("failed to load mapping data, err: %v", err)
 		}
+	} else if len(cfg.sampleDocuments) != 0 {
+		if ms.pm, err = convert.InferMappingFromDocuments(cfg.sampleDocuments); err != nil {
+			return nil, fmt.Errorf("failed to infer mapping from sample documents, err: %v", err)
+		}
+	}
+	if loaded != nil {
+		ms.pm, ms.dm, ms.rf = loaded.Property, loaded.Dynamic, loaded.Runtime
+	}
+	if ms.im, err = loadIndexMappings(cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.runtimeMappings) != 0 {
+		if ms.searchRF, err = convert.LoadRuntimeMappings(cfg.runtimeMappings); err != nil {
+			return nil, fmt.Errorf("failed to load runtime mappings, err: %v", err)
+		}
+		ms.rf = ms.rf.Shadow(ms.searchRF)
+	}
+	return ms, nil
+}
+
+// loadIndexMappings loads es mapping data of multiple indices, nil is returned if they aren't provided
+func loadIndexMappings(cfg *Config) (*convert.IndexMappings, error) {
+	if len(cfg.mappingResponse) != 0 {
+		return convert.LoadIndexMappingsData(cfg.mappingResponse)
+	}
+	if len(cfg.indexMappings) == 0 {
+		return nil, nil
+	}
+	var mappings = make(map[string]*mapping.PropertyMapping, len(cfg.indexMappings))
+	for index, data := range cfg.indexMappings {
+		pm, err := convert.LoadMappingPayload(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load mapping data of index: %s, err: %v", index, err)
+		}
+		mappings[index] = pm
+	}
+	return convert.NewIndexMappings(mappings), nil
+}
+
+// luceneToAstNode converts lucene query string to optimized ast node, mappings of config are loaded if ms is nil
+func luceneToAstNode(
+	query string,
+	cfg *Config,
+	ms *mappings,
+) (nod dsl.AstNode, err error) {
+	if ms == nil {
+		if ms, err = loadMappings(cfg); err != nil {
+			return nil, err
+		}
+	}
+	var pm = ms.pm
+
+	var cvtOpts []convert.ConverterOption
+	if ms.im != nil {
+		cvtOpts = append(cvtOpts, convert.WithIndexMappings(ms.im), convert.WithIndexScope(cfg.indexScope))
+	}
+	if ms.dm != nil {
+		cvtOpts = append(cvtOpts, convert.WithDynamicMapping(ms.dm))
+	}
+	if ms.rf != nil {
+		cvtOpts = append(cvtOpts, convert.WithRuntimeFields(ms.rf))
+	}
+	if cfg.maxFieldExpansion > 0 {
+		cvtOpts = append(cvtOpts, convert.WithMaxFieldExpansion(cfg.maxFieldExpansion))
+	}
+	if cfg.numericInference {
+		cvtOpts = append(cvtOpts, convert.WithNumericInference(cfg.numericInference))
+	}
+	if len(cfg.keywordFields) != 0 {
+		cvtOpts = append(cvtOpts, convert.WithKeywordFields(cfg.keywordFields))
+	}
+	if len(cfg.inferenceRules) != 0 {
+		for _, rule := range cfg.inferenceRules {
+			if err := rule.Validate(); err != nil {
+				return nil, err
+			}
+		}
+		cvtOpts = append(cvtOpts, convert.WithInferenceRules(cfg.inferenceRules))
+	}
+	if cfg.regexpFlags != "" {
+		cvtOpts = append(cvtOpts, convert.WithRegexpFlags(cfg.regexpFlags))
+	}
+	if cfg.rangeRelation != "" {
+		cvtOpts = append(cvtOpts, convert.WithRangeRelation(cfg.rangeRelation))
+	}
+	if len(cfg.fieldRelations) != 0 {
+		cvtOpts = append(cvtOpts, convert.WithFieldRangeRelations(cfg.fieldRelations))
+	}
+	if cfg.preserveDateMath {
+		cvtOpts = append(cvtOpts, convert.WithPreserveDateMath(cfg.preserveDateMath))
+	}
+	if cfg.timeZone != "" {
+		cvtOpts = append(cvtOpts, convert.WithTimeZone(cfg.timeZone))
+	}
+	if len(cfg.fieldTimeZones) != 0 {
+		cvtOpts = append(cvtOpts, convert.WithFieldTimeZones(cfg.fieldTimeZones))
+	}
+	if cfg.dateString {
+		cvtOpts = append(cvtOpts, convert.WithDateString(cfg.dateString))
 	}
 
 	var cvt convert.Converter
 	if len(cfg.filterPatterns) > 0 {
-		cvt = convert.NewConverterWithFilter(pm, cfg.customFuncs, cfg.filterPatterns)
+		cvt = convert.NewConverterWithFilter(pm, cfg.customFuncs, cfg.filterPatterns, cvtOpts...)
 	} else {
-		cvt = convert.NewConverter(pm, cfg.customFuncs)
+		cvt = convert.NewConverter(pm, cfg.customFuncs, cvtOpts...)
 	}
 	var qry *lucene.Lucene
-	var nod dsl.AstNode
 	defer func() {
 		if r := recover(); r != nil {
 			nod = &dsl.EmptyNode{}
@@ -77,9 +437,18 @@ func LuceneToDSL(
 		return nil, err
 	}
 
-	if nod, err = cvt.LuceneToAstNode(qry); err != nil {
+	return cvt.LuceneToAstNode(qry)
+}
+
+// LuceneToQuery converts lucene query string to strongly typed ES query, nil is returned for empty query,
+// WithCompact is ignored because typed query always holds all params
+func LuceneToQuery(
+	query string,
+	opts ...Option,
+) (dsl.Query, error) {
+	nod, err := luceneToAstNode(query, newConfig(opts...), nil)
+	if err != nil {
 		return nil, err
 	}
-
-	return nod.ToDSL(), nil
+	return dsl.NewQuery(nod)
 }
@@ -50,6 +50,7 @@ var mappingJSON = []byte(`{
     "title": {"type": "text"},
     "count": {"type": "integer"},
     "price": {"type": "float"},
+    "ratio": {"type": "double"},
     "is_active": {"type": "boolean"},
     "created_at": {"type": "date"},
     "ip_address": {"type": "ip"},
@@ -73,6 +74,7 @@ func TestLuceneToDSL_WithMapping(t *testing.T) {
 		{"integer_term", `count:100`, mustDSL(`{"term":{"count":{"boost":1,"value":100}}}`), false},
 		{"boolean_term", `is_active:true`, mustDSL(`{"term":{"is_active":{"boost":1,"value":true}}}`), false},
 		{"float_term", `price:19.99`, mustDSL(`{"term":{"price":{"boost":1,"value":19.989999771118164}}}`), false},
+		{"double_term", `ratio:19.99`, mustDSL(`{"term":{"ratio":{"boost":1,"value":19.99}}}`), false},
 		{"ip_term", `ip_address:192.168.1.1`, mustDSL(`{"term":{"ip_address":{"boost":1,"value":"192.168.1.1"}}}`), false},
 		{"byte_term", `level:5`, mustDSL(`{"term":{"level":{"boost":1,"value":5}}}`), false},
 		{"half_float_term", `weight:1.5`, mustDSL(`{"term":{"weight":{"boost":1,"value":1.5}}}`), false},
@@ -130,7 +132,7 @@ func TestLuceneToDSL_WithMapping(t *testing.T) {
 		{"empty_query", ``, nil, true},
 		{"invalid_syntax", `status: AND count:`, nil, true},
 		{"invalid_mapping", `status:active`, nil, true},
-		{"field_not_in_mapping", `unknown_field:value`, nil, true},
+		{"field_not_in_mapping", `unknown_field:value`, mustDSL(`{"match":{"unknown_field":{"boost":1,"max_expansions":50,"query":"value"}}}`), false},
 	}
 
 	for _, tt := range tests {
@@ -165,6 +167,7 @@ func TestLuceneToDSL_WithoutMapping(t *testing.T) {
 		{"negative_integer", `count:-456`, mustDSL(`{"term":{"count":{"boost":1,"value":"-456"}}}`), false},
 		{"float", `price:3.14`, mustDSL(`{"term":{"price":{"boost":1,"value":"3.14"}}}`), false},
 		{"date", `created_at:2021-01-01`, mustDSL(`{"range":{"created_at":{"boost":1,"format":"epoch_millis","gte":1609459200000,"lte":1640995199999,"relation":"INTERSECTS"}}}`), false},
+		{"open_date_range", `ts:>2021-01-01`, mustDSL(`{"range":{"ts":{"boost":1,"format":"epoch_millis","gt":1609459200000,"relation":"INTERSECTS"}}}`), false},
 		{"ipv4", `ip:192.168.1.1`, mustDSL(`{"term":{"ip":{"boost":1,"value":"192.168.1.1"}}}`), false},
 		{"ipv4_cidr", `ip:192.168.0.0/24`, mustDSL(`{"range":{"ip":{"boost":1,"gte":"192.168.0.1","lte":"192.168.0.254","relation":"INTERSECTS"}}}`), false},
 		{"keyword", `status:active`, mustDSL(`{"term":{"status":{"boost":1,"value":"active"}}}`), false},
@@ -383,6 +386,7 @@ func TestLuceneToDSL_RangeQueries(t *testing.T) {
 		{"lt", `count:<100`, mustDSL(`{"range":{"count":{"boost":1,"gt":-2147483648,"lt":100,"relation":"INTERSECTS"}}}`), false},
 		{"lte", `count:<=100`, mustDSL(`{"range":{"count":{"boost":1,"gt":-2147483648,"lte":100,"relation":"INTERSECTS"}}}`), false},
 		{"boost_range", `count:[10 TO 100]^1.5`, mustDSL(`{"range":{"count":{"boost":1.5,"gte":10,"lte":100,"relation":"INTERSECTS"}}}`), false},
+		{"double_range", `ratio:[0.5 TO 1]`, mustDSL(`{"range":{"ratio":{"boost":1,"gte":0.5,"lte":1,"relation":"INTERSECTS"}}}`), false},
 		{"float_range", `price:[10.5 TO 100.5]`, mustDSL(`{"range":{"price":{"boost":1,"gte":10.5,"lte":100.5,"relation":"INTERSECTS"}}}`), false},
 		{"date_range", `created_at:[2021-01-01 TO 2021-12-31]`, mustDSL(`{"range":{"created_at":{"boost":1,"format":"epoch_millis","gte":1609459200000,"lte":1640908800000,"relation":"INTERSECTS"}}}`), false},
 		{"ip_range", `ip_address:[192.168.0.0 TO 192.168.255.255]`, mustDSL(`{"range":{"ip_address":{"boost":1,"gte":"192.168.0.0","lte":"192.168.255.255","relation":"INTERSECTS"}}}`), false},
@@ -497,7 +501,7 @@ func TestLuceneToDSL_ErrorCases(t *testing.T) {
 		{"empty_query", ``, []Option{WithMappingData(mappingJSON)}, true},
 		{"invalid_syntax", `status: AND count:`, []Option{WithMappingData(mappingJSON)}, true},
 		{"invalid_mapping", `status:active`, []Option{WithMappingData([]byte(`{invalid json}`))}, true},
-		{"field_not_in_mapping", `unknown_field:value`, []Option{WithMappingData(mappingJSON)}, true},
+		{"field_not_in_strict_mapping", `unknown_field:value`, []Option{WithMappingData([]byte(`{"dynamic": "strict", "properties": {"status": {"type": "keyword"}}}`))}, true},
 	}
 
 	for _, tt := range tests {
@@ -584,6 +588,217 @@ func TestLuceneToDSL_FilterContext(t *testing.T) {
 	})
 }
 
+func TestLuceneToDSL_RegexpFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		opts    []Option
+		want    dsl.DSL
+		wantErr bool
+	}{
+		{"interval", `status:/act<1-100>/`, []Option{WithMappingData(mappingJSON)}, mustDSL(`{"regexp":{"status":{"flags":"ALL","max_determinized_states":10000,"rewrite":"constant_score","value":"act<1-100>"}}}`), false},
+		{"complement", `status:/ab~(cd)/`, []Option{WithMappingData(mappingJSON)}, mustDSL(`{"regexp":{"status":{"flags":"ALL","max_determinized_states":10000,"rewrite":"constant_score","value":"ab~(cd)"}}}`), false},
+		{"custom_flags", `status:/act<1-100>/`, []Option{WithMappingData(mappingJSON), WithRegexpFlags("INTERVAL")}, mustDSL(`{"regexp":{"status":{"flags":"INTERVAL","max_determinized_states":10000,"rewrite":"constant_score","value":"act<1-100>"}}}`), false},
+		{"perl_class_unsupported", `status:/\d+/`, []Option{WithMappingData(mappingJSON)}, nil, true},
+		{"lazy_quantifier_unsupported", `status:/a*?/`, []Option{WithMappingData(mappingJSON)}, nil, true},
+		{"anchor_unsupported", `status:/^act/`, []Option{WithMappingData(mappingJSON)}, nil, true},
+		{"invalid_flags", `status:/act.*/`, []Option{WithMappingData(mappingJSON), WithRegexpFlags("FOO")}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LuceneToDSL(tt.query, tt.opts...)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assertDSLEqual(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLuceneToDSL_PatternQueries(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    dsl.DSL
+		wantErr bool
+	}{
+		{"prefix_contains_wildcard", `status:ab* OR status:ab*d`, mustDSL(`{"prefix":{"status":{"rewrite":"constant_score","value":"ab"}}}`), false},
+		{"regexp_contained_by_prefix", `status:ab* AND status:/ab[0-9]+/`, mustDSL(`{"regexp":{"status":{"flags":"ALL","max_determinized_states":10000,"rewrite":"constant_score","value":"ab[0-9]+"}}}`), false},
+		{"wildcard_same_as_regexp", `status:/a.*c/ OR status:a*c`, mustDSL(`{"regexp":{"status":{"flags":"ALL","max_determinized_states":10000,"rewrite":"constant_score","value":"a.*c"}}}`), false},
+		{"overlapped_patterns", `status:foo* AND status:f*o`, mustDSL(`{"bool":{"minimum_should_match":0,"must":[{"prefix":{"status":{"rewrite":"constant_score","value":"foo"}}},{"wildcard":{"status":{"boost":1,"rewrite":"constant_score","value":"f*o"}}}]}}`), false},
+		// fields may have multiple values, so disjoint patterns are kept
+		{"disjoint_prefixes", `status:abc* AND status:xyz*`, mustDSL(`{"bool":{"minimum_should_match":0,"must":[{"prefix":{"status":{"rewrite":"constant_score","value":"abc"}}},{"prefix":{"status":{"rewrite":"constant_score","value":"xyz"}}}]}}`), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LuceneToDSL(tt.query, WithMappingData(mappingJSON))
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assertDSLEqual(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLuceneToDSL_RangeRelation(t *testing.T) {
+	var rangeMappingJSON = []byte(`{
+  "properties": {
+    "age_band": {"type": "integer_range"},
+    "price_band": {"type": "double_range"},
+    "subnet": {"type": "ip_range"},
+    "count": {"type": "integer"}
+  }
+}`)
+	tests := []struct {
+		name    string
+		query   string
+		opts    []Option
+		want    dsl.DSL
+		wantErr bool
+	}{
+		{"point_value", `age_band:30`, nil, mustDSL(`{"range":{"age_band":{"boost":1,"gte":30,"lte":30,"relation":"CONTAINS"}}}`), false},
+		{"point_double_value", `price_band:1.5`, nil, mustDSL(`{"range":{"price_band":{"boost":1,"gte":1.5,"lte":1.5,"relation":"CONTAINS"}}}`), false},
+		{"point_ip_value", `subnet:192.168.0.1`, nil, mustDSL(`{"range":{"subnet":{"boost":1,"gte":"192.168.0.1","lte":"192.168.0.1","relation":"CONTAINS"}}}`), false},
+		{"default_relation", `age_band:[10 TO 20]`, nil, mustDSL(`{"range":{"age_band":{"boost":1,"gte":10,"lte":20,"relation":"INTERSECTS"}}}`), false},
+		{"custom_default_relation", `age_band:[10 TO 20]`, []Option{WithRangeRelation(dsl.WITHIN)}, mustDSL(`{"range":{"age_band":{"boost":1,"gte":10,"lte":20,"relation":"WITHIN"}}}`), false},
+		{"field_relation", `age_band:[10 TO 20]`, []Option{WithRangeRelation(dsl.WITHIN), WithFieldRangeRelations(map[string]dsl.RelationType{"age_band": dsl.CONTAINS})}, mustDSL(`{"range":{"age_band":{"boost":1,"gte":10,"lte":20,"relation":"CONTAINS"}}}`), false},
+		{"lower_case_relation", `age_band:[10 TO 20]`, []Option{WithRangeRelation("within"), WithFieldRangeRelations(map[string]dsl.RelationType{"other_band": "Contains"})}, mustDSL(`{"range":{"age_band":{"boost":1,"gte":10,"lte":20,"relation":"WITHIN"}}}`), false},
+		{"invalid_relation", `age_band:[10 TO 20]`, []Option{WithRangeRelation("disjoint")}, nil, true},
+		{"invalid_field_relation", `count:[10 TO 20]`, []Option{WithFieldRangeRelations(map[string]dsl.RelationType{"age_band": "overlaps"})}, nil, true},
+		{"non_range_field", `count:[10 TO 20]`, []Option{WithRangeRelation(dsl.WITHIN)}, mustDSL(`{"range":{"count":{"boost":1,"gte":10,"lte":20,"relation":"INTERSECTS"}}}`), false},
+		{"point_and_range", `age_band:30 AND age_band:[10 TO 20]`, nil, mustDSL(`{"bool":{"minimum_should_match":0,"must":[{"range":{"age_band":{"boost":1,"gte":30,"lte":30,"relation":"CONTAINS"}}},{"range":{"age_band":{"boost":1,"gte":10,"lte":20,"relation":"INTERSECTS"}}}]}}`), false},
+		{"within_intersect", `age_band:[10 TO 20] AND age_band:[15 TO 30]`, []Option{WithRangeRelation(dsl.WITHIN)}, mustDSL(`{"range":{"age_band":{"boost":1,"gte":15,"lte":20,"relation":"WITHIN"}}}`), false},
+		{"not_range", `NOT age_band:[10 TO 20]`, nil, mustDSL(`{"bool":{"minimum_should_match":0,"must_not":{"range":{"age_band":{"boost":1,"gte":10,"lte":20,"relation":"INTERSECTS"}}}}}`), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LuceneToDSL(tt.query, append([]Option{WithMappingData(rangeMappingJSON)}, tt.opts...)...)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assertDSLEqual(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLuceneToDSL_PreserveDateMath(t *testing.T) {
+	var dateMappingJSON = []byte(`{
+  "properties": {
+    "created_at": {"type": "date"},
+    "day": {"type": "date", "format": "yyyy-MM-dd"}
+  }
+}`)
+	tests := []struct {
+		name    string
+		query   string
+		want    dsl.DSL
+		wantErr bool
+	}{
+		{"open_range", `created_at:>now-1h`, mustDSL(`{"range":{"created_at":{"boost":1,"gt":"now-1h","relation":"INTERSECTS"}}}`), false},
+		{"rounding_range", `created_at:[now-1d/d TO now/d}`, mustDSL(`{"range":{"created_at":{"boost":1,"gte":"now-1d/d","lt":"now/d","relation":"INTERSECTS"}}}`), false},
+		{"single_value", `created_at:now/d`, mustDSL(`{"range":{"created_at":{"boost":1,"gte":"now/d","lte":"now/d","relation":"INTERSECTS"}}}`), false},
+		{"custom_format", `day:[2021-01-01 TO 2021-02-01]`, mustDSL(`{"range":{"day":{"boost":1,"format":"yyyy-MM-dd||epoch_millis","gte":"2021-01-01","lte":"2021-02-01","relation":"INTERSECTS"}}}`), false},
+		{"merge_range", `created_at:[now-7d/d TO now] AND created_at:[now-1d/d TO now+1d]`, mustDSL(`{"range":{"created_at":{"boost":1,"gte":"now-1d/d","lte":"now","relation":"INTERSECTS"}}}`), false},
+		{"not_range", `NOT created_at:{now-1d/d TO now/d]`, mustDSL(`{"bool":{"minimum_should_match":1,"should":[{"range":{"created_at":{"boost":1,"lte":"now-1d/d","relation":"INTERSECTS"}}},{"range":{"created_at":{"boost":1,"gt":"now/d","relation":"INTERSECTS"}}}]}}`), false},
+		{"conflict_range", `created_at:{now/d TO now/d]`, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LuceneToDSL(tt.query, WithMappingData(dateMappingJSON), WithPreserveDateMath(true))
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assertDSLEqual(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLuceneToDSL_TimeZone(t *testing.T) {
+	var shanghai = WithTimeZone("Asia/Shanghai")
+	tests := []struct {
+		name    string
+		query   string
+		opts    []Option
+		want    dsl.DSL
+		wantErr bool
+	}{
+		{"single_date", `created_at:2021-03-02`, []Option{shanghai}, mustDSL(`{"range":{"created_at":{"boost":1,"format":"epoch_millis","gte":1614614400000,"lte":1614700799999,"relation":"INTERSECTS"}}}`), false},
+		{"date_range", `created_at:[2021-03-02 TO 2021-03-03]`, []Option{shanghai}, mustDSL(`{"range":{"created_at":{"boost":1,"format":"epoch_millis","gte":1614614400000,"lte":1614700800000,"relation":"INTERSECTS"}}}`), false},
+		{"utc_offset", `created_at:[2021-03-02 TO 2021-03-03]`, []Option{WithTimeZone("+08:00")}, mustDSL(`{"range":{"created_at":{"boost":1,"format":"epoch_millis","gte":1614614400000,"lte":1614700800000,"relation":"INTERSECTS"}}}`), false},
+		{"field_time_zone", `created_at:[2021-03-02 TO 2021-03-03]`, []Option{shanghai, WithFieldTimeZones(map[string]string{"created_at": "UTC"})}, mustDSL(`{"range":{"created_at":{"boost":1,"format":"epoch_millis","gte":1614643200000,"lte":1614729600000,"relation":"INTERSECTS"}}}`), false},
+		{"dst_day", `created_at:2021-03-14`, []Option{WithTimeZone("America/New_York")}, mustDSL(`{"range":{"created_at":{"boost":1,"format":"epoch_millis","gte":1615698000000,"lte":1615780799999,"relation":"INTERSECTS"}}}`), false},
+		{"date_math", `created_at:[now-1d/d TO now/d]`, []Option{shanghai, WithPreserveDateMath(true)}, mustDSL(`{"range":{"created_at":{"boost":1,"gte":"now-1d/d","lte":"now/d","relation":"INTERSECTS","time_zone":"Asia/Shanghai"}}}`), false},
+		{"non_date_field", `count:[10 TO 20]`, []Option{WithTimeZone("Invalid/Zone")}, mustDSL(`{"range":{"count":{"boost":1,"gte":10,"lte":20,"relation":"INTERSECTS"}}}`), false},
+		{"invalid_time_zone", `created_at:2021-03-02`, []Option{WithTimeZone("Invalid/Zone")}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LuceneToDSL(tt.query, append([]Option{WithMappingData(mappingJSON)}, tt.opts...)...)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assertDSLEqual(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLuceneToDSL_DateOutput(t *testing.T) {
+	var dateMappingJSON = []byte(`{
+  "properties": {
+    "created_at": {"type": "date"},
+    "nano_ts": {"type": "date_nanos"},
+    "day": {"type": "date", "format": "yyyy-MM-dd"},
+    "quarter": {"type": "date", "format": "yyyy-QQQ||yyyy"}
+  }
+}`)
+	tests := []struct {
+		name    string
+		query   string
+		opts    []Option
+		want    dsl.DSL
+		wantErr bool
+	}{
+		{"date_nanos", `nano_ts:[2024-01-01T00:00:00.000000001 TO 2024-01-02]`, nil, mustDSL(`{"range":{"nano_ts":{"boost":1,"format":"strict_date_optional_time_nanos","gte":"2024-01-01T00:00:00.000000001Z","lte":"2024-01-02T00:00:00.000000000Z","relation":"INTERSECTS"}}}`), false},
+		{"date_nanos_merge", `nano_ts:>2024-01-01T00:00:00.000000001 AND nano_ts:[2024-01-01T00:00:00.000000002 TO 2024-01-02]`, nil, mustDSL(`{"range":{"nano_ts":{"boost":1,"format":"strict_date_optional_time_nanos","gte":"2024-01-01T00:00:00.000000002Z","lte":"2024-01-02T00:00:00.000000000Z","relation":"INTERSECTS"}}}`), false},
+		{"date_nanos_no_overlap", `nano_ts:>2024-01-01T00:00:00.000000002 AND nano_ts:<=2024-01-01T00:00:00.000000001`, nil, mustDSL(`{"bool":{"minimum_should_match":0,"must":[{"range":{"nano_ts":{"boost":1,"format":"strict_date_optional_time_nanos","gt":"2024-01-01T00:00:00.000000002Z","relation":"INTERSECTS"}}},{"range":{"nano_ts":{"boost":1,"format":"strict_date_optional_time_nanos","lte":"2024-01-01T00:00:00.000000001Z","relation":"INTERSECTS"}}}]}}`), false},
+		{"default_format", `created_at:[2021-03-02 TO 2021-03-03]`, []Option{WithDateString(true)}, mustDSL(`{"range":{"created_at":{"boost":1,"format":"strict_date_optional_time","gte":"2021-03-02T00:00:00.000Z","lte":"2021-03-03T00:00:00.000Z","relation":"INTERSECTS"}}}`), false},
+		{"mapping_format", `day:[2021-03-02 TO 2021-03-03}`, []Option{WithDateString(true)}, mustDSL(`{"range":{"day":{"boost":1,"format":"yyyy-MM-dd","gte":"2021-03-02","lt":"2021-03-03","relation":"INTERSECTS"}}}`), false},
+		// es rounds lte "2021-03-03" up to the end of day, so it's printed in strict_date_optional_time
+		{"mapping_format_round_up", `day:[2021-03-02 TO 2021-03-03]`, []Option{WithDateString(true)}, mustDSL(`{"range":{"day":{"boost":1,"format":"yyyy-MM-dd||strict_date_optional_time","gte":"2021-03-02","lte":"2021-03-03T00:00:00.000Z","relation":"INTERSECTS"}}}`), false},
+		{"mapping_format_coarser", `day:>=2024-03-01T10:00:00`, []Option{WithDateString(true)}, mustDSL(`{"range":{"day":{"boost":1,"format":"yyyy-MM-dd||strict_date_optional_time","gte":"2024-03-01T10:00:00.000Z","relation":"INTERSECTS"}}}`), false},
+		{"time_zone", `created_at:[2021-03-02 TO 2021-03-03]`, []Option{WithDateString(true), WithTimeZone("Asia/Shanghai")}, mustDSL(`{"range":{"created_at":{"boost":1,"format":"strict_date_optional_time","gte":"2021-03-02T00:00:00.000+08:00","lte":"2021-03-03T00:00:00.000+08:00","relation":"INTERSECTS","time_zone":"Asia/Shanghai"}}}`), false},
+		{"unsupported_format", `quarter:[2021 TO 2022]`, []Option{WithDateString(true)}, mustDSL(`{"range":{"quarter":{"boost":1,"format":"yyyy-QQQ||yyyy||strict_date_optional_time","gte":"2021","lte":"2022-01-01T00:00:00.000Z","relation":"INTERSECTS"}}}`), false},
+		{"epoch_millis", `created_at:[2021-03-02 TO 2021-03-03]`, nil, mustDSL(`{"range":{"created_at":{"boost":1,"format":"epoch_millis","gte":1614643200000,"lte":1614729600000,"relation":"INTERSECTS"}}}`), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LuceneToDSL(tt.query, append([]Option{WithMappingData(dateMappingJSON)}, tt.opts...)...)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assertDSLEqual(t, tt.want, got)
+			}
+		})
+	}
+}
+
 func TestLuceneToDSL_SubQueryCombinations(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -668,3 +883,870 @@ func TestLuceneToDSL_SubQueryCombinations(t *testing.T) {
 		})
 	}
 }
+
+func TestLuceneToQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"term", `status:active`},
+		{"float_term", `price:19.99`},
+		{"range", `count:[10 TO 100]`},
+		{"open_range", `count:>10`},
+		{"date_range", `created_at:[2021-01-01 TO 2021-12-31]`},
+		{"prefix", `status:act*`},
+		{"wildcard", `status:act*ve`},
+		{"regexp", `status:/act.*/`},
+		{"match", `title:hello`},
+		{"match_phrase", `title:"hello world"`},
+		{"exists", `_exists_:status`},
+		{"or", `status:active OR status:pending`},
+		{"not", `NOT status:inactive`},
+		{"nested_bool", `(status:active OR title:hello) AND NOT count:[1 TO 5]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := LuceneToDSL(tt.query, WithMappingData(mappingJSON))
+			assert.NoError(t, err)
+			got, err := LuceneToQuery(tt.query, WithMappingData(mappingJSON))
+			assert.NoError(t, err)
+			data, err := json.Marshal(got)
+			assert.NoError(t, err)
+			assert.Equal(t, want.String(), string(data))
+		})
+	}
+
+	t.Run("typed_access", func(t *testing.T) {
+		got, err := LuceneToQuery(`status:active OR count:>=10`, WithMappingData(mappingJSON))
+		assert.NoError(t, err)
+		boolQuery, ok := got.(*dsl.BoolQuery)
+		assert.True(t, ok)
+		assert.Equal(t, 1, boolQuery.MinimumShouldMatch)
+		assert.Equal(t, 2, len(boolQuery.Should))
+		for _, q := range boolQuery.Should {
+			switch q := q.(type) {
+			case *dsl.TermQuery:
+				assert.Equal(t, "status", q.Field)
+				assert.Equal(t, "active", q.Value)
+			case *dsl.RangeQuery:
+				assert.Equal(t, "count", q.Field)
+				assert.Equal(t, int64(10), q.Gte)
+				assert.Equal(t, int64(2147483647), q.Lt)
+			default:
+				t.Errorf("unexpected query: %T", q)
+			}
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := LuceneToQuery(`status:[1 TO`, WithMappingData(mappingJSON))
+		assert.Error(t, err)
+	})
+}
+
+func TestLuceneToDSL_DeterministicOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"multi_fields_and", `title:hello AND status:active AND count:>=10 AND is_active:true`, `{"bool":{"minimum_should_match":0,"must":[{"range":{"count":{"boost":1,"gte":10,"lt":2147483647,"relation":"INTERSECTS"}}},{"term":{"is_active":{"boost":1,"value":true}}},{"match":{"title":{"boost":1,"max_expansions":50,"query":"hello"}}},{"term":{"status":{"boost":1,"value":"active"}}}]}}`},
+		{"multi_fields_or", `title:hello OR status:active OR count:>=10 OR is_active:true`, `{"bool":{"minimum_should_match":1,"should":[{"range":{"count":{"boost":1,"gte":10,"lt":2147483647,"relation":"INTERSECTS"}}},{"term":{"is_active":{"boost":1,"value":true}}},{"match":{"title":{"boost":1,"max_expansions":50,"query":"hello"}}},{"term":{"status":{"boost":1,"value":"active"}}}]}}`},
+		{"nested_bool", `(status:active OR title:hello) AND (count:1 OR price:2) AND NOT tags:foo AND NOT level:3`, ``},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, err := LuceneToDSL(tt.query, WithMappingData(mappingJSON))
+			assert.NoError(t, err)
+			if tt.want != "" {
+				assert.Equal(t, tt.want, first.String())
+			}
+			for i := 0; i < 20; i++ {
+				got, err := LuceneToDSL(tt.query, WithMappingData(mappingJSON))
+				assert.NoError(t, err)
+				assert.Equal(t, first.String(), got.String())
+			}
+		})
+	}
+}
+
+func TestLuceneToFingerprint(t *testing.T) {
+	tests := []struct {
+		name    string
+		queries []string
+		opts    []Option
+	}{
+		{"reorder", []string{`count:1 AND level:2`, `level:2 AND count:1`, `(count:1) AND level:[2 TO 2]`}, nil},
+		{"or_reorder", []string{`status:active OR title:hello OR count:>=10`, `count:>=10 OR status:active OR title:hello`}, nil},
+		{"merged_range", []string{`count:[1 TO 10]`, `count:>=1 AND count:<=10`, `count:[1 TO 100] AND count:<=10`}, nil},
+		{"query_shape", []string{`status:active AND count:>10`, `count:>5 AND status:closed`}, []Option{WithIgnoreValues(true)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := LuceneToFingerprint(tt.queries[0], append([]Option{WithMappingData(mappingJSON)}, tt.opts...)...)
+			assert.NoError(t, err)
+			for _, query := range tt.queries[1:] {
+				got, err := LuceneToFingerprint(query, append([]Option{WithMappingData(mappingJSON)}, tt.opts...)...)
+				assert.NoError(t, err)
+				assert.Equal(t, want, got, query)
+			}
+		})
+	}
+
+	t.Run("different", func(t *testing.T) {
+		var seen = map[string]string{}
+		for _, query := range []string{`count:1`, `count:2`, `level:1`, `count:1 OR level:2`, `count:1 AND level:2`, `count:>1`, `NOT count:1`} {
+			got, err := LuceneToFingerprint(query, WithMappingData(mappingJSON))
+			assert.NoError(t, err)
+			_, ok := seen[got]
+			assert.False(t, ok, "fingerprint of %s is same as %s", query, seen[got])
+			seen[got] = query
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := LuceneToFingerprint(`count:[1 TO`, WithMappingData(mappingJSON))
+		assert.Error(t, err)
+	})
+}
+
+func TestLuceneToDSL_Compact(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"term", `status:active`, `{"term":{"status":"active"}}`},
+		{"term_with_boost", `status:active^2`, `{"term":{"status":{"boost":2,"value":"active"}}}`},
+		{"range", `count:[10 TO 100]`, `{"range":{"count":{"gte":10,"lte":100}}}`},
+		{"date_range", `created_at:[2021-01-01 TO 2021-12-31]`, `{"range":{"created_at":{"format":"epoch_millis","gte":1609459200000,"lte":1640908800000}}}`},
+		{"prefix", `status:act*`, `{"prefix":{"status":"act"}}`},
+		{"wildcard", `status:act*ve`, `{"wildcard":{"status":"act*ve"}}`},
+		{"regexp", `status:/act.*/`, `{"regexp":{"status":"act.*"}}`},
+		{"match", `title:hello`, `{"match":{"title":"hello"}}`},
+		{"match_phrase", `title:"hello world"`, `{"match_phrase":{"title":"hello world"}}`},
+		{"or", `status:active OR status:pending`, `{"bool":{"should":[{"term":{"status":"active"}},{"term":{"status":"pending"}}]}}`},
+		{"and", `status:active AND count:>=10`, `{"bool":{"must":[{"term":{"status":"active"}},{"range":{"count":{"gte":10,"lt":2147483647}}}]}}`},
+		{"not", `NOT status:inactive`, `{"bool":{"must_not":{"term":{"status":"inactive"}}}}`},
+		{"or_not", `status:active OR NOT title:hello`, `{"bool":{"must_not":{"match":{"title":"hello"}},"should":{"term":{"status":"active"}}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LuceneToDSL(tt.query, WithMappingData(mappingJSON), WithCompact(true))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+
+	t.Run("typed_query_ignores_compact", func(t *testing.T) {
+		got, err := LuceneToQuery(`status:active`, WithMappingData(mappingJSON), WithCompact(true))
+		assert.NoError(t, err)
+		assert.Equal(t, &dsl.TermQuery{Field: "status", Value: "active", Boost: 1}, got)
+	})
+}
+
+func TestLuceneToSearchRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		opts    []dsl.SearchRequestOption
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "paging_and_sort",
+			query: `status:active`,
+			opts: []dsl.SearchRequestOption{
+				dsl.WithSize(20),
+				dsl.WithFrom(40),
+				dsl.WithSort(dsl.Sort{Field: "created_at", Order: dsl.DESC_ORDER}),
+				dsl.WithSource([]string{"status", "title"}, nil),
+			},
+			want: `{"_source":{"includes":["status","title"]},"from":40,"query":{"term":{"status":"active"}},"size":20,"sort":[{"created_at":{"order":"desc"}}]}`,
+		},
+		{
+			name:  "search_after",
+			query: `count:>=10`,
+			opts: []dsl.SearchRequestOption{
+				dsl.WithSort(dsl.Sort{Field: "count", Order: dsl.ASC_ORDER}, dsl.Sort{Field: "_shard_doc"}),
+				dsl.WithSearchAfter(10, 100),
+				dsl.WithTrackTotalHits(false),
+				dsl.WithTimeout("1s"),
+			},
+			want: `{"query":{"range":{"count":{"gte":10,"lt":2147483647}}},"search_after":[10,100],"sort":[{"count":{"order":"asc"}},"_shard_doc"],"timeout":"1s","track_total_hits":false}`,
+		},
+		{
+			name:  "highlight",
+			query: `title:hello AND NOT status:closed`,
+			opts:  []dsl.SearchRequestOption{dsl.WithHighlight()},
+			want:  `{"highlight":{"fields":{"title":{"type":"unified"}},"highlight_query":{"match":{"title":"hello"}}},"query":{"bool":{"must":{"match":{"title":"hello"}},"must_not":{"term":{"status":"closed"}}}}}`,
+		},
+		{
+			name:    "sort_text_field",
+			query:   `status:active`,
+			opts:    []dsl.SearchRequestOption{dsl.WithSort(dsl.Sort{Field: "title"})},
+			wantErr: true,
+		},
+		{
+			name:    "unknown_source_path",
+			query:   `status:active`,
+			opts:    []dsl.SearchRequestOption{dsl.WithSource([]string{"missing"}, nil)},
+			wantErr: true,
+		},
+		{
+			name:    "invalid_query",
+			query:   `count:[100 TO 10]`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LuceneToSearchRequest(tt.query, WithMappingData(mappingJSON), WithCompact(true), WithSearchOptions(tt.opts...))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+
+	t.Run("index_mappings", func(t *testing.T) {
+		var opt = WithIndexMappings(map[string][]byte{
+			"logs-1": []byte(`{"properties": {"status": {"type": "keyword"}, "code": {"type": "keyword"}}}`),
+			"logs-2": []byte(`{"properties": {"status": {"type": "keyword"}, "code": {"type": "text"}}}`),
+		})
+		got, err := LuceneToSearchRequest(`status:ok`, opt, WithCompact(true), WithSearchOptions(dsl.WithSort(dsl.Sort{Field: "status"})))
+		assert.NoError(t, err)
+		assert.Equal(t, `{"query":{"term":{"status":"ok"}},"sort":["status"]}`, got.String())
+		_, err = LuceneToSearchRequest(`status:ok`, opt, WithSearchOptions(dsl.WithSort(dsl.Sort{Field: "code"})))
+		assert.EqualError(t, err, "index: logs-2, err: sort field: code is text field without fielddata, sort by its keyword sub field instead")
+		_, err = LuceneToSearchRequest(`status:ok`, opt, WithSearchOptions(dsl.WithSource([]string{"missing"}, nil)))
+		assert.Error(t, err)
+	})
+
+	t.Run("highlight_wildcard_field", func(t *testing.T) {
+		got, err := LuceneToSearchRequest(`t*:hello`, WithMappingData(mappingJSON), WithSearchOptions(dsl.WithHighlight()))
+		assert.NoError(t, err)
+		var fields = got[dsl.HIGHLIGHT_KEY].(dsl.DSL)[dsl.FIELDS_KEY].(dsl.DSL)
+		assert.Equal(t, `{"tags":{"number_of_fragments":0},"title":{"type":"unified"}}`, fields.String())
+	})
+}
+
+func TestLuceneToQueryInfo(t *testing.T) {
+	t.Run("leaves", func(t *testing.T) {
+		info, err := LuceneToQueryInfo(`title:hello AND count:[10 TO 100] AND NOT status:closed`, WithMappingData(mappingJSON))
+		assert.NoError(t, err)
+		assert.Equal(t, []*dsl.LeafInfo{
+			{Field: "title", FieldType: mapping.TEXT_FIELD_TYPE, Kind: "match", Values: []interface{}{"hello"}, Depth: 2},
+			{Field: "count", FieldType: mapping.INTEGER_FIELD_TYPE, Kind: "range", Bounds: map[string]interface{}{"gte": int64(10), "lte": int64(100)}, Depth: 2},
+			{Field: "status", FieldType: mapping.KEYWORD_FIELD_TYPE, Kind: "term", Values: []interface{}{"closed"}, Negated: true, Filter: true, Depth: 2},
+		}, info.Leaves)
+		assert.Equal(t, 2, info.Depth)
+		assert.Equal(t, map[string]int{"range": 1, "match": 1, "term": 1}, info.KindCounts)
+		assert.Equal(t, map[string]int{"must": 2, "must_not": 1}, info.ClauseCounts)
+		assert.Equal(t, []string{"count", "status", "title"}, info.Fields())
+	})
+
+	t.Run("filter_context", func(t *testing.T) {
+		info, err := LuceneToQueryInfo(`status:active`, WithMappingData(mappingJSON), WithFilterContext([]string{"status"}))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(info.Leaves))
+		assert.True(t, info.Leaves[0].Filter)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := LuceneToQueryInfo(`count:[100 TO 10]`, WithMappingData(mappingJSON))
+		assert.Error(t, err)
+	})
+}
+
+func TestLuceneToDSL_IndexMappings(t *testing.T) {
+	var mappingResponse = []byte(`{
+  "logs-1": {"mappings": {"properties": {"status": {"type": "keyword"}, "code": {"type": "keyword"}, "host": {"type": "keyword"}}}},
+  "logs-2": {"mappings": {"properties": {"status": {"type": "keyword"}, "code": {"type": "long"}}}}
+}`)
+	tests := []struct {
+		name    string
+		query   string
+		scope   bool
+		want    string
+		wantErr bool
+	}{
+		{"clause_per_type", `code:500`, false, `{"bool":{"should":[{"term":{"code":"500"}},{"term":{"code":500}}]}}`, false},
+		{"skip_unparsable_type", `code:abc`, false, `{"term":{"code":"abc"}}`, false},
+		{"combined", `status:ok AND code:[500 TO 599]`, false, `{"bool":{"minimum_should_match":1,"must":{"term":{"status":"ok"}},"should":[{"range":{"code":{"gte":"500","lte":"599"}}},{"range":{"code":{"gte":500,"lte":599}}}]}}`, false},
+		{"negated", `NOT code:404`, false, `{"bool":{"must_not":[{"term":{"code":"404"}},{"term":{"code":404}}]}}`, false},
+		{"same_type_in_all_indices", `status:ok`, true, `{"term":{"status":"ok"}}`, false},
+		{"scoped_clause_per_type", `code:500`, true, `{"bool":{"should":[{"bool":{"filter":{"term":{"_index":"logs-1"}},"must":{"term":{"code":"500"}}}},{"bool":{"filter":{"term":{"_index":"logs-2"}},"must":{"term":{"code":500}}}}]}}`, false},
+		{"scoped_partial_indices", `host:web-1`, true, `{"bool":{"filter":{"term":{"_index":"logs-1"}},"must":{"term":{"host":"web-1"}}}}`, false},
+		{"missing_field", `missing:1`, false, ``, true},
+		{"invalid_range", `code:[599 TO 500]`, false, ``, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LuceneToDSL(tt.query, WithIndexMappingsResponse(mappingResponse), WithIndexScope(tt.scope), WithCompact(true))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+
+	t.Run("index_mappings", func(t *testing.T) {
+		got, err := LuceneToDSL(`code:500`, WithIndexMappings(map[string][]byte{
+			"logs-1": []byte(`{"properties": {"code": {"type": "keyword"}}}`),
+			"logs-2": []byte(`{"properties": {"code": {"type": "long"}}}`),
+		}), WithCompact(true))
+		assert.NoError(t, err)
+		assert.Equal(t, `{"bool":{"should":[{"term":{"code":"500"}},{"term":{"code":500}}]}}`, got.String())
+	})
+
+	t.Run("groups", func(t *testing.T) {
+		var indexMappings = WithIndexMappings(map[string][]byte{
+			"a": []byte(`{"properties": {"x": {"type": "long"}, "d": {"type": "date"}, "user": {"properties": {"name": {"type": "keyword"}, "age": {"type": "long"}}}}}`),
+			"b": []byte(`{"properties": {"x": {"type": "keyword"}, "d": {"type": "keyword"}, "user": {"properties": {"name": {"type": "text"}}}}}`),
+		})
+		for _, tt := range []struct {
+			name  string
+			query string
+			scope bool
+			want  string
+		}{
+			{"group", `x:(1 OR 2)`, false, `{"bool":{"should":[{"term":{"x":1}},{"term":{"x":2}},{"term":{"x":"1"}},{"term":{"x":"2"}}]}}`},
+			{
+				"scoped_group", `x:(1 OR 2)`, true,
+				`{"bool":{"should":[{"bool":{"filter":{"term":{"_index":"a"}},"minimum_should_match":1,"should":[{"term":{"x":1}},{"term":{"x":2}}]}},` +
+					`{"bool":{"filter":{"term":{"_index":"b"}},"minimum_should_match":1,"should":[{"term":{"x":"1"}},{"term":{"x":"2"}}]}}]}}`,
+			},
+			{
+				"term_and_range", `x:(1 OR [5 TO 9])`, false,
+				`{"bool":{"should":[{"range":{"x":{"gte":5,"lte":9}}},{"term":{"x":1}},{"range":{"x":{"gte":"5","lte":"9"}}},{"term":{"x":"1"}}]}}`,
+			},
+			{
+				"scoped_term_and_range", `x:(1 OR [5 TO 9])`, true,
+				`{"bool":{"should":[{"bool":{"filter":{"term":{"_index":"a"}},"minimum_should_match":1,"should":[{"range":{"x":{"gte":5,"lte":9}}},{"term":{"x":1}}]}},` +
+					`{"bool":{"filter":{"term":{"_index":"b"}},"minimum_should_match":1,"should":[{"range":{"x":{"gte":"5","lte":"9"}}},{"term":{"x":"1"}}]}}]}}`,
+			},
+			{
+				"and_group", `x:(1 AND 2)`, false,
+				`{"bool":{"should":[{"bool":{"must":[{"term":{"x":1}},{"term":{"x":2}}]}},{"bool":{"must":[{"term":{"x":"1"}},{"term":{"x":"2"}}]}}]}}`,
+			},
+			{"not_group", `NOT x:(1 OR 2)`, false, `{"bool":{"must_not":[{"term":{"x":1}},{"term":{"x":2}},{"term":{"x":"1"}},{"term":{"x":"2"}}]}}`},
+			{
+				"date_and_keyword_group", `d:(2024-01-02 OR 2024-01-05)`, false,
+				`{"bool":{"should":[{"range":{"d":{"format":"epoch_millis","gte":1704153600000,"lte":1704239999999}}},` +
+					`{"range":{"d":{"format":"epoch_millis","gte":1704412800000,"lte":1704499199999}}},{"term":{"d":"2024-01-02"}},{"term":{"d":"2024-01-05"}}]}}`,
+			},
+			{"wildcard_field", `user.*:alice`, false, `{"bool":{"should":[{"term":{"user.name":"alice"}},{"match":{"user.name":"alice"}}]}}`},
+			{
+				"scoped_wildcard_field", `user.*:30`, true,
+				`{"bool":{"should":[{"bool":{"filter":{"term":{"_index":"a"}},"must":{"term":{"user.age":30}}}},` +
+					`{"bool":{"filter":{"term":{"_index":"a"}},"must":{"term":{"user.name":"30"}}}},{"bool":{"filter":{"term":{"_index":"b"}},"must":{"match":{"user.name":"30"}}}}]}}`,
+			},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				got, err := LuceneToDSL(tt.query, indexMappings, WithIndexScope(tt.scope), WithCompact(true))
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got.String())
+			})
+		}
+	})
+
+	t.Run("flattened", func(t *testing.T) {
+		var indexMappings = WithIndexMappings(map[string][]byte{
+			"a": []byte(`{"properties": {"labels": {"type": "flattened"}}}`),
+			"b": []byte(`{"properties": {"labels": {"properties": {"app": {"type": "text"}}}}}`),
+		})
+		got, err := LuceneToDSL(`labels.app:web`, indexMappings, WithCompact(true))
+		assert.NoError(t, err)
+		assert.Equal(t, `{"bool":{"should":[{"term":{"labels.app":"web"}},{"match":{"labels.app":"web"}}]}}`, got.String())
+
+		got, err = LuceneToDSL(`labels.app:web`, indexMappings, WithIndexScope(true), WithCompact(true))
+		assert.NoError(t, err)
+		assert.Equal(t, `{"bool":{"should":[{"bool":{"filter":{"term":{"_index":"a"}},"must":{"term":{"labels.app":"web"}}}},`+
+			`{"bool":{"filter":{"term":{"_index":"b"}},"must":{"match":{"labels.app":"web"}}}}]}}`, got.String())
+
+		_, err = LuceneToDSL(`labels.app:/we.*/`, WithIndexMappings(map[string][]byte{
+			"a": []byte(`{"properties": {"labels": {"type": "flattened"}}}`),
+		}))
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid_mapping_data", func(t *testing.T) {
+		_, err := LuceneToDSL(`code:500`, WithIndexMappings(map[string][]byte{"logs-1": []byte(`{`)}))
+		assert.Error(t, err)
+		_, err = LuceneToDSL(`code:500`, WithIndexMappingsResponse([]byte(`[]`)))
+		assert.Error(t, err)
+	})
+}
+
+func TestLuceneToDSL_MappingPayloads(t *testing.T) {
+	var componentTemplates = []byte(`{"component_templates": [
+  {"name": "base", "component_template": {"template": {"mappings": {"properties": {"code": {"type": "keyword"}}}}}}
+]}`)
+	tests := []struct {
+		name    string
+		opts    []Option
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "mapping_response",
+			opts: []Option{WithMappingData([]byte(`{"logs-1": {"mappings": {"properties": {"code": {"type": "long"}}}}}`))},
+			want: `{"term":{"code":500}}`,
+		},
+		{
+			name: "legacy_typed_mapping",
+			opts: []Option{WithMappingData([]byte(`{"mappings": {"_doc": {"properties": {"code": {"type": "long"}}}}}`))},
+			want: `{"term":{"code":500}}`,
+		},
+		{
+			name: "index_template",
+			opts: []Option{WithIndexTemplate([]byte(`{"composed_of": ["base"]}`), componentTemplates)},
+			want: `{"term":{"code":"500"}}`,
+		},
+		{
+			name: "index_template_overrides_component",
+			opts: []Option{WithIndexTemplate(
+				[]byte(`{"composed_of": ["base"], "template": {"mappings": {"properties": {"code": {"type": "long"}}}}}`),
+				componentTemplates,
+			)},
+			want: `{"term":{"code":500}}`,
+		},
+		{
+			name:    "missing_component_template",
+			opts:    []Option{WithIndexTemplate([]byte(`{"composed_of": ["base", "extra"]}`), componentTemplates)},
+			wantErr: true,
+		},
+		{
+			name:    "unrecognised_payload",
+			opts:    []Option{WithMappingData([]byte(`{"code": 1, "status": 2}`))},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LuceneToDSL(`code:500`, append(tt.opts, WithCompact(true))...)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+}
+
+func TestLuceneToDSL_DynamicTemplates(t *testing.T) {
+	var mappingData = []byte(`{
+  "dynamic_templates": [
+    {"labels": {"path_match": "labels.*", "match_mapping_type": "string", "mapping": {"type": "keyword"}}},
+    {"counters": {"path_match": "counters.*", "match_mapping_type": "long", "mapping": {"type": "long"}}}
+  ],
+  "properties": {
+    "status": {"type": "keyword"},
+    "labels": {"type": "object"},
+    "counters": {"type": "object"},
+    "audit": {"type": "object", "dynamic": "strict", "properties": {"user": {"type": "keyword"}}},
+    "raw": {"type": "object", "dynamic": false}
+  }
+}`)
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{"string_template", `labels.team:platform`, `{"term":{"labels.team":"platform"}}`, false},
+		{"numeric_value_as_string", `labels.code:500`, `{"term":{"labels.code":"500"}}`, false},
+		{"long_template", `counters.hits:[10 TO 20]`, `{"range":{"counters.hits":{"gte":10,"lte":20}}}`, false},
+		{"combined", `status:ok AND labels.team:platform`, `{"bool":{"must":[{"term":{"status":"ok"}},{"term":{"labels.team":"platform"}}]}}`, false},
+		{"default_dynamic_string", `counters.name:abc`, `{"match":{"counters.name":"abc"}}`, false},
+		{"default_dynamic_long", `counters.total:5`, `{"term":{"counters.total":5}}`, false},
+		{"default_dynamic_double", `other.ratio:0.5`, `{"term":{"other.ratio":0.5}}`, false},
+		{"strict", `audit.action:login`, ``, true},
+		{"disabled", `raw.x:1`, ``, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LuceneToDSL(tt.query, WithMappingData(mappingData), WithCompact(true))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+}
+
+func TestLuceneToDSL_RuntimeFields(t *testing.T) {
+	var mappingData = []byte(`{
+  "runtime": {
+    "day_of_week": {"type": "keyword", "script": {"source": "emit(doc['ts'].value.dayOfWeekEnum.toString())"}},
+    "duration": {"type": "long", "script": {"source": "emit(doc['end'].value - doc['start'].value)"}}
+  },
+  "properties": {
+    "status": {"type": "keyword"},
+    "duration": {"type": "keyword"}
+  }
+}`)
+	var runtimeMappings = []byte(`{"runtime_mappings": {
+  "score": {"type": "double", "script": {"source": "emit(1.5)"}},
+  "unused": {"type": "long", "script": {"source": "emit(1)"}}
+}}`)
+	tests := []struct {
+		name    string
+		query   string
+		opts    []Option
+		want    string
+		wantErr bool
+	}{
+		{"runtime_field", `day_of_week:MONDAY`, nil, `{"term":{"day_of_week":"MONDAY"}}`, false},
+		{"shadow_property", `duration:[10 TO 20]`, nil, `{"range":{"duration":{"gte":10,"lte":20}}}`, false},
+		{"search_time_runtime_field", `score:{1 TO 2]`, []Option{WithRuntimeMappings(runtimeMappings)}, `{"range":{"score":{"gt":1,"lte":2}}}`, false},
+		{"search_time_shadows_mapping", `day_of_week:1`, []Option{WithRuntimeMappings([]byte(`{"day_of_week": {"type": "long"}}`))}, `{"term":{"day_of_week":1}}`, false},
+		{"search_time_without_mapping", `score:1.5`, []Option{WithRuntimeMappings(runtimeMappings), WithMappingData(nil)}, `{"term":{"score":1.5}}`, false},
+		{"invalid_runtime_value", `duration:abc`, nil, ``, true},
+		{"invalid_runtime_mappings", `score:1`, []Option{WithRuntimeMappings([]byte(`{"score": 1}`))}, ``, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts = append([]Option{WithMappingData(mappingData), WithCompact(true)}, tt.opts...)
+			got, err := LuceneToDSL(tt.query, opts...)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+
+	t.Run("search_request", func(t *testing.T) {
+		got, err := LuceneToSearchRequest(`score:{1 TO 2] AND status:ok`,
+			WithMappingData(mappingData),
+			WithRuntimeMappings(runtimeMappings),
+			WithSearchOptions(dsl.WithSort(dsl.Sort{Field: "score", Order: dsl.DESC_ORDER})),
+			WithCompact(true),
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"query":{"bool":{"must":[{"range":{"score":{"gt":1,"lte":2}}},{"term":{"status":"ok"}}]}},`+
+			`"runtime_mappings":{"score":{"script":{"source":"emit(1.5)"},"type":"double"}},"sort":[{"score":{"order":"desc"}}]}`, got.String())
+	})
+}
+
+func TestLuceneToDSL_ObjectFieldExpansion(t *testing.T) {
+	var mappingData = []byte(`{
+  "properties": {
+    "status": {"type": "keyword"},
+    "user": {
+      "properties": {
+        "name": {"type": "text", "fields": {"raw": {"type": "keyword"}}},
+        "age": {"type": "integer"},
+        "address": {"type": "object", "properties": {"city": {"type": "keyword"}}}
+      }
+    },
+    "empty": {"type": "object"}
+  }
+}`)
+	tests := []struct {
+		name    string
+		query   string
+		opts    []Option
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "exists_object",
+			query: `_exists_:user`,
+			want:  `{"bool":{"should":[{"exists":{"field":"user.address.city"}},{"exists":{"field":"user.age"}},{"exists":{"field":"user.name"}}]}}`,
+		},
+		{name: "exists_nested_object", query: `_exists_:user.address`, want: `{"exists":{"field":"user.address.city"}}`},
+		{name: "exists_leaf", query: `_exists_:status`, want: `{"exists":{"field":"status"}}`},
+		{name: "exists_empty_object", query: `_exists_:empty`, want: `{"exists":{"field":"empty"}}`},
+		{
+			name:  "wildcard_compatible_fields",
+			query: `user.*:alice`,
+			want:  `{"bool":{"should":[{"term":{"user.address.city":"alice"}},{"match":{"user.name":"alice"}},{"term":{"user.name.raw":"alice"}}]}}`,
+		},
+		{
+			name:  "wildcard_numeric_value",
+			query: `user.*:30`,
+			want:  `{"bool":{"should":[{"term":{"user.address.city":"30"}},{"term":{"user.age":30}},{"match":{"user.name":"30"}},{"term":{"user.name.raw":"30"}}]}}`,
+		},
+		{name: "wildcard_single_field", query: `user.a?e:30`, want: `{"term":{"user.age":30}}`},
+		{name: "wildcard_no_compatible_field", query: `user.a?e:alice`, wantErr: true},
+		{name: "wildcard_no_field", query: `missing.*:alice`, wantErr: true},
+		{name: "exists_exceeds_max_expansion", query: `_exists_:user`, opts: []Option{WithMaxFieldExpansion(2)}, wantErr: true},
+		{name: "wildcard_exceeds_max_expansion", query: `user.*:alice`, opts: []Option{WithMaxFieldExpansion(3)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts = append([]Option{WithMappingData(mappingData), WithCompact(true)}, tt.opts...)
+			got, err := LuceneToDSL(tt.query, opts...)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+}
+
+func TestLuceneToDSL_FlattenedField(t *testing.T) {
+	var mappingData = []byte(`{
+  "properties": {
+    "status": {"type": "keyword"},
+    "labels": {"type": "flattened"},
+    "k8s": {"properties": {"annotations": {"type": "flattened"}}}
+  }
+}`)
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{"root_term", `labels:web`, `{"term":{"labels":"web"}}`, false},
+		{"keyed_term", `labels.app:web`, `{"term":{"labels.app":"web"}}`, false},
+		{"keyed_numeric_value", `labels.replicas:3`, `{"term":{"labels.replicas":"3"}}`, false},
+		{"nested_keyed_term", `k8s.annotations.owner.team:infra`, `{"term":{"k8s.annotations.owner.team":"infra"}}`, false},
+		{"group", `labels.app:(web OR api)`, `{"bool":{"should":[{"term":{"labels.app":"web"}},{"term":{"labels.app":"api"}}]}}`, false},
+		{"prefix", `labels.app:we*`, `{"prefix":{"labels.app":"we"}}`, false},
+		{"wildcard", `labels.app:w*b*`, `{"wildcard":{"labels.app":"w*b*"}}`, false},
+		{"lexicographic_range", `labels.version:[10 TO 9]`, `{"range":{"labels.version":{"gte":"10","lte":"9"}}}`, false},
+		{"exists", `_exists_:labels.app`, `{"exists":{"field":"labels.app"}}`, false},
+		{"regexp", `labels.app:/we.*/`, ``, true},
+		{"fuzzy", `labels.app:web~1`, ``, true},
+		{"unmapped", `other.app:web`, `{"match":{"other.app":"web"}}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LuceneToDSL(tt.query, WithMappingData(mappingData), WithCompact(true))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+}
+
+func TestLuceneToDSL_GeoField(t *testing.T) {
+	var mappingData = []byte(`{
+  "properties": {
+    "status": {"type": "keyword"},
+    "loc": {"type": "geo_point"},
+    "area": {"type": "geo_shape"}
+  }
+}`)
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{
+			"bounding_box", `loc:[40.1,-74.2 TO 40.9,-73.6]`,
+			`{"geo_bounding_box":{"loc":{"bottom_right":{"lat":40.1,"lon":-73.6},"top_left":{"lat":40.9,"lon":-74.2}}}}`, false,
+		},
+		{
+			"bounding_box_crossing_dateline", `loc:[-10,170 TO 10,-170]`,
+			`{"geo_bounding_box":{"loc":{"bottom_right":{"lat":-10,"lon":-170},"top_left":{"lat":10,"lon":170}}}}`, false,
+		},
+		{
+			"envelope", `area:[40.1,-74.2 TO 40.9,-73.6]`,
+			`{"geo_shape":{"area":{"relation":"INTERSECTS","shape":{"coordinates":[[-74.2,40.9],[-73.6,40.1]],"type":"envelope"}}}}`, false,
+		},
+		{
+			"distance", `loc:"40.7,-74.0 within 5km"`,
+			`{"geo_distance":{"distance":"5km","loc":{"lat":40.7,"lon":-74}}}`, false,
+		},
+		{
+			"distance_on_shape", `area:"40.7, -74.0 WITHIN 1.5 mi"^2`,
+			`{"geo_distance":{"area":{"lat":40.7,"lon":-74},"boost":2,"distance":"1.5mi"}}`, false,
+		},
+		{
+			"bool_composition", `status:open AND NOT loc:"40.7,-74.0 within 5km"`,
+			`{"bool":{"must":{"term":{"status":"open"}},"must_not":{"geo_distance":{"distance":"5km","loc":{"lat":40.7,"lon":-74}}}}}`, false,
+		},
+		{"exists", `loc:*`, `{"exists":{"field":"loc"}}`, false},
+		{"lat_out_of_range", `loc:[91,0 TO 92,1]`, ``, true},
+		{"lon_out_of_range", `loc:"40.7,-181 within 5km"`, ``, true},
+		{"south_above_north", `loc:[40.9,-74.2 TO 40.1,-73.6]`, ``, true},
+		{"exclusive_range", `loc:{40.1,-74.2 TO 40.9,-73.6}`, ``, true},
+		{"open_range", `loc:[40.1,-74.2 TO *]`, ``, true},
+		{"unknown_unit", `loc:"40.7,-74.0 within 5 parsecs"`, ``, true},
+		{"missing_unit", `loc:"40.7,-74.0 within 5"`, ``, true},
+		{"zero_distance", `loc:"40.7,-74.0 within 0km"`, ``, true},
+		{"single_term", `loc:foo`, ``, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LuceneToDSL(tt.query, WithMappingData(mappingData), WithCompact(true))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+}
+
+func TestLuceneToDSL_NumericInference(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		opts  []Option
+		want  string
+	}{
+		{"integer", `views:100`, nil, `{"term":{"views":100}}`},
+		{"float", `price:3.14`, nil, `{"term":{"price":3.14}}`},
+		{"open_range", `views:>100`, nil, `{"range":{"views":{"gt":100,"lt":9223372036854775807}}}`},
+		{"closed_range", `views:[9 TO 100]`, nil, `{"range":{"views":{"gte":9,"lte":100}}}`},
+		{"integer_and_float_range", `price:[1 TO 2.5]`, nil, `{"range":{"price":{"gte":1,"lte":2.5}}}`},
+		{"merged_ranges", `views:[9 TO 50] OR views:[20 TO 100]`, nil, `{"range":{"views":{"gte":9,"lte":100}}}`},
+		{"intersected_ranges", `views:[9 TO 50] AND views:[20 TO 100]`, nil, `{"range":{"views":{"gte":20,"lte":50}}}`},
+		{"keyword_field", `user_id:007`, []Option{WithKeywordFields([]string{"*_id"})}, `{"term":{"user_id":"007"}}`},
+		{"keyword_field_range", `order_id:[0100 TO 0200]`, []Option{WithKeywordFields([]string{"order_id"})}, `{"range":{"order_id":{"gte":"0100","lte":"0200"}}}`},
+		{"string", `status:active`, nil, `{"term":{"status":"active"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts = append([]Option{WithNumericInference(true), WithCompact(true)}, tt.opts...)
+			got, err := LuceneToDSL(tt.query, opts...)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+}
+
+func TestLuceneToDSL_SampleDocuments(t *testing.T) {
+	var docs = [][]byte{
+		[]byte(`{"views": 100, "msg": "hello world", "user": {"name": "bob"}, "tags": ["a", "b"]}`),
+		[]byte(`{"views": 2.5, "created": "2021-01-01"}`),
+	}
+	tests := []struct {
+		name  string
+		query string
+		opts  []Option
+		want  string
+	}{
+		{"numeric", `views:>=10`, nil, `{"range":{"views":{"gte":10,"lt":1.7976931348623157e+308}}}`},
+		{"text", `msg:hello`, nil, `{"match":{"msg":"hello"}}`},
+		{"keyword_sub_field", `msg.keyword:hello`, nil, `{"term":{"msg.keyword":"hello"}}`},
+		{"object_field", `user.name:bob`, nil, `{"term":{"user.name":"bob"}}`},
+		{"array_field", `tags:a`, nil, `{"term":{"tags":"a"}}`},
+		{"mapping_data_first", `views:100`, []Option{WithMappingData([]byte(`{"properties":{"views":{"type":"keyword"}}}`))}, `{"term":{"views":"100"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts = append([]Option{WithSampleDocuments(docs), WithCompact(true)}, tt.opts...)
+			got, err := LuceneToDSL(tt.query, opts...)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+
+	_, err := LuceneToDSL(`x:1`, WithSampleDocuments([][]byte{[]byte(`{"x": 1}`), []byte(`{"x": {"y": 1}}`)}))
+	assert.Error(t, err)
+}
+
+func TestLuceneToDSL_InferenceRules(t *testing.T) {
+	var rules = []convert.InferenceRule{
+		{Pattern: "*_at", Type: "date"},
+		{Pattern: "@timestamp", Type: "date"},
+		{Pattern: "*_ip", Type: "ip"},
+		{Pattern: "/(.*_id|zip)/", Type: "keyword"},
+		{Pattern: "*_count", Type: "long"},
+	}
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{"date", `created_at:[2021 TO 2022]`, `{"range":{"created_at":{"format":"epoch_millis","gte":1609459200000,"lte":1640995200000}}}`, false},
+		{"timestamp", `@timestamp:[2021 TO 2022]`, `{"range":{"@timestamp":{"format":"epoch_millis","gte":1609459200000,"lte":1640995200000}}}`, false},
+		{"ip", `client_ip:10.0.0.1`, `{"term":{"client_ip":"10.0.0.1"}}`, false},
+		{"keyword_id", `user_id:20240101`, `{"term":{"user_id":"20240101"}}`, false},
+		{"keyword_zip", `zip:02134`, `{"term":{"zip":"02134"}}`, false},
+		{"long", `view_count:[10 TO 20]`, `{"range":{"view_count":{"gte":10,"lte":20}}}`, false},
+		{"no_rule", `price:3.14`, `{"term":{"price":3.14}}`, false},
+		{"invalid_value", `view_count:abc`, ``, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LuceneToDSL(tt.query, WithInferenceRules(rules), WithNumericInference(true), WithCompact(true))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+
+	_, err := LuceneToDSL(`x:1`, WithInferenceRules([]convert.InferenceRule{{Pattern: "/(/", Type: "keyword"}}))
+	assert.Error(t, err)
+}
+
+func TestLuceneToDSL_QueryTypeUnification(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		opts    []Option
+		want    string
+		wantErr bool
+	}{
+		{"open_left_date_range", `ts:[* TO 2024-01-01]`, nil, `{"range":{"ts":{"format":"epoch_millis","lte":1704067200000}}}`, false},
+		{"date_to_now", `ts:[2024-01-02 TO now]`, []Option{WithPreserveDateMath(true), WithTimeZone("Asia/Shanghai")}, `{"range":{"ts":{"gte":"2024-01-02","lte":"now","time_zone":"Asia/Shanghai"}}}`, false},
+		{"date_to_date_math", `ts:[2024-01-02 TO now-1d]`, []Option{WithPreserveDateMath(true), WithTimeZone("Asia/Shanghai")}, `{"range":{"ts":{"gte":"2024-01-02","lte":"now-1d","time_zone":"Asia/Shanghai"}}}`, false},
+		{"date_to_anchored_date_math", `ts:[2024-01-02 TO 2024-01-02||+3d]`, nil, `{"range":{"ts":{"format":"epoch_millis","gte":1704153600000,"lte":1704412800000}}}`, false},
+		{"long_and_double", `x:5 OR x:2.5`, nil, `{"bool":{"should":[{"term":{"x":5}},{"term":{"x":2.5}}]}}`, false},
+		{"range_and_double", `x:[1 TO 5] OR x:7.5`, nil, `{"bool":{"should":[{"range":{"x":{"gte":1,"lte":5}}},{"term":{"x":7.5}}]}}`, false},
+		{"long_and_keyword", `x:5 AND x:abc`, nil, `{"bool":{"must":[{"term":{"x":"5"}},{"term":{"x":"abc"}}]}}`, false},
+		{"group", `x:(5 OR abc)`, nil, `{"bool":{"should":[{"term":{"x":"5"}},{"term":{"x":"abc"}}]}}`, false},
+		{"nested_paren", `x:5 OR (x:abc OR x:def)`, nil, `{"bool":{"should":[{"term":{"x":"abc"}},{"term":{"x":"def"}},{"term":{"x":"5"}}]}}`, false},
+		{"nested_group", `x:(5 OR (abc OR 7))`, nil, `{"bool":{"should":[{"term":{"x":"abc"}},{"term":{"x":"7"}},{"term":{"x":"5"}}]}}`, false},
+		{"negated", `NOT x:abc AND x:5`, nil, `{"bool":{"must":{"term":{"x":"5"}},"must_not":{"term":{"x":"abc"}}}}`, false},
+		{"exists_skipped", `x:5 OR x:*`, nil, `{"exists":{"field":"x"}}`, false},
+		{"conflict", `ts:2024-01-01 OR ts:5`, nil, ``, true},
+		{"inference_rule", `ts:2024-01-01 OR ts:5`, []Option{WithInferenceRules([]convert.InferenceRule{{Pattern: "ts", Type: "keyword"}})}, `{"bool":{"should":[{"term":{"ts":"2024-01-01"}},{"term":{"ts":"5"}}]}}`, false},
+		{"with_mapping", `x:5 OR x:abc`, []Option{WithMappingData([]byte(`{"properties":{"x":{"type":"long"}}}`))}, ``, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts = append([]Option{WithNumericInference(true), WithCompact(true)}, tt.opts...)
+			got, err := LuceneToDSL(tt.query, opts...)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+}
@@ -0,0 +1,652 @@
+package utils
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DEFAULT_MAX_DETERMINIZED_STATES is default limit of states when patterns are compiled to automaton, same as es
+const DEFAULT_MAX_DETERMINIZED_STATES = 10000
+
+var (
+	ErrTooManyStates        = errors.New("automaton exceeds max determinized states")
+	ErrUnsupportedAutomaton = errors.New("pattern can't be compiled to automaton")
+)
+
+// PatternRelation is relation between languages of two patterns
+type PatternRelation uint32
+
+const (
+	UNKNOWN_RELATION  PatternRelation = iota // relation is undecidable, i.e. too many states
+	DISJOINT_RELATION                        // no text is matched by both patterns
+	OVERLAP_RELATION                         // some texts are matched by both patterns
+	SUBSET_RELATION                          // texts matched by first pattern are all matched by second pattern
+	SUPERSET_RELATION                        // texts matched by second pattern are all matched by first pattern
+	EQUAL_RELATION                           // both patterns match the same texts
+)
+
+// automatonPattern is pattern which can be compiled to automaton
+type automatonPattern interface {
+	toAutomaton(maxStates int) (*automaton, error)
+}
+
+// ComparePattern compiles both patterns to deterministic automata and decides relation of their languages.
+// Each automaton built during comparing is limited to maxStates states, ErrTooManyStates is returned if it's exceeded.
+func ComparePattern(a, b PatternMatcher, maxStates int) (PatternRelation, error) {
+	var pa, oka = a.(automatonPattern)
+	var pb, okb = b.(automatonPattern)
+	if !oka || !okb {
+		return UNKNOWN_RELATION, ErrUnsupportedAutomaton
+	}
+	var da, db *automaton
+	var err error
+	if da, err = pa.toAutomaton(maxStates); err != nil {
+		return UNKNOWN_RELATION, err
+	}
+	if db, err = pb.toAutomaton(maxStates); err != nil {
+		return UNKNOWN_RELATION, err
+	}
+
+	var aSubB, bSubA bool
+	if aSubB, err = subsetOf(da, db, maxStates); err != nil {
+		return UNKNOWN_RELATION, err
+	}
+	if bSubA, err = subsetOf(db, da, maxStates); err != nil {
+		return UNKNOWN_RELATION, err
+	}
+	if aSubB && bSubA {
+		return EQUAL_RELATION, nil
+	} else if aSubB {
+		return SUBSET_RELATION, nil
+	} else if bSubA {
+		return SUPERSET_RELATION, nil
+	}
+
+	if inter, err := intersectAutomaton(da, db, maxStates); err != nil {
+		return UNKNOWN_RELATION, err
+	} else if inter.isEmpty() {
+		return DISJOINT_RELATION, nil
+	} else {
+		return OVERLAP_RELATION, nil
+	}
+}
+
+func (p *prefixPattern) toAutomaton(maxStates int) (*automaton, error) {
+	var b = newAutomatonBuilder(maxStates)
+	var f = b.concat(b.runes([]rune(p.pattern)), b.anyString())
+	return b.determinize(f)
+}
+
+func (w *wildcardPattern) toAutomaton(maxStates int) (*automaton, error) {
+	var b = newAutomatonBuilder(maxStates)
+	var f = b.emptyString()
+	for _, c := range w.pattern {
+		switch c {
+		case '*':
+			f = b.concat(f, b.anyString())
+		case '?':
+			f = b.concat(f, b.anyChar())
+		default:
+			f = b.concat(f, b.char(c, c))
+		}
+	}
+	return b.determinize(f)
+}
+
+func (r *regexpPattern) toAutomaton(maxStates int) (*automaton, error) {
+	var b = newAutomatonBuilder(maxStates)
+	var f = b.regexp(r.ast)
+	return b.determinize(f)
+}
+
+// automaton is finite automaton on unicode code points.
+// it's nondeterministic during building, and deterministic (no epsilon, non-overlapping sorted transitions) after determinize.
+type automaton struct {
+	states []autoState
+	start  int
+}
+
+type autoState struct {
+	accept bool
+	eps    []int
+	trans  []autoTrans
+}
+
+type autoTrans struct {
+	lo, hi rune
+	to     int
+}
+
+func (a *automaton) isEmpty() bool {
+	var visited = make([]bool, len(a.states))
+	var stack = []int{a.start}
+	visited[a.start] = true
+	for len(stack) != 0 {
+		var s = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if a.states[s].accept {
+			return false
+		}
+		var next = a.states[s].eps
+		for _, t := range a.states[s].trans {
+			next = append(next[:len(next):len(next)], t.to)
+		}
+		for _, to := range next {
+			if !visited[to] {
+				visited[to] = true
+				stack = append(stack, to)
+			}
+		}
+	}
+	return true
+}
+
+// complement returns dfa which accepts texts not accepted by a, a must be deterministic
+func (a *automaton) complement() *automaton {
+	var n = len(a.states)
+	var res = &automaton{states: make([]autoState, n+1), start: a.start}
+	for i, s := range a.states {
+		var trans = make([]autoTrans, 0, len(s.trans)+1)
+		var next rune = 0
+		for _, t := range s.trans {
+			if t.lo > next {
+				trans = append(trans, autoTrans{lo: next, hi: t.lo - 1, to: n})
+			}
+			trans = append(trans, t)
+			next = t.hi + 1
+		}
+		if next <= unicode.MaxRune {
+			trans = append(trans, autoTrans{lo: next, hi: unicode.MaxRune, to: n})
+		}
+		res.states[i] = autoState{accept: !s.accept, trans: trans}
+	}
+	// dead state of a, which is accepted state of complement
+	res.states[n] = autoState{accept: true, trans: []autoTrans{{lo: 0, hi: unicode.MaxRune, to: n}}}
+	return res
+}
+
+// intersectAutomaton returns product dfa of a and b, both of them must be deterministic
+func intersectAutomaton(a, b *automaton, maxStates int) (*automaton, error) {
+	var res = &automaton{}
+	var ids = map[[2]int]int{}
+	var queue [][2]int
+	var add = func(p [2]int) (int, error) {
+		if id, ok := ids[p]; ok {
+			return id, nil
+		}
+		if len(res.states) >= maxStates {
+			return 0, ErrTooManyStates
+		}
+		var id = len(res.states)
+		ids[p] = id
+		res.states = append(res.states, autoState{accept: a.states[p[0]].accept && b.states[p[1]].accept})
+		queue = append(queue, p)
+		return id, nil
+	}
+	if _, err := add([2]int{a.start, b.start}); err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(queue); i++ {
+		var p = queue[i]
+		var trans []autoTrans
+		for _, ta := range a.states[p[0]].trans {
+			for _, tb := range b.states[p[1]].trans {
+				var lo, hi = maxRune(ta.lo, tb.lo), minRune(ta.hi, tb.hi)
+				if lo > hi {
+					continue
+				}
+				if to, err := add([2]int{ta.to, tb.to}); err != nil {
+					return nil, err
+				} else {
+					trans = append(trans, autoTrans{lo: lo, hi: hi, to: to})
+				}
+			}
+		}
+		sort.Slice(trans, func(i, j int) bool { return trans[i].lo < trans[j].lo })
+		res.states[ids[p]].trans = trans
+	}
+	return res, nil
+}
+
+// subsetOf check texts accepted by a are all accepted by b, both of them must be deterministic
+func subsetOf(a, b *automaton, maxStates int) (bool, error) {
+	if diff, err := intersectAutomaton(a, b.complement(), maxStates); err != nil {
+		return false, err
+	} else {
+		return diff.isEmpty(), nil
+	}
+}
+
+// fragment is part of nfa with single entry and single exit state
+type fragment struct {
+	start, end int
+}
+
+// automatonBuilder builds thompson nfa, number of nfa states is also limited to avoid blow-up of repetition
+type automatonBuilder struct {
+	nfa       automaton
+	maxStates int
+	err       error
+}
+
+func newAutomatonBuilder(maxStates int) *automatonBuilder {
+	return &automatonBuilder{maxStates: maxStates}
+}
+
+func (b *automatonBuilder) newState() int {
+	if len(b.nfa.states) >= 4*b.maxStates {
+		b.err = ErrTooManyStates
+		return 0
+	}
+	b.nfa.states = append(b.nfa.states, autoState{})
+	return len(b.nfa.states) - 1
+}
+
+func (b *automatonBuilder) addEps(from, to int) {
+	if b.err == nil {
+		b.nfa.states[from].eps = append(b.nfa.states[from].eps, to)
+	}
+}
+
+func (b *automatonBuilder) addTrans(from int, lo, hi rune, to int) {
+	if b.err == nil {
+		b.nfa.states[from].trans = append(b.nfa.states[from].trans, autoTrans{lo: lo, hi: hi, to: to})
+	}
+}
+
+func (b *automatonBuilder) newFragment() fragment {
+	return fragment{start: b.newState(), end: b.newState()}
+}
+
+func (b *automatonBuilder) empty() fragment {
+	return b.newFragment()
+}
+
+func (b *automatonBuilder) emptyString() fragment {
+	var f = b.newFragment()
+	b.addEps(f.start, f.end)
+	return f
+}
+
+func (b *automatonBuilder) char(lo, hi rune) fragment {
+	var f = b.newFragment()
+	b.addTrans(f.start, lo, hi, f.end)
+	return f
+}
+
+func (b *automatonBuilder) anyChar() fragment {
+	return b.char(0, unicode.MaxRune)
+}
+
+func (b *automatonBuilder) anyString() fragment {
+	var f = b.newFragment()
+	b.addEps(f.start, f.end)
+	b.addTrans(f.end, 0, unicode.MaxRune, f.end)
+	return f
+}
+
+func (b *automatonBuilder) runes(text []rune) fragment {
+	var f = b.emptyString()
+	for _, c := range text {
+		f = b.concat(f, b.char(c, c))
+	}
+	return f
+}
+
+func (b *automatonBuilder) concat(x, y fragment) fragment {
+	b.addEps(x.end, y.start)
+	return fragment{start: x.start, end: y.end}
+}
+
+func (b *automatonBuilder) union(fs ...fragment) fragment {
+	var f = b.newFragment()
+	for _, x := range fs {
+		b.addEps(f.start, x.start)
+		b.addEps(x.end, f.end)
+	}
+	return f
+}
+
+func (b *automatonBuilder) optional(x fragment) fragment {
+	b.addEps(x.start, x.end)
+	return x
+}
+
+func (b *automatonBuilder) star(x fragment) fragment {
+	var f = b.newFragment()
+	b.addEps(f.start, x.start)
+	b.addEps(f.start, f.end)
+	b.addEps(x.end, x.start)
+	b.addEps(x.end, f.end)
+	return f
+}
+
+// embed copies dfa into nfa of builder
+func (b *automatonBuilder) embed(d *automaton) fragment {
+	var f = b.newFragment()
+	if b.err != nil {
+		return f
+	}
+	var offset = len(b.nfa.states)
+	for range d.states {
+		b.newState()
+	}
+	if b.err != nil {
+		return f
+	}
+	for i, s := range d.states {
+		for _, t := range s.trans {
+			b.addTrans(offset+i, t.lo, t.hi, offset+t.to)
+		}
+		if s.accept {
+			b.addEps(offset+i, f.end)
+		}
+	}
+	b.addEps(f.start, offset+d.start)
+	return f
+}
+
+func (b *automatonBuilder) regexp(ast *regexpAst) fragment {
+	if b.err != nil {
+		return fragment{}
+	}
+	switch ast.kind {
+	case regexpChar:
+		return b.char(ast.char, ast.char)
+	case regexpCharClass:
+		var ranges = normalizeRanges(ast.ranges)
+		if ast.negate {
+			ranges = complementRanges(ranges)
+		}
+		var f = b.newFragment()
+		for _, r := range ranges {
+			b.addTrans(f.start, r.lo, r.hi, f.end)
+		}
+		return f
+	case regexpAnyChar:
+		return b.anyChar()
+	case regexpAnyString:
+		return b.anyString()
+	case regexpEmpty:
+		return b.empty()
+	case regexpEmptyString:
+		return b.emptyString()
+	case regexpString:
+		return b.runes(ast.str)
+	case regexpInterval:
+		return b.interval(ast)
+	case regexpConcat:
+		var f = b.emptyString()
+		for _, sub := range ast.subs {
+			f = b.concat(f, b.regexp(sub))
+		}
+		return f
+	case regexpUnion:
+		var fs = make([]fragment, 0, len(ast.subs))
+		for _, sub := range ast.subs {
+			fs = append(fs, b.regexp(sub))
+		}
+		return b.union(fs...)
+	case regexpIntersection:
+		var res *automaton
+		for _, sub := range ast.subs {
+			var d, err = b.subAutomaton(sub)
+			if err == nil && res != nil {
+				d, err = intersectAutomaton(res, d, b.maxStates)
+			}
+			if err != nil {
+				b.err = err
+				return fragment{}
+			}
+			res = d
+		}
+		return b.embed(res)
+	case regexpComplement:
+		var d, err = b.subAutomaton(ast.subs[0])
+		if err != nil {
+			b.err = err
+			return fragment{}
+		}
+		return b.embed(d.complement())
+	case regexpRepeat:
+		var f = b.emptyString()
+		for i := 0; i < ast.min && b.err == nil; i++ {
+			f = b.concat(f, b.regexp(ast.subs[0]))
+		}
+		if ast.max < 0 {
+			return b.concat(f, b.star(b.regexp(ast.subs[0])))
+		}
+		for i := ast.min; i < ast.max && b.err == nil; i++ {
+			f = b.concat(f, b.optional(b.regexp(ast.subs[0])))
+		}
+		return f
+	default:
+		b.err = ErrUnsupportedAutomaton
+		return fragment{}
+	}
+}
+
+// subAutomaton builds dfa of sub expression, which is used by intersection and complement
+func (b *automatonBuilder) subAutomaton(ast *regexpAst) (*automaton, error) {
+	var sub = newAutomatonBuilder(b.maxStates)
+	return sub.determinize(sub.regexp(ast))
+}
+
+// interval builds numeric interval `<n-m>`, the same as matchInterval
+func (b *automatonBuilder) interval(ast *regexpAst) fragment {
+	if ast.digits > 0 {
+		return b.digitsRange(padDigits(ast.min, ast.digits), padDigits(ast.max, ast.digits))
+	}
+	// leading zeros followed by number without leading zero
+	var fs []fragment
+	if ast.min == 0 {
+		fs = append(fs, b.char('0', '0'))
+	}
+	var width = len(strconv.Itoa(ast.max))
+	for w, lower := 1, 1; w <= width; w, lower = w+1, lower*10 {
+		var lo, hi = ast.min, ast.max
+		if lo < lower {
+			lo = lower
+		}
+		if w < width {
+			hi = minInt(hi, lower*10-1)
+		}
+		if lo <= hi {
+			fs = append(fs, b.digitsRange(padDigits(lo, w), padDigits(hi, w)))
+		}
+	}
+	var zeros = b.star(b.char('0', '0'))
+	return b.concat(zeros, b.union(fs...))
+}
+
+// digitsRange builds decimal strings with the same width as lo and hi, whose value is in [lo, hi]
+func (b *automatonBuilder) digitsRange(lo, hi string) fragment {
+	if len(lo) == 0 || b.err != nil {
+		return b.emptyString()
+	}
+	if lo[0] == hi[0] {
+		return b.concat(b.char(rune(lo[0]), rune(lo[0])), b.digitsRange(lo[1:], hi[1:]))
+	}
+	var n = len(lo) - 1
+	var fs = []fragment{
+		b.concat(b.char(rune(lo[0]), rune(lo[0])), b.digitsRange(lo[1:], strings.Repeat("9", n))),
+		b.concat(b.char(rune(hi[0]), rune(hi[0])), b.digitsRange(strings.Repeat("0", n), hi[1:])),
+	}
+	if hi[0]-lo[0] > 1 {
+		var f = b.char(rune(lo[0]+1), rune(hi[0]-1))
+		for i := 0; i < n; i++ {
+			f = b.concat(f, b.char('0', '9'))
+		}
+		fs = append(fs, f)
+	}
+	return b.union(fs...)
+}
+
+// determinize converts nfa fragment to dfa by subset construction
+func (b *automatonBuilder) determinize(f fragment) (*automaton, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	b.nfa.states[f.end].accept = true
+	var nfa = b.nfa.states
+	var res = &automaton{}
+	var ids = map[string]int{}
+	var queue [][]int
+	var add = func(set []int) (int, error) {
+		var key = setKey(set)
+		if id, ok := ids[key]; ok {
+			return id, nil
+		}
+		if len(res.states) >= b.maxStates {
+			return 0, ErrTooManyStates
+		}
+		var id = len(res.states)
+		ids[key] = id
+		var accept = false
+		for _, s := range set {
+			accept = accept || nfa[s].accept
+		}
+		res.states = append(res.states, autoState{accept: accept})
+		queue = append(queue, set)
+		return id, nil
+	}
+	if _, err := add(epsClosure(nfa, []int{f.start})); err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(queue); i++ {
+		var set = queue[i]
+		var points []rune
+		for _, s := range set {
+			for _, t := range nfa[s].trans {
+				points = append(points, t.lo, t.hi+1)
+			}
+		}
+		sort.Slice(points, func(i, j int) bool { return points[i] < points[j] })
+		var trans []autoTrans
+		for j := 0; j+1 < len(points); j++ {
+			var lo, hi = points[j], points[j+1] - 1
+			if lo > hi {
+				continue
+			}
+			var targets []int
+			for _, s := range set {
+				for _, t := range nfa[s].trans {
+					if t.lo <= lo && hi <= t.hi {
+						targets = append(targets, t.to)
+					}
+				}
+			}
+			if len(targets) == 0 {
+				continue
+			}
+			var to, err = add(epsClosure(nfa, targets))
+			if err != nil {
+				return nil, err
+			}
+			if k := len(trans) - 1; k >= 0 && trans[k].to == to && trans[k].hi+1 == lo {
+				trans[k].hi = hi
+			} else {
+				trans = append(trans, autoTrans{lo: lo, hi: hi, to: to})
+			}
+		}
+		res.states[i].trans = trans
+	}
+	return res, nil
+}
+
+// epsClosure returns sorted states reachable from states by epsilon transitions
+func epsClosure(nfa []autoState, states []int) []int {
+	var visited = map[int]bool{}
+	var stack = append([]int{}, states...)
+	for _, s := range states {
+		visited[s] = true
+	}
+	for len(stack) != 0 {
+		var s = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, to := range nfa[s].eps {
+			if !visited[to] {
+				visited[to] = true
+				stack = append(stack, to)
+			}
+		}
+	}
+	var res = make([]int, 0, len(visited))
+	for s := range visited {
+		res = append(res, s)
+	}
+	sort.Ints(res)
+	return res
+}
+
+func setKey(set []int) string {
+	var sb strings.Builder
+	for _, s := range set {
+		sb.WriteString(strconv.Itoa(s))
+		sb.WriteByte(',')
+	}
+	return sb.String()
+}
+
+// normalizeRanges sorts and merges overlapping ranges
+func normalizeRanges(ranges []runeRange) []runeRange {
+	var sorted = append([]runeRange{}, ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].lo < sorted[j].lo })
+	var res []runeRange
+	for _, r := range sorted {
+		if k := len(res) - 1; k >= 0 && r.lo <= res[k].hi+1 {
+			res[k].hi = maxRune(res[k].hi, r.hi)
+		} else {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// complementRanges returns ranges not covered by normalized ranges
+func complementRanges(ranges []runeRange) []runeRange {
+	var res []runeRange
+	var next rune = 0
+	for _, r := range ranges {
+		if r.lo > next {
+			res = append(res, runeRange{lo: next, hi: r.lo - 1})
+		}
+		next = r.hi + 1
+	}
+	if next <= unicode.MaxRune {
+		res = append(res, runeRange{lo: next, hi: unicode.MaxRune})
+	}
+	return res
+}
+
+func padDigits(v, width int) string {
+	var s = strconv.Itoa(v)
+	if len(s) < width {
+		s = strings.Repeat("0", width-len(s)) + s
+	}
+	return s
+}
+
+func minRune(a, b rune) rune {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxRune(a, b rune) rune {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustRegexpPattern(pattern string) PatternMatcher {
+	if m, err := NewRegexpPattern(pattern, ALL_REGEXP_FLAG); err != nil {
+		panic(err)
+	} else {
+		return m
+	}
+}
+
+func TestComparePattern(t *testing.T) {
+	tests := []struct {
+		name string
+		a    PatternMatcher
+		b    PatternMatcher
+		want PatternRelation
+	}{
+		{"prefix_subset_prefix", NewPrefixPattern("abc"), NewPrefixPattern("ab"), SUBSET_RELATION},
+		{"prefix_disjoint_prefix", NewPrefixPattern("abc"), NewPrefixPattern("xyz"), DISJOINT_RELATION},
+		{"prefix_equal_wildcard", NewPrefixPattern("ab"), NewWildCardPattern("ab*"), EQUAL_RELATION},
+		{"wildcard_overlap_wildcard", NewWildCardPattern("foo*"), NewWildCardPattern("f*o"), OVERLAP_RELATION},
+		{"wildcard_subset_wildcard", NewWildCardPattern("ab?d"), NewWildCardPattern("ab*"), SUBSET_RELATION},
+		{"wildcard_superset_wildcard", NewWildCardPattern("*"), NewWildCardPattern("a?c"), SUPERSET_RELATION},
+		{"wildcard_disjoint_wildcard", NewWildCardPattern("a?"), NewWildCardPattern("a??"), DISJOINT_RELATION},
+		{"regexp_equal_prefix", mustRegexpPattern("ab.*"), NewPrefixPattern("ab"), EQUAL_RELATION},
+		{"regexp_subset_wildcard", mustRegexpPattern("ab[0-9]+"), NewWildCardPattern("ab*"), SUBSET_RELATION},
+		{"regexp_disjoint_regexp", mustRegexpPattern("[0-9]+"), mustRegexpPattern("[a-z]+"), DISJOINT_RELATION},
+		{"regexp_overlap_regexp", mustRegexpPattern("a.*"), mustRegexpPattern(".*z"), OVERLAP_RELATION},
+		{"regexp_equal_regexp", mustRegexpPattern("(a|b)+"), mustRegexpPattern("[ab][ab]*"), EQUAL_RELATION},
+		{"interval_subset", mustRegexpPattern("<10-20>"), mustRegexpPattern("<1-100>"), SUBSET_RELATION},
+		{"interval_disjoint", mustRegexpPattern("<01-10>"), mustRegexpPattern("<20-30>"), DISJOINT_RELATION},
+		{"interval_equal", mustRegexpPattern("<0-19>"), mustRegexpPattern("0*1?[0-9]"), EQUAL_RELATION},
+		{"interval_fixed_width", mustRegexpPattern("<0-9>"), mustRegexpPattern("0*[0-9]"), SUBSET_RELATION},
+		{"complement_disjoint", mustRegexpPattern("~(ab@)"), NewPrefixPattern("ab"), DISJOINT_RELATION},
+		{"intersection_equal", mustRegexpPattern("a@&@b"), mustRegexpPattern("a(.*b)?&.*b"), EQUAL_RELATION},
+		{"negate_char_class", mustRegexpPattern("[^a]"), mustRegexpPattern("a"), DISJOINT_RELATION},
+		{"empty_language", mustRegexpPattern("a#"), NewPrefixPattern("a"), SUBSET_RELATION},
+		{"unicode", NewPrefixPattern("你"), mustRegexpPattern("你好"), SUPERSET_RELATION},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ComparePattern(tt.a, tt.b, DEFAULT_MAX_DETERMINIZED_STATES)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestComparePatternError(t *testing.T) {
+	// go regexp can't be compiled to automaton
+	var got, err = ComparePattern(regexp.MustCompile("ab.*"), NewPrefixPattern("ab"), DEFAULT_MAX_DETERMINIZED_STATES)
+	assert.Equal(t, ErrUnsupportedAutomaton, err)
+	assert.Equal(t, UNKNOWN_RELATION, got)
+
+	// the classic exponential blow-up of determinize
+	got, err = ComparePattern(mustRegexpPattern("(a|b)*a(a|b){20}"), NewPrefixPattern("a"), DEFAULT_MAX_DETERMINIZED_STATES)
+	assert.Equal(t, ErrTooManyStates, err)
+	assert.Equal(t, UNKNOWN_RELATION, got)
+
+	got, err = ComparePattern(mustRegexpPattern("(a{1000}){100}"), NewPrefixPattern("a"), DEFAULT_MAX_DETERMINIZED_STATES)
+	assert.Equal(t, ErrTooManyStates, err)
+	assert.Equal(t, UNKNOWN_RELATION, got)
+
+	got, err = ComparePattern(NewPrefixPattern("ab"), NewWildCardPattern("a*"), 2)
+	assert.Equal(t, ErrTooManyStates, err)
+	assert.Equal(t, UNKNOWN_RELATION, got)
+}
+
+func TestAutomatonAcceptSameAsMatcher(t *testing.T) {
+	var patterns = []string{"<1-100>", "<01-10>", "<0-5>x", "a~(b)c", "[^a-c]+", "(ab|cd){1,2}", "@z&a@"}
+	var texts = []string{"", "0", "5", "05", "10", "010", "100", "0100", "101", "5x", "0x", "ac", "abc", "axc", "dd", "ab", "abcd", "abab", "az", "abz", "z"}
+	for _, pattern := range patterns {
+		var m = mustRegexpPattern(pattern)
+		var d, err = m.(automatonPattern).toAutomaton(DEFAULT_MAX_DETERMINIZED_STATES)
+		assert.Nil(t, err)
+		for _, text := range texts {
+			assert.Equal(t, m.Match([]byte(text)), d.accept([]rune(text)), "pattern: %s, text: %s", pattern, text)
+		}
+	}
+}
+
+func (a *automaton) accept(text []rune) bool {
+	var s = a.start
+next:
+	for _, c := range text {
+		for _, t := range a.states[s].trans {
+			if t.lo <= c && c <= t.hi {
+				s = t.to
+				continue next
+			}
+		}
+		return false
+	}
+	return a.states[s].accept
+}
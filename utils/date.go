@@ -0,0 +1,251 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	EPOCH_MILLIS_FORMAT = "epoch_millis"
+	EPOCH_SECOND_FORMAT = "epoch_second"
+	DATE_FORMAT         = "strict_date_optional_time"
+	DATE_NANOS_FORMAT   = "strict_date_optional_time_nanos"
+)
+
+// builtinDateFormats are java patterns of es built-in date formats, "strict_" prefix is removed
+// reference: https://www.elastic.co/guide/en/elasticsearch/reference/current/mapping-date-format.html#built-in-date-formats
+var builtinDateFormats = map[string]string{
+	"date_optional_time":               "yyyy-MM-dd'T'HH:mm:ss.SSSXXX",
+	"date_optional_time_nanos":         "yyyy-MM-dd'T'HH:mm:ss.SSSSSSSSSXXX",
+	"basic_date":                       "yyyyMMdd",
+	"basic_date_time":                  "yyyyMMdd'T'HHmmss.SSSZ",
+	"basic_date_time_no_millis":        "yyyyMMdd'T'HHmmssZ",
+	"date":                             "yyyy-MM-dd",
+	"date_hour":                        "yyyy-MM-dd'T'HH",
+	"date_hour_minute":                 "yyyy-MM-dd'T'HH:mm",
+	"date_hour_minute_second":          "yyyy-MM-dd'T'HH:mm:ss",
+	"date_hour_minute_second_fraction": "yyyy-MM-dd'T'HH:mm:ss.SSS",
+	"date_hour_minute_second_millis":   "yyyy-MM-dd'T'HH:mm:ss.SSS",
+	"date_time":                        "yyyy-MM-dd'T'HH:mm:ss.SSSXXX",
+	"date_time_no_millis":              "yyyy-MM-dd'T'HH:mm:ssXXX",
+	"year":                             "yyyy",
+	"year_month":                       "yyyy-MM",
+	"year_month_day":                   "yyyy-MM-dd",
+}
+
+// FormatDate formats time with es date format, which is name of built-in format (i.e. "strict_date_optional_time")
+// or java pattern (i.e. "yyyy-MM-dd HH:mm:ss"), epoch_millis and epoch_second are formatted as number.
+func FormatDate(t time.Time, format string) (interface{}, error) {
+	switch format {
+	case EPOCH_MILLIS_FORMAT:
+		return t.UnixNano() / 1e6, nil
+	case EPOCH_SECOND_FORMAT:
+		return t.Unix(), nil
+	}
+	if pattern, ok := builtinDateFormats[strings.TrimPrefix(format, "strict_")]; ok {
+		format = pattern
+	}
+	var sb strings.Builder
+	var runes = []rune(format)
+	for i := 0; i < len(runes); {
+		var c = runes[i]
+		if c == '\'' {
+			// quoted literal, two single quotes means a single quote
+			if i+1 < len(runes) && runes[i+1] == '\'' {
+				sb.WriteRune('\'')
+				i += 2
+				continue
+			}
+			var j = i + 1
+			for ; j < len(runes); j++ {
+				if runes[j] != '\'' {
+					sb.WriteRune(runes[j])
+				} else if j+1 < len(runes) && runes[j+1] == '\'' {
+					sb.WriteRune('\'')
+					j++
+				} else {
+					break
+				}
+			}
+			i = j + 1
+			continue
+		}
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			sb.WriteRune(c)
+			i++
+			continue
+		}
+		var j = i
+		for j < len(runes) && runes[j] == c {
+			j++
+		}
+		if s, err := formatDateField(t, c, j-i); err != nil {
+			return nil, fmt.Errorf("date format: %s is unsupported, err: %v", format, err)
+		} else {
+			sb.WriteString(s)
+		}
+		i = j
+	}
+	return sb.String(), nil
+}
+
+// formatDateField formats field of time represented by pattern letter repeated count times
+func formatDateField(t time.Time, letter rune, count int) (string, error) {
+	switch letter {
+	case 'y', 'u':
+		if count == 2 {
+			return t.Format("06"), nil
+		}
+		return fmt.Sprintf("%0*d", count, t.Year()), nil
+	case 'M':
+		return pickLayout(t, count, "1", "01", "Jan", "January"), nil
+	case 'd':
+		return pickLayout(t, count, "2", "02"), nil
+	case 'H':
+		if count == 1 {
+			return strconv.Itoa(t.Hour()), nil
+		}
+		return t.Format("15"), nil
+	case 'h':
+		return pickLayout(t, count, "3", "03"), nil
+	case 'm':
+		return pickLayout(t, count, "4", "04"), nil
+	case 's':
+		return pickLayout(t, count, "5", "05"), nil
+	case 'S':
+		if count > 9 {
+			return "", fmt.Errorf("fraction of second is more than 9 digits")
+		}
+		return fmt.Sprintf("%09d", t.Nanosecond())[:count], nil
+	case 'a':
+		return t.Format("PM"), nil
+	case 'E':
+		return pickLayout(t, count, "Mon", "Mon", "Mon", "Monday"), nil
+	case 'z':
+		return t.Format("MST"), nil
+	case 'Z':
+		return pickLayout(t, count, "-0700", "-0700", "-0700", "-07:00"), nil
+	case 'X':
+		return pickLayout(t, count, "Z07", "Z0700", "Z07:00"), nil
+	case 'x':
+		return pickLayout(t, count, "-07", "-0700", "-07:00"), nil
+	default:
+		return "", fmt.Errorf("pattern letter: %c is unsupported", letter)
+	}
+}
+
+// pickLayout formats time with go layout chosen by count of pattern letter, the last layout is used for larger count
+func pickLayout(t time.Time, count int, layouts ...string) string {
+	if count > len(layouts) {
+		count = len(layouts)
+	}
+	return t.Format(layouts[count-1])
+}
+
+// IsExactDateFormat reports whether date printed with format is parsed by es back to t at precision, missing components
+// of the printed date are filled with min values, or with values of rounding up for gt / lte bounds when roundUp is true,
+// i.e. month and day are 01 and others are max values.
+// reference: https://www.elastic.co/guide/en/elasticsearch/reference/current/query-dsl-range-query.html#missing-date-components
+func IsExactDateFormat(t time.Time, format string, roundUp bool, precision time.Duration) bool {
+	var level = dateFormatLevel(format)
+	var year, month, day = t.Date()
+	var hour, minute, second = t.Clock()
+	var fill = func(v *int, min, max int) {
+		if roundUp {
+			*v = max
+		} else {
+			*v = min
+		}
+	}
+	if level < 1 {
+		month = time.January
+	}
+	if level < 2 {
+		day = 1
+	}
+	if level < 3 {
+		fill(&hour, 0, 23)
+	}
+	if level < 4 {
+		fill(&minute, 0, 59)
+	}
+	if level < 5 {
+		fill(&second, 0, 59)
+	}
+	// level more than 5 is digits of fraction of second
+	var unit = int(time.Second)
+	for i := 5; i < level && unit > 1; i++ {
+		unit /= 10
+	}
+	var nanosecond = t.Nanosecond() - t.Nanosecond()%unit
+	if roundUp {
+		nanosecond += unit - 1
+	}
+	var parsed = time.Date(year, month, day, hour, minute, second, nanosecond, t.Location())
+	return parsed.Truncate(precision).Equal(t.Truncate(precision))
+}
+
+// dateFormatLevel returns the finest component printed by format, which is 0 for year, 1 for month, 2 for day,
+// 3 for hour, 4 for minute, 5 for second, and 5 plus digits of fraction of second
+func dateFormatLevel(format string) int {
+	switch format {
+	case EPOCH_MILLIS_FORMAT:
+		return 8
+	case EPOCH_SECOND_FORMAT:
+		return 5
+	}
+	if pattern, ok := builtinDateFormats[strings.TrimPrefix(format, "strict_")]; ok {
+		format = pattern
+	}
+	var level, quoted = 0, false
+	var runes = []rune(format)
+	for i := 0; i < len(runes); i++ {
+		var c = runes[i]
+		if c == '\'' {
+			quoted = !quoted
+			continue
+		} else if quoted {
+			continue
+		}
+		var l = -1
+		switch c {
+		case 'M':
+			l = 1
+		case 'd':
+			l = 2
+		case 'H', 'h':
+			l = 3
+		case 'm':
+			l = 4
+		case 's':
+			l = 5
+		case 'S':
+			var j = i
+			for j < len(runes) && runes[j] == 'S' {
+				j++
+			}
+			l, i = 5+j-i, j-1
+		}
+		if l > level {
+			level = l
+		}
+	}
+	return level
+}
+
+// LoadTimeZone loads time zone in the form accepted by es, i.e. IANA time zone id ("Asia/Shanghai") or UTC offset ("+08:00"),
+// nil is returned for empty time zone.
+func LoadTimeZone(timeZone string) (*time.Location, error) {
+	if timeZone == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{"Z07:00", "Z0700"} {
+		if t, err := time.Parse(layout, timeZone); err == nil {
+			var _, offset = t.Zone()
+			return time.FixedZone(timeZone, offset), nil
+		}
+	}
+	return time.LoadLocation(timeZone)
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatDate(t *testing.T) {
+	var (
+		date     = time.Date(2024, time.January, 2, 3, 4, 5, 6007008, time.UTC)
+		shanghai = time.FixedZone("+08:00", 8*3600)
+	)
+	tests := []struct {
+		name   string
+		t      time.Time
+		format string
+		want   interface{}
+	}{
+		{"epoch_millis", date, "epoch_millis", int64(1704164645006)},
+		{"epoch_second", date, "epoch_second", int64(1704164645)},
+		{"strict_date_optional_time", date, "strict_date_optional_time", "2024-01-02T03:04:05.006Z"},
+		{"date_optional_time_with_offset", date.In(shanghai), "date_optional_time", "2024-01-02T11:04:05.006+08:00"},
+		{"strict_date_optional_time_nanos", date, "strict_date_optional_time_nanos", "2024-01-02T03:04:05.006007008Z"},
+		{"basic_date_time", date, "basic_date_time", "20240102T030405.006+0000"},
+		{"year_month", date, "strict_year_month", "2024-01"},
+		{"java_pattern", date, "yyyy/MM/dd HH:mm:ss", "2024/01/02 03:04:05"},
+		{"quoted_literal", date, "yyyy-MM-dd'T'HH 'o''clock'", "2024-01-02T03 o'clock"},
+		{"text_fields", date, "EEE, d MMM yy h:mm a", "Tue, 2 Jan 24 3:04 AM"},
+		{"fraction", date, "ss.SSSSSS", "05.006007"},
+		{"offset", date.In(shanghai), "HH:mmZ x XXX", "11:04+0800 +08 +08:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FormatDate(tt.t, tt.format)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := FormatDate(date, "yyyy-MM-dd QQQ")
+	assert.NotNil(t, err)
+	_, err = FormatDate(date, "ss.SSSSSSSSSS")
+	assert.NotNil(t, err)
+}
+
+func TestIsExactDateFormat(t *testing.T) {
+	var (
+		day      = time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+		endOfDay = time.Date(2024, time.March, 1, 23, 59, 59, 999999999, time.UTC)
+		hour     = time.Date(2024, time.March, 1, 10, 0, 0, 0, time.UTC)
+		millis   = time.Date(2024, time.March, 1, 10, 0, 0, 1e6, time.UTC)
+		nanos    = time.Date(2024, time.March, 1, 10, 0, 0, 1, time.UTC)
+	)
+	tests := []struct {
+		name      string
+		t         time.Time
+		format    string
+		roundUp   bool
+		precision time.Duration
+		want      bool
+	}{
+		{"day", day, "yyyy-MM-dd", false, time.Millisecond, true},
+		{"day_round_up", day, "yyyy-MM-dd", true, time.Millisecond, false},
+		{"end_of_day_round_up", endOfDay, "yyyy-MM-dd", true, time.Millisecond, true},
+		{"coarser_format", hour, "yyyy-MM-dd", false, time.Millisecond, false},
+		{"quoted_letters", hour, "yyyy-MM-dd'T'HH 'o''clock'", false, time.Millisecond, true},
+		{"epoch_second", hour, "epoch_second", false, time.Millisecond, true},
+		{"epoch_second_millis", millis, "epoch_second", false, time.Millisecond, false},
+		{"epoch_millis", millis, "epoch_millis", false, time.Millisecond, true},
+		{"built_in_format", millis, "strict_date_optional_time", false, time.Millisecond, true},
+		{"nanos_of_date", nanos, "strict_date_optional_time", false, time.Millisecond, true},
+		{"nanos_of_date_nanos", nanos, "strict_date_optional_time", false, time.Nanosecond, false},
+		{"date_nanos", nanos, "strict_date_optional_time_nanos", false, time.Nanosecond, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsExactDateFormat(tt.t, tt.format, tt.roundUp, tt.precision))
+		})
+	}
+}
+
+func TestLoadTimeZone(t *testing.T) {
+	loc, err := LoadTimeZone("")
+	assert.Nil(t, err)
+	assert.Nil(t, loc)
+
+	for timeZone, offset := range map[string]int{"+08:00": 8 * 3600, "-0530": -(5*3600 + 30*60), "Z": 0, "Asia/Shanghai": 8 * 3600, "UTC": 0} {
+		loc, err = LoadTimeZone(timeZone)
+		assert.Nil(t, err)
+		var _, got = time.Date(2021, time.March, 2, 0, 0, 0, 0, loc).Zone()
+		assert.Equal(t, offset, got, timeZone)
+	}
+
+	_, err = LoadTimeZone("Invalid/Zone")
+	assert.NotNil(t, err)
+}
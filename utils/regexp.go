@@ -0,0 +1,547 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RegexpFlag enables optional operators of lucene regexp syntax
+// reference: https://www.elastic.co/guide/en/elasticsearch/reference/current/regexp-syntax.html#regexp-optional-operators
+type RegexpFlag uint32
+
+const (
+	INTERSECTION_REGEXP_FLAG RegexpFlag = 1 << iota // enables `&`
+	COMPLEMENT_REGEXP_FLAG                          // enables `~`
+	EMPTY_REGEXP_FLAG                               // enables `#`
+	ANYSTRING_REGEXP_FLAG                           // enables `@`
+	INTERVAL_REGEXP_FLAG                            // enables `<n-m>`
+
+	NONE_REGEXP_FLAG RegexpFlag = 0
+	ALL_REGEXP_FLAG  RegexpFlag = INTERSECTION_REGEXP_FLAG | COMPLEMENT_REGEXP_FLAG |
+		EMPTY_REGEXP_FLAG | ANYSTRING_REGEXP_FLAG | INTERVAL_REGEXP_FLAG
+)
+
+// MAX_REGEXP_REPEAT is max count of repetition `{n,m}`, which bounds work of matching and compiling regexp
+const MAX_REGEXP_REPEAT = 1000
+
+var regexpFlagNames = map[string]RegexpFlag{
+	"ALL":          ALL_REGEXP_FLAG,
+	"NONE":         NONE_REGEXP_FLAG,
+	"EMPTY":        EMPTY_REGEXP_FLAG,
+	"INTERVAL":     INTERVAL_REGEXP_FLAG,
+	"ANYSTRING":    ANYSTRING_REGEXP_FLAG,
+	"COMPLEMENT":   COMPLEMENT_REGEXP_FLAG,
+	"INTERSECTION": INTERSECTION_REGEXP_FLAG,
+}
+
+// ParseRegexpFlags parse flags parameter of es regexp query, e.g. "COMPLEMENT|INTERVAL".
+// empty flags is same as "ALL", which is default value in es.
+func ParseRegexpFlags(flags string) (RegexpFlag, error) {
+	if flags == "" {
+		return ALL_REGEXP_FLAG, nil
+	}
+	var res RegexpFlag
+	for _, name := range strings.Split(flags, "|") {
+		if flag, ok := regexpFlagNames[strings.ToUpper(strings.TrimSpace(name))]; ok {
+			res |= flag
+		} else {
+			return NONE_REGEXP_FLAG, fmt.Errorf("unknown regexp flag: %s", name)
+		}
+	}
+	return res, nil
+}
+
+type regexpKind uint32
+
+const (
+	regexpChar         regexpKind = iota // single char
+	regexpCharClass                      // [a-z], [^a-z]
+	regexpAnyChar                        // .
+	regexpAnyString                      // @
+	regexpEmpty                          // #, matches nothing
+	regexpEmptyString                    // (), matches empty string
+	regexpString                         // "literal"
+	regexpInterval                       // <n-m>
+	regexpConcat                         // ab
+	regexpUnion                          // a|b
+	regexpIntersection                   // a&b
+	regexpComplement                     // ~a
+	regexpRepeat                         // a*, a+, a?, a{n,m}
+)
+
+type runeRange struct {
+	lo, hi rune
+}
+
+// regexpAst is syntax tree of lucene regexp
+type regexpAst struct {
+	kind   regexpKind
+	subs   []*regexpAst
+	char   rune
+	str    []rune
+	ranges []runeRange
+	negate bool
+	// min and max is repeat times for regexpRepeat or bound for regexpInterval, max = -1 means no upper bound
+	min, max int
+	// digits is fixed width of regexpInterval, 0 means any width
+	digits int
+}
+
+// regexpParser parse lucene regexp, grammar is same as `org.apache.lucene.util.automaton.RegExp`
+//
+//	unionexp     ::= interexp | unionexp
+//	interexp     ::= concatexp & interexp
+//	concatexp    ::= repeatexp concatexp
+//	repeatexp    ::= repeatexp ? | repeatexp * | repeatexp + | repeatexp {n} | repeatexp {n,} | repeatexp {n,m} | complexp
+//	complexp     ::= ~ complexp | charclassexp
+//	charclassexp ::= [ charclasses ] | [^ charclasses ] | simpleexp
+//	simpleexp    ::= charexp | . | # | @ | "<string>" | ( ) | ( unionexp ) | <n-m>
+//	charexp      ::= <unicode character> | \ <unicode character>
+type regexpParser struct {
+	text  []rune
+	pos   int
+	flags RegexpFlag
+}
+
+// parseRegexp parse lucene regexp with enabled optional operators
+func parseRegexp(pattern string, flags RegexpFlag) (*regexpAst, error) {
+	var p = &regexpParser{text: []rune(pattern), flags: flags}
+	var ast, err = p.parseUnionExp()
+	if err != nil {
+		return nil, err
+	}
+	if p.more() {
+		return nil, fmt.Errorf("unexpected character '%c' at position %d", p.text[p.pos], p.pos)
+	}
+	return ast, nil
+}
+
+func (p *regexpParser) more() bool {
+	return p.pos < len(p.text)
+}
+
+func (p *regexpParser) peek(chars string) bool {
+	return p.more() && strings.ContainsRune(chars, p.text[p.pos])
+}
+
+func (p *regexpParser) match(c rune) bool {
+	if p.more() && p.text[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *regexpParser) check(flag RegexpFlag) bool {
+	return p.flags&flag == flag
+}
+
+func (p *regexpParser) next() (rune, error) {
+	if !p.more() {
+		return 0, fmt.Errorf("unexpected end of string")
+	}
+	p.pos++
+	return p.text[p.pos-1], nil
+}
+
+func (p *regexpParser) parseUnionExp() (*regexpAst, error) {
+	var e, err = p.parseInterExp()
+	if err != nil {
+		return nil, err
+	}
+	if p.match('|') {
+		if o, err := p.parseUnionExp(); err != nil {
+			return nil, err
+		} else {
+			return &regexpAst{kind: regexpUnion, subs: []*regexpAst{e, o}}, nil
+		}
+	}
+	return e, nil
+}
+
+func (p *regexpParser) parseInterExp() (*regexpAst, error) {
+	var e, err = p.parseConcatExp()
+	if err != nil {
+		return nil, err
+	}
+	if p.check(INTERSECTION_REGEXP_FLAG) && p.match('&') {
+		if o, err := p.parseInterExp(); err != nil {
+			return nil, err
+		} else {
+			return &regexpAst{kind: regexpIntersection, subs: []*regexpAst{e, o}}, nil
+		}
+	}
+	return e, nil
+}
+
+func (p *regexpParser) parseConcatExp() (*regexpAst, error) {
+	var subs []*regexpAst
+	for {
+		var e, err = p.parseRepeatExp()
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, e)
+		if !p.more() || p.peek(")|") || (p.check(INTERSECTION_REGEXP_FLAG) && p.peek("&")) {
+			break
+		}
+	}
+	if len(subs) == 1 {
+		return subs[0], nil
+	}
+	return &regexpAst{kind: regexpConcat, subs: subs}, nil
+}
+
+func (p *regexpParser) parseRepeatExp() (*regexpAst, error) {
+	if p.peek("?*+{") {
+		return nil, fmt.Errorf("missing argument to repetition operator '%c' at position %d", p.text[p.pos], p.pos)
+	}
+	var e, err = p.parseComplExp()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek("?*+{") {
+		var start = p.pos
+		switch p.text[p.pos] {
+		case '?':
+			p.pos++
+			e = &regexpAst{kind: regexpRepeat, subs: []*regexpAst{e}, min: 0, max: 1}
+		case '*':
+			p.pos++
+			e = &regexpAst{kind: regexpRepeat, subs: []*regexpAst{e}, min: 0, max: -1}
+		case '+':
+			p.pos++
+			e = &regexpAst{kind: regexpRepeat, subs: []*regexpAst{e}, min: 1, max: -1}
+		case '{':
+			p.pos++
+			var min, max int
+			if min, err = p.parseInt(); err != nil {
+				return nil, err
+			}
+			max = min
+			if p.match(',') {
+				if p.peek("}") {
+					max = -1
+				} else if max, err = p.parseInt(); err != nil {
+					return nil, err
+				} else if max < min {
+					return nil, fmt.Errorf("invalid repetition range {%d,%d} at position %d", min, max, start)
+				}
+			}
+			if !p.match('}') {
+				return nil, fmt.Errorf("expected '}' at position %d", p.pos)
+			}
+			if min > MAX_REGEXP_REPEAT || max > MAX_REGEXP_REPEAT {
+				return nil, fmt.Errorf("repetition count at position %d exceeds %d", start, MAX_REGEXP_REPEAT)
+			}
+			e = &regexpAst{kind: regexpRepeat, subs: []*regexpAst{e}, min: min, max: max}
+		}
+		// `a*?` is lazy quantifier of perl / re2, which has no meaning in lucene regexp
+		if p.peek("?") {
+			return nil, fmt.Errorf("lazy quantifier at position %d is not supported by lucene regexp", start)
+		}
+	}
+	return e, nil
+}
+
+func (p *regexpParser) parseInt() (int, error) {
+	var start = p.pos
+	for p.peek("0123456789") {
+		p.pos++
+	}
+	if start == p.pos {
+		return 0, fmt.Errorf("integer expected at position %d", start)
+	}
+	return strconv.Atoi(string(p.text[start:p.pos]))
+}
+
+func (p *regexpParser) parseComplExp() (*regexpAst, error) {
+	if p.check(COMPLEMENT_REGEXP_FLAG) && p.match('~') {
+		if e, err := p.parseComplExp(); err != nil {
+			return nil, err
+		} else {
+			return &regexpAst{kind: regexpComplement, subs: []*regexpAst{e}}, nil
+		}
+	}
+	return p.parseCharClassExp()
+}
+
+func (p *regexpParser) parseCharClassExp() (*regexpAst, error) {
+	if !p.match('[') {
+		return p.parseSimpleExp()
+	}
+	var e = &regexpAst{kind: regexpCharClass, negate: p.match('^')}
+	for p.more() && !p.peek("]") {
+		var lo, err = p.parseCharExp()
+		if err != nil {
+			return nil, err
+		}
+		var hi = lo
+		if p.match('-') {
+			if hi, err = p.parseCharExp(); err != nil {
+				return nil, err
+			} else if hi < lo {
+				return nil, fmt.Errorf("invalid character class range %c-%c at position %d", lo, hi, p.pos)
+			}
+		}
+		e.ranges = append(e.ranges, runeRange{lo: lo, hi: hi})
+	}
+	if !p.match(']') {
+		return nil, fmt.Errorf("expected ']' at position %d", p.pos)
+	}
+	if len(e.ranges) == 0 {
+		return nil, fmt.Errorf("empty character class at position %d", p.pos)
+	}
+	return e, nil
+}
+
+func (p *regexpParser) parseSimpleExp() (*regexpAst, error) {
+	var start = p.pos
+	if p.match('.') {
+		return &regexpAst{kind: regexpAnyChar}, nil
+	} else if p.check(EMPTY_REGEXP_FLAG) && p.match('#') {
+		return &regexpAst{kind: regexpEmpty}, nil
+	} else if p.check(ANYSTRING_REGEXP_FLAG) && p.match('@') {
+		return &regexpAst{kind: regexpAnyString}, nil
+	} else if p.match('"') {
+		for p.more() && !p.peek(`"`) {
+			p.pos++
+		}
+		if !p.match('"') {
+			return nil, fmt.Errorf("expected '\"' at position %d", p.pos)
+		}
+		return &regexpAst{kind: regexpString, str: p.text[start+1 : p.pos-1]}, nil
+	} else if p.match('(') {
+		if p.match(')') {
+			return &regexpAst{kind: regexpEmptyString}, nil
+		}
+		var e, err = p.parseUnionExp()
+		if err != nil {
+			return nil, err
+		}
+		if !p.match(')') {
+			return nil, fmt.Errorf("expected ')' at position %d", p.pos)
+		}
+		return e, nil
+	} else if p.check(INTERVAL_REGEXP_FLAG) && p.match('<') {
+		return p.parseInterval(start)
+	} else if p.peek("^$") {
+		// lucene regexp is always anchored, `^` and `$` are literal chars which are usually re2 anchors by mistake
+		return nil, fmt.Errorf("anchor '%c' at position %d is not supported by lucene regexp, escape it to match literally", p.text[p.pos], p.pos)
+	} else if p.peek(")") {
+		return nil, fmt.Errorf("unexpected ')' at position %d", p.pos)
+	}
+	if c, err := p.parseCharExp(); err != nil {
+		return nil, err
+	} else {
+		return &regexpAst{kind: regexpChar, char: c}, nil
+	}
+}
+
+// parseInterval parse numeric interval `<n-m>`, the `<` has been consumed
+func (p *regexpParser) parseInterval(start int) (*regexpAst, error) {
+	for p.more() && !p.peek(">") {
+		p.pos++
+	}
+	if !p.match('>') {
+		return nil, fmt.Errorf("expected '>' at position %d", p.pos)
+	}
+	var body = string(p.text[start+1 : p.pos-1])
+	var idx = strings.IndexByte(body, '-')
+	if idx <= 0 || idx == len(body)-1 {
+		return nil, fmt.Errorf("interval syntax error at position %d, expect <n-m>", start)
+	}
+	var smin, smax = body[:idx], body[idx+1:]
+	var min, err1 = strconv.Atoi(smin)
+	var max, err2 = strconv.Atoi(smax)
+	if err1 != nil || err2 != nil || min < 0 || max < 0 {
+		return nil, fmt.Errorf("interval syntax error at position %d, expect <n-m>", start)
+	}
+	var digits = 0
+	if len(smin) == len(smax) {
+		digits = len(smin)
+	}
+	if min > max {
+		min, max = max, min
+	}
+	return &regexpAst{kind: regexpInterval, min: min, max: max, digits: digits}, nil
+}
+
+// shorthand character class of perl / re2, which is escaped literal char in lucene regexp
+var perlClasses = "dDwWsSbBAz"
+
+func (p *regexpParser) parseCharExp() (rune, error) {
+	if p.match('\\') {
+		if p.peek(perlClasses) {
+			return 0, fmt.Errorf("character class '\\%c' at position %d is not supported by lucene regexp", p.text[p.pos], p.pos-1)
+		}
+	}
+	return p.next()
+}
+
+// regexpPattern matches whole text with lucene regexp semantics
+type regexpPattern struct {
+	ast   *regexpAst
+	flags RegexpFlag
+}
+
+// NewRegexpPattern parse lucene regexp with enabled optional operators and return its matcher.
+// Like es, the whole text must match the pattern, there is no need for anchors.
+func NewRegexpPattern(pattern string, flags RegexpFlag) (PatternMatcher, error) {
+	if ast, err := parseRegexp(pattern, flags); err != nil {
+		return nil, err
+	} else {
+		return &regexpPattern{ast: ast, flags: flags}, nil
+	}
+}
+
+func (r *regexpPattern) Match(text []byte) bool {
+	var m = &regexpMatcher{text: []rune(string(text)), memo: map[regexpMemoKey][]bool{}}
+	return m.ends(r.ast, 0)[len(m.text)]
+}
+
+type regexpMemoKey struct {
+	ast *regexpAst
+	pos int
+}
+
+// regexpMatcher compute all end positions of sub-match starting with a given position,
+// which supports complement and intersection directly without building automaton.
+type regexpMatcher struct {
+	text []rune
+	memo map[regexpMemoKey][]bool
+}
+
+func (m *regexpMatcher) ends(ast *regexpAst, i int) []bool {
+	var key = regexpMemoKey{ast: ast, pos: i}
+	if res, ok := m.memo[key]; ok {
+		return res
+	}
+	var n = len(m.text)
+	var res = make([]bool, n+1)
+	switch ast.kind {
+	case regexpChar:
+		if i < n && m.text[i] == ast.char {
+			res[i+1] = true
+		}
+	case regexpCharClass:
+		if i < n {
+			var in = false
+			for _, r := range ast.ranges {
+				if r.lo <= m.text[i] && m.text[i] <= r.hi {
+					in = true
+					break
+				}
+			}
+			res[i+1] = in != ast.negate
+		}
+	case regexpAnyChar:
+		if i < n {
+			res[i+1] = true
+		}
+	case regexpAnyString:
+		for j := i; j <= n; j++ {
+			res[j] = true
+		}
+	case regexpEmpty:
+	case regexpEmptyString:
+		res[i] = true
+	case regexpString:
+		if j := i + len(ast.str); j <= n && string(m.text[i:j]) == string(ast.str) {
+			res[j] = true
+		}
+	case regexpInterval:
+		for j := i + 1; j <= n && m.text[j-1] >= '0' && m.text[j-1] <= '9'; j++ {
+			res[j] = matchInterval(string(m.text[i:j]), ast)
+		}
+	case regexpConcat:
+		var cur = make([]bool, n+1)
+		cur[i] = true
+		for _, sub := range ast.subs {
+			cur = m.step(sub, cur)
+		}
+		res = cur
+	case regexpUnion:
+		for _, sub := range ast.subs {
+			orBits(res, m.ends(sub, i))
+		}
+	case regexpIntersection:
+		copy(res, m.ends(ast.subs[0], i))
+		for _, sub := range ast.subs[1:] {
+			var o = m.ends(sub, i)
+			for j := range res {
+				res[j] = res[j] && o[j]
+			}
+		}
+	case regexpComplement:
+		var o = m.ends(ast.subs[0], i)
+		for j := i; j <= n; j++ {
+			res[j] = !o[j]
+		}
+	case regexpRepeat:
+		var cur = make([]bool, n+1)
+		cur[i] = true
+		for k := 0; k < ast.min && anyBits(cur); k++ {
+			cur = m.step(ast.subs[0], cur)
+		}
+		orBits(res, cur)
+		// positions of more repetitions are all matched once a repetition adds no new position
+		for k := ast.min; ast.max < 0 || k < ast.max; k++ {
+			cur = m.step(ast.subs[0], cur)
+			if !orBits(res, cur) {
+				break
+			}
+		}
+	}
+	m.memo[key] = res
+	return res
+}
+
+// step return end positions of matching ast from any of start positions
+func (m *regexpMatcher) step(ast *regexpAst, starts []bool) []bool {
+	var res = make([]bool, len(starts))
+	for i, ok := range starts {
+		if ok {
+			orBits(res, m.ends(ast, i))
+		}
+	}
+	return res
+}
+
+// anyBits report whether any bit of a is set
+func anyBits(a []bool) bool {
+	for _, ok := range a {
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// orBits merge b into a and report whether a is changed
+func orBits(a, b []bool) bool {
+	var changed = false
+	for i := range a {
+		if b[i] && !a[i] {
+			a[i] = true
+			changed = true
+		}
+	}
+	return changed
+}
+
+// matchInterval check digits text is in numeric interval of ast.
+// if bounds have same width, text must have the same width (with leading zeros),
+// otherwise text can have any number of leading zeros.
+func matchInterval(text string, ast *regexpAst) bool {
+	if ast.digits > 0 && len(text) != ast.digits {
+		return false
+	}
+	var trimmed = strings.TrimLeft(text, "0")
+	if len(trimmed) > len(strconv.Itoa(ast.max)) {
+		return false
+	}
+	var v, _ = strconv.Atoi("0" + trimmed)
+	return ast.min <= v && v <= ast.max
+}
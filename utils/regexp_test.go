@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRegexpFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   string
+		want    RegexpFlag
+		wantErr bool
+	}{
+		{"empty", "", ALL_REGEXP_FLAG, false},
+		{"all", "ALL", ALL_REGEXP_FLAG, false},
+		{"none", "NONE", NONE_REGEXP_FLAG, false},
+		{"combine", "COMPLEMENT|interval", COMPLEMENT_REGEXP_FLAG | INTERVAL_REGEXP_FLAG, false},
+		{"unknown", "COMPLEMENT|FOO", NONE_REGEXP_FLAG, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRegexpFlags(tt.flags)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestRegexpPatternMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		text    string
+		want    bool
+	}{
+		{"literal", "abc", "abc", true},
+		{"anchored_prefix", "ab", "abc", false},
+		{"anchored_suffix", "bc", "abc", false},
+		{"any_char", "a.c", "abc", true},
+		{"star", "ab*c", "ac", true},
+		{"plus", "ab+c", "ac", false},
+		{"optional", "ab?c", "abc", true},
+		{"repeat_exact", "a{2}", "aa", true},
+		{"repeat_exact_fail", "a{2}", "aaa", false},
+		{"repeat_range", "a{1,3}", "aaa", true},
+		{"repeat_at_least", "a{2,}", "aaaaa", true},
+		{"repeat_large_range", "a{0,1000}", "aaaaa", true},
+		{"repeat_large_range_fail", "a{0,1000}b", "aaaaa", false},
+		{"repeat_nested_range", "(ab|a){2,5}", "aababab", true},
+		{"repeat_nested_range_fail", "(ab|a){2,3}", "aababab", false},
+		{"union", "abc|xyz", "xyz", true},
+		{"group", "a(bc)+", "abcbc", true},
+		{"empty_group", "a()b", "ab", true},
+		{"char_class", "[a-c]+", "abcabc", true},
+		{"negate_char_class", "[^a-c]", "d", true},
+		{"negate_char_class_fail", "[^a-c]", "a", false},
+		{"escape", `a\.b`, "a.b", true},
+		{"escape_fail", `a\.b`, "axb", false},
+		{"string_literal", `"a+b"c`, "a+bc", true},
+		{"any_string", "ab@", "abxyz", true},
+		{"empty_language", "a#", "a", false},
+		{"interval", "foo<1-100>", "foo80", true},
+		{"interval_out_of_range", "foo<1-100>", "foo101", false},
+		{"interval_leading_zero", "<1-100>", "0080", true},
+		{"interval_fixed_width", "<01-10>", "05", true},
+		{"interval_fixed_width_fail", "<01-10>", "5", false},
+		{"complement", "ab~(cd)", "abcc", true},
+		{"complement_fail", "ab~(cd)", "abcd", false},
+		{"complement_any", "~(abc)", "", true},
+		{"intersection", "aaa.+&.+bbb", "aaabbb", true},
+		{"intersection_fail", "aaa.+&.+bbb", "aaabb", false},
+		{"unicode", "你.", "你好", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewRegexpPattern(tt.pattern, ALL_REGEXP_FLAG)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, m.Match([]byte(tt.text)))
+		})
+	}
+}
+
+func TestRegexpPatternFlags(t *testing.T) {
+	// without optional operators, the chars are literal
+	m, err := NewRegexpPattern("a@b", NONE_REGEXP_FLAG)
+	assert.Nil(t, err)
+	assert.True(t, m.Match([]byte("a@b")))
+	assert.False(t, m.Match([]byte("axxb")))
+
+	m, err = NewRegexpPattern("a~b", INTERVAL_REGEXP_FLAG)
+	assert.Nil(t, err)
+	assert.True(t, m.Match([]byte("a~b")))
+
+	m, err = NewRegexpPattern("a&b", COMPLEMENT_REGEXP_FLAG)
+	assert.Nil(t, err)
+	assert.True(t, m.Match([]byte("a&b")))
+
+	m, err = NewRegexpPattern("a#", ANYSTRING_REGEXP_FLAG)
+	assert.Nil(t, err)
+	assert.True(t, m.Match([]byte("a#")))
+
+	m, err = NewRegexpPattern("<1-2>", COMPLEMENT_REGEXP_FLAG)
+	assert.Nil(t, err)
+	assert.True(t, m.Match([]byte("<1-2>")))
+}
+
+func TestRegexpPatternInvalid(t *testing.T) {
+	var patterns = []string{
+		`\d+`,
+		`\w`,
+		`a\s`,
+		`a*?`,
+		`a+?`,
+		`^abc`,
+		`abc$`,
+		`*abc`,
+		`(abc`,
+		`abc)`,
+		`[abc`,
+		`[z-a]`,
+		`a{2`,
+		`a{x}`,
+		`a{3,1}`,
+		`a{1001}`,
+		`a{0,100000000}`,
+		`<1->`,
+		`<foo>`,
+		`"abc`,
+		`abc\`,
+	}
+	for _, pattern := range patterns {
+		t.Run(pattern, func(t *testing.T) {
+			m, err := NewRegexpPattern(pattern, ALL_REGEXP_FLAG)
+			assert.NotNil(t, err)
+			assert.Nil(t, m)
+		})
+	}
+}